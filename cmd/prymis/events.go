@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"net/url"
+	"prymis/engine/dom"
+	"prymis/engine/layout"
+	"strings"
+)
+
+// fetchPage downloads the HTML at target, returning an error page on failure.
+func fetchPage(target string) string {
+	resp, err := http.Get(target)
+	if err != nil {
+		return fmt.Sprintf("<html><body><h1>Error</h1><p>%v</p></body></html>", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+// fetchResource downloads a subresource of the page at base, such as a web
+// font.
+func fetchResource(base, href string) ([]byte, error) {
+	resp, err := http.Get(resolveURL(base, href))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", href, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// resolveURL resolves a link href against the URL of the current page.
+func resolveURL(base, href string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return b.ResolveReference(ref).String()
+}
+
+// fireLoadEvents signals that a freshly parsed document is ready.
+func fireLoadEvents(doc *dom.Node) {
+	doc.DispatchEvent(dom.NewEvent(dom.EventDOMContentLoaded))
+	doc.DispatchEvent(dom.NewEvent(dom.EventLoad))
+}
+
+// dispatchClick hit-tests the page at (x, y), moves focus and dispatches a
+// click event. It returns the href of a link whose default action should be
+// followed, if any.
+func dispatchClick(page *layout.Document, x, y float32) string {
+	box := page.Layout.HitTest(x, y)
+	if box == nil {
+		if active := page.DOM.ActiveElement(); active != nil {
+			active.Blur()
+		}
+		return ""
+	}
+	target := box.StyledNode.Node
+	if target.NodeType != dom.ElementNode && target.Parent != nil {
+		target = target.Parent
+	}
+
+	focus := target
+	for focus != nil && !focus.IsFocusable() {
+		focus = focus.Parent
+	}
+	if focus != nil {
+		focus.Focus()
+	} else if active := page.DOM.ActiveElement(); active != nil {
+		active.Blur()
+	}
+
+	ev := dom.NewEvent(dom.EventClick)
+	ev.X, ev.Y = x, y
+	if !target.DispatchEvent(ev) {
+		return ""
+	}
+	if link, _ := target.Closest("a[href]"); link != nil {
+		return link.GetAttribute("href")
+	}
+	return ""
+}
+
+// dispatchKey sends a keydown to the focused element of the page and applies
+// the default action for text fields. It returns false when there is no page
+// or no element has focus, leaving the key to the browser chrome. A non-empty href is returned
+// when Enter activates a focused link.
+func dispatchKey(page *layout.Document, key, keycode byte) (handled bool, href string) {
+	if page == nil {
+		return false, ""
+	}
+	target := page.DOM.ActiveElement()
+	if target == nil {
+		return false, ""
+	}
+	ev := dom.NewEvent(dom.EventKeyDown)
+	ev.Key = keyName(key, keycode)
+	if !target.DispatchEvent(ev) {
+		return true, ""
+	}
+
+	switch strings.ToLower(target.TagName) {
+	case "input", "textarea":
+		value := target.GetAttribute("value")
+		if key == 8 {
+			if len(value) == 0 {
+				return true, ""
+			}
+			value = value[:len(value)-1]
+		} else if key >= ' ' {
+			value += string(key)
+		} else {
+			return true, ""
+		}
+		target.SetAttribute("value", value)
+		target.DispatchEvent(dom.NewEvent(dom.EventInput))
+	case "a":
+		if key == 13 {
+			return true, target.GetAttribute("href")
+		}
+	}
+	// Outside text fields the keys that scroll keep doing so.
+	return !isScrollKey(key, keycode), ""
+}
+
+// keyName maps the decoded X11 key, or its keycode for keys without a
+// character, to a DOM key value.
+func keyName(key, keycode byte) string {
+	if name, ok := keyNames[keycode]; ok && key == 0 {
+		return name
+	}
+	switch key {
+	case 8:
+		return "Backspace"
+	case 13:
+		return "Enter"
+	case 0:
+		return "Unidentified"
+	}
+	return string(key)
+}
+
+// X11 keycodes of the keys that scroll the page, in the evdev keymap.
+const (
+	keyHome     = 110
+	keyUp       = 111
+	keyPageUp   = 112
+	keyLeft     = 113
+	keyRight    = 114
+	keyEnd      = 115
+	keyDown     = 116
+	keyPageDown = 117
+)
+
+var keyNames = map[byte]string{
+	keyHome: "Home", keyUp: "ArrowUp", keyPageUp: "PageUp", keyLeft: "ArrowLeft",
+	keyRight: "ArrowRight", keyEnd: "End", keyDown: "ArrowDown", keyPageDown: "PageDown",
+}
+
+// lineScroll is how far an arrow key or a notch of the mouse wheel scrolls.
+const lineScroll = 40
+
+func isScrollKey(key, keycode byte) bool {
+	_, ok := keyNames[keycode]
+	return ok && key == 0 || key == ' '
+}
+
+// scrollForKey scrolls the page shown in viewport for a key that scrolls and
+// reports whether it was one. Space types into the address bar instead while
+// a URL is being typed. A page step keeps some of the previous view in sight.
+func scrollForKey(page *layout.Document, key, keycode byte, typing bool, viewport image.Rectangle) bool {
+	if page == nil || !isScrollKey(key, keycode) || key == ' ' && typing {
+		return false
+	}
+	step := float32(viewport.Dy()) * 7 / 8
+	x, _ := page.ScrollPosition()
+	switch {
+	case key == ' ', keycode == keyPageDown:
+		page.ScrollBy(0, step)
+	case keycode == keyPageUp:
+		page.ScrollBy(0, -step)
+	case keycode == keyUp:
+		page.ScrollBy(0, -lineScroll)
+	case keycode == keyDown:
+		page.ScrollBy(0, lineScroll)
+	case keycode == keyLeft:
+		page.ScrollBy(-lineScroll, 0)
+	case keycode == keyRight:
+		page.ScrollBy(lineScroll, 0)
+	case keycode == keyHome:
+		page.ScrollTo(x, 0)
+	case keycode == keyEnd:
+		_, h := page.Layout.ScrollSize()
+		page.ScrollTo(x, h)
+	}
+	return true
+}
+
+// scrollForWheel scrolls for X11 pointer buttons 4 to 7, the mouse wheel
+// turned up or down or tilted left or right with the pointer at (x, y) in the
+// document, and reports whether anything moved. The innermost scrollable box
+// under the pointer scrolls, or else the page.
+func scrollForWheel(page *layout.Document, button int, x, y float32) bool {
+	switch button {
+	case 4:
+		return page.ScrollAt(x, y, 0, -lineScroll)
+	case 5:
+		return page.ScrollAt(x, y, 0, lineScroll)
+	case 6:
+		return page.ScrollAt(x, y, -lineScroll, 0)
+	case 7:
+		return page.ScrollAt(x, y, lineScroll, 0)
+	}
+	return false
+}
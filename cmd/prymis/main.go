@@ -1,10 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
-	"io"
-	"net/http"
+	"image/draw"
+	"image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"prymis/engine/dom"
 	"prymis/engine/gui"
 	"prymis/engine/layout"
 	"prymis/engine/parser"
@@ -14,6 +19,20 @@ import (
 )
 
 func main() {
+	headless := flag.String("headless", "", "render the page at `url` (or file, or .pdl display list) to a PNG instead of opening a window")
+	out := flag.String("o", "page.png", "output `file` of -headless; a .pdl file receives the display list instead")
+	width := flag.Int("width", 800, "viewport width of -headless")
+	height := flag.Int("height", 600, "viewport height of -headless")
+	stylesheet := flag.String("css", "", "author style sheet `file` of -headless")
+	flag.Parse()
+	if *headless != "" {
+		if err := runHeadless(*headless, *stylesheet, *out, *width, *height); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fmt.Println("Prymis Browser - Launching GUI...")
 
 	// 1. Initialize X11 Window
@@ -37,76 +56,239 @@ func main() {
 
 	fmt.Println("Prymis is ready! Interface is inside the window.")
 
+	// The page is parsed once per navigation; DOM changes after that are
+	// applied incrementally by page.Update.
+	var page *layout.Document
+	fetch := func(href string) ([]byte, error) {
+		return fetchResource(currentURL, href)
+	}
+
 	// 3. Main Loop
+	frame := image.Rect(0, 0, 800, 600)
+	// fragment is scrolled to once a newly loaded page has been laid out.
+	fragment := ""
 	needsRender := true
+	// screen repaints only what changed between frames; exposed asks for
+	// the whole window to be sent again all the same.
+	var screen render.Screen
+	exposed := true
 	for {
 		// handle X11 events
+		navigateTo := ""
 		if ev := win.PollEvent(); ev != nil {
 			if ev.Type == gui.KeyPress {
-				if ev.Key == 13 { // Enter
-					currentURL = typingBuffer
-					typingBuffer = ""
-					fmt.Printf("Navigating to: %s\n", currentURL)
-					if strings.HasPrefix(currentURL, "http") {
-						resp, err := http.Get(currentURL)
-						if err == nil {
-							body, _ := io.ReadAll(resp.Body)
-							html = string(body)
-							resp.Body.Close()
-						} else {
-							html = fmt.Sprintf("<html><body><h1>Error</h1><p>%v</p></body></html>", err)
+				if handled, href := dispatchKey(page, ev.Key, ev.Keycode); handled {
+					if href != "" {
+						navigateTo = resolveURL(currentURL, href)
+					}
+				} else if !scrollForKey(page, ev.Key, ev.Keycode, typingBuffer != "", render.ContentViewport(frame)) {
+					switch ev.Key {
+					case 13: // Enter
+						navigateTo = typingBuffer
+						typingBuffer = ""
+					case 8: // Backspace
+						if len(typingBuffer) > 0 {
+							typingBuffer = typingBuffer[:len(typingBuffer)-1]
 						}
+					case 0:
+					default:
+						typingBuffer += string(ev.Key)
 					}
-				} else if ev.Key == 8 { // Backspace
-					if len(typingBuffer) > 0 {
-						typingBuffer = typingBuffer[:len(typingBuffer)-1]
+				}
+				needsRender = true
+			} else if ev.Type == gui.ButtonPress && ev.Button == 1 {
+				if page != nil {
+					content := render.ContentViewport(frame)
+					if image.Pt(ev.X, ev.Y).In(content) {
+						sx, sy := page.Layout.ScrollOffset()
+						x, y := float32(ev.X-content.Min.X)+sx, float32(ev.Y-content.Min.Y)+sy
+						if href := dispatchClick(page, x, y); href != "" {
+							navigateTo = resolveURL(currentURL, href)
+						}
+					} else if active := page.DOM.ActiveElement(); active != nil {
+						// Clicking the chrome hands the keyboard back to the address bar.
+						active.Blur()
 					}
-				} else if ev.Key != 0 {
-					typingBuffer += string(ev.Key)
 				}
 				needsRender = true
+			} else if ev.Type == gui.ButtonPress && ev.Button >= 4 && ev.Button <= 7 {
+				content := render.ContentViewport(frame)
+				if page != nil && image.Pt(ev.X, ev.Y).In(content) {
+					sx, sy := page.Layout.ScrollOffset()
+					x, y := float32(ev.X-content.Min.X)+sx, float32(ev.Y-content.Min.Y)+sy
+					if scrollForWheel(page, ev.Button, x, y) {
+						needsRender = true
+					}
+				}
 			} else if ev.Type == gui.Expose {
+				needsRender, exposed = true, true
+			} else if ev.Type == gui.ConfigureNotify && (ev.Width != frame.Dx() || ev.Height != frame.Dy()) {
+				frame = image.Rect(0, 0, ev.Width, ev.Height)
 				needsRender = true
 			}
 		}
 
+		if navigateTo != "" {
+			target, frag, hasFragment := strings.Cut(navigateTo, "#")
+			current, _, _ := strings.Cut(currentURL, "#")
+			if page != nil && hasFragment && target == current {
+				// Fragment navigation within the page only scrolls.
+				currentURL = navigateTo
+				page.ScrollToFragment(frag)
+			} else {
+				currentURL = navigateTo
+				fmt.Printf("Navigating to: %s\n", currentURL)
+				if strings.HasPrefix(currentURL, "http") {
+					html = fetchPage(currentURL)
+					page = nil
+					fragment = frag
+				}
+			}
+		}
+
+		dom.DeliverMutationRecords()
+
 		if needsRender {
 			// Safety: Recover from parser/layout panics
-			canvas := func() *image.RGBA {
+			canvas, damage := func() (*image.RGBA, image.Rectangle) {
 				defer func() {
 					if r := recover(); r != nil {
 						fmt.Printf("⚠️ Render Panic: %v\n", r)
+						// The canvas may be half painted.
+						screen = render.Screen{}
 					}
 				}()
 
-				p := parser.NewHTMLParser(html)
-				domTree := p.Parse()
-				cp := parser.NewCSSParser(css)
-				rules := cp.Parse()
-				styleTree := layout.NewStyledNode(domTree, rules)
-				layoutTree := layout.NewLayoutTree(styleTree)
-				viewport := layout.Dimensions{
-					Content: layout.Rect{X: 0, Y: 100, Width: 800, Height: 0},
+				if page == nil {
+					p := parser.NewHTMLParser(html)
+					doc := dom.NewDocument(p.Parse())
+					cp := parser.NewCSSParser(css)
+					page = layout.NewDocument(doc, cp.Parse(), currentURL, fetch)
+					fireLoadEvents(doc)
+				}
+				content := render.ContentViewport(frame)
+				page.Update(layout.Dimensions{
+					Content: layout.Rect{Width: float32(content.Dx()), Height: float32(content.Dy())},
+				})
+				if fragment != "" {
+					page.ScrollToFragment(fragment)
+					fragment = ""
 				}
-				layoutTree.Layout(viewport)
 
 				// Use typingBuffer if active, otherwise currentURL
 				displayText := currentURL
 				if typingBuffer != "" {
 					displayText = typingBuffer + "_"
 				}
-				return render.Paint(layoutTree, image.Rect(0, 0, 800, 600), displayText)
+				return screen.Paint(page.Layout, frame, displayText)
 			}()
 
 			if canvas != nil {
-				win.Draw(canvas)
+				if exposed {
+					win.Draw(canvas)
+				} else {
+					win.DrawRect(canvas, damage)
+				}
 			}
-			needsRender = false
+			needsRender, exposed = false, false
 		}
 		time.Sleep(10 * time.Millisecond)
 	}
 }
 
-func runHeadless() {
-	// ... (Previous file-based logic)
+// runHeadless renders the page at target, a URL or a file, styled by the
+// style sheet in the file css if given, without the browser chrome into a PNG
+// of width by height pixels at out. If out names a .pdl file, the page's
+// display list is written there instead, for replayList to paint later.
+func runHeadless(target, css, out string, width, height int) error {
+	if strings.HasSuffix(target, ".pdl") {
+		return replayList(target, out, width, height)
+	}
+	var html string
+	var fetch layout.Fetcher
+	if strings.HasPrefix(target, "http") {
+		html = fetchPage(target)
+		fetch = func(href string) ([]byte, error) {
+			return fetchResource(target, href)
+		}
+	} else {
+		b, err := os.ReadFile(target)
+		if err != nil {
+			return err
+		}
+		html = string(b)
+		// Subresources of a file are files next to it.
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			return err
+		}
+		target = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+		fetch = func(href string) ([]byte, error) {
+			u, err := url.Parse(href)
+			if err != nil || u.Scheme != "file" {
+				return nil, fmt.Errorf("fetch %s: not a file", href)
+			}
+			return os.ReadFile(filepath.FromSlash(u.Path))
+		}
+	}
+
+	var rules []parser.StyleRule
+	if css != "" {
+		b, err := os.ReadFile(css)
+		if err != nil {
+			return err
+		}
+		rules = parser.NewCSSParser(string(b)).Parse()
+	}
+
+	doc := dom.NewDocument(parser.NewHTMLParser(html).Parse())
+	page := layout.NewDocument(doc, rules, target, fetch)
+	fireLoadEvents(doc)
+	dom.DeliverMutationRecords()
+	page.Update(layout.Dimensions{
+		Content: layout.Rect{Width: float32(width), Height: float32(height)},
+	})
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(out, ".pdl") {
+		_, err = render.BuildDisplayList(page.Layout).WriteTo(f)
+	} else {
+		err = png.Encode(f, render.RenderPage(page.Layout, width, height))
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replayList paints the display list in the file in, as runHeadless writes
+// it, into a PNG of width by height pixels at out.
+func replayList(in, out string, width, height int) error {
+	src, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	var list render.DisplayList
+	_, err = list.ReadFrom(src)
+	src.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	list.Replay(render.NewRaster(canvas))
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, canvas); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
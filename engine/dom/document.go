@@ -0,0 +1,120 @@
+package dom
+
+// NewDocument returns a document node whose document element is root.
+func NewDocument(root *Node) *Node {
+	doc := &Node{NodeType: DocumentNode, ids: make(map[string][]*Node)}
+	if root != nil {
+		doc.AppendChild(root)
+	}
+	return doc
+}
+
+// OwnerDocument returns the document n is connected to, or nil.
+func (n *Node) OwnerDocument() *Node {
+	for ; n != nil; n = n.Parent {
+		if n.NodeType == DocumentNode {
+			return n
+		}
+	}
+	return nil
+}
+
+// DocumentElement returns the root element of a document.
+func (n *Node) DocumentElement() *Node {
+	for _, child := range n.Children {
+		if child.NodeType == ElementNode {
+			return child
+		}
+	}
+	return nil
+}
+
+func (doc *Node) indexSubtree(n *Node) {
+	if doc.ids == nil {
+		return
+	}
+	if n.NodeType == ElementNode {
+		if id, ok := n.Attributes["id"]; ok && id != "" {
+			doc.indexID(id, n)
+		}
+	}
+	for _, child := range n.Children {
+		doc.indexSubtree(child)
+	}
+}
+
+func (doc *Node) unindexSubtree(n *Node) {
+	if doc.ids == nil {
+		return
+	}
+	if n.NodeType == ElementNode {
+		if id, ok := n.Attributes["id"]; ok && id != "" {
+			doc.unindexID(id, n)
+		}
+	}
+	for _, child := range n.Children {
+		doc.unindexSubtree(child)
+	}
+}
+
+func (doc *Node) indexID(id string, n *Node) {
+	doc.ids[id] = append(doc.ids[id], n)
+}
+
+func (doc *Node) unindexID(id string, n *Node) {
+	list := doc.ids[id]
+	for i, e := range list {
+		if e == n {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(doc.ids, id)
+	} else {
+		doc.ids[id] = list
+	}
+}
+
+// lookupID returns the first element in tree order with the given id.
+func (doc *Node) lookupID(id string) *Node {
+	list := doc.ids[id]
+	if len(list) == 0 {
+		return nil
+	}
+	first := list[0]
+	for _, e := range list[1:] {
+		if precedes(e, first) {
+			first = e
+		}
+	}
+	return first
+}
+
+// precedes reports whether a comes before b in tree order.
+func precedes(a, b *Node) bool {
+	pathA, pathB := ancestry(a), ancestry(b)
+	for i := 0; i < len(pathA) && i < len(pathB); i++ {
+		if pathA[i] == pathB[i] {
+			continue
+		}
+		if i == 0 {
+			return false
+		}
+		parent := pathA[i-1]
+		return parent.indexOf(pathA[i]) < parent.indexOf(pathB[i])
+	}
+	return len(pathA) < len(pathB)
+}
+
+// ancestry returns the path from the root down to n.
+func ancestry(n *Node) []*Node {
+	var path []*Node
+	for ; n != nil; n = n.Parent {
+		path = append(path, n)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
@@ -5,29 +5,59 @@ type NodeType int
 const (
 	ElementNode NodeType = iota
 	TextNode
+	DocumentNode
 )
 
 type AttrMap map[string]string
 
+const (
+	HTMLNamespace   = "http://www.w3.org/1999/xhtml"
+	SVGNamespace    = "http://www.w3.org/2000/svg"
+	MathMLNamespace = "http://www.w3.org/1998/Math/MathML"
+	XLinkNamespace  = "http://www.w3.org/1999/xlink"
+	XMLNamespace    = "http://www.w3.org/XML/1998/namespace"
+	XMLNSNamespace  = "http://www.w3.org/2000/xmlns/"
+)
+
 type Node struct {
-	Children   []*Node
-	NodeType   NodeType
+	Children []*Node
+	Parent   *Node
+	NodeType NodeType
 	// Element data
 	TagName    string
+	Namespace  string
 	Attributes AttrMap
 	// Text data
-	Text       string
+	Text string
+	// Document data: elements by id, in insertion order, and the element
+	// holding focus.
+	ids     map[string][]*Node
+	focused *Node
+	// Mutation observers registered on this node.
+	observers []registeredObserver
+	// Event listeners registered on this node.
+	listeners []*listenerEntry
 }
 
 func Text(data string) *Node {
 	return &Node{NodeType: TextNode, Text: data}
 }
 
+// Element returns an element in the HTML namespace.
 func Element(name string, attrs AttrMap, children []*Node) *Node {
-	return &Node{
+	return ElementNS(HTMLNamespace, name, attrs, children)
+}
+
+func ElementNS(namespace, name string, attrs AttrMap, children []*Node) *Node {
+	n := &Node{
 		TagName:    name,
+		Namespace:  namespace,
 		Attributes: attrs,
 		Children:   children,
 		NodeType:   ElementNode,
 	}
+	for _, child := range children {
+		child.Parent = n
+	}
+	return n
 }
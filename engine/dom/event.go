@@ -0,0 +1,211 @@
+package dom
+
+import "strings"
+
+type EventPhase int
+
+const (
+	PhaseNone EventPhase = iota
+	PhaseCapturing
+	PhaseAtTarget
+	PhaseBubbling
+)
+
+// Standard event types.
+const (
+	EventClick            = "click"
+	EventInput            = "input"
+	EventKeyDown          = "keydown"
+	EventFocus            = "focus"
+	EventBlur             = "blur"
+	EventLoad             = "load"
+	EventDOMContentLoaded = "DOMContentLoaded"
+)
+
+type Event struct {
+	Type          string
+	Target        *Node
+	CurrentTarget *Node
+	Phase         EventPhase
+	Bubbles       bool
+	Cancelable    bool
+	// Mouse data, in page coordinates.
+	X, Y   float32
+	Button int
+	// Keyboard data: the key value, e.g. "a", "Enter" or "ArrowDown".
+	Key string
+
+	defaultPrevented   bool
+	stopped            bool
+	stoppedImmediately bool
+}
+
+// NewEvent returns an event of the given type with the bubbles and
+// cancelable flags the standard types are dispatched with.
+func NewEvent(eventType string) *Event {
+	e := &Event{Type: eventType}
+	switch eventType {
+	case EventClick, EventKeyDown:
+		e.Bubbles, e.Cancelable = true, true
+	case EventInput, EventDOMContentLoaded:
+		e.Bubbles = true
+	}
+	return e
+}
+
+func (e *Event) StopPropagation() {
+	e.stopped = true
+}
+
+// StopImmediatePropagation also skips the remaining listeners on the
+// current target.
+func (e *Event) StopImmediatePropagation() {
+	e.stopped = true
+	e.stoppedImmediately = true
+}
+
+func (e *Event) PreventDefault() {
+	if e.Cancelable {
+		e.defaultPrevented = true
+	}
+}
+
+func (e *Event) DefaultPrevented() bool {
+	return e.defaultPrevented
+}
+
+// EventListener wraps a handler so it can be identified for removal.
+type EventListener struct {
+	handle func(*Event)
+}
+
+func NewEventListener(handle func(*Event)) *EventListener {
+	return &EventListener{handle: handle}
+}
+
+type listenerEntry struct {
+	eventType string
+	listener  *EventListener
+	capture   bool
+	removed   bool
+}
+
+// AddEventListener registers l for eventType. Registering the same listener
+// twice with the same capture flag has no effect.
+func (n *Node) AddEventListener(eventType string, l *EventListener, capture bool) {
+	if l == nil {
+		return
+	}
+	for _, e := range n.listeners {
+		if e.eventType == eventType && e.listener == l && e.capture == capture {
+			return
+		}
+	}
+	n.listeners = append(n.listeners, &listenerEntry{eventType: eventType, listener: l, capture: capture})
+}
+
+func (n *Node) RemoveEventListener(eventType string, l *EventListener, capture bool) {
+	for i, e := range n.listeners {
+		if e.eventType == eventType && e.listener == l && e.capture == capture {
+			e.removed = true
+			n.listeners = append(n.listeners[:i], n.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// DispatchEvent runs the capture, target and bubble phases for e with n as
+// the target. It returns false if a listener called PreventDefault.
+func (n *Node) DispatchEvent(e *Event) bool {
+	e.Target = n
+	e.stopped, e.stoppedImmediately, e.defaultPrevented = false, false, false
+
+	var path []*Node
+	for p := n.Parent; p != nil; p = p.Parent {
+		path = append(path, p)
+	}
+
+	e.Phase = PhaseCapturing
+	for i := len(path) - 1; i >= 0 && !e.stopped; i-- {
+		path[i].invokeListeners(e, true)
+	}
+	if !e.stopped {
+		e.Phase = PhaseAtTarget
+		n.invokeListeners(e, true)
+		if !e.stopped {
+			n.invokeListeners(e, false)
+		}
+	}
+	if e.Bubbles {
+		e.Phase = PhaseBubbling
+		for i := 0; i < len(path) && !e.stopped; i++ {
+			path[i].invokeListeners(e, false)
+		}
+	}
+
+	e.Phase = PhaseNone
+	e.CurrentTarget = nil
+	return !e.defaultPrevented
+}
+
+func (n *Node) invokeListeners(e *Event, capture bool) {
+	// Listeners added during dispatch do not run; removed ones are skipped.
+	listeners := append([]*listenerEntry(nil), n.listeners...)
+	e.CurrentTarget = n
+	for _, l := range listeners {
+		if l.removed || l.eventType != e.Type || l.capture != capture {
+			continue
+		}
+		l.listener.handle(e)
+		if e.stoppedImmediately {
+			return
+		}
+	}
+}
+
+// IsFocusable reports whether an element can receive keyboard focus.
+func (n *Node) IsFocusable() bool {
+	if n.NodeType != ElementNode || n.HasAttribute("disabled") {
+		return false
+	}
+	switch strings.ToLower(n.TagName) {
+	case "input", "textarea", "select", "button":
+		return true
+	case "a", "area":
+		return n.HasAttribute("href")
+	}
+	return n.HasAttribute("tabindex")
+}
+
+// ActiveElement returns the focused element of n's document.
+func (n *Node) ActiveElement() *Node {
+	doc := n.OwnerDocument()
+	if doc == nil || doc.focused == nil || doc.focused.OwnerDocument() != doc {
+		return nil
+	}
+	return doc.focused
+}
+
+// Focus moves document focus to n, firing blur on the previously focused
+// element and focus on n.
+func (n *Node) Focus() {
+	doc := n.OwnerDocument()
+	if doc == nil || !n.IsFocusable() || doc.focused == n {
+		return
+	}
+	if old := n.ActiveElement(); old != nil {
+		doc.focused = nil
+		old.DispatchEvent(NewEvent(EventBlur))
+	}
+	doc.focused = n
+	n.DispatchEvent(NewEvent(EventFocus))
+}
+
+func (n *Node) Blur() {
+	doc := n.OwnerDocument()
+	if doc == nil || doc.focused != n {
+		return
+	}
+	doc.focused = nil
+	n.DispatchEvent(NewEvent(EventBlur))
+}
@@ -0,0 +1,211 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDispatchEvent(t *testing.T) {
+	type tree struct{ html, body, target *Node }
+	// listen adds a listener to n that logs name and then runs do.
+	listen := func(log *[]string, n *Node, name string, capture bool, do func(*Event)) *EventListener {
+		l := NewEventListener(func(e *Event) {
+			*log = append(*log, name)
+			if do != nil {
+				do(e)
+			}
+		})
+		n.AddEventListener(EventClick, l, capture)
+		return l
+	}
+	// all puts a capturing and a bubbling listener on every node.
+	all := func(log *[]string, tr tree) {
+		for _, n := range []*Node{tr.html, tr.body, tr.target} {
+			listen(log, n, n.TagName+" capture", true, nil)
+			listen(log, n, n.TagName+" bubble", false, nil)
+		}
+	}
+	tests := []struct {
+		name    string
+		setup   func(log *[]string, tr tree)
+		bubbles bool
+		want    string
+		// prevented is whether DispatchEvent is to report the default
+		// action as prevented.
+		prevented bool
+	}{
+		{
+			name:    "capture, target, bubble",
+			setup:   all,
+			bubbles: true,
+			want:    "html capture, body capture, p capture, p bubble, body bubble, html bubble",
+		},
+		{
+			name:  "no bubbling",
+			setup: all,
+			want:  "html capture, body capture, p capture, p bubble",
+		},
+		{
+			name: "stop propagation",
+			setup: func(log *[]string, tr tree) {
+				listen(log, tr.body, "body first", true, func(e *Event) { e.StopPropagation() })
+				all(log, tr)
+			},
+			bubbles: true,
+			want:    "html capture, body first, body capture",
+		},
+		{
+			name: "stop immediate propagation",
+			setup: func(log *[]string, tr tree) {
+				listen(log, tr.body, "body first", true, func(e *Event) { e.StopImmediatePropagation() })
+				all(log, tr)
+			},
+			bubbles: true,
+			want:    "html capture, body first",
+		},
+		{
+			name: "stop while bubbling",
+			setup: func(log *[]string, tr tree) {
+				listen(log, tr.body, "body first", false, func(e *Event) { e.StopPropagation() })
+				all(log, tr)
+			},
+			bubbles: true,
+			want:    "html capture, body capture, p capture, p bubble, body first, body bubble",
+		},
+		{
+			name: "prevent default",
+			setup: func(log *[]string, tr tree) {
+				listen(log, tr.target, "p", false, func(e *Event) { e.PreventDefault() })
+				listen(log, tr.html, "html", false, func(e *Event) {
+					if !e.DefaultPrevented() {
+						*log = append(*log, "not prevented")
+					}
+				})
+			},
+			bubbles:   true,
+			want:      "p, html",
+			prevented: true,
+		},
+		{
+			name: "removed during dispatch",
+			setup: func(log *[]string, tr tree) {
+				var later *EventListener
+				listen(log, tr.target, "first", false, func(e *Event) {
+					tr.target.RemoveEventListener(EventClick, later, false)
+					listen(log, tr.target, "added", false, nil)
+				})
+				later = listen(log, tr.target, "later", false, nil)
+			},
+			want: "first",
+		},
+		{
+			name: "registered twice",
+			setup: func(log *[]string, tr tree) {
+				l := listen(log, tr.target, "once", false, nil)
+				tr.target.AddEventListener(EventClick, l, false)
+				tr.target.AddEventListener(EventClick, l, true)
+			},
+			want: "once, once",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := tree{target: Element("p", nil, nil)}
+			tr.body = Element("body", nil, []*Node{tr.target})
+			tr.html = Element("html", nil, []*Node{tr.body})
+			var log []string
+			tt.setup(&log, tr)
+			e := NewEvent(EventClick)
+			e.Bubbles = tt.bubbles
+			if ok := tr.target.DispatchEvent(e); ok == tt.prevented {
+				t.Errorf("DispatchEvent = %v", ok)
+			}
+			if got := strings.Join(log, ", "); got != tt.want {
+				t.Errorf("listeners ran as\n%s\nwant\n%s", got, tt.want)
+			}
+			if e.Target != tr.target || e.CurrentTarget != nil || e.Phase != PhaseNone {
+				t.Errorf("after dispatch: target %v, current target %v, phase %v", e.Target, e.CurrentTarget, e.Phase)
+			}
+		})
+	}
+}
+
+func TestEventPhases(t *testing.T) {
+	target := Element("p", nil, nil)
+	body := Element("body", nil, []*Node{target})
+	var got []EventPhase
+	var current []*Node
+	record := NewEventListener(func(e *Event) {
+		got = append(got, e.Phase)
+		current = append(current, e.CurrentTarget)
+	})
+	body.AddEventListener(EventClick, record, true)
+	target.AddEventListener(EventClick, record, false)
+	body.AddEventListener(EventClick, record, false)
+	target.DispatchEvent(NewEvent(EventClick))
+	want := []EventPhase{PhaseCapturing, PhaseAtTarget, PhaseBubbling}
+	wantCurrent := []*Node{body, target, body}
+	if len(got) != len(want) {
+		t.Fatalf("phases %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] || current[i] != wantCurrent[i] {
+			t.Errorf("call %d: phase %v on %s", i, got[i], current[i].TagName)
+		}
+	}
+	// Events that cannot be canceled ignore PreventDefault.
+	input := NewEvent(EventInput)
+	target.AddEventListener(EventInput, NewEventListener(func(e *Event) { e.PreventDefault() }), false)
+	if !target.DispatchEvent(input) || input.DefaultPrevented() {
+		t.Error("a non-cancelable event was canceled")
+	}
+}
+
+func TestFocus(t *testing.T) {
+	a := Element("input", nil, nil)
+	b := Element("a", AttrMap{"href": "#"}, nil)
+	plain := Element("div", nil, nil)
+	link := Element("a", nil, nil)
+	disabled := Element("button", AttrMap{"disabled": ""}, nil)
+	body := Element("body", nil, []*Node{a, b, plain, link, disabled})
+	NewDocument(Element("html", nil, []*Node{body}))
+
+	var log []string
+	for _, n := range []*Node{a, b} {
+		for _, typ := range []string{EventFocus, EventBlur} {
+			n.AddEventListener(typ, NewEventListener(func(e *Event) {
+				active := "none"
+				if f := n.ActiveElement(); f != nil {
+					active = f.TagName
+				}
+				log = append(log, typ+" "+n.TagName+" (active "+active+")")
+			}), false)
+		}
+	}
+	// Focus and blur do not bubble.
+	body.AddEventListener(EventFocus, NewEventListener(func(*Event) { log = append(log, "bubbled") }), false)
+
+	a.Focus()
+	a.Focus()
+	b.Focus()
+	for _, n := range []*Node{plain, link, disabled} {
+		n.Focus()
+	}
+	if b.ActiveElement() != b {
+		t.Errorf("active element is %v, want the link", b.ActiveElement())
+	}
+	b.Blur()
+	a.Blur()
+	want := "focus input (active input), blur input (active none), focus a (active a), blur a (active none)"
+	if got := strings.Join(log, ", "); got != want {
+		t.Errorf("focus events ran as\n%s\nwant\n%s", got, want)
+	}
+	if a.ActiveElement() != nil {
+		t.Errorf("active element after blur is %v", a.ActiveElement())
+	}
+	// Detached elements cannot take focus.
+	Element("input", nil, nil).Focus()
+	if a.ActiveElement() != nil {
+		t.Error("detached element took focus")
+	}
+}
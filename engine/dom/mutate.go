@@ -0,0 +1,350 @@
+package dom
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrHierarchyRequest = errors.New("dom: node cannot be inserted at this point in the hierarchy")
+	ErrNotFound         = errors.New("dom: node is not a child of this node")
+	ErrInvalidCharacter = errors.New("dom: invalid character in name")
+)
+
+func (n *Node) FirstChild() *Node {
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n.Children[0]
+}
+
+func (n *Node) LastChild() *Node {
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n.Children[len(n.Children)-1]
+}
+
+func (n *Node) NextSibling() *Node {
+	if n.Parent == nil {
+		return nil
+	}
+	i := n.Parent.indexOf(n)
+	if i < 0 || i+1 >= len(n.Parent.Children) {
+		return nil
+	}
+	return n.Parent.Children[i+1]
+}
+
+func (n *Node) PreviousSibling() *Node {
+	if n.Parent == nil {
+		return nil
+	}
+	i := n.Parent.indexOf(n)
+	if i <= 0 {
+		return nil
+	}
+	return n.Parent.Children[i-1]
+}
+
+// Contains reports whether other is n or one of its descendants.
+func (n *Node) Contains(other *Node) bool {
+	for ; other != nil; other = other.Parent {
+		if other == n {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Node) indexOf(child *Node) int {
+	for i, c := range n.Children {
+		if c == child {
+			return i
+		}
+	}
+	return -1
+}
+
+func (n *Node) AppendChild(child *Node) error {
+	return n.InsertBefore(child, nil)
+}
+
+// InsertBefore inserts child into n before ref, or at the end when ref is
+// nil. A child that already has a parent is moved.
+func (n *Node) InsertBefore(child, ref *Node) error {
+	if err := n.ensurePreInsertionValidity(child, ref, nil); err != nil {
+		return err
+	}
+	if ref == child {
+		ref = child.NextSibling()
+	}
+	if child.Parent != nil {
+		child.Parent.removeAndNotify(child)
+	}
+	i := len(n.Children)
+	if ref != nil {
+		i = n.indexOf(ref)
+	}
+	n.insertAt(i, child)
+	queueMutationRecord(MutationRecord{
+		Type:            MutationChildList,
+		Target:          n,
+		AddedNodes:      []*Node{child},
+		PreviousSibling: child.PreviousSibling(),
+		NextSibling:     ref,
+	})
+	return nil
+}
+
+func (n *Node) RemoveChild(child *Node) error {
+	if child == nil || n.indexOf(child) < 0 {
+		return ErrNotFound
+	}
+	n.removeAndNotify(child)
+	return nil
+}
+
+func (n *Node) removeAndNotify(child *Node) {
+	prev, next := child.PreviousSibling(), child.NextSibling()
+	n.removeAt(n.indexOf(child))
+	queueMutationRecord(MutationRecord{
+		Type:            MutationChildList,
+		Target:          n,
+		RemovedNodes:    []*Node{child},
+		PreviousSibling: prev,
+		NextSibling:     next,
+	})
+}
+
+// ReplaceChild puts newChild in place of oldChild, which is detached.
+func (n *Node) ReplaceChild(newChild, oldChild *Node) error {
+	if oldChild == nil || oldChild.Parent != n {
+		return ErrNotFound
+	}
+	if err := n.ensurePreInsertionValidity(newChild, nil, oldChild); err != nil {
+		return err
+	}
+	if newChild == oldChild {
+		return nil
+	}
+	ref := oldChild.NextSibling()
+	if ref == newChild {
+		ref = newChild.NextSibling()
+	}
+	if newChild.Parent != nil {
+		newChild.Parent.removeAndNotify(newChild)
+	}
+	prev := oldChild.PreviousSibling()
+	n.removeAt(n.indexOf(oldChild))
+	i := len(n.Children)
+	if ref != nil {
+		i = n.indexOf(ref)
+	}
+	n.insertAt(i, newChild)
+	queueMutationRecord(MutationRecord{
+		Type:            MutationChildList,
+		Target:          n,
+		AddedNodes:      []*Node{newChild},
+		RemovedNodes:    []*Node{oldChild},
+		PreviousSibling: prev,
+		NextSibling:     ref,
+	})
+	return nil
+}
+
+// ensurePreInsertionValidity applies the DOM hierarchy rules for inserting
+// child into n before ref. replacing is the child being replaced, if any.
+func (n *Node) ensurePreInsertionValidity(child, ref, replacing *Node) error {
+	if child == nil || child.NodeType == DocumentNode {
+		return ErrHierarchyRequest
+	}
+	if n.NodeType != ElementNode && n.NodeType != DocumentNode {
+		return ErrHierarchyRequest
+	}
+	if child.Contains(n) {
+		return ErrHierarchyRequest
+	}
+	if ref != nil && ref.Parent != n {
+		return ErrNotFound
+	}
+	if n.NodeType == DocumentNode {
+		if child.NodeType == TextNode {
+			return ErrHierarchyRequest
+		}
+		if e := n.DocumentElement(); e != nil && e != child && e != replacing {
+			return ErrHierarchyRequest
+		}
+	}
+	return nil
+}
+
+func (n *Node) insertAt(i int, child *Node) {
+	n.Children = append(n.Children, nil)
+	copy(n.Children[i+1:], n.Children[i:])
+	n.Children[i] = child
+	child.Parent = n
+	if doc := n.OwnerDocument(); doc != nil {
+		doc.indexSubtree(child)
+	}
+}
+
+func (n *Node) removeAt(i int) {
+	child := n.Children[i]
+	if doc := n.OwnerDocument(); doc != nil {
+		doc.unindexSubtree(child)
+	}
+	copy(n.Children[i:], n.Children[i+1:])
+	n.Children[len(n.Children)-1] = nil
+	n.Children = n.Children[:len(n.Children)-1]
+	child.Parent = nil
+}
+
+// CloneNode returns a detached copy of n. Descendants are copied only when
+// deep is set.
+func (n *Node) CloneNode(deep bool) *Node {
+	clone := &Node{
+		NodeType:  n.NodeType,
+		TagName:   n.TagName,
+		Namespace: n.Namespace,
+		Text:      n.Text,
+	}
+	if n.Attributes != nil {
+		clone.Attributes = make(AttrMap, len(n.Attributes))
+		for k, v := range n.Attributes {
+			clone.Attributes[k] = v
+		}
+	}
+	if n.NodeType == DocumentNode {
+		clone.ids = make(map[string][]*Node)
+	}
+	if deep {
+		for _, child := range n.Children {
+			clone.insertAt(len(clone.Children), child.CloneNode(true))
+		}
+	}
+	return clone
+}
+
+func (n *Node) GetAttribute(name string) string {
+	return n.Attributes[name]
+}
+
+func (n *Node) HasAttribute(name string) bool {
+	_, ok := n.Attributes[name]
+	return ok
+}
+
+func (n *Node) SetAttribute(name, value string) error {
+	if n.NodeType != ElementNode {
+		return ErrHierarchyRequest
+	}
+	if !isValidAttributeName(name) {
+		return ErrInvalidCharacter
+	}
+	if n.Attributes == nil {
+		n.Attributes = make(AttrMap)
+	}
+	if name == "id" {
+		n.reindexID(value, true)
+	}
+	old := n.Attributes[name]
+	n.Attributes[name] = value
+	queueMutationRecord(MutationRecord{
+		Type:          MutationAttributes,
+		Target:        n,
+		AttributeName: name,
+		OldValue:      old,
+	})
+	return nil
+}
+
+func (n *Node) RemoveAttribute(name string) {
+	old, ok := n.Attributes[name]
+	if !ok {
+		return
+	}
+	if name == "id" {
+		n.reindexID("", false)
+	}
+	delete(n.Attributes, name)
+	queueMutationRecord(MutationRecord{
+		Type:          MutationAttributes,
+		Target:        n,
+		AttributeName: name,
+		OldValue:      old,
+	})
+}
+
+// reindexID moves n in its document's id index ahead of an id change.
+func (n *Node) reindexID(id string, set bool) {
+	doc := n.OwnerDocument()
+	if doc == nil || doc.ids == nil {
+		return
+	}
+	if old, ok := n.Attributes["id"]; ok && old != "" {
+		doc.unindexID(old, n)
+	}
+	if set && id != "" {
+		doc.indexID(id, n)
+	}
+}
+
+// TextContent returns the concatenated data of all descendant text nodes.
+func (n *Node) TextContent() string {
+	if n.NodeType == TextNode {
+		return n.Text
+	}
+	var sb strings.Builder
+	n.collectText(&sb)
+	return sb.String()
+}
+
+func (n *Node) collectText(sb *strings.Builder) {
+	for _, child := range n.Children {
+		if child.NodeType == TextNode {
+			sb.WriteString(child.Text)
+		} else {
+			child.collectText(sb)
+		}
+	}
+}
+
+// SetTextContent replaces all children of an element with a single text
+// node holding data, or sets the data of a text node.
+func (n *Node) SetTextContent(data string) {
+	if n.NodeType == TextNode {
+		old := n.Text
+		n.Text = data
+		queueMutationRecord(MutationRecord{Type: MutationCharacterData, Target: n, OldValue: old})
+		return
+	}
+	if n.NodeType == DocumentNode {
+		return
+	}
+	removed := append([]*Node(nil), n.Children...)
+	for len(n.Children) > 0 {
+		n.removeAt(len(n.Children) - 1)
+	}
+	var added []*Node
+	if data != "" {
+		added = []*Node{Text(data)}
+		n.insertAt(0, added[0])
+	}
+	if len(removed) > 0 || len(added) > 0 {
+		queueMutationRecord(MutationRecord{Type: MutationChildList, Target: n, AddedNodes: added, RemovedNodes: removed})
+	}
+}
+
+func isValidAttributeName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case ' ', '\t', '\n', '\r', '\f', '"', '\'', '>', '/', '=', 0:
+			return false
+		}
+	}
+	return true
+}
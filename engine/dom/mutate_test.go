@@ -0,0 +1,291 @@
+package dom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// childNames lists the tag names, or the data of text nodes, of n's
+// children, checking that each of them points back at n.
+func childNames(t *testing.T, n *Node) string {
+	t.Helper()
+	var names []string
+	for _, c := range n.Children {
+		if c.Parent != n {
+			t.Errorf("%s has parent %v, want %s", c.TagName, c.Parent, n.TagName)
+		}
+		if c.NodeType == TextNode {
+			names = append(names, c.Text)
+		} else {
+			names = append(names, c.TagName)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+func TestInsertBefore(t *testing.T) {
+	a, b, c := Element("a", nil, nil), Element("b", nil, nil), Element("c", nil, nil)
+	p := Element("p", nil, []*Node{a, b, c})
+	d := Element("d", nil, nil)
+	if err := p.InsertBefore(d, b); err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(t, p); got != "a d b c" {
+		t.Errorf("after inserting d before b: %s", got)
+	}
+	// A node already in the parent moves rather than appearing twice.
+	if err := p.InsertBefore(c, a); err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(t, p); got != "c a d b" {
+		t.Errorf("after moving c before a: %s", got)
+	}
+	if err := p.AppendChild(a); err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(t, p); got != "c d b a" {
+		t.Errorf("after appending a: %s", got)
+	}
+	// Inserting a node before itself leaves it where it is.
+	if err := p.InsertBefore(d, d); err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(t, p); got != "c d b a" {
+		t.Errorf("after inserting d before itself: %s", got)
+	}
+	// Moving between parents detaches from the old one.
+	q := Element("q", nil, nil)
+	if err := q.AppendChild(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(t, p); got != "c d a" {
+		t.Errorf("after moving b away: %s", got)
+	}
+	if got := childNames(t, q); got != "b" {
+		t.Errorf("new parent has %s", got)
+	}
+	if c.NextSibling() != d || d.PreviousSibling() != c || a.NextSibling() != nil || c.PreviousSibling() != nil {
+		t.Error("siblings do not follow the children order")
+	}
+	if p.FirstChild() != c || p.LastChild() != a || q.FirstChild() != q.LastChild() {
+		t.Error("first and last children are wrong")
+	}
+}
+
+func TestHierarchyErrors(t *testing.T) {
+	child := Element("child", nil, nil)
+	p := Element("p", nil, []*Node{child})
+	root := Element("root", nil, []*Node{p})
+	text := Text("x")
+	other := Element("other", nil, []*Node{Element("o", nil, nil)})
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"nil child", p.AppendChild(nil), ErrHierarchyRequest},
+		{"into itself", p.AppendChild(p), ErrHierarchyRequest},
+		{"into a descendant", child.AppendChild(root), ErrHierarchyRequest},
+		{"into a text node", text.AppendChild(Element("e", nil, nil)), ErrHierarchyRequest},
+		{"before a stranger", p.InsertBefore(Element("e", nil, nil), other.FirstChild()), ErrNotFound},
+		{"remove a stranger", p.RemoveChild(other.FirstChild()), ErrNotFound},
+		{"remove nil", p.RemoveChild(nil), ErrNotFound},
+		{"replace a stranger", p.ReplaceChild(Element("e", nil, nil), other.FirstChild()), ErrNotFound},
+		{"replace with an ancestor", p.ReplaceChild(root, child), ErrHierarchyRequest},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+	// Failed calls leave the tree alone.
+	if got := childNames(t, p); got != "child" {
+		t.Errorf("p has %s", got)
+	}
+	if got := childNames(t, other); got != "o" {
+		t.Errorf("other has %s", got)
+	}
+}
+
+func TestRemoveAndReplaceChild(t *testing.T) {
+	a, b, c := Element("a", nil, nil), Element("b", nil, nil), Element("c", nil, nil)
+	p := Element("p", nil, []*Node{a, b, c})
+	if err := p.RemoveChild(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(t, p); got != "a c" || b.Parent != nil {
+		t.Errorf("after removing b: %s, parent %v", got, b.Parent)
+	}
+	if err := p.ReplaceChild(b, a); err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(t, p); got != "b c" || a.Parent != nil {
+		t.Errorf("after replacing a by b: %s, a's parent %v", got, a.Parent)
+	}
+	// A sibling replacing its neighbor takes its place.
+	if err := p.ReplaceChild(c, b); err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(t, p); got != "c" {
+		t.Errorf("after replacing b by c: %s", got)
+	}
+	if err := p.ReplaceChild(c, c); err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(t, p); got != "c" {
+		t.Errorf("after replacing c by itself: %s", got)
+	}
+}
+
+func TestCloneNode(t *testing.T) {
+	inner := Element("i", AttrMap{"class": "x"}, []*Node{Text("hi")})
+	n := Element("p", AttrMap{"id": "a"}, []*Node{inner})
+	p := Element("body", nil, []*Node{n})
+
+	shallow := n.CloneNode(false)
+	if shallow.Parent != nil || len(shallow.Children) != 0 || shallow.GetAttribute("id") != "a" {
+		t.Errorf("shallow clone: %+v", shallow)
+	}
+	deep := n.CloneNode(true)
+	if deep.Parent != nil || deep.TextContent() != "hi" {
+		t.Errorf("deep clone has parent %v and text %q", deep.Parent, deep.TextContent())
+	}
+	ci := deep.FirstChild()
+	if ci == inner || ci.Parent != deep || ci.GetAttribute("class") != "x" {
+		t.Errorf("deep clone child: %+v", ci)
+	}
+	// The copies share nothing with the original.
+	if err := deep.SetAttribute("id", "b"); err != nil {
+		t.Fatal(err)
+	}
+	ci.FirstChild().SetTextContent("bye")
+	if n.GetAttribute("id") != "a" || n.TextContent() != "hi" || p.FirstChild() != n {
+		t.Error("changing the clone changed the original")
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	n := Element("p", nil, nil)
+	if err := n.SetAttribute("data-x", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if !n.HasAttribute("data-x") || n.GetAttribute("data-x") != "1" {
+		t.Errorf("data-x = %q", n.GetAttribute("data-x"))
+	}
+	for _, name := range []string{"", "a b", "a=b", `a"`, "a/", "a>"} {
+		if err := n.SetAttribute(name, "v"); !errors.Is(err, ErrInvalidCharacter) {
+			t.Errorf("SetAttribute(%q) = %v", name, err)
+		}
+		if n.HasAttribute(name) {
+			t.Errorf("invalid attribute %q was set", name)
+		}
+	}
+	if err := Text("x").SetAttribute("a", "b"); !errors.Is(err, ErrHierarchyRequest) {
+		t.Errorf("SetAttribute on a text node = %v", err)
+	}
+	n.RemoveAttribute("data-x")
+	n.RemoveAttribute("missing")
+	if n.HasAttribute("data-x") {
+		t.Error("data-x was not removed")
+	}
+}
+
+func TestTextContent(t *testing.T) {
+	n := Element("p", nil, []*Node{Text("a"), Element("b", nil, []*Node{Text("b")}), Text("c")})
+	if got := n.TextContent(); got != "abc" {
+		t.Errorf("TextContent = %q", got)
+	}
+	old := n.FirstChild()
+	n.SetTextContent("new")
+	if got := childNames(t, n); got != "new" || old.Parent != nil {
+		t.Errorf("after SetTextContent: %s", got)
+	}
+	n.SetTextContent("")
+	if len(n.Children) != 0 {
+		t.Errorf("empty text left %d children", len(n.Children))
+	}
+}
+
+func TestDocumentChildren(t *testing.T) {
+	html := Element("html", nil, nil)
+	doc := NewDocument(html)
+	if doc.DocumentElement() != html || html.OwnerDocument() != doc {
+		t.Fatal("html is not the document element")
+	}
+	other := Element("html", nil, nil)
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"second element", doc.AppendChild(other), ErrHierarchyRequest},
+		{"text", doc.AppendChild(Text("x")), ErrHierarchyRequest},
+		{"a document", html.AppendChild(NewDocument(nil)), ErrHierarchyRequest},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+	// Replacing the document element, or moving it, keeps a single one.
+	if err := doc.InsertBefore(html, html); err != nil {
+		t.Errorf("moving the document element: %v", err)
+	}
+	if err := doc.ReplaceChild(other, html); err != nil {
+		t.Fatalf("replacing the document element: %v", err)
+	}
+	if doc.DocumentElement() != other || len(doc.Children) != 1 || html.OwnerDocument() != nil {
+		t.Error("the replaced document element is still attached")
+	}
+}
+
+func TestIDIndex(t *testing.T) {
+	a := Element("p", AttrMap{"id": "a"}, nil)
+	body := Element("body", nil, []*Node{a})
+	doc := NewDocument(Element("html", nil, []*Node{body}))
+	if doc.GetElementById("a") != a {
+		t.Fatal("#a is not indexed")
+	}
+	// An earlier element with the same id takes over, and gives way when
+	// it is removed.
+	b := Element("div", AttrMap{"id": "a"}, nil)
+	if err := body.InsertBefore(b, a); err != nil {
+		t.Fatal(err)
+	}
+	if doc.GetElementById("a") != b {
+		t.Error("the first #a in tree order is not found")
+	}
+	if err := body.RemoveChild(b); err != nil {
+		t.Fatal(err)
+	}
+	if doc.GetElementById("a") != a {
+		t.Error("removed element is still found")
+	}
+	if err := a.SetAttribute("id", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if doc.GetElementById("a") != nil || doc.GetElementById("c") != a {
+		t.Error("changing the id did not move the element in the index")
+	}
+	a.RemoveAttribute("id")
+	if doc.GetElementById("c") != nil {
+		t.Error("element is found by a removed id")
+	}
+	// Detached subtrees are not indexed.
+	if err := body.RemoveChild(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetAttribute("id", "d"); err != nil {
+		t.Fatal(err)
+	}
+	if doc.GetElementById("d") != nil {
+		t.Error("detached element is found")
+	}
+	if err := body.AppendChild(a); err != nil {
+		t.Fatal(err)
+	}
+	if doc.GetElementById("d") != a {
+		t.Error("reattached element is not found")
+	}
+}
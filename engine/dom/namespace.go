@@ -0,0 +1,67 @@
+package dom
+
+import "strings"
+
+// attributePrefixes maps the prefixes the HTML parser recognises on
+// attributes of foreign elements to their namespaces.
+var attributePrefixes = map[string]string{
+	"xlink": XLinkNamespace,
+	"xml":   XMLNamespace,
+	"xmlns": XMLNSNamespace,
+}
+
+// IsHTML reports whether n is an element in the HTML namespace.
+func (n *Node) IsHTML() bool {
+	return n.NodeType == ElementNode && n.Namespace == HTMLNamespace
+}
+
+// AttributeNamespace returns the namespace of the attribute with qualified
+// name qname. HTML elements never have namespaced attributes; on SVG and
+// MathML elements the xlink, xml and xmlns prefixes are honoured.
+func (n *Node) AttributeNamespace(qname string) string {
+	if n.IsHTML() {
+		return ""
+	}
+	if qname == "xmlns" {
+		return XMLNSNamespace
+	}
+	if i := strings.IndexByte(qname, ':'); i > 0 {
+		return attributePrefixes[qname[:i]]
+	}
+	return ""
+}
+
+// GetAttributeNS returns the value of the attribute with the given namespace
+// and local name.
+func (n *Node) GetAttributeNS(namespace, local string) string {
+	v, _ := n.lookupAttributeNS(namespace, local, false)
+	return v
+}
+
+// lookupAttributeNS finds an attribute by namespace and local name; anyNS
+// ignores the namespace.
+func (n *Node) lookupAttributeNS(namespace, local string, anyNS bool) (string, bool) {
+	for qname, v := range n.Attributes {
+		ns := n.AttributeNamespace(qname)
+		name := qname
+		if ns != "" {
+			if i := strings.IndexByte(qname, ':'); i > 0 {
+				name = qname[i+1:]
+			}
+		}
+		if name == local && (anyNS || ns == namespace) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// sameTagName compares element names the way type selectors and
+// getElementsByTagName do: ASCII case-insensitively for HTML elements and
+// exactly for foreign ones.
+func sameTagName(n *Node, name string) bool {
+	if n.IsHTML() {
+		return strings.EqualFold(n.TagName, name)
+	}
+	return n.TagName == name
+}
@@ -0,0 +1,182 @@
+package dom
+
+import "errors"
+
+type MutationType string
+
+const (
+	MutationChildList     MutationType = "childList"
+	MutationAttributes    MutationType = "attributes"
+	MutationCharacterData MutationType = "characterData"
+)
+
+// MutationRecord describes a single change to the tree.
+type MutationRecord struct {
+	Type            MutationType
+	Target          *Node
+	AddedNodes      []*Node
+	RemovedNodes    []*Node
+	PreviousSibling *Node
+	NextSibling     *Node
+	AttributeName   string
+	OldValue        string
+}
+
+type MutationObserverInit struct {
+	ChildList             bool
+	Attributes            bool
+	CharacterData         bool
+	Subtree               bool
+	AttributeOldValue     bool
+	CharacterDataOldValue bool
+	AttributeFilter       []string
+}
+
+type MutationCallback func(records []MutationRecord, observer *MutationObserver)
+
+// MutationObserver queues records for the nodes it observes. Records are
+// handed to the callback by DeliverMutationRecords, or can be drained early
+// with TakeRecords.
+type MutationObserver struct {
+	callback MutationCallback
+	records  []MutationRecord
+	targets  []*Node
+}
+
+type registeredObserver struct {
+	observer *MutationObserver
+	options  MutationObserverInit
+}
+
+var ErrInvalidObserverOptions = errors.New("dom: observe requires childList, attributes or characterData")
+
+// pendingObservers holds observers with queued records awaiting delivery.
+var pendingObservers []*MutationObserver
+
+func NewMutationObserver(callback MutationCallback) *MutationObserver {
+	return &MutationObserver{callback: callback}
+}
+
+// Observe starts watching target. Observing the same target again replaces
+// the previous options.
+func (o *MutationObserver) Observe(target *Node, options MutationObserverInit) error {
+	if options.AttributeOldValue || len(options.AttributeFilter) > 0 {
+		options.Attributes = true
+	}
+	if options.CharacterDataOldValue {
+		options.CharacterData = true
+	}
+	if !options.ChildList && !options.Attributes && !options.CharacterData {
+		return ErrInvalidObserverOptions
+	}
+	for i, r := range target.observers {
+		if r.observer == o {
+			target.observers[i].options = options
+			return nil
+		}
+	}
+	target.observers = append(target.observers, registeredObserver{observer: o, options: options})
+	o.targets = append(o.targets, target)
+	return nil
+}
+
+func (o *MutationObserver) Disconnect() {
+	for _, target := range o.targets {
+		for i, r := range target.observers {
+			if r.observer == o {
+				target.observers = append(target.observers[:i], target.observers[i+1:]...)
+				break
+			}
+		}
+	}
+	o.targets = nil
+	o.records = nil
+}
+
+// TakeRecords returns and clears the queued records.
+func (o *MutationObserver) TakeRecords() []MutationRecord {
+	records := o.records
+	o.records = nil
+	return records
+}
+
+// DeliverMutationRecords invokes the callback of every observer with queued
+// records. The event loop calls it once per turn. Observers without a
+// callback keep their records for TakeRecords.
+func DeliverMutationRecords() {
+	for len(pendingObservers) > 0 {
+		observers := pendingObservers
+		pendingObservers = nil
+		for _, o := range observers {
+			if o.callback == nil {
+				continue
+			}
+			if records := o.TakeRecords(); len(records) > 0 {
+				o.callback(records, o)
+			}
+		}
+	}
+}
+
+// queueMutationRecord hands record to every observer registered on the target
+// or, with Subtree set, on one of its ancestors.
+func queueMutationRecord(record MutationRecord) {
+	interested := map[*MutationObserver]bool{}
+	var order []*MutationObserver
+	for n := record.Target; n != nil; n = n.Parent {
+		for _, r := range n.observers {
+			if n != record.Target && !r.options.Subtree {
+				continue
+			}
+			if !r.options.wants(record) {
+				continue
+			}
+			if _, seen := interested[r.observer]; !seen {
+				order = append(order, r.observer)
+			}
+			interested[r.observer] = interested[r.observer] || r.options.wantsOldValue(record)
+		}
+	}
+	for _, o := range order {
+		rec := record
+		if !interested[o] {
+			rec.OldValue = ""
+		}
+		if len(o.records) == 0 {
+			pendingObservers = append(pendingObservers, o)
+		}
+		o.records = append(o.records, rec)
+	}
+}
+
+func (opts *MutationObserverInit) wants(record MutationRecord) bool {
+	switch record.Type {
+	case MutationChildList:
+		return opts.ChildList
+	case MutationCharacterData:
+		return opts.CharacterData
+	case MutationAttributes:
+		if !opts.Attributes {
+			return false
+		}
+		if len(opts.AttributeFilter) == 0 {
+			return true
+		}
+		for _, name := range opts.AttributeFilter {
+			if name == record.AttributeName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (opts *MutationObserverInit) wantsOldValue(record MutationRecord) bool {
+	switch record.Type {
+	case MutationAttributes:
+		return opts.AttributeOldValue
+	case MutationCharacterData:
+		return opts.CharacterDataOldValue
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package dom
+
+import "testing"
+
+func TestDeliverMutationRecords(t *testing.T) {
+	root := Element("div", AttrMap{}, nil)
+	var delivered []MutationRecord
+	withCallback := NewMutationObserver(func(records []MutationRecord, _ *MutationObserver) {
+		delivered = append(delivered, records...)
+	})
+	withoutCallback := NewMutationObserver(nil)
+	opts := MutationObserverInit{ChildList: true, Attributes: true, Subtree: true}
+	for _, o := range []*MutationObserver{withCallback, withoutCallback} {
+		if err := o.Observe(root, opts); err != nil {
+			t.Fatal(err)
+		}
+		defer o.Disconnect()
+	}
+
+	root.SetAttribute("class", "x")
+	root.AppendChild(Text("hi"))
+	DeliverMutationRecords()
+
+	if len(delivered) != 2 {
+		t.Fatalf("callback got %d records, want 2", len(delivered))
+	}
+	if delivered[0].Type != MutationAttributes || delivered[1].Type != MutationChildList {
+		t.Errorf("records delivered as %s, %s", delivered[0].Type, delivered[1].Type)
+	}
+	if got := withCallback.TakeRecords(); len(got) != 0 {
+		t.Errorf("delivered observer still holds %d records", len(got))
+	}
+	// Without a callback the records wait for TakeRecords.
+	if got := withoutCallback.TakeRecords(); len(got) != 2 {
+		t.Errorf("observer without callback holds %d records, want 2", len(got))
+	}
+
+	root.SetAttribute("class", "y")
+	DeliverMutationRecords()
+	if got := withoutCallback.TakeRecords(); len(got) != 1 {
+		t.Errorf("observer without callback holds %d records after a second delivery, want 1", len(got))
+	}
+}
+
+func TestObserveOptions(t *testing.T) {
+	root := Element("div", AttrMap{}, []*Node{Element("p", AttrMap{}, nil)})
+	child := root.Children[0]
+	tests := []struct {
+		name   string
+		opts   MutationObserverInit
+		mutate func()
+		want   int
+	}{
+		{"attributes only", MutationObserverInit{Attributes: true}, func() { root.SetAttribute("a", "1"); root.AppendChild(Text("x")) }, 1},
+		{"no subtree", MutationObserverInit{Attributes: true}, func() { child.SetAttribute("a", "1") }, 0},
+		{"subtree", MutationObserverInit{Attributes: true, Subtree: true}, func() { child.SetAttribute("a", "2") }, 1},
+		{"filter", MutationObserverInit{AttributeFilter: []string{"b"}}, func() { root.SetAttribute("a", "3"); root.SetAttribute("b", "1") }, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewMutationObserver(nil)
+			if err := o.Observe(root, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			defer o.Disconnect()
+			tt.mutate()
+			if got := len(o.TakeRecords()); got != tt.want {
+				t.Errorf("got %d records, want %d", got, tt.want)
+			}
+		})
+	}
+	if err := NewMutationObserver(nil).Observe(root, MutationObserverInit{}); err != ErrInvalidObserverOptions {
+		t.Errorf("empty options: got %v", err)
+	}
+}
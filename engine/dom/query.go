@@ -0,0 +1,157 @@
+package dom
+
+import "strings"
+
+// QuerySelector returns the first descendant element matching selector.
+func (n *Node) QuerySelector(selector string) (*Node, error) {
+	list, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := list.simpleID(); ok {
+		if e := n.GetElementById(id); e != nil && e != n {
+			return e, nil
+		}
+		return nil, nil
+	}
+	var found *Node
+	n.walkElements(func(e *Node) bool {
+		if list.Match(e) {
+			found = e
+			return false
+		}
+		return true
+	})
+	return found, nil
+}
+
+// QuerySelectorAll returns every descendant element matching selector in
+// tree order.
+func (n *Node) QuerySelectorAll(selector string) ([]*Node, error) {
+	list, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	var found []*Node
+	n.walkElements(func(e *Node) bool {
+		if list.Match(e) {
+			found = append(found, e)
+		}
+		return true
+	})
+	return found, nil
+}
+
+func (n *Node) Matches(selector string) (bool, error) {
+	list, err := ParseSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return list.Match(n), nil
+}
+
+// Closest returns the nearest inclusive ancestor matching selector.
+func (n *Node) Closest(selector string) (*Node, error) {
+	list, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	for e := n; e != nil; e = e.Parent {
+		if list.Match(e) {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetElementById uses the document's id index when n is connected to one
+// and falls back to a tree walk for detached subtrees.
+func (n *Node) GetElementById(id string) *Node {
+	if id == "" {
+		return nil
+	}
+	if doc := n.OwnerDocument(); doc != nil && doc.ids != nil {
+		if n == doc {
+			return doc.lookupID(id)
+		}
+		var first *Node
+		for _, e := range doc.ids[id] {
+			if e != n && n.Contains(e) && (first == nil || precedes(e, first)) {
+				first = e
+			}
+		}
+		return first
+	}
+	return n.firstWithID(id)
+}
+
+func (n *Node) firstWithID(id string) *Node {
+	var found *Node
+	n.walkElements(func(e *Node) bool {
+		if e.Attributes["id"] == id {
+			found = e
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// GetElementsByTagName returns descendant elements with the given tag name,
+// or all of them for "*".
+func (n *Node) GetElementsByTagName(name string) []*Node {
+	var found []*Node
+	n.walkElements(func(e *Node) bool {
+		if name == "*" || sameTagName(e, name) {
+			found = append(found, e)
+		}
+		return true
+	})
+	return found
+}
+
+// GetElementsByClassName returns descendant elements carrying every class in
+// the space separated list names.
+func (n *Node) GetElementsByClassName(names string) []*Node {
+	classes := strings.Fields(names)
+	if len(classes) == 0 {
+		return nil
+	}
+	var found []*Node
+	n.walkElements(func(e *Node) bool {
+		for _, c := range classes {
+			if !hasClass(e, c) {
+				return true
+			}
+		}
+		found = append(found, e)
+		return true
+	})
+	return found
+}
+
+// walkElements visits the descendant elements of n in tree order until fn
+// returns false.
+func (n *Node) walkElements(fn func(*Node) bool) bool {
+	for _, child := range n.Children {
+		if child.NodeType != ElementNode {
+			continue
+		}
+		if !fn(child) || !child.walkElements(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// simpleID reports whether the list is a lone "#id" selector.
+func (l SelectorList) simpleID() (string, bool) {
+	if len(l) != 1 || len(l[0].compounds) != 1 {
+		return "", false
+	}
+	c := l[0].compounds[0]
+	if len(c.ids) != 1 || (c.tag != "" && c.tag != "*") || len(c.classes) > 0 || len(c.attrs) > 0 || len(c.pseudos) > 0 || c.never {
+		return "", false
+	}
+	return c.ids[0], true
+}
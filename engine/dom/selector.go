@@ -0,0 +1,668 @@
+package dom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selector is a single complex selector such as "div.note > p:first-child".
+// Compounds are stored left to right; combinators[i] joins compounds[i] and
+// compounds[i+1].
+type Selector struct {
+	compounds   []compound
+	combinators []byte
+	specificity int
+}
+
+// SelectorList is a comma separated group of selectors.
+type SelectorList []*Selector
+
+type compound struct {
+	tag string
+	// ns restricts the element namespace unless anyNS is set.
+	ns      string
+	anyNS   bool
+	ids     []string
+	classes []string
+	attrs   []attrSelector
+	pseudos []pseudoClass
+	// never is set for pseudo-elements, which no element can match.
+	never bool
+}
+
+type attrSelector struct {
+	name  string
+	op    string
+	value string
+	fold  bool
+	// With hasNS the attribute is looked up by namespace, or in any
+	// namespace when anyNS is set; otherwise only un-namespaced attributes
+	// match.
+	hasNS bool
+	ns    string
+	anyNS bool
+}
+
+type pseudoClass struct {
+	name string
+	a, b int
+	args SelectorList
+}
+
+// ParseSelector parses a selector list.
+func ParseSelector(s string) (SelectorList, error) {
+	return ParseSelectorNS(s, nil)
+}
+
+// ParseSelectorNS parses a selector list in which namespace prefixes resolve
+// through namespaces, as declared by @namespace rules. The empty prefix holds
+// the default namespace, if any.
+func ParseSelectorNS(s string, namespaces map[string]string) (SelectorList, error) {
+	p := &selectorParser{input: s, namespaces: namespaces}
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.input[p.pos])
+	}
+	return list, nil
+}
+
+// Match reports whether any selector in the list matches n.
+func (l SelectorList) Match(n *Node) bool {
+	for _, s := range l {
+		if s.Match(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// Specificity returns the selector's specificity packed as a*1e6 + b*1e3 + c.
+func (s *Selector) Specificity() int {
+	return s.specificity
+}
+
+func (s *Selector) Match(n *Node) bool {
+	if n == nil || n.NodeType != ElementNode {
+		return false
+	}
+	return s.matchAt(len(s.compounds)-1, n)
+}
+
+func (s *Selector) matchAt(i int, n *Node) bool {
+	if !s.compounds[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch s.combinators[i-1] {
+	case '>':
+		p := parentElement(n)
+		return p != nil && s.matchAt(i-1, p)
+	case '+':
+		p := previousElementSibling(n)
+		return p != nil && s.matchAt(i-1, p)
+	case '~':
+		for p := previousElementSibling(n); p != nil; p = previousElementSibling(p) {
+			if s.matchAt(i-1, p) {
+				return true
+			}
+		}
+	default:
+		for p := parentElement(n); p != nil; p = parentElement(p) {
+			if s.matchAt(i-1, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *compound) match(n *Node) bool {
+	if c.never {
+		return false
+	}
+	if !c.anyNS && n.Namespace != c.ns {
+		return false
+	}
+	if c.tag != "" && c.tag != "*" && !sameTagName(n, c.tag) {
+		return false
+	}
+	for _, id := range c.ids {
+		if n.Attributes["id"] != id {
+			return false
+		}
+	}
+	for _, class := range c.classes {
+		if !hasClass(n, class) {
+			return false
+		}
+	}
+	for _, a := range c.attrs {
+		if !a.match(n) {
+			return false
+		}
+	}
+	for _, p := range c.pseudos {
+		if !p.match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *attrSelector) match(n *Node) bool {
+	var v string
+	var ok bool
+	if a.hasNS {
+		v, ok = n.lookupAttributeNS(a.ns, a.name, a.anyNS)
+	} else {
+		name := a.name
+		if n.IsHTML() {
+			name = strings.ToLower(name)
+		}
+		v, ok = n.Attributes[name]
+		ok = ok && n.AttributeNamespace(name) == ""
+	}
+	if !ok {
+		return false
+	}
+	want := a.value
+	if a.fold {
+		v, want = strings.ToLower(v), strings.ToLower(want)
+	}
+	switch a.op {
+	case "":
+		return true
+	case "=":
+		return v == want
+	case "~=":
+		for _, f := range strings.Fields(v) {
+			if f == want {
+				return true
+			}
+		}
+		return false
+	case "|=":
+		return v == want || strings.HasPrefix(v, want+"-")
+	case "^=":
+		return want != "" && strings.HasPrefix(v, want)
+	case "$=":
+		return want != "" && strings.HasSuffix(v, want)
+	case "*=":
+		return want != "" && strings.Contains(v, want)
+	}
+	return false
+}
+
+func (p *pseudoClass) match(n *Node) bool {
+	switch p.name {
+	case "root":
+		return parentElement(n) == nil
+	case "empty":
+		for _, c := range n.Children {
+			if c.NodeType == ElementNode || c.Text != "" {
+				return false
+			}
+		}
+		return true
+	case "first-child":
+		return previousElementSibling(n) == nil
+	case "last-child":
+		return nextElementSibling(n) == nil
+	case "only-child":
+		return previousElementSibling(n) == nil && nextElementSibling(n) == nil
+	case "first-of-type":
+		return elementIndex(n, false, true) == 1
+	case "last-of-type":
+		return elementIndex(n, true, true) == 1
+	case "only-of-type":
+		return elementIndex(n, false, true) == 1 && elementIndex(n, true, true) == 1
+	case "nth-child":
+		return nthMatch(p.a, p.b, elementIndex(n, false, false))
+	case "nth-last-child":
+		return nthMatch(p.a, p.b, elementIndex(n, true, false))
+	case "nth-of-type":
+		return nthMatch(p.a, p.b, elementIndex(n, false, true))
+	case "nth-last-of-type":
+		return nthMatch(p.a, p.b, elementIndex(n, true, true))
+	case "not":
+		return !p.args.Match(n)
+	case "is", "where":
+		return p.args.Match(n)
+	case "link", "any-link":
+		return n.IsHTML() && (sameTagName(n, "a") || sameTagName(n, "area")) && n.HasAttribute("href")
+	case "checked":
+		return n.HasAttribute("checked") || n.HasAttribute("selected")
+	case "disabled":
+		return n.HasAttribute("disabled")
+	case "enabled":
+		return !n.HasAttribute("disabled")
+	}
+	// Dynamic states such as :hover are never matched.
+	return false
+}
+
+func nthMatch(a, b, index int) bool {
+	if a == 0 {
+		return index == b
+	}
+	k := index - b
+	return k%a == 0 && k/a >= 0
+}
+
+// elementIndex returns the 1-based position of n among its element siblings,
+// counted from the end when fromEnd is set and restricted to siblings with
+// the same tag name when ofType is set.
+func elementIndex(n *Node, fromEnd, ofType bool) int {
+	index := 1
+	next := previousElementSibling
+	if fromEnd {
+		next = nextElementSibling
+	}
+	for s := next(n); s != nil; s = next(s) {
+		if !ofType || (s.Namespace == n.Namespace && sameTagName(s, n.TagName)) {
+			index++
+		}
+	}
+	return index
+}
+
+func hasClass(n *Node, class string) bool {
+	for _, c := range strings.Fields(n.Attributes["class"]) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func parentElement(n *Node) *Node {
+	if n.Parent == nil || n.Parent.NodeType != ElementNode {
+		return nil
+	}
+	return n.Parent
+}
+
+func previousElementSibling(n *Node) *Node {
+	for s := n.PreviousSibling(); s != nil; s = s.PreviousSibling() {
+		if s.NodeType == ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+func nextElementSibling(n *Node) *Node {
+	for s := n.NextSibling(); s != nil; s = s.NextSibling() {
+		if s.NodeType == ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+type selectorParser struct {
+	pos        int
+	input      string
+	namespaces map[string]string
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("dom: invalid selector %q: %s", p.input, fmt.Sprintf(format, args...))
+}
+
+func (p *selectorParser) parseList() (SelectorList, error) {
+	var list SelectorList
+	for {
+		sel, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, sel)
+		p.skipWhitespace()
+		if p.eof() || p.input[p.pos] != ',' {
+			return list, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) parseComplex() (*Selector, error) {
+	sel := &Selector{}
+	p.skipWhitespace()
+	for {
+		c, spec, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		sel.compounds = append(sel.compounds, c)
+		sel.specificity += spec
+
+		hadSpace := p.skipWhitespace()
+		if p.eof() || p.input[p.pos] == ',' || p.input[p.pos] == ')' {
+			return sel, nil
+		}
+		comb := byte(' ')
+		switch p.input[p.pos] {
+		case '>', '+', '~':
+			comb = p.input[p.pos]
+			p.pos++
+			p.skipWhitespace()
+		default:
+			if !hadSpace {
+				return nil, p.errorf("unexpected %q", p.input[p.pos])
+			}
+		}
+		sel.combinators = append(sel.combinators, comb)
+	}
+}
+
+func (p *selectorParser) parseCompound() (compound, int, error) {
+	var c compound
+	spec := 0
+	start := p.pos
+	c.ns, c.anyNS = p.namespaces[""], true
+	if _, ok := p.namespaces[""]; ok {
+		c.anyNS = false
+	}
+	if prefix, ok := p.parseNamespacePrefix(); ok {
+		ns, any, err := p.resolvePrefix(prefix)
+		if err != nil {
+			return c, 0, err
+		}
+		c.ns, c.anyNS = ns, any
+		if p.eof() || (p.input[p.pos] != '*' && !isSelectorIdentChar(p.input[p.pos])) {
+			return c, 0, p.errorf("expected type selector after namespace prefix")
+		}
+	}
+	if !p.eof() && p.input[p.pos] == '*' {
+		p.pos++
+		c.tag = "*"
+	} else if name := p.parseIdent(); name != "" {
+		c.tag = name
+		spec += 1
+	}
+	if c.tag == "" {
+		// The default namespace only applies to type and universal selectors.
+		c.anyNS = true
+	}
+	for !p.eof() {
+		switch p.input[p.pos] {
+		case '#':
+			p.pos++
+			id := p.parseIdent()
+			if id == "" {
+				return c, 0, p.errorf("expected id after '#'")
+			}
+			c.ids = append(c.ids, id)
+			spec += 1000000
+		case '.':
+			p.pos++
+			class := p.parseIdent()
+			if class == "" {
+				return c, 0, p.errorf("expected class name after '.'")
+			}
+			c.classes = append(c.classes, class)
+			spec += 1000
+		case '[':
+			a, err := p.parseAttr()
+			if err != nil {
+				return c, 0, err
+			}
+			c.attrs = append(c.attrs, a)
+			spec += 1000
+		case ':':
+			p.pos++
+			if !p.eof() && p.input[p.pos] == ':' {
+				p.pos++
+				if p.parseIdent() == "" {
+					return c, 0, p.errorf("expected pseudo-element name")
+				}
+				c.never = true
+				spec += 1
+				continue
+			}
+			pc, s, err := p.parsePseudo()
+			if err != nil {
+				return c, 0, err
+			}
+			if pc.name == "before" || pc.name == "after" || pc.name == "first-line" || pc.name == "first-letter" {
+				c.never = true
+				spec += 1
+				continue
+			}
+			c.pseudos = append(c.pseudos, pc)
+			spec += s
+		default:
+			if p.pos == start {
+				return c, 0, p.errorf("unexpected %q", p.input[p.pos])
+			}
+			return c, spec, nil
+		}
+	}
+	if p.pos == start {
+		return c, 0, p.errorf("empty selector")
+	}
+	return c, spec, nil
+}
+
+func (p *selectorParser) parseAttr() (attrSelector, error) {
+	var a attrSelector
+	p.pos++ // '['
+	p.skipWhitespace()
+	if prefix, ok := p.parseNamespacePrefix(); ok {
+		ns, any, err := p.resolvePrefix(prefix)
+		if err != nil {
+			return a, err
+		}
+		a.hasNS, a.ns, a.anyNS = true, ns, any
+	}
+	a.name = p.parseIdent()
+	if a.name == "" {
+		return a, p.errorf("expected attribute name")
+	}
+	p.skipWhitespace()
+	if p.eof() {
+		return a, p.errorf("unterminated attribute selector")
+	}
+	if p.input[p.pos] != ']' {
+		for _, op := range []string{"=", "~=", "|=", "^=", "$=", "*="} {
+			if strings.HasPrefix(p.input[p.pos:], op) {
+				a.op = op
+				break
+			}
+		}
+		if a.op == "" {
+			return a, p.errorf("unexpected %q in attribute selector", p.input[p.pos])
+		}
+		p.pos += len(a.op)
+		p.skipWhitespace()
+		if p.eof() {
+			return a, p.errorf("unterminated attribute selector")
+		}
+		if q := p.input[p.pos]; q == '"' || q == '\'' {
+			end := strings.IndexByte(p.input[p.pos+1:], q)
+			if end < 0 {
+				return a, p.errorf("unterminated string")
+			}
+			a.value = p.input[p.pos+1 : p.pos+1+end]
+			p.pos += end + 2
+		} else {
+			a.value = p.parseIdent()
+		}
+		p.skipWhitespace()
+		if !p.eof() && (p.input[p.pos] == 'i' || p.input[p.pos] == 'I') {
+			a.fold = true
+			p.pos++
+			p.skipWhitespace()
+		} else if !p.eof() && (p.input[p.pos] == 's' || p.input[p.pos] == 'S') {
+			p.pos++
+			p.skipWhitespace()
+		}
+	}
+	if p.eof() || p.input[p.pos] != ']' {
+		return a, p.errorf("unterminated attribute selector")
+	}
+	p.pos++
+	return a, nil
+}
+
+func (p *selectorParser) parsePseudo() (pseudoClass, int, error) {
+	pc := pseudoClass{name: strings.ToLower(p.parseIdent())}
+	if pc.name == "" {
+		return pc, 0, p.errorf("expected pseudo-class name")
+	}
+	if p.eof() || p.input[p.pos] != '(' {
+		switch pc.name {
+		case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type", "not", "is", "where":
+			return pc, 0, p.errorf(":%s requires an argument", pc.name)
+		}
+		return pc, 1000, nil
+	}
+	p.pos++ // '('
+	switch pc.name {
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		end := strings.IndexByte(p.input[p.pos:], ')')
+		if end < 0 {
+			return pc, 0, p.errorf("unterminated :%s", pc.name)
+		}
+		a, b, ok := parseNth(p.input[p.pos : p.pos+end])
+		if !ok {
+			return pc, 0, p.errorf("invalid :%s argument", pc.name)
+		}
+		pc.a, pc.b = a, b
+		p.pos += end + 1
+		return pc, 1000, nil
+	case "not", "is", "where":
+		args, err := p.parseList()
+		if err != nil {
+			return pc, 0, err
+		}
+		p.skipWhitespace()
+		if p.eof() || p.input[p.pos] != ')' {
+			return pc, 0, p.errorf("unterminated :%s", pc.name)
+		}
+		p.pos++
+		pc.args = args
+		if pc.name == "where" {
+			return pc, 0, nil
+		}
+		spec := 0
+		for _, s := range args {
+			if s.specificity > spec {
+				spec = s.specificity
+			}
+		}
+		return pc, spec, nil
+	}
+	return pc, 0, p.errorf("unknown functional pseudo-class :%s", pc.name)
+}
+
+// parseNamespacePrefix consumes "prefix|", "*|" or "|" ahead of a type or
+// attribute name. The "|=" attribute operator is not mistaken for one.
+func (p *selectorParser) parseNamespacePrefix() (string, bool) {
+	start := p.pos
+	prefix := "*"
+	if !p.eof() && p.input[p.pos] == '*' {
+		p.pos++
+	} else {
+		prefix = p.parseIdent()
+	}
+	if p.pos+1 < len(p.input) && p.input[p.pos] == '|' && p.input[p.pos+1] != '=' {
+		p.pos++
+		return prefix, true
+	}
+	p.pos = start
+	return "", false
+}
+
+// resolvePrefix maps a selector namespace prefix to a namespace URI. "*"
+// matches any namespace and "" means no namespace.
+func (p *selectorParser) resolvePrefix(prefix string) (ns string, any bool, err error) {
+	switch prefix {
+	case "*":
+		return "", true, nil
+	case "":
+		return "", false, nil
+	}
+	ns, ok := p.namespaces[prefix]
+	if !ok {
+		return "", false, p.errorf("undeclared namespace prefix %q", prefix)
+	}
+	return ns, false, nil
+}
+
+// parseNth parses the an+b microsyntax used by :nth-child and friends.
+func parseNth(s string) (a, b int, ok bool) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	switch s {
+	case "odd":
+		return 2, 1, true
+	case "even":
+		return 2, 0, true
+	}
+	i := strings.IndexByte(s, 'n')
+	if i < 0 {
+		b, err := strconv.Atoi(s)
+		return 0, b, err == nil
+	}
+	switch coef := s[:i]; coef {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		v, err := strconv.Atoi(coef)
+		if err != nil {
+			return 0, 0, false
+		}
+		a = v
+	}
+	if rest := s[i+1:]; rest != "" {
+		v, err := strconv.Atoi(rest)
+		if err != nil || (rest[0] != '+' && rest[0] != '-') {
+			return 0, 0, false
+		}
+		b = v
+	}
+	return a, b, true
+}
+
+func (p *selectorParser) parseIdent() string {
+	start := p.pos
+	for !p.eof() {
+		c := p.input[p.pos]
+		if c == '\\' && p.pos+1 < len(p.input) {
+			p.pos += 2
+			continue
+		}
+		if !isSelectorIdentChar(c) {
+			break
+		}
+		p.pos++
+	}
+	return strings.ReplaceAll(p.input[start:p.pos], "\\", "")
+}
+
+func (p *selectorParser) skipWhitespace() bool {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\n\r\f", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *selectorParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func isSelectorIdentChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		c == '-' || c == '_' || c >= 0x80
+}
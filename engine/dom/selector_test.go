@@ -0,0 +1,260 @@
+package dom
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNth(t *testing.T) {
+	tests := []struct {
+		in   string
+		a, b int
+		ok   bool
+	}{
+		{"odd", 2, 1, true},
+		{"EVEN", 2, 0, true},
+		{"3", 0, 3, true},
+		{"-2", 0, -2, true},
+		{"n", 1, 0, true},
+		{"+n", 1, 0, true},
+		{"-n+3", -1, 3, true},
+		{"2n+1", 2, 1, true},
+		{" 3n - 2 ", 3, -2, true},
+		{"0n+5", 0, 5, true},
+		{"", 0, 0, false},
+		{"n2", 0, 0, false},
+		{"2n+", 0, 0, false},
+		{"xn", 0, 0, false},
+	}
+	for _, tt := range tests {
+		a, b, ok := parseNth(tt.in)
+		if ok != tt.ok || ok && (a != tt.a || b != tt.b) {
+			t.Errorf("parseNth(%q) = %d, %d, %v, want %d, %d, %v", tt.in, a, b, ok, tt.a, tt.b, tt.ok)
+		}
+	}
+}
+
+// selectorTree returns a list of six items, the third and fifth of them
+// <span>s, inside a document:
+//
+//	<html id="root"><div id="main" class="box wide" lang="en-GB">
+//	  <ul> <li id="i1" class="a">…<li id="i6"> </ul>
+//	  <p id="empty"></p>
+//	  <a id="link" href="/x" title="Hello World">
+//	</div></html>
+func selectorTree() *Node {
+	var items []*Node
+	for i, tag := range []string{"li", "li", "span", "li", "span", "li"} {
+		id := "i" + string(rune('1'+i))
+		items = append(items, Element(tag, AttrMap{"id": id, "class": "a"}, []*Node{Text(id)}))
+	}
+	return NewDocument(Element("html", AttrMap{"id": "root"}, []*Node{
+		Element("div", AttrMap{"id": "main", "class": "box  wide", "lang": "en-GB"}, []*Node{
+			Element("ul", AttrMap{}, items),
+			Element("p", AttrMap{"id": "empty"}, nil),
+			Element("a", AttrMap{"id": "link", "href": "/x", "title": "Hello World"}, nil),
+		}),
+	}))
+}
+
+func TestQuerySelectorAll(t *testing.T) {
+	doc := selectorTree()
+	tests := []struct {
+		sel  string
+		want string
+	}{
+		{"#main", "main"},
+		{".box.wide", "main"},
+		{".box.narrow", ""},
+		{"div > ul > li", "i1 i2 i4 i6"},
+		{"div li", "i1 i2 i4 i6"},
+		{"html > li", ""},
+		{"#i2 + span", "i3"},
+		{"#i2 ~ span", "i3 i5"},
+		{"#i3 + span", ""},
+		{"li:first-child", "i1"},
+		{"li:last-child", "i6"},
+		{"ul > :nth-child(odd)", "i1 i3 i5"},
+		{"ul > :nth-child(2n)", "i2 i4 i6"},
+		{"ul > :nth-child(-n+2)", "i1 i2"},
+		{"ul > :nth-last-child(1)", "i6"},
+		{"li:nth-of-type(3)", "i4"},
+		{"span:nth-of-type(2)", "i5"},
+		{"li:nth-last-of-type(2)", "i4"},
+		{"span:first-of-type", "i3"},
+		{"span:last-of-type", "i5"},
+		{"p:only-of-type", "empty"},
+		{"ul:only-child", ""},
+		{":empty", "empty link"},
+		{"html:root", "root"},
+		{"div:root", ""},
+		{"ul > :not(li)", "i3 i5"},
+		{":is(span, p)", "i3 i5 empty"},
+		{"[href]", "link"},
+		{"[title=\"Hello World\"]", "link"},
+		{"[title='hello world' i]", "link"},
+		{"[title='hello world']", ""},
+		{"[title~=World]", "link"},
+		{"[lang|=en]", "main"},
+		{"[title^=Hell]", "link"},
+		{"[title$=rld]", "link"},
+		{"[title*='o W']", "link"},
+		{"[title^='']", ""},
+		{"a:link", "link"},
+		{"#i1, #empty, #i1", "i1 empty"},
+		{"li::before", ""},
+	}
+	for _, tt := range tests {
+		nodes, err := doc.QuerySelectorAll(tt.sel)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sel, err)
+			continue
+		}
+		var ids []string
+		for _, n := range nodes {
+			ids = append(ids, n.GetAttribute("id"))
+		}
+		if got := strings.Join(ids, " "); got != tt.want {
+			t.Errorf("%s matches %q, want %q", tt.sel, got, tt.want)
+		}
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, sel := range []string{
+		"",
+		"div,",
+		"a > > b",
+		"[href",
+		"[=x]",
+		":nth-child",
+		":nth-child(2n+)",
+		":nth-child(3",
+		":not(p",
+		":foo(x)",
+		"ns|div",
+		"div)",
+	} {
+		if _, err := ParseSelector(sel); err == nil {
+			t.Errorf("ParseSelector(%q) succeeded", sel)
+		}
+	}
+}
+
+func TestSpecificity(t *testing.T) {
+	tests := []struct {
+		sel  string
+		want int
+	}{
+		{"*", 0},
+		{"li", 1},
+		{"ul li", 2},
+		{".a", 1000},
+		{"li:first-child", 1001},
+		{"[href]", 1000},
+		{"#main", 1000000},
+		{"#main .a > li", 1001001},
+		{":where(#main) li", 1},
+		{":is(#main, .a) li", 1000001},
+		{":not(.a, li)", 1000},
+	}
+	for _, tt := range tests {
+		list, err := ParseSelector(tt.sel)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sel, err)
+			continue
+		}
+		if got := list[0].Specificity(); got != tt.want {
+			t.Errorf("specificity of %s = %d, want %d", tt.sel, got, tt.want)
+		}
+	}
+}
+
+func TestClosestAndMatches(t *testing.T) {
+	doc := selectorTree()
+	li := doc.GetElementById("i4")
+	got, err := li.Closest("div[lang]")
+	if err != nil || got == nil || got.GetAttribute("id") != "main" {
+		t.Errorf("Closest(div[lang]) = %v, %v", got, err)
+	}
+	if got, _ := li.Closest("li"); got != li {
+		t.Error("Closest does not start at the element itself")
+	}
+	if ok, err := li.Matches("ul > li.a:nth-child(4)"); !ok || err != nil {
+		t.Errorf("Matches = %v, %v", ok, err)
+	}
+	if _, err := li.Matches("li["); err == nil {
+		t.Error("Matches accepted an invalid selector")
+	}
+	byClass := doc.GetElementsByClassName("wide box")
+	if len(byClass) != 1 || byClass[0].GetAttribute("id") != "main" {
+		t.Errorf("GetElementsByClassName = %v", byClass)
+	}
+	var tags []string
+	for _, n := range doc.GetElementsByTagName("span") {
+		tags = append(tags, n.GetAttribute("id"))
+	}
+	if want := []string{"i3", "i5"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("GetElementsByTagName(span) = %v, want %v", tags, want)
+	}
+}
+
+func TestNamespaceSelectors(t *testing.T) {
+	doc := NewDocument(Element("html", AttrMap{"id": "root"}, []*Node{
+		Element("a", AttrMap{"id": "ha", "href": "/x"}, nil),
+		ElementNS(SVGNamespace, "svg", AttrMap{"id": "svg"}, []*Node{
+			ElementNS(SVGNamespace, "a", AttrMap{"id": "sa", "xlink:href": "#x"}, nil),
+			ElementNS(SVGNamespace, "clipPath", AttrMap{"id": "clip", "href": "#y"}, nil),
+		}),
+		ElementNS(MathMLNamespace, "math", AttrMap{"id": "math"}, nil),
+	}))
+	namespaces := map[string]string{"svg": SVGNamespace, "xl": XLinkNamespace}
+	withDefault := map[string]string{"": SVGNamespace, "h": HTMLNamespace}
+	tests := []struct {
+		sel        string
+		namespaces map[string]string
+		want       string
+	}{
+		{"a", nil, "ha sa"},
+		{"svg|a", namespaces, "sa"},
+		{"*|a", namespaces, "ha sa"},
+		{"|a", namespaces, ""},
+		{"svg|*", namespaces, "svg sa clip"},
+		// Foreign element names are case-sensitive.
+		{"clipPath", nil, "clip"},
+		{"clippath", nil, ""},
+		{"HTML", nil, "root"},
+		// The default namespace applies to type selectors only.
+		{"a", withDefault, "sa"},
+		{"h|a", withDefault, "ha"},
+		{"#math", withDefault, "math"},
+		{"a:link", nil, "ha"},
+		{"[href]", nil, "ha clip"},
+		{"[xl|href]", namespaces, "sa"},
+		{"[*|href]", namespaces, "ha sa clip"},
+		{"[|href]", namespaces, "ha clip"},
+	}
+	for _, tt := range tests {
+		list, err := ParseSelectorNS(tt.sel, tt.namespaces)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sel, err)
+			continue
+		}
+		var ids []string
+		doc.walkElements(func(n *Node) bool {
+			if list.Match(n) {
+				ids = append(ids, n.GetAttribute("id"))
+			}
+			return true
+		})
+		if got := strings.Join(ids, " "); got != tt.want {
+			t.Errorf("%s matches %q, want %q", tt.sel, got, tt.want)
+		}
+	}
+	for _, sel := range []string{"foo|a", "svg|", "svg|.x"} {
+		if _, err := ParseSelectorNS(sel, namespaces); err == nil {
+			t.Errorf("%s parsed", sel)
+		}
+	}
+}
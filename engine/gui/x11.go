@@ -25,10 +25,18 @@ type X11Window struct {
 type Event struct {
 	Type int
 	Key  byte
+	// Keycode is the raw X11 keycode of a KeyPress.
+	Keycode byte
+	// Button and pointer position of a ButtonPress, relative to the window.
+	Button int
+	X, Y   int
+	// Width and Height are the new size of the window on ConfigureNotify.
+	Width, Height int
 }
 
 const (
 	KeyPress        = 2
+	ButtonPress     = 4
 	Expose          = 12
 	MapNotify       = 19
 	ConfigureNotify = 22
@@ -126,7 +134,7 @@ func NewX11Window(width, height uint16) (*X11Window, error) {
 	binary.LittleEndian.PutUint32(createBuf[28:32], 0x806)
 	binary.LittleEndian.PutUint32(createBuf[32:36], 0xFFFFFF) // background white
 	binary.LittleEndian.PutUint32(createBuf[36:40], 0x000000) // border black
-	binary.LittleEndian.PutUint32(createBuf[40:44], 0x18001)  // KeyPress(1) | Exposure(0x8000) | StructureNotify(0x10000)
+	binary.LittleEndian.PutUint32(createBuf[40:44], 0x18005)  // KeyPress(1) | ButtonPress(4) | Exposure(0x8000) | StructureNotify(0x10000)
 
 	conn.Write(createBuf)
 
@@ -209,15 +217,31 @@ func (w *X11Window) PollEvent() *Event {
 	switch evType {
 	case KeyPress:
 		// ... key handling ...
-		return &Event{Type: KeyPress, Key: decodeKey(buf[1])}
+		return &Event{Type: KeyPress, Key: decodeKey(buf[1]), Keycode: buf[1]}
+	case ButtonPress:
+		return &Event{
+			Type:   ButtonPress,
+			Button: int(buf[1]),
+			X:      int(int16(binary.LittleEndian.Uint16(buf[24:26]))),
+			Y:      int(int16(binary.LittleEndian.Uint16(buf[26:28]))),
+		}
 	case Expose:
 		return &Event{Type: Expose}
 	case MapNotify:
 		return &Event{Type: MapNotify}
+	case ConfigureNotify:
+		w.width = binary.LittleEndian.Uint16(buf[20:22])
+		w.height = binary.LittleEndian.Uint16(buf[22:24])
+		return &Event{Type: ConfigureNotify, Width: int(w.width), Height: int(w.height)}
 	}
 	return nil
 }
 
+// Size returns the size of the window.
+func (w *X11Window) Size() (width, height int) {
+	return int(w.width), int(w.height)
+}
+
 func decodeKey(keycode byte) byte {
 	char := byte(0)
 	if keycode >= 24 && keycode <= 33 {
@@ -263,17 +287,28 @@ func decodeKey(keycode byte) byte {
 }
 
 func (w *X11Window) Draw(img *image.RGBA) error {
-	bounds := img.Bounds()
-	width := uint16(bounds.Dx())
-	height := uint16(bounds.Dy())
+	return w.DrawRect(img, img.Bounds())
+}
+
+// DrawRect copies the part r of img to the same place in the window.
+func (w *X11Window) DrawRect(img *image.RGBA, r image.Rectangle) error {
+	r = r.Intersect(img.Bounds())
+	if r.Empty() {
+		return nil
+	}
+	width := uint16(r.Dx())
+	height := uint16(r.Dy())
 
-	pix := img.Pix
 	bgrx := make([]byte, int(width)*int(height)*4)
-	for i := 0; i < int(width)*int(height); i++ {
-		r, g, b := pix[i*4], pix[i*4+1], pix[i*4+2]
-		bgrx[i*4] = b
-		bgrx[i*4+1] = g
-		bgrx[i*4+2] = r
+	i := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(r.Min.X, y):]
+		for x := 0; x < int(width); x++ {
+			bgrx[i] = row[x*4+2]
+			bgrx[i+1] = row[x*4+1]
+			bgrx[i+2] = row[x*4]
+			i += 4
+		}
 	}
 
 	headerLen := 6
@@ -286,6 +321,8 @@ func (w *X11Window) Draw(img *image.RGBA) error {
 	binary.LittleEndian.PutUint32(header[8:12], w.gcid)
 	binary.LittleEndian.PutUint16(header[12:14], width)
 	binary.LittleEndian.PutUint16(header[14:16], height)
+	binary.LittleEndian.PutUint16(header[16:18], uint16(r.Min.X-img.Rect.Min.X))
+	binary.LittleEndian.PutUint16(header[18:20], uint16(r.Min.Y-img.Rect.Min.Y))
 	header[21] = 24
 	w.conn.Write(header)
 	w.conn.Write(bgrx)
@@ -0,0 +1,429 @@
+package layout
+
+import "math"
+
+// layoutBlock lays out a block-level box in normal flow following CSS 2.1
+// sections 10.3.3 and 10.6.3.
+func (b *LayoutBox) layoutBlock(container Dimensions) {
+	// Width depends on the container; height depends on the children.
+	b.calculateBlockWidth(container)
+	b.calculateBlockPosition(container)
+	b.layoutContents()
+	b.calculateBlockHeight()
+}
+
+// layoutContents lays out the children in the formatting context the box
+// establishes for them and sets its content height.
+func (b *LayoutBox) layoutContents() {
+	switch b.StyledNode.Value("display") {
+	case "flex", "inline-flex":
+		b.layoutFlex()
+	case "grid", "inline-grid":
+		b.layoutGrid()
+	case "table", "inline-table":
+		if b.wrapped != nil {
+			b.layoutTableWrapper()
+		} else {
+			b.layoutTable()
+		}
+	default:
+		b.layoutBlockChildren()
+	}
+}
+
+// calculateBlockWidth resolves width, horizontal padding, borders and
+// margins so that they add up to the containing block's width.
+func (b *LayoutBox) calculateBlockWidth(container Dimensions) {
+	style := b.StyledNode
+	cbWidth := container.Content.Width
+	fontSize := style.FontSize()
+	zero := Length{Unit: "px"}
+
+	width := style.Length("width", autoLength)
+	marginLeft := style.Length("margin-left", zero)
+	marginRight := style.Length("margin-right", zero)
+	paddingLeft, borderLeft := b.edge(sideLeft, cbWidth)
+	paddingRight, borderRight := b.edge(sideRight, cbWidth)
+
+	d := &b.Dimensions
+	d.Padding.Left, d.Padding.Right = paddingLeft, paddingRight
+	d.Border.Left, d.Border.Right = borderLeft, borderRight
+
+	if b.override.hasWidth {
+		// A flex or grid container has sized the box; its auto margins count as 0.
+		d.Content.Width = b.override.width
+		d.Margin.Left, d.Margin.Right = marginLeft.ToPx(cbWidth, fontSize, style.viewportRect()), marginRight.ToPx(cbWidth, fontSize, style.viewportRect())
+		return
+	}
+
+	// Non-content parts that box-sizing: border-box folds into width.
+	frame := paddingLeft + paddingRight + borderLeft + borderRight
+	widthPx := float32(0)
+	if !width.IsAuto() {
+		widthPx = b.constrainWidth(b.contentSize(width.ToPx(cbWidth, fontSize, style.viewportRect()), frame), cbWidth, frame)
+	}
+
+	mlPx, mrPx := marginLeft.ToPx(cbWidth, fontSize, style.viewportRect()), marginRight.ToPx(cbWidth, fontSize, style.viewportRect())
+	total := widthPx + frame + mlPx + mrPx
+
+	// If width is not auto and the box is too wide, auto margins become 0.
+	if !width.IsAuto() && total > cbWidth {
+		if marginLeft.IsAuto() {
+			marginLeft, mlPx = zero, 0
+		}
+		if marginRight.IsAuto() {
+			marginRight, mrPx = zero, 0
+		}
+	}
+
+	underflow := cbWidth - total
+	rtl := style.Value("direction") == "rtl"
+	switch {
+	case !width.IsAuto() && !marginLeft.IsAuto() && !marginRight.IsAuto():
+		// Over-constrained: the margin on the end side absorbs the difference.
+		if rtl {
+			mlPx += underflow
+		} else {
+			mrPx += underflow
+		}
+	case !width.IsAuto() && marginLeft.IsAuto() && !marginRight.IsAuto():
+		mlPx = underflow
+	case !width.IsAuto() && !marginLeft.IsAuto() && marginRight.IsAuto():
+		mrPx = underflow
+	case !width.IsAuto():
+		// Both margins auto: center the box.
+		mlPx, mrPx = underflow/2, underflow/2
+	default:
+		if marginLeft.IsAuto() {
+			mlPx = 0
+		}
+		if marginRight.IsAuto() {
+			mrPx = 0
+		}
+		fill := cbWidth - frame - mlPx - mrPx
+		widthPx = b.constrainWidth(fill, cbWidth, frame)
+		if b.wrapped != nil {
+			widthPx = b.tableWrapperWidth(cbWidth, fill)
+		}
+		// The width of a table, max-width or min-width turns auto into a
+		// fixed width; resolve the margins again with it.
+		if widthPx != fill {
+			extra := fill - widthPx
+			switch {
+			case marginLeft.IsAuto() && marginRight.IsAuto():
+				mlPx += extra / 2
+				mrPx += extra / 2
+			case marginLeft.IsAuto():
+				mlPx += extra
+			case rtl:
+				mlPx += extra
+			default:
+				mrPx += extra
+			}
+		}
+	}
+
+	d.Content.Width = widthPx
+	d.Margin.Left, d.Margin.Right = mlPx, mrPx
+}
+
+// edge returns the padding and border widths of b at one side. In the
+// collapsing border model tables and cells get half of the collapsed border
+// at each side, and tables have no padding.
+func (b *LayoutBox) edge(side int, cbWidth float32) (padding, border float32) {
+	style := b.StyledNode
+	if b.collapsed != nil {
+		border = b.collapsed[side].width / 2
+		if style.isTable() {
+			return 0, border
+		}
+	} else {
+		border = style.BorderWidth(sides[side])
+	}
+	return style.Length("padding-"+sides[side], Length{Unit: "px"}).ToPx(cbWidth, style.FontSize(), style.viewportRect()), border
+}
+
+// frameX returns the left and right padding and borders of b.
+func (b *LayoutBox) frameX(cbWidth float32) float32 {
+	pl, bl := b.edge(sideLeft, cbWidth)
+	pr, br := b.edge(sideRight, cbWidth)
+	return pl + bl + pr + br
+}
+
+// contentSize converts a specified width or height to a content-box size,
+// honoring box-sizing: border-box.
+func (b *LayoutBox) contentSize(size, frame float32) float32 {
+	if b.StyledNode.Value("box-sizing") == "border-box" {
+		size -= frame
+	}
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
+// constrainWidth applies max-width and then min-width (CSS 2.1 10.4).
+func (b *LayoutBox) constrainWidth(width, cbWidth, frame float32) float32 {
+	style := b.StyledNode
+	fontSize := style.FontSize()
+	if l, ok := ParseLength(style.Value("max-width")); ok && !l.IsAuto() {
+		if max := b.contentSize(l.ToPx(cbWidth, fontSize, style.viewportRect()), frame); width > max {
+			width = max
+		}
+	}
+	if l, ok := ParseLength(style.Value("min-width")); ok && !l.IsAuto() {
+		if min := b.contentSize(l.ToPx(cbWidth, fontSize, style.viewportRect()), frame); width < min {
+			width = min
+		}
+	}
+	if width < 0 {
+		return 0
+	}
+	return width
+}
+
+// calculateBlockPosition resolves the vertical box edges and places the
+// content box below the previously laid out siblings.
+func (b *LayoutBox) calculateBlockPosition(container Dimensions) {
+	style := b.StyledNode
+	cbWidth := container.Content.Width
+	fontSize := style.FontSize()
+	zero := Length{Unit: "px"}
+	d := &b.Dimensions
+
+	// Vertical margins and padding percentages refer to the containing
+	// block's width too.
+	d.Margin.Top = style.Length("margin-top", zero).ToPx(cbWidth, fontSize, style.viewportRect())
+	d.Margin.Bottom = style.Length("margin-bottom", zero).ToPx(cbWidth, fontSize, style.viewportRect())
+	d.Padding.Top, d.Border.Top = b.edge(sideTop, cbWidth)
+	d.Padding.Bottom, d.Border.Bottom = b.edge(sideBottom, cbWidth)
+
+	d.Content.X = container.Content.X + d.Margin.Left + d.Border.Left + d.Padding.Left
+	// Position the box below all the previous boxes in the container.
+	d.Content.Y = container.Content.Y + container.Content.Height + d.Margin.Top + d.Border.Top + d.Padding.Top
+}
+
+// layoutBlockChildren stacks the children in the content box, collapsing
+// adjoining vertical margins, and sets the content height and the box's own
+// collapsed margins as seen by its parent. Floats go into the float context
+// of the block formatting context, and a box that establishes one grows to
+// contain them.
+func (b *LayoutBox) layoutBlockChildren() {
+	d := &b.Dimensions
+	style := b.StyledNode
+	bfc := b.establishesBFC()
+	topAdjoins := !bfc && d.Border.Top == 0 && d.Padding.Top == 0
+	bottomAdjoins := !bfc && d.Border.Bottom == 0 && d.Padding.Bottom == 0 &&
+		isAutoOrZero(style.Value("height")) && isAutoOrZero(style.Value("min-height"))
+
+	top := marginOf(d.Margin.Top)
+	var pending collapsedMargin
+	cursor := float32(0)
+	atTop := true
+	contentY := d.Content.Y
+
+	floats := b.floats
+	if bfc || floats == nil {
+		floats = &floatContext{}
+	}
+	// place moves a child into position along with the floats placed
+	// inside it, from index n on.
+	place := func(child *LayoutBox, y float32, n int) {
+		floats.shift(n, y-child.flowBorderBox().Y)
+		child.moveBorderBoxTo(y)
+	}
+
+	children := b.Children
+	if b.hasInlineContent() {
+		// An inline formatting context: line boxes separate the margins, and
+		// the block is empty only when no line takes up space.
+		children = nil
+		cursor = b.layoutLines(floats)
+		atTop = len(b.Lines) == 0
+	} else {
+		b.Lines = nil
+	}
+	for _, child := range children {
+		child.floats = nil
+		switch {
+		case child.isAbsolutelyPositioned():
+			// Its containing block lays it out once it knows its own size.
+			child.setStaticPosition(d.Content.X, contentY+cursor+pending.value())
+			continue
+		case child.isFloat():
+			child.layoutFloat(*d)
+			floats.placeFloat(child, contentY+cursor+pending.value(), d.Content.X, d.Content.X+d.Content.Width)
+			continue
+		}
+		avoids := child.establishesBFC() || child.StyledNode.isReplaced()
+		if !avoids {
+			child.floats = floats
+		}
+		n := len(floats.floats)
+		d.Content.Height = cursor
+		if !avoids && n > 0 {
+			// What flows around the floats depends on where the child ends
+			// up; lay it out where its own top margin most likely puts it.
+			mt := marginOf(child.StyledNode.Length("margin-top", Length{Unit: "px"}).ToPx(d.Content.Width, child.StyledNode.FontSize(), child.StyledNode.viewportRect()))
+			if atTop && topAdjoins {
+				d.Content.Height = -mt.value()
+			} else {
+				d.Content.Height = cursor + pending.join(mt).value() - mt.value()
+			}
+		}
+		child.Layout(*d)
+		m := pending.join(child.marginTop)
+		// Clearance puts the border box below the floats the child clears
+		// when its margins would leave it higher, and keeps its top margin
+		// from collapsing with the margins before it (CSS 2.1 9.5.2).
+		var offset float32
+		if !(atTop && topAdjoins) {
+			offset = cursor + m.value()
+		}
+		clear, cleared := floats.clearance(child.StyledNode.Value("clear"))
+		cleared = cleared && contentY+offset < clear
+		if child.collapsesThrough && !cleared {
+			if atTop && topAdjoins {
+				top = top.join(m).join(child.marginBottom)
+			} else {
+				pending = m.join(child.marginBottom)
+			}
+			place(child, contentY+offset, n)
+			continue
+		}
+		switch {
+		case cleared:
+			offset = clear - contentY
+		case atTop && topAdjoins:
+			// The first child's top margin becomes part of ours.
+			top = top.join(m)
+		}
+		if avoids && len(floats.floats) > 0 {
+			offset = b.avoidFloats(child, contentY+offset, floats) - contentY
+		}
+		place(child, contentY+offset, n)
+		cursor = offset + child.Dimensions.BorderBox().Height
+		pending = child.marginBottom
+		atTop = false
+	}
+
+	b.marginTop = top
+	b.marginBottom = marginOf(d.Margin.Bottom)
+	// An empty block's top and bottom margins adjoin, provided it ends up
+	// with zero height; calculateBlockHeight checks that.
+	b.collapsesThrough = atTop && topAdjoins && d.Border.Bottom == 0 && d.Padding.Bottom == 0 &&
+		isAutoOrZero(style.Value("min-height"))
+	if bottomAdjoins {
+		// The last child's bottom margin becomes part of ours.
+		b.marginBottom = b.marginBottom.join(pending)
+	} else {
+		cursor += pending.value()
+	}
+	if floats != b.floats {
+		if bottom, ok := floats.bottom(); ok {
+			cursor = max(cursor, bottom-contentY)
+		}
+	}
+	d.Content.Height = cursor
+}
+
+// avoidFloats lays child out next to the floats beside y, or below them when
+// it does not fit there, since the border box of a block formatting context
+// root in flow must not overlap them (CSS 2.1 9.5). It returns the top of
+// the child's border box.
+func (b *LayoutBox) avoidFloats(child *LayoutBox, y float32, floats *floatContext) float32 {
+	container := b.Dimensions
+	left, right := container.Content.X, container.Content.X+container.Content.Width
+	container.Content.Height = 0
+	for {
+		l, r := floats.band(y, child.Dimensions.BorderBox().Height, left, right)
+		container.Content.X, container.Content.Width = l, r-l
+		child.Layout(container)
+		next, ok := floats.nextBottom(y)
+		if !ok || l == left && r == right || child.Dimensions.MarginBox().Width <= r-l+layoutEpsilon {
+			return y
+		}
+		y = next
+	}
+}
+
+// moveBorderBoxTo shifts the box and its descendants vertically so that the
+// top of its border box is at y.
+func (b *LayoutBox) moveBorderBoxTo(y float32) {
+	if dy := y - b.flowBorderBox().Y; dy != 0 {
+		b.translate(0, dy)
+	}
+}
+
+func isAutoOrZero(v string) bool {
+	l, ok := ParseLength(v)
+	return !ok || l.IsAuto() || l.Value == 0
+}
+
+// calculateBlockHeight applies an explicit height and min/max-height.
+// Percentage heights are treated as auto since the containing block height
+// is not known in advance. The rows of a table already fill the height it
+// was given, which is only a minimum.
+func (b *LayoutBox) calculateBlockHeight() {
+	d := &b.Dimensions
+	switch h, ok := b.definiteHeight(); {
+	case b.StyledNode.isTable():
+	case ok:
+		d.Content.Height = h
+	default:
+		d.Content.Height = b.clampHeight(d.Content.Height)
+	}
+	if d.Content.Height != 0 {
+		b.collapsesThrough = false
+	}
+}
+
+// definiteHeight returns the content height of the box when it does not
+// depend on the content: one assigned by a flex container, or a height that
+// is not a percentage, within min-height and max-height. The vertical
+// padding and borders must be resolved.
+func (b *LayoutBox) definiteHeight() (float32, bool) {
+	if b.override.hasHeight {
+		return b.override.height, true
+	}
+	style := b.StyledNode
+	d := &b.Dimensions
+	frame := d.Padding.Top + d.Padding.Bottom + d.Border.Top + d.Border.Bottom
+	if l, ok := ParseLength(style.Value("height")); ok && !l.IsAuto() && l.Unit != "%" {
+		return b.clampHeight(b.contentSize(l.ToPx(0, style.FontSize(), style.viewportRect()), frame)), true
+	}
+	return 0, false
+}
+
+// clampHeight applies max-height and then min-height to a content height.
+func (b *LayoutBox) clampHeight(h float32) float32 {
+	d := &b.Dimensions
+	frame := d.Padding.Top + d.Padding.Bottom + d.Border.Top + d.Border.Bottom
+	lo, hi, _ := b.sizeLimits(false, 0, frame)
+	return max(lo, min(h, hi))
+}
+
+// sizeLimits returns the content sizes that min-width and max-width, or
+// min-height and max-height when horizontal is false, allow; hi is +Inf for
+// max-*: none. autoMin reports a min-* of auto, which flex items resolve to
+// an automatic minimum size. Percentage heights are ignored.
+func (b *LayoutBox) sizeLimits(horizontal bool, cbWidth, frame float32) (lo, hi float32, autoMin bool) {
+	style := b.StyledNode
+	fontSize := style.FontSize()
+	axis := "height"
+	if horizontal {
+		axis = "width"
+	}
+	hi = float32(math.Inf(1))
+	if l, ok := ParseLength(style.Value("max-" + axis)); ok && !l.IsAuto() && (horizontal || l.Unit != "%") {
+		hi = b.contentSize(l.ToPx(cbWidth, fontSize, style.viewportRect()), frame)
+	}
+	l, ok := ParseLength(style.Value("min-" + axis))
+	if !ok || l.IsAuto() {
+		return 0, hi, true
+	}
+	if horizontal || l.Unit != "%" {
+		lo = b.contentSize(l.ToPx(cbWidth, fontSize, style.viewportRect()), frame)
+	}
+	return lo, hi, false
+}
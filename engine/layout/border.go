@@ -0,0 +1,85 @@
+package layout
+
+import "strings"
+
+// BorderSide is the border painted along one side of a box.
+type BorderSide struct {
+	Width float32
+	// Style is a border-style keyword and Color the border color as
+	// specified; currentcolor or empty means the color of the text.
+	Style, Color string
+}
+
+// Borders returns the borders of b in the order top, right, bottom, left. In
+// the collapsing border model a table cell, and the table, paint the half of
+// the winning border on each grid line that lies inside them.
+func (b *LayoutBox) Borders() [4]BorderSide {
+	w := b.Dimensions.Border
+	widths := [4]float32{w.Top, w.Right, w.Bottom, w.Left}
+	var out [4]BorderSide
+	for i, side := range sides {
+		out[i].Width = widths[i]
+		if b.collapsed != nil {
+			out[i].Style, out[i].Color = b.collapsed[i].style, b.collapsed[i].color
+			continue
+		}
+		out[i].Style = b.StyledNode.Value("border-" + side + "-style")
+		out[i].Color = b.StyledNode.Value("border-" + side + "-color")
+	}
+	return out
+}
+
+// BorderRadii returns the horizontal and vertical radii of the corners of
+// the border box of b, top-left, top-right, bottom-right and bottom-left.
+// Percentages refer to the border box, and the radii are scaled down together
+// when adjacent ones would overlap (CSS Backgrounds 3 section 5.5). Tables in
+// the collapsing border model have square corners.
+func (b *LayoutBox) BorderRadii() [4][2]float32 {
+	var radii [4][2]float32
+	if b.collapsed != nil || b.BoxType == InlineNode {
+		return radii
+	}
+	style := b.StyledNode
+	bb := b.Dimensions.BorderBox()
+	for i, corner := range corners {
+		parts := splitValue(style.Value("border-" + corner + "-radius"))
+		if len(parts) == 0 || len(parts) > 2 {
+			continue
+		}
+		rx := radiusLength(parts[0], bb.Width, style.FontSize(), style.viewportRect())
+		ry := rx
+		if len(parts) == 2 {
+			ry = radiusLength(parts[1], bb.Height, style.FontSize(), style.viewportRect())
+		} else if strings.HasSuffix(parts[0], "%") {
+			ry = radiusLength(parts[0], bb.Height, style.FontSize(), style.viewportRect())
+		}
+		if rx > 0 && ry > 0 {
+			radii[i] = [2]float32{rx, ry}
+		}
+	}
+	f := float32(1)
+	fit := func(length, a, b float32) {
+		if a+b > length {
+			f = min(f, length/(a+b))
+		}
+	}
+	fit(bb.Width, radii[0][0], radii[1][0])
+	fit(bb.Height, radii[1][1], radii[2][1])
+	fit(bb.Width, radii[2][0], radii[3][0])
+	fit(bb.Height, radii[3][1], radii[0][1])
+	if f < 1 {
+		for i := range radii {
+			radii[i][0] *= f
+			radii[i][1] *= f
+		}
+	}
+	return radii
+}
+
+func radiusLength(v string, reference, fontSize float32, viewport Rect) float32 {
+	l, ok := ParseLength(v)
+	if !ok || l.IsAuto() {
+		return 0
+	}
+	return max(0, l.ToPx(reference, fontSize, viewport))
+}
@@ -0,0 +1,57 @@
+package layout
+
+import "testing"
+
+func TestBorderRadii(t *testing.T) {
+	// #b is 200 by 100 pixels.
+	tests := []struct {
+		css  string
+		want [4][2]float32
+	}{
+		{"", [4][2]float32{}},
+		{"border-radius: 10px;", [4][2]float32{{10, 10}, {10, 10}, {10, 10}, {10, 10}}},
+		{"border-radius: 10px 20px;", [4][2]float32{{10, 10}, {20, 20}, {10, 10}, {20, 20}}},
+		{"border-radius: 1px 2px 3px 4px;", [4][2]float32{{1, 1}, {2, 2}, {3, 3}, {4, 4}}},
+		{"border-radius: 10px / 5px;", [4][2]float32{{10, 5}, {10, 5}, {10, 5}, {10, 5}}},
+		{"border-radius: 10%;", [4][2]float32{{20, 10}, {20, 10}, {20, 10}, {20, 10}}},
+		{"border-top-left-radius: 10px 5px;", [4][2]float32{{10, 5}}},
+		// Radii that would overlap are scaled down together.
+		{"border-radius: 100px;", [4][2]float32{{50, 50}, {50, 50}, {50, 50}, {50, 50}}},
+		{"border-radius: 150px 50px 0 0;", [4][2]float32{{100, 100}, {100 / 3.0, 100 / 3.0}}},
+		// A corner with a zero radius along either axis is square.
+		{"border-radius: 10px / 0;", [4][2]float32{}},
+		{"border-radius: -5px;", [4][2]float32{}},
+		{"border-radius: 1px / 2px / 3px;", [4][2]float32{}},
+	}
+	for _, tt := range tests {
+		page := layoutPage(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#b { width: 200px; height: 100px; `+tt.css+` }
+</style></head><body><div id="b"></div></body></html>`, 300)
+		got := boxOf(t, page, "b").BorderRadii()
+		for i := range got {
+			for j := range got[i] {
+				if d := got[i][j] - tt.want[i][j]; d > 0.01 || d < -0.01 {
+					t.Errorf("%q: radii %v, want %v", tt.css, got, tt.want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestBorders(t *testing.T) {
+	page := layoutPage(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#b { border: 2px dashed red; border-left: 5px double; border-bottom-style: none; }
+</style></head><body><div id="b"></div></body></html>`, 300)
+	want := [4]BorderSide{
+		{2, "dashed", "red"},
+		{2, "dashed", "red"},
+		{0, "none", "red"},
+		{5, "double", "currentcolor"},
+	}
+	if got := boxOf(t, page, "b").Borders(); got != want {
+		t.Errorf("Borders = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+package layout
+
+import "prymis/engine/dom"
+
+// continuation records which edges of an inline box belong to other pieces.
+type continuation uint8
+
+const (
+	continuesBefore continuation = 1 << iota
+	continuesAfter
+)
+
+// boxTypeOf maps the display of a styled node to the box it generates. Text
+// is inline, and so is an element without a display value. The root element,
+// floats, absolutely positioned boxes (CSS 2.1 9.7) and the children of flex
+// and grid containers are blockified.
+func boxTypeOf(node *StyledNode) BoxType {
+	if node.Node.NodeType != dom.ElementNode {
+		return InlineNode
+	}
+	display := node.SpecifiedValues["display"]
+	if node.Parent == nil || node.isBlockified() {
+		switch display {
+		case "inline-flex":
+			display = "flex"
+		case "inline-grid":
+			display = "grid"
+		case "inline-table":
+			display = "table"
+		default:
+			display = "block"
+		}
+	}
+	switch display {
+	case "", "inline":
+		if node.isReplaced() {
+			return InlineBlockNode
+		}
+		return InlineNode
+	case "inline-block", "inline-flex", "inline-grid", "inline-table":
+		return InlineBlockNode
+	}
+	return BlockNode
+}
+
+func (s *StyledNode) isBlockified() bool {
+	if s.Parent != nil && s.Parent.isFlexOrGridContainer() {
+		return true
+	}
+	if f := s.Value("float"); f != "" && f != "none" {
+		return true
+	}
+	switch s.Value("position") {
+	case "absolute", "fixed":
+		return true
+	}
+	return false
+}
+
+func (s *StyledNode) isFlexOrGridContainer() bool {
+	switch s.Value("display") {
+	case "flex", "inline-flex", "grid", "inline-grid":
+		return true
+	}
+	return false
+}
+
+// wrapInlineRuns generates the anonymous boxes of a block container (CSS 2.1
+// 9.2.1.1). Inline boxes containing block-level boxes are split around them,
+// and when the container then has block-level children, every run of
+// inline-level boxes is wrapped in an anonymous block. Runs that hold only
+// collapsible white space would produce no line boxes and are dropped. In a
+// flex or grid container every run becomes an anonymous item. Floats and
+// absolutely positioned boxes take no part in this: they stay in the run
+// they are in, where lines flow around floats and the others get their
+// static position.
+func wrapInlineRuns(parent *StyledNode, children []*LayoutBox) []*LayoutBox {
+	children = splitInlines(children)
+	flex := parent.isFlexOrGridContainer()
+	hasBlock, hasInline := false, false
+	for _, child := range children {
+		if !child.inFlow() && !flex {
+			continue
+		}
+		if child.isInlineLevel() {
+			hasInline = true
+		} else {
+			hasBlock = true
+		}
+	}
+	if !hasInline || !hasBlock && !flex {
+		return children
+	}
+	var wrapped []*LayoutBox
+	var run *LayoutBox
+	flush := func() {
+		if run != nil && !isCollapsibleWhiteSpace(run.Children) {
+			wrapped = append(wrapped, run)
+		}
+		run = nil
+	}
+	for _, child := range children {
+		if !child.inFlow() && !flex && run != nil {
+			run.Children = append(run.Children, child)
+			continue
+		}
+		if !child.isInlineLevel() {
+			flush()
+			wrapped = append(wrapped, child)
+			continue
+		}
+		if run == nil {
+			run = &LayoutBox{BoxType: AnonymousBlock, StyledNode: anonymousStyle(parent)}
+		}
+		run.Children = append(run.Children, child)
+	}
+	flush()
+	return wrapped
+}
+
+// isCollapsibleWhiteSpace reports whether boxes are only text made of white
+// space that collapses away.
+func isCollapsibleWhiteSpace(boxes []*LayoutBox) bool {
+	for _, box := range boxes {
+		node := box.StyledNode.Node
+		if node.NodeType != dom.TextNode || !whiteSpaceOf(box.StyledNode).collapse {
+			return false
+		}
+		for _, r := range node.Text {
+			switch r {
+			case ' ', '\t', '\n', '\r', '\f':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splitInlines replaces the inline boxes among children that contain
+// block-level boxes by their pieces.
+func splitInlines(children []*LayoutBox) []*LayoutBox {
+	var out []*LayoutBox
+	for i, child := range children {
+		if child.BoxType == InlineNode && child.containsBlock() {
+			if out == nil {
+				out = append(out, children[:i]...)
+			}
+			out = append(out, child.split()...)
+		} else if out != nil {
+			out = append(out, child)
+		}
+	}
+	if out == nil {
+		return children
+	}
+	return out
+}
+
+func (b *LayoutBox) containsBlock() bool {
+	for _, child := range b.Children {
+		if !child.isInlineLevel() && child.inFlow() || child.BoxType == InlineNode && child.containsBlock() {
+			return true
+		}
+	}
+	return false
+}
+
+// split breaks an inline box around the block-level boxes it contains. It
+// returns copies of b holding the inline content before, between and after
+// them, interleaved with the block-level boxes, which become siblings of the
+// pieces. b itself is left untouched so that it can be split again when the
+// tree is rebuilt.
+func (b *LayoutBox) split() []*LayoutBox {
+	var pieces, inline []*LayoutBox
+	var current *LayoutBox
+	open := func() {
+		current = &LayoutBox{BoxType: InlineNode, StyledNode: b.StyledNode, continued: b.continued}
+		pieces = append(pieces, current)
+		inline = append(inline, current)
+	}
+	for _, child := range splitInlines(b.Children) {
+		if child.isInlineLevel() || !child.inFlow() {
+			if current == nil {
+				open()
+			}
+			current.Children = append(current.Children, child)
+			continue
+		}
+		if current == nil {
+			open()
+		}
+		pieces = append(pieces, child)
+		current = nil
+	}
+	if current == nil {
+		open()
+	}
+	for i, piece := range inline {
+		if i > 0 {
+			piece.continued |= continuesBefore
+		}
+		if i < len(inline)-1 {
+			piece.continued |= continuesAfter
+		}
+	}
+	return pieces
+}
+
+// anonymousStyle returns the style of an anonymous box inside parent: it
+// inherits what is inheritable and has initial values otherwise.
+func anonymousStyle(parent *StyledNode) *StyledNode {
+	values := make(map[string]string)
+	inherit(values, parent.SpecifiedValues)
+	values["display"] = "block"
+	return &StyledNode{Node: parent.Node, SpecifiedValues: values, Parent: parent}
+}
@@ -0,0 +1,734 @@
+package layout
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Side indexes into margins, in the order of sides.
+const (
+	sideTop = iota
+	sideRight
+	sideBottom
+	sideLeft
+)
+
+// layoutEpsilon absorbs rounding errors when sizes that should add up to an
+// available space are compared with it.
+const layoutEpsilon = 1.0 / 64
+
+// flexAxes describes the axes of a flex container: whether the main axis is
+// horizontal, and the sides at which the main and cross axes start.
+type flexAxes struct {
+	row                   bool
+	mainStart, mainEnd    int
+	crossStart, crossEnd  int
+	reverse, wrapReverse  bool
+	multiLine             bool
+	directionReverse, rtl bool
+}
+
+func flexAxesOf(style *StyledNode) flexAxes {
+	dir := style.Value("flex-direction")
+	wrap := style.Value("flex-wrap")
+	a := flexAxes{
+		row:              !strings.HasPrefix(dir, "column"),
+		directionReverse: strings.HasSuffix(dir, "-reverse"),
+		rtl:              style.Value("direction") == "rtl",
+		wrapReverse:      wrap == "wrap-reverse",
+		multiLine:        wrap == "wrap" || wrap == "wrap-reverse",
+	}
+	a.reverse = a.directionReverse
+	if a.row {
+		a.reverse = a.reverse != a.rtl
+		a.mainStart, a.crossStart = sideLeft, sideTop
+	} else {
+		a.mainStart, a.crossStart = sideTop, sideLeft
+		if a.rtl {
+			a.crossStart = sideRight
+		}
+	}
+	if a.reverse {
+		a.mainStart = (a.mainStart + 2) % 4
+	}
+	if a.wrapReverse {
+		a.crossStart = (a.crossStart + 2) % 4
+	}
+	a.mainEnd, a.crossEnd = (a.mainStart+2)%4, (a.crossStart+2)%4
+	return a
+}
+
+// flexItem is a flex item during layout. Sizes are content-box sizes along
+// the main and cross axes of the container.
+type flexItem struct {
+	box          *LayoutBox
+	grow, shrink float32
+	// base and hypo are the flex base size and the hypothetical main size.
+	base, hypo       float32
+	minMain, maxMain float32
+	main, cross      float32
+	frozen           bool
+	violation        float32
+
+	// margin holds the margins by side; auto ones are 0 until the free space
+	// they absorb is known.
+	margin [4]float32
+	auto   [4]bool
+	// mainExtra and crossExtra are the padding, borders and non-auto
+	// margins along each axis.
+	mainExtra, crossExtra float32
+	align                 string
+	// baseline is the distance from the top margin edge to the first
+	// baseline.
+	baseline          float32
+	mainPos, crossPos float32
+}
+
+// flexLine is a line of items, with its cross size and offset.
+type flexLine struct {
+	items      []*flexItem
+	cross, pos float32
+}
+
+// layoutFlex lays out the children of a flex container following CSS
+// Flexible Box Layout section 9 and sets its content height. The width and
+// the vertical edges of the container are already resolved.
+func (b *LayoutBox) layoutFlex() {
+	d := &b.Dimensions
+	style := b.StyledNode
+	a := flexAxesOf(style)
+	b.Lines = nil
+	b.marginTop, b.marginBottom = marginOf(d.Margin.Top), marginOf(d.Margin.Bottom)
+	b.collapsesThrough = false
+
+	container := *d
+	container.Content.Height = 0
+	width := d.Content.Width
+	height, definite := b.definiteHeight()
+	mainGap, crossGap := style.gap("column-gap", width), style.gap("row-gap", height)
+	if !a.row {
+		mainGap, crossGap = crossGap, mainGap
+	}
+
+	items := b.flexItems(container, a)
+	// The inner main size of the container, if it does not depend on the
+	// items, and the space lines break in.
+	mainSize, mainDefinite := width, true
+	if !a.row {
+		mainSize, mainDefinite = height, definite
+	}
+	for _, it := range items {
+		it.sizeMain(a, container, mainSize, mainDefinite)
+	}
+	avail := mainSize
+	if !mainDefinite {
+		_, avail, _ = b.sizeLimits(false, 0, d.Padding.Top+d.Padding.Bottom+d.Border.Top+d.Border.Bottom)
+	}
+
+	// Collect the items into lines (9.3).
+	lines := []*flexLine{{}}
+	used := float32(0)
+	for _, it := range items {
+		line := lines[len(lines)-1]
+		outer := it.hypo + it.mainExtra
+		if len(line.items) > 0 {
+			if a.multiLine && used+mainGap+outer > avail+layoutEpsilon {
+				line = &flexLine{}
+				lines = append(lines, line)
+				used = 0
+			} else {
+				used += mainGap
+			}
+		}
+		used += outer
+		line.items = append(line.items, it)
+	}
+	if !mainDefinite {
+		longest := float32(0)
+		for _, line := range lines {
+			longest = max(longest, line.hypotheticalSize(mainGap))
+		}
+		mainSize = b.clampHeight(longest)
+	}
+
+	// Resolve the main sizes, then the cross sizes of the items (9.4).
+	for _, line := range lines {
+		line.resolveLengths(mainSize - mainGap*float32(max(len(line.items)-1, 0)))
+		for _, it := range line.items {
+			it.sizeCross(a, container)
+		}
+		line.cross = line.crossSize(a)
+	}
+
+	crossSize := width
+	if a.row {
+		if definite {
+			crossSize = height
+		} else {
+			total := crossGap * float32(len(lines)-1)
+			for _, line := range lines {
+				total += line.cross
+			}
+			crossSize = b.clampHeight(total)
+		}
+	}
+	if !a.multiLine {
+		lines[0].cross = crossSize
+	}
+
+	// Distribute the cross space among the lines (9.4, align-content).
+	free := crossSize - crossGap*float32(len(lines)-1)
+	for _, line := range lines {
+		free -= line.cross
+	}
+	alignContent := flexAlignment(style.Value("align-content"), a.wrapReverse, false)
+	if alignContent == "stretch" || alignContent == "normal" || alignContent == "" {
+		if free > 0 && a.multiLine {
+			for _, line := range lines {
+				line.cross += free / float32(len(lines))
+			}
+		}
+		alignContent = "flex-start"
+	}
+	pos, between := distribute(alignContent, free, len(lines))
+	if !a.multiLine {
+		pos, between = 0, 0
+	}
+	justify := flexAlignment(style.Value("justify-content"), a.directionReverse, a.row && a.mainStart == sideRight)
+	for _, line := range lines {
+		line.pos = pos
+		pos += line.cross + crossGap + between
+		for _, it := range line.items {
+			it.stretch(a, line.cross, container)
+		}
+		line.alignMain(a, mainSize, mainGap, justify)
+		line.alignCross(a)
+	}
+
+	// Move the items into place, mirroring reversed axes.
+	for _, it := range items {
+		outerMain := it.main + it.mainExtra + it.autoMargins(a.mainStart, a.mainEnd)
+		outerCross := it.cross + it.crossExtra + it.autoMargins(a.crossStart, a.crossEnd)
+		mp, cp := it.mainPos, it.crossPos
+		if a.reverse {
+			mp = mainSize - mp - outerMain
+		}
+		if a.wrapReverse != (!a.row && a.rtl) {
+			cp = crossSize - cp - outerCross
+		}
+		x, y := mp, cp
+		if !a.row {
+			x, y = cp, mp
+		}
+		box := it.box
+		bb := box.flowBorderBox()
+		box.translate(d.Content.X+x+it.margin[sideLeft]-bb.X, d.Content.Y+y+it.margin[sideTop]-bb.Y)
+		box.Dimensions.Margin = EdgeSizes{
+			Top:    it.margin[sideTop],
+			Right:  it.margin[sideRight],
+			Bottom: it.margin[sideBottom],
+			Left:   it.margin[sideLeft],
+		}
+	}
+
+	d.Content.Height = crossSize
+	if !a.row {
+		d.Content.Height = mainSize
+	}
+}
+
+// flexItems returns the flex items of b in order-modified document order
+// with their edges resolved. Absolutely positioned children are not items;
+// their static position is the start of the content box.
+func (b *LayoutBox) flexItems(container Dimensions, a flexAxes) []*flexItem {
+	var items []*flexItem
+	cbWidth := container.Content.Width
+	for _, child := range b.Children {
+		style := child.StyledNode
+		switch style.Value("position") {
+		case "absolute", "fixed":
+			child.setStaticPosition(container.Content.X, container.Content.Y)
+			continue
+		}
+		it := &flexItem{
+			box:    child,
+			grow:   flexFactor(style.Value("flex-grow"), 0),
+			shrink: flexFactor(style.Value("flex-shrink"), 1),
+			align:  flexAlignment(style.Value("align-self"), a.wrapReverse, false),
+		}
+		if it.align == "auto" || it.align == "" {
+			it.align = flexAlignment(b.StyledNode.Value("align-items"), a.wrapReverse, false)
+		}
+		if it.align == "normal" || it.align == "" || it.align == "baseline" && !a.row {
+			it.align = "stretch"
+		}
+		child.resolveInlineEdges(cbWidth)
+		fontSize := style.FontSize()
+		for i, side := range sides {
+			m := style.Length("margin-"+side, Length{Unit: "px"})
+			it.auto[i] = m.IsAuto()
+			it.margin[i] = m.ToPx(cbWidth, fontSize, style.viewportRect())
+		}
+		cd := child.Dimensions
+		frameX := cd.Padding.Left + cd.Padding.Right + cd.Border.Left + cd.Border.Right
+		frameY := cd.Padding.Top + cd.Padding.Bottom + cd.Border.Top + cd.Border.Bottom
+		it.mainExtra, it.crossExtra = frameX, frameY
+		if !a.row {
+			it.mainExtra, it.crossExtra = frameY, frameX
+		}
+		it.mainExtra += it.margin[a.mainStart] + it.margin[a.mainEnd]
+		it.crossExtra += it.margin[a.crossStart] + it.margin[a.crossEnd]
+		items = append(items, it)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return orderOf(items[i].box.StyledNode) < orderOf(items[j].box.StyledNode)
+	})
+	return items
+}
+
+func orderOf(s *StyledNode) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s.Value("order")))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func flexFactor(v string, def float32) float32 {
+	if f, ok := parseNumber(v); ok && f >= 0 {
+		return f
+	}
+	return def
+}
+
+// gap returns the used value of row-gap or column-gap; normal is 0.
+func (s *StyledNode) gap(name string, reference float32) float32 {
+	l, ok := ParseLength(s.Value(name))
+	if !ok || l.IsAuto() {
+		return 0
+	}
+	return max(0, l.ToPx(reference, s.FontSize(), s.viewportRect()))
+}
+
+// frames returns the padding plus borders of the item across the width and
+// the height.
+func (it *flexItem) frames() (x, y float32) {
+	d := it.box.Dimensions
+	return d.Padding.Left + d.Padding.Right + d.Border.Left + d.Border.Right,
+		d.Padding.Top + d.Padding.Bottom + d.Border.Top + d.Border.Bottom
+}
+
+// sizeMain determines the flex base size and hypothetical main size of the
+// item (9.2) and its min and max main sizes, with the automatic minimum size
+// of section 4.5. For a column the cross size is settled first, since the
+// content height depends on it.
+func (it *flexItem) sizeMain(a flexAxes, container Dimensions, mainSize float32, mainDefinite bool) {
+	box := it.box
+	style := box.StyledNode
+	fontSize := style.FontSize()
+	cbWidth := container.Content.Width
+	frameX, frameY := it.frames()
+	mainFrame := frameX
+	if !a.row {
+		mainFrame = frameY
+		it.cross = it.initialWidth(a, cbWidth, frameX)
+	}
+
+	// The size the width or height property gives, if definite.
+	specified, hasSpecified := float32(0), false
+	if a.row {
+		if l := style.Length("width", autoLength); !l.IsAuto() {
+			specified, hasSpecified = box.contentSize(l.ToPx(cbWidth, fontSize, style.viewportRect()), mainFrame), true
+		}
+	} else if l := style.Length("height", autoLength); !l.IsAuto() && l.Unit != "%" {
+		specified, hasSpecified = box.contentSize(l.ToPx(0, fontSize, style.viewportRect()), mainFrame), true
+	}
+
+	// The content size: max-content width, or the height at the cross size.
+	var minContent, maxContent float32
+	content := func() {
+		if a.row {
+			minContent, maxContent = box.contentWidths()
+		} else {
+			box.layoutAssigned(container, sizeOverride{width: it.cross, hasWidth: true})
+			maxContent = box.Dimensions.Content.Height
+			minContent = maxContent
+		}
+	}
+
+	basis := strings.ToLower(strings.TrimSpace(style.Value("flex-basis")))
+	measured := false
+	if l, ok := ParseLength(basis); ok && !l.IsAuto() && (l.Unit != "%" || mainDefinite) {
+		it.base = box.contentSize(l.ToPx(mainSize, fontSize, style.viewportRect()), mainFrame)
+	} else if hasSpecified && basis != "content" {
+		it.base = specified
+	} else {
+		content()
+		measured = true
+		it.base = maxContent
+	}
+
+	lo, hi, autoMin := box.sizeLimits(a.row, cbWidth, mainFrame)
+	if autoMin {
+		switch style.Value("overflow") {
+		case "", "visible":
+			if !measured {
+				content()
+			}
+			suggestion := minContent
+			if hasSpecified {
+				suggestion = min(suggestion, specified)
+			}
+			lo = min(suggestion, hi)
+		}
+	}
+	it.minMain, it.maxMain = lo, hi
+	it.hypo = max(lo, min(it.base, hi))
+}
+
+// initialWidth returns the width of an item in a column before lines are
+// stretched: the container's width for an item that a single line will
+// stretch anyway, else its own width or the shrink-to-fit width.
+func (it *flexItem) initialWidth(a flexAxes, cbWidth, frameX float32) float32 {
+	box := it.box
+	style := box.StyledNode
+	if l := style.Length("width", autoLength); !l.IsAuto() {
+		return box.constrainWidth(box.contentSize(l.ToPx(cbWidth, style.FontSize(), style.viewportRect()), frameX), cbWidth, frameX)
+	}
+	fill := cbWidth - frameX - it.margin[sideLeft] - it.margin[sideRight]
+	if it.align == "stretch" && !a.multiLine && !it.auto[sideLeft] && !it.auto[sideRight] {
+		return box.constrainWidth(fill, cbWidth, frameX)
+	}
+	return box.constrainWidth(box.shrinkToFit(fill), cbWidth, frameX)
+}
+
+// layoutAssigned lays out the box in container with the size a flex or grid
+// container gave it. The cached layout is kept when neither changed.
+func (b *LayoutBox) layoutAssigned(container Dimensions, size sizeOverride) {
+	if b.override != size {
+		b.override = size
+		b.valid = false
+	}
+	b.Layout(container)
+}
+
+// hypotheticalSize returns the outer hypothetical main sizes of the items
+// of the line plus the gaps between them.
+func (l *flexLine) hypotheticalSize(gap float32) float32 {
+	size := gap * float32(max(len(l.items)-1, 0))
+	for _, it := range l.items {
+		size += it.hypo + it.mainExtra
+	}
+	return size
+}
+
+// resolveLengths distributes the free space of the line among its items
+// according to their flex factors (9.7) and sets their main sizes.
+func (l *flexLine) resolveLengths(space float32) {
+	grow := l.hypotheticalSize(0) < space
+	for _, it := range l.items {
+		it.main, it.frozen = it.hypo, false
+		factor := it.shrink
+		if grow {
+			factor = it.grow
+		}
+		if factor == 0 || grow && it.base > it.hypo || !grow && it.base < it.hypo {
+			it.frozen = true
+		}
+	}
+	remaining := func() float32 {
+		free := space
+		for _, it := range l.items {
+			if it.frozen {
+				free -= it.main + it.mainExtra
+			} else {
+				free -= it.base + it.mainExtra
+			}
+		}
+		return free
+	}
+	initial := remaining()
+	for {
+		var unfrozen []*flexItem
+		var factors, scaled float32
+		for _, it := range l.items {
+			if it.frozen {
+				continue
+			}
+			unfrozen = append(unfrozen, it)
+			if grow {
+				factors += it.grow
+			} else {
+				factors += it.shrink
+			}
+			scaled += it.shrink * it.base
+		}
+		if len(unfrozen) == 0 {
+			return
+		}
+		free := remaining()
+		if factors < 1 {
+			if f := initial * factors; abs(f) < abs(free) {
+				free = f
+			}
+		}
+		var total float32
+		for _, it := range unfrozen {
+			size := it.base
+			switch {
+			case grow:
+				size += free * it.grow / factors
+			case scaled > 0:
+				size += free * it.shrink * it.base / scaled
+			}
+			clamped := max(it.minMain, min(size, it.maxMain), 0)
+			it.violation = clamped - size
+			it.main = clamped
+			total += it.violation
+		}
+		for _, it := range unfrozen {
+			if total == 0 || total > 0 && it.violation > 0 || total < 0 && it.violation < 0 {
+				it.frozen = true
+			}
+		}
+	}
+}
+
+func abs(f float32) float32 {
+	return float32(math.Abs(float64(f)))
+}
+
+// sizeCross lays the item out at its main size and takes its hypothetical
+// cross size and baseline from the result.
+func (it *flexItem) sizeCross(a flexAxes, container Dimensions) {
+	box := it.box
+	if !a.row {
+		box.layoutAssigned(container, sizeOverride{width: it.cross, height: it.main, hasWidth: true, hasHeight: true})
+		return
+	}
+	box.layoutAssigned(container, sizeOverride{width: it.main, hasWidth: true})
+	it.cross = box.Dimensions.Content.Height
+	bb := box.Dimensions.BorderBox()
+	if y, ok := box.firstBaseline(); ok {
+		it.baseline = y - bb.Y + it.margin[sideTop]
+	} else {
+		it.baseline = bb.Height + it.margin[sideTop]
+	}
+}
+
+// crossSize returns the cross size of the line: that of its largest item,
+// or more when baseline-aligned items are offset against each other.
+func (l *flexLine) crossSize(a flexAxes) float32 {
+	var size, above, below float32
+	for _, it := range l.items {
+		outer := it.cross + it.crossExtra
+		if it.align == "baseline" && !a.wrapReverse {
+			above = max(above, it.baseline)
+			below = max(below, outer-it.baseline)
+		}
+		size = max(size, outer)
+	}
+	return max(size, above+below)
+}
+
+// stretch sizes an item with align-self: stretch and an auto cross size to
+// fill the cross size of its line.
+func (it *flexItem) stretch(a flexAxes, lineCross float32, container Dimensions) {
+	if it.align != "stretch" || it.auto[a.crossStart] || it.auto[a.crossEnd] {
+		return
+	}
+	box := it.box
+	style := box.StyledNode
+	frameX, frameY := it.frames()
+	if a.row {
+		if l := style.Length("height", autoLength); !l.IsAuto() && l.Unit != "%" {
+			return
+		}
+		lo, hi, _ := box.sizeLimits(false, 0, frameY)
+		it.cross = max(lo, min(lineCross-it.crossExtra, hi), 0)
+		box.layoutAssigned(container, sizeOverride{width: it.main, height: it.cross, hasWidth: true, hasHeight: true})
+		return
+	}
+	if !style.Length("width", autoLength).IsAuto() {
+		return
+	}
+	cbWidth := container.Content.Width
+	it.cross = box.constrainWidth(lineCross-it.crossExtra, cbWidth, frameX)
+	box.layoutAssigned(container, sizeOverride{width: it.cross, height: it.main, hasWidth: true, hasHeight: true})
+}
+
+// autoMargins returns the space given to the item's auto margins on two
+// sides.
+func (it *flexItem) autoMargins(start, end int) float32 {
+	var m float32
+	if it.auto[start] {
+		m += it.margin[start]
+	}
+	if it.auto[end] {
+		m += it.margin[end]
+	}
+	return m
+}
+
+// alignMain places the items of the line along the main axis: positive
+// free space goes to auto margins, or else is distributed by
+// justify-content (9.5).
+func (l *flexLine) alignMain(a flexAxes, mainSize, gap float32, justify string) {
+	free := mainSize - l.hypotheticalSize(gap)
+	autos := 0
+	for _, it := range l.items {
+		free += it.hypo - it.main
+		if it.auto[a.mainStart] {
+			autos++
+		}
+		if it.auto[a.mainEnd] {
+			autos++
+		}
+	}
+	if free > 0 && autos > 0 {
+		share := free / float32(autos)
+		for _, it := range l.items {
+			for _, side := range []int{a.mainStart, a.mainEnd} {
+				if it.auto[side] {
+					it.margin[side] = share
+				}
+			}
+		}
+		free = 0
+	}
+	pos, between := distribute(justify, free, len(l.items))
+	for _, it := range l.items {
+		it.mainPos = pos
+		pos += it.main + it.mainExtra + it.autoMargins(a.mainStart, a.mainEnd) + gap + between
+	}
+}
+
+// alignCross places the items of the line along the cross axis with auto
+// margins or align-self (9.6).
+func (l *flexLine) alignCross(a flexAxes) {
+	var above float32
+	for _, it := range l.items {
+		if it.align == "baseline" && !a.wrapReverse {
+			above = max(above, it.baseline)
+		}
+	}
+	for _, it := range l.items {
+		free := l.cross - it.cross - it.crossExtra
+		start, end := it.auto[a.crossStart], it.auto[a.crossEnd]
+		offset := float32(0)
+		switch {
+		case start || end:
+			if free > 0 {
+				if start && end {
+					it.margin[a.crossStart], it.margin[a.crossEnd] = free/2, free/2
+				} else if start {
+					it.margin[a.crossStart] = free
+				} else {
+					it.margin[a.crossEnd] = free
+				}
+			}
+		case it.align == "flex-end":
+			offset = free
+		case it.align == "center":
+			offset = free / 2
+		case it.align == "baseline" && !a.wrapReverse:
+			offset = above - it.baseline
+		}
+		it.crossPos = l.pos + offset
+	}
+}
+
+// flexAlignment reduces an alignment value to the values measured from the
+// start of the axis, flex-start and flex-end. start and end are the ends of
+// the axis in the writing mode, which are swapped when the flex direction
+// or wrapping is reversed; left and right only apply to a horizontal axis,
+// with leftIsEnd set when its start is on the right.
+func flexAlignment(v string, reversed, leftIsEnd bool) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "unsafe "), "safe ")
+	switch v {
+	case "start", "self-start", "left":
+		if reversed || v == "left" && leftIsEnd {
+			return "flex-end"
+		}
+		return "flex-start"
+	case "end", "self-end", "right":
+		if reversed || v == "right" && leftIsEnd {
+			return "flex-start"
+		}
+		return "flex-end"
+	case "first baseline":
+		return "baseline"
+	case "last baseline":
+		return "flex-end"
+	}
+	return v
+}
+
+// distribute returns the offset of the first of n subjects and the extra
+// space between adjacent ones when free space is distributed according to
+// justify-content or align-content. Negative free space makes the spacing
+// values fall back to flex-start or center.
+func distribute(value string, free float32, n int) (offset, between float32) {
+	if n == 0 {
+		return 0, 0
+	}
+	switch value {
+	case "flex-end":
+		return free, 0
+	case "center":
+		return free / 2, 0
+	case "space-between":
+		if free > 0 && n > 1 {
+			return 0, free / float32(n-1)
+		}
+	case "space-around":
+		if free < 0 {
+			return free / 2, 0
+		}
+		return free / float32(n) / 2, free / float32(n)
+	case "space-evenly":
+		if free < 0 {
+			return free / 2, 0
+		}
+		return free / float32(n+1), free / float32(n+1)
+	}
+	return 0, 0
+}
+
+// flexContentWidths returns the intrinsic widths of a flex container: the
+// items of a row sit side by side, a wrapping row can put each on a line of
+// its own at min-content, and the items of a column are stacked.
+func (b *LayoutBox) flexContentWidths() (minContent, maxContent float32) {
+	style := b.StyledNode
+	a := flexAxesOf(style)
+	n := 0
+	for _, child := range b.Children {
+		switch child.StyledNode.Value("position") {
+		case "absolute", "fixed":
+			continue
+		}
+		lo, hi := child.outerWidths()
+		n++
+		if !a.row {
+			minContent, maxContent = max(minContent, lo), max(maxContent, hi)
+			continue
+		}
+		maxContent += hi
+		if a.multiLine {
+			minContent = max(minContent, lo)
+		} else {
+			minContent += lo
+		}
+	}
+	if a.row && n > 1 {
+		gaps := style.gap("column-gap", 0) * float32(n-1)
+		maxContent += gaps
+		if !a.multiLine {
+			minContent += gaps
+		}
+	}
+	return minContent, maxContent
+}
@@ -0,0 +1,110 @@
+package layout
+
+import "testing"
+
+func TestFlexLayout(t *testing.T) {
+	const items = `<div id="c"><div id="a"></div><div id="b"></div></div>`
+	const three = `<div id="c"><div id="a"></div><div id="b"></div><div id="d"></div></div>`
+	tests := []struct {
+		name string
+		css  string
+		html string
+		want map[string]Rect
+	}{
+		{
+			name: "grow",
+			css:  "#a { flex: 1 0 50px; } #b { flex: 3 0 50px; }",
+			html: items,
+			want: map[string]Rect{"a": {0, 0, 100, 20}, "b": {100, 0, 200, 20}},
+		},
+		{
+			name: "shrink by base size",
+			css:  "#a { width: 300px; } #b { width: 100px; }",
+			html: items,
+			want: map[string]Rect{"a": {0, 0, 225, 20}, "b": {225, 0, 75, 20}},
+		},
+		{
+			name: "no shrink",
+			css:  "#a { width: 200px; flex-shrink: 0; } #b { width: 200px; }",
+			html: items,
+			want: map[string]Rect{"a": {0, 0, 200, 20}, "b": {200, 0, 100, 20}},
+		},
+		{
+			name: "max size frozen",
+			css:  "#a, #b { flex: 1 1 0; } #a { max-width: 50px; }",
+			html: items,
+			want: map[string]Rect{"a": {0, 0, 50, 20}, "b": {50, 0, 250, 20}},
+		},
+		{
+			name: "min size frozen",
+			css:  "#a, #b { width: 200px; } #a { min-width: 180px; }",
+			html: items,
+			want: map[string]Rect{"a": {0, 0, 180, 20}, "b": {180, 0, 120, 20}},
+		},
+		{
+			name: "wrap",
+			css:  "#c { flex-wrap: wrap; } #a, #b, #d { width: 120px; }",
+			html: three,
+			want: map[string]Rect{"b": {120, 0, 120, 20}, "d": {0, 20, 120, 20}},
+		},
+		{
+			name: "wrap with gaps",
+			css:  "#c { flex-wrap: wrap; gap: 5px 10px; } #a, #b, #d { width: 140px; }",
+			html: three,
+			want: map[string]Rect{"b": {150, 0, 140, 20}, "d": {0, 25, 140, 20}},
+		},
+		{
+			name: "no wrap overflows",
+			css:  "#a, #b, #d { width: 120px; flex-shrink: 0; }",
+			html: three,
+			want: map[string]Rect{"d": {240, 0, 120, 20}},
+		},
+		{
+			name: "space between",
+			css:  "#c { justify-content: space-between; } #a, #b { width: 50px; }",
+			html: items,
+			want: map[string]Rect{"a": {0, 0, 50, 20}, "b": {250, 0, 50, 20}},
+		},
+		{
+			name: "center",
+			css:  "#c { justify-content: center; } #a, #b { width: 50px; }",
+			html: items,
+			want: map[string]Rect{"a": {100, 0, 50, 20}},
+		},
+		{
+			name: "auto margin",
+			css:  "#a, #b { width: 50px; } #b { margin-left: auto; }",
+			html: items,
+			want: map[string]Rect{"b": {250, 0, 50, 20}},
+		},
+		{
+			name: "align and stretch",
+			css:  "#c { height: 100px; align-items: center; } #a, #b { width: 50px; } #b { height: auto; align-self: stretch; }",
+			html: items,
+			want: map[string]Rect{"a": {0, 40, 50, 20}, "b": {50, 0, 50, 100}},
+		},
+		{
+			name: "order",
+			css:  "#a, #b { width: 50px; } #b { order: -1; }",
+			html: items,
+			want: map[string]Rect{"a": {50, 0, 50, 20}, "b": {0, 0, 50, 20}},
+		},
+		{
+			name: "row reverse",
+			css:  "#c { flex-direction: row-reverse; } #a, #b { width: 50px; }",
+			html: items,
+			want: map[string]Rect{"a": {250, 0, 50, 20}, "b": {200, 0, 50, 20}},
+		},
+		{
+			name: "column grow",
+			css:  "#c { flex-direction: column; height: 200px; } #a { flex-grow: 1; height: 0; } #b { height: 50px; }",
+			html: items,
+			want: map[string]Rect{"a": {0, 0, 300, 150}, "b": {0, 150, 300, 50}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkBoxes(t, "#c { display: flex; } :where(#c > div) { height: 20px; }\n", tt.css, tt.html, tt.want)
+		})
+	}
+}
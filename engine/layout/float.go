@@ -0,0 +1,144 @@
+package layout
+
+// isFloat reports whether b is floated to the left or right.
+func (b *LayoutBox) isFloat() bool {
+	switch b.StyledNode.Value("float") {
+	case "left", "right":
+		return true
+	}
+	return false
+}
+
+// floatContext holds the floats placed so far in a block formatting context.
+// Line boxes get shorter beside them, and block formatting context roots in
+// flow are placed next to or below them. Rectangles are margin boxes in the
+// same coordinates as the layout.
+type floatContext struct {
+	floats []placedFloat
+	// top is the top of the last float placed: later floats go no higher.
+	top float32
+}
+
+type placedFloat struct {
+	rect  Rect
+	right bool
+}
+
+// band returns the left and right edges of the space the floats leave within
+// [left, right] along the vertical range [y, y+h).
+func (f *floatContext) band(y, h, left, right float32) (float32, float32) {
+	if f == nil {
+		return left, right
+	}
+	for _, fl := range f.floats {
+		r := fl.rect
+		if r.Height <= 0 || r.Y >= y+max(h, layoutEpsilon) || r.Y+r.Height <= y {
+			continue
+		}
+		if fl.right {
+			right = min(right, r.X)
+		} else {
+			left = max(left, r.X+r.Width)
+		}
+	}
+	return left, right
+}
+
+// nextBottom returns the highest float bottom below y, where the space beside
+// the floats changes.
+func (f *floatContext) nextBottom(y float32) (float32, bool) {
+	if f == nil {
+		return 0, false
+	}
+	next, ok := float32(0), false
+	for _, fl := range f.floats {
+		if b := fl.rect.Y + fl.rect.Height; b > y+layoutEpsilon && (!ok || b < next) {
+			next, ok = b, true
+		}
+	}
+	return next, ok
+}
+
+// clearance returns the bottom of the floats that a box with the given clear
+// value must be placed below, if there are any.
+func (f *floatContext) clearance(clear string) (float32, bool) {
+	if f == nil {
+		return 0, false
+	}
+	bottom, ok := float32(0), false
+	for _, fl := range f.floats {
+		switch {
+		case clear == "both", clear == "left" && !fl.right, clear == "right" && fl.right:
+			if b := fl.rect.Y + fl.rect.Height; !ok || b > bottom {
+				bottom, ok = b, true
+			}
+		}
+	}
+	return bottom, ok
+}
+
+// placeFloat puts box, a float that has been laid out, as high as possible
+// at or below y and as far to its side as possible between left and right
+// (CSS 2.1 9.5.1). A float that does not fit beside the earlier floats goes
+// below them.
+func (f *floatContext) placeFloat(box *LayoutBox, y, left, right float32) {
+	if bottom, ok := f.clearance(box.StyledNode.Value("clear")); ok {
+		y = max(y, bottom)
+	}
+	y = max(y, f.top)
+	mb := box.Dimensions.MarginBox()
+	side := box.StyledNode.Value("float") == "right"
+	for {
+		l, r := f.band(y, mb.Height, left, right)
+		next, ok := f.nextBottom(y)
+		if r-l >= mb.Width-layoutEpsilon || !ok {
+			if side {
+				l = r - mb.Width
+			}
+			f.floats = append(f.floats, placedFloat{rect: Rect{X: l, Y: y, Width: mb.Width, Height: mb.Height}, right: side})
+			f.top = y
+			bb := box.flowBorderBox()
+			m := box.Dimensions.Margin
+			box.translate(l+m.Left-bb.X, y+m.Top-bb.Y)
+			return
+		}
+		y = next
+	}
+}
+
+// shift moves the floats from index n on, which were placed inside a box
+// that has since been moved.
+func (f *floatContext) shift(n int, dy float32) {
+	for i := n; i < len(f.floats); i++ {
+		f.floats[i].rect.Y += dy
+	}
+	if n < len(f.floats) {
+		f.top += dy
+	}
+}
+
+// bottom returns the lowest float bottom, which a block formatting context
+// root grows to contain.
+func (f *floatContext) bottom() (float32, bool) {
+	return f.clearance("both")
+}
+
+// layoutFloat lays out a float in container, at its width or else shrunk to
+// fit (CSS 2.1 10.3.5). The float context places it afterwards.
+func (b *LayoutBox) layoutFloat(container Dimensions) {
+	cbWidth := container.Content.Width
+	container.Content.Height = 0
+	b.resolveInlineEdges(cbWidth)
+	d := b.Dimensions
+	frame := d.Padding.Left + d.Padding.Right + d.Border.Left + d.Border.Right
+	size := sizeOverride{hasWidth: true}
+	var ok bool
+	if size.width, ok = b.replacedOrSpecifiedWidth(cbWidth, frame); !ok {
+		size.width = b.constrainWidth(b.shrinkToFit(cbWidth-frame-d.Margin.Left-d.Margin.Right), cbWidth, frame)
+	}
+	if b.StyledNode.isReplaced() {
+		_, size.height = b.replacedSize(cbWidth)
+		size.hasHeight = true
+	}
+	b.layoutAssigned(container, size)
+}
@@ -0,0 +1,104 @@
+package layout
+
+import (
+	"strings"
+	"unicode"
+
+	"prymis/engine/text"
+)
+
+// fontMetrics are the vertical metrics of a style's primary font in pixels.
+type fontMetrics struct {
+	ascent, descent, lineGap, xHeight float32
+}
+
+// FontSpec returns the font that text in this style is set in.
+func (s *StyledNode) FontSpec() text.FontSpec {
+	style := strings.ToLower(s.Value("font-style"))
+	return text.FontSpec{
+		Families:      parseFontFamilies(s.Value("font-family")),
+		Size:          s.FontSize(),
+		Weight:        computeFontWeight(s.Value("font-weight"), ""),
+		Stretch:       fontStretch(s.Value("font-stretch")),
+		Italic:        style == "italic" || strings.HasPrefix(style, "oblique"),
+		LetterSpacing: spacing(s, "letter-spacing"),
+		WordSpacing:   spacing(s, "word-spacing"),
+		Faces:         s.fontSet(),
+	}
+}
+
+// fontSet returns the web fonts of the document s belongs to.
+func (s *StyledNode) fontSet() *text.FontSet {
+	for s.Parent != nil {
+		s = s.Parent
+	}
+	return s.fonts
+}
+
+// viewportRect returns the viewport of the document s belongs to, which
+// viewport-percentage lengths refer to.
+func (s *StyledNode) viewportRect() Rect {
+	for s.Parent != nil {
+		s = s.Parent
+	}
+	return s.viewport
+}
+
+// parseFontFamilies splits a font-family value into unquoted family names.
+func parseFontFamilies(v string) []string {
+	var families []string
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) >= 2 && (name[0] == '"' || name[0] == '\'') && name[len(name)-1] == name[0] {
+			name = name[1 : len(name)-1]
+		} else {
+			name = strings.Join(strings.Fields(name), " ")
+		}
+		if name != "" {
+			families = append(families, name)
+		}
+	}
+	return families
+}
+
+// metricsOf returns the font metrics of a style.
+func metricsOf(s *StyledNode) fontMetrics {
+	m := text.MetricsOf(s.FontSpec())
+	return fontMetrics{ascent: m.Ascent, descent: m.Descent, lineGap: m.LineGap, xHeight: m.XHeight}
+}
+
+// advances returns the horizontal advance of every rune of text in style s,
+// including kerning, letter-spacing and word-spacing.
+func advances(runes []rune, s *StyledNode) []float32 {
+	return text.Advances(runes, s.FontSpec())
+}
+
+// spacing resolves letter-spacing or word-spacing; normal is 0.
+func spacing(s *StyledNode, name string) float32 {
+	l, ok := ParseLength(s.Value(name))
+	if !ok || l.IsAuto() || l.Unit == "%" {
+		return 0
+	}
+	return l.ToPx(0, s.FontSize(), s.viewportRect())
+}
+
+// lineHeight returns the used line-height of a style in pixels.
+func lineHeight(s *StyledNode, m fontMetrics) float32 {
+	v := strings.TrimSpace(s.Value("line-height"))
+	if v == "" || v == "normal" {
+		return m.ascent + m.descent + m.lineGap
+	}
+	if l, ok := ParseLength(v); ok && !l.IsAuto() {
+		return l.ToPx(s.FontSize(), s.FontSize(), s.viewportRect())
+	}
+	if f, ok := parseNumber(v); ok && f >= 0 {
+		return f * s.FontSize()
+	}
+	return m.ascent + m.descent + m.lineGap
+}
+
+// isWide reports whether r is a full-width East Asian character.
+func isWide(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0xff01 && r <= 0xff60) || (r >= 0x3000 && r <= 0x303f)
+}
@@ -0,0 +1,243 @@
+package layout
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"prymis/engine/text"
+)
+
+var (
+	errBadDataURL = errors.New("layout: malformed data URL")
+	errNoFetcher  = errors.New("layout: no resource fetcher")
+)
+
+// Fetcher loads a subresource of a page by its absolute URL, such as a
+// linked style sheet or the font file of an @font-face rule. Without one
+// only data: URLs and local() sources load.
+type Fetcher func(url string) ([]byte, error)
+
+// loadFontFaces adds the web fonts declared by the @font-face rules of
+// sheets to set, loading font files with fetch. A rule whose sources all
+// fail to load is ignored.
+func loadFontFaces(sheets []styleSheet, set *text.FontSet, fetch Fetcher) {
+	for _, sheet := range sheets {
+		for _, rule := range sheet.rules {
+			if rule.AtRule != "font-face" {
+				continue
+			}
+			desc := make(map[string]string)
+			for _, d := range rule.Declarations {
+				desc[strings.ToLower(d.Name)] = d.Value
+			}
+			families := parseFontFamilies(desc["font-family"])
+			if len(families) != 1 {
+				continue
+			}
+			font := loadFontSource(desc["src"], sheet.base, fetch)
+			if font == nil {
+				continue
+			}
+			style := strings.ToLower(strings.TrimSpace(desc["font-style"]))
+			set.Add(&text.Face{
+				Family:  families[0],
+				Font:    font,
+				Weight:  weightRange(desc["font-weight"]),
+				Stretch: stretchRange(desc["font-stretch"]),
+				Italic:  style == "italic" || strings.HasPrefix(style, "oblique"),
+				Ranges:  parseUnicodeRange(desc["unicode-range"]),
+			})
+		}
+	}
+}
+
+// loadFontSource returns the first font of a src descriptor that loads:
+// local(name) refers to an installed font, url(...) to a font file relative
+// to base, whose format() hint, if any, must be one that text can parse.
+func loadFontSource(src, base string, fetch Fetcher) *text.Font {
+	for _, item := range splitTopLevel(src, ',') {
+		item = strings.TrimSpace(item)
+		lower := strings.ToLower(item)
+		switch {
+		case strings.HasPrefix(lower, "local("):
+			if f := text.LocalFont(unquote(functionArg(item))); f != nil {
+				return f
+			}
+		case strings.HasPrefix(lower, "url("):
+			if i := strings.Index(lower, "format("); i >= 0 && !supportedFontFormat(unquote(functionArg(item[i:]))) {
+				continue
+			}
+			data, err := fetchURL(resolveURL(base, unquote(functionArg(item))), fetch)
+			if err != nil {
+				continue
+			}
+			if f, err := text.ParseFontData(data); err == nil {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+func supportedFontFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "truetype", "opentype", "woff", "collection":
+		return true
+	}
+	return false
+}
+
+// fetchURL decodes a data: URL or loads any other URL with fetch.
+func fetchURL(u string, fetch Fetcher) ([]byte, error) {
+	if strings.HasPrefix(strings.ToLower(u), "data:") {
+		comma := strings.IndexByte(u, ',')
+		if comma < 0 {
+			return nil, errBadDataURL
+		}
+		meta, payload := u[5:comma], u[comma+1:]
+		if strings.HasSuffix(strings.ToLower(meta), ";base64") {
+			payload = strings.Join(strings.Fields(payload), "")
+			if b, err := base64.StdEncoding.DecodeString(payload); err == nil {
+				return b, nil
+			}
+			return base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+		}
+		s, err := url.PathUnescape(payload)
+		return []byte(s), err
+	}
+	if fetch == nil {
+		return nil, errNoFetcher
+	}
+	return fetch(u)
+}
+
+// functionArg returns the argument of the first function call in s, such
+// as the URL of url(...).
+func functionArg(s string) string {
+	open := strings.IndexByte(s, '(')
+	if open < 0 {
+		return ""
+	}
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return strings.TrimSpace(s[open+1 : i])
+			}
+		}
+	}
+	return strings.TrimSpace(s[open+1:])
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// splitTopLevel splits s at sep outside of strings and parentheses.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// weightRange parses the font-weight descriptor: one weight or a range.
+func weightRange(v string) [2]int {
+	fields := strings.Fields(v)
+	switch len(fields) {
+	case 1:
+		w := computeFontWeight(fields[0], "")
+		return [2]int{w, w}
+	case 2:
+		lo, hi := computeFontWeight(fields[0], ""), computeFontWeight(fields[1], "")
+		return [2]int{min(lo, hi), max(lo, hi)}
+	}
+	return [2]int{400, 400}
+}
+
+// stretchRange parses the font-stretch descriptor: one width or a range.
+func stretchRange(v string) [2]float32 {
+	fields := strings.Fields(v)
+	switch len(fields) {
+	case 1:
+		s := fontStretch(fields[0])
+		return [2]float32{s, s}
+	case 2:
+		lo, hi := fontStretch(fields[0]), fontStretch(fields[1])
+		return [2]float32{min(lo, hi), max(lo, hi)}
+	}
+	return [2]float32{100, 100}
+}
+
+var fontStretchKeywords = map[string]float32{
+	"ultra-condensed": 50, "extra-condensed": 62.5, "condensed": 75,
+	"semi-condensed": 87.5, "normal": 100, "semi-expanded": 112.5,
+	"expanded": 125, "extra-expanded": 150, "ultra-expanded": 200,
+}
+
+// fontStretch resolves a font-stretch keyword or percentage.
+func fontStretch(v string) float32 {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if s, ok := fontStretchKeywords[v]; ok {
+		return s
+	}
+	if strings.HasSuffix(v, "%") {
+		if f, err := strconv.ParseFloat(v[:len(v)-1], 32); err == nil && f > 0 {
+			return float32(f)
+		}
+	}
+	return 100
+}
+
+// parseUnicodeRange parses a unicode-range descriptor such as
+// "U+0000-00FF, U+4??"; an empty value means every character.
+func parseUnicodeRange(v string) []text.RuneRange {
+	var ranges []text.RuneRange
+	for _, item := range strings.Split(v, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if !strings.HasPrefix(item, "U+") {
+			continue
+		}
+		item = item[2:]
+		lo, hi := item, item
+		if i := strings.IndexByte(item, '-'); i >= 0 {
+			lo, hi = item[:i], item[i+1:]
+		} else if strings.Contains(item, "?") {
+			lo, hi = strings.ReplaceAll(item, "?", "0"), strings.ReplaceAll(item, "?", "F")
+		}
+		l, err1 := strconv.ParseUint(lo, 16, 32)
+		h, err2 := strconv.ParseUint(hi, 16, 32)
+		if err1 != nil || err2 != nil || l > h {
+			continue
+		}
+		ranges = append(ranges, text.RuneRange{Lo: rune(l), Hi: rune(h)})
+	}
+	return ranges
+}
@@ -0,0 +1,213 @@
+package layout
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"prymis/engine/dom"
+	"prymis/engine/parser"
+	"prymis/engine/text"
+)
+
+// serveFiles returns a fetcher that loads files by URL, and the URLs it has
+// fetched so far.
+func serveFiles(files map[string]string) (Fetcher, *[]string) {
+	var fetched []string
+	return func(url string) ([]byte, error) {
+		fetched = append(fetched, url)
+		if data, ok := files[url]; ok {
+			return []byte(data), nil
+		}
+		return nil, fmt.Errorf("%s not found", url)
+	}, &fetched
+}
+
+func serifFont(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile("../text/fonts/DejaVuSerif.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func openPage(html string, rules []parser.StyleRule, url string, fetch Fetcher) *Document {
+	return NewDocument(dom.NewDocument(parser.NewHTMLParser(html).Parse()), rules, url, fetch)
+}
+
+// familyOf returns the family text in the document of s is set in when it
+// asks for family.
+func familyOf(s *StyledNode, family string) string {
+	spec := s.FontSpec()
+	spec.Families = []string{family}
+	return text.Select(spec).Family()
+}
+
+func TestFontFacesPerDocument(t *testing.T) {
+	const page = `<html><head><style>
+@font-face { font-family: Web; src: url(web.ttf); }
+</style></head><body>text</body></html>`
+	fetch, _ := serveFiles(map[string]string{"https://a.test/web.ttf": serifFont(t)})
+	withFaces := openPage(page, nil, "https://a.test/", fetch)
+	defer withFaces.Close()
+	without := openPage(`<html><body>text</body></html>`, nil, "https://b.test/", fetch)
+	defer without.Close()
+	// Each document loads its resources through its own fetcher.
+	other, fetched := serveFiles(nil)
+	elsewhere := openPage(page, nil, "https://a.test/", other)
+	defer elsewhere.Close()
+	noFetcher := openPage(page, nil, "https://a.test/", nil)
+	defer noFetcher.Close()
+
+	if got := familyOf(withFaces.Style, "Web"); got != "DejaVu Serif" {
+		t.Errorf("page declaring Web uses %q", got)
+	}
+	if got := familyOf(without.Style, "Web"); got != "DejaVu Sans" {
+		t.Errorf("other page uses %q for Web", got)
+	}
+	for _, d := range []*Document{elsewhere, noFetcher} {
+		if got := familyOf(d.Style, "Web"); got != "DejaVu Sans" {
+			t.Errorf("page whose font does not load uses %q for Web", got)
+		}
+	}
+	if want := []string{"https://a.test/web.ttf"}; !reflect.DeepEqual(*fetched, want) {
+		t.Errorf("other fetcher fetched %q, want %q", *fetched, want)
+	}
+	// Text outside any document, such as the browser interface, only sees
+	// installed fonts.
+	if got := text.Select(text.FontSpec{Families: []string{"Web"}, Size: 16}).Family(); got != "DejaVu Sans" {
+		t.Errorf("text without a document uses %q for Web", got)
+	}
+}
+
+func TestFontFaceURLs(t *testing.T) {
+	const face = `@font-face { font-family: Web; src: url(%s) format("truetype"); }`
+	tests := []struct {
+		name   string
+		url    string
+		html   string
+		author string
+		sheets map[string]string
+		font   string
+	}{
+		{
+			name: "style element",
+			url:  "https://a.test/dir/page.html",
+			html: `<style>` + fmt.Sprintf(face, "'fonts/web.ttf'") + `</style>`,
+			font: "https://a.test/dir/fonts/web.ttf",
+		},
+		{
+			name: "base element",
+			url:  "https://a.test/dir/page.html",
+			html: `<base href="https://cdn.test/x/"><style>` + fmt.Sprintf(face, "web.ttf") + `</style>`,
+			font: "https://cdn.test/x/web.ttf",
+		},
+		{
+			name:   "linked sheet",
+			url:    "https://a.test/dir/page.html",
+			html:   `<link rel="preload stylesheet" href="css/site.css">`,
+			sheets: map[string]string{"https://a.test/dir/css/site.css": fmt.Sprintf(face, "../fonts/web.ttf")},
+			font:   "https://a.test/dir/fonts/web.ttf",
+		},
+		{
+			name:   "author sheet",
+			url:    "https://a.test/dir/page.html",
+			author: fmt.Sprintf(face, "/web.ttf"),
+			font:   "https://a.test/web.ttf",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{tt.font: serifFont(t)}
+			for url, css := range tt.sheets {
+				files[url] = css
+			}
+			fetch, _ := serveFiles(files)
+			page := openPage(`<html><head>`+tt.html+`</head><body>text</body></html>`,
+				parser.NewCSSParser(tt.author).Parse(), tt.url, fetch)
+			defer page.Close()
+			if got := familyOf(page.Style, "Web"); got != "DejaVu Serif" {
+				t.Errorf("Web is set in %q", got)
+			}
+		})
+	}
+}
+
+func TestPageStyleSheets(t *testing.T) {
+	fetch, fetched := serveFiles(map[string]string{
+		"https://a.test/linked.css": "#p { height: 30px; }",
+		"https://a.test/icon.css":   "#p { color: red; }",
+	})
+	author := parser.NewCSSParser("#p { width: 100px; height: 10px; }").Parse()
+	page := openPage(`<html><head>
+<style>#p { width: 50px; }</style>
+<style type="text/plain">#p { padding: 5px; }</style>
+<link rel="stylesheet" href="linked.css">
+<link rel="icon" href="icon.css">
+</head><body><p id="p">x</p></body></html>`, author, "https://a.test/", fetch)
+	defer page.Close()
+
+	p := page.nodes[page.DOM.GetElementById("p")]
+	for name, want := range map[string]string{"width": "50px", "height": "30px", "padding-left": "", "color": ""} {
+		if got := p.SpecifiedValues[name]; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if want := []string{"https://a.test/linked.css"}; !reflect.DeepEqual(*fetched, want) {
+		t.Errorf("fetched %q, want %q", *fetched, want)
+	}
+}
+
+func TestFontFaceDescriptors(t *testing.T) {
+	ranges := []struct {
+		in   string
+		want []text.RuneRange
+	}{
+		{"", nil},
+		{"U+41", []text.RuneRange{{Lo: 0x41, Hi: 0x41}}},
+		{"u+0000-00ff", []text.RuneRange{{Lo: 0, Hi: 0xff}}},
+		{"U+4??", []text.RuneRange{{Lo: 0x400, Hi: 0x4ff}}},
+		{"U+0-7F, U+2000-206F", []text.RuneRange{{Lo: 0, Hi: 0x7f}, {Lo: 0x2000, Hi: 0x206f}}},
+		{"U+FF-00, U+ZZ, 41", nil},
+	}
+	for _, tt := range ranges {
+		if got := parseUnicodeRange(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseUnicodeRange(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	weights := []struct {
+		in   string
+		want [2]int
+	}{
+		{"", [2]int{400, 400}},
+		{"bold", [2]int{700, 700}},
+		{"300", [2]int{300, 300}},
+		{"100 900", [2]int{100, 900}},
+		{"900 100", [2]int{100, 900}},
+		{"normal bold", [2]int{400, 700}},
+	}
+	for _, tt := range weights {
+		if got := weightRange(tt.in); got != tt.want {
+			t.Errorf("weightRange(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	stretches := []struct {
+		in   string
+		want [2]float32
+	}{
+		{"", [2]float32{100, 100}},
+		{"condensed", [2]float32{75, 75}},
+		{"50% 200%", [2]float32{50, 200}},
+		{"expanded semi-condensed", [2]float32{87.5, 125}},
+		{"-10%", [2]float32{100, 100}},
+	}
+	for _, tt := range stretches {
+		if got := stretchRange(tt.in); got != tt.want {
+			t.Errorf("stretchRange(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,1271 @@
+package layout
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxGridTracks bounds the tracks that repeat() and placement can create.
+const maxGridTracks = 1000
+
+// trackKind is the kind of a track sizing function.
+type trackKind uint8
+
+const (
+	trackFixed trackKind = iota
+	trackAuto
+	trackMinContent
+	trackMaxContent
+	trackFitContent
+	trackFlex
+)
+
+type trackSize struct {
+	kind   trackKind
+	length Length  // of fixed and fit-content sizes
+	fr     float32 // of flexible sizes
+}
+
+// trackSizing holds the min and max track sizing functions of a track.
+type trackSizing struct {
+	min, max trackSize
+}
+
+var autoTrack = trackSizing{trackSize{kind: trackAuto}, trackSize{kind: trackAuto}}
+
+// parseTrackSize parses a track size: a breadth, minmax() or fit-content().
+// A flexible size alone stands for minmax(auto, <flex>).
+func parseTrackSize(tok string) (trackSizing, bool) {
+	lower := strings.ToLower(tok)
+	switch {
+	case strings.HasPrefix(lower, "minmax("):
+		args := splitTopLevel(functionArg(tok), ',')
+		if len(args) != 2 {
+			return trackSizing{}, false
+		}
+		lo, ok1 := parseTrackBreadth(args[0])
+		hi, ok2 := parseTrackBreadth(args[1])
+		if !ok1 || !ok2 || lo.kind == trackFlex {
+			return trackSizing{}, false
+		}
+		return trackSizing{lo, hi}, true
+	case strings.HasPrefix(lower, "fit-content("):
+		l, ok := ParseLength(functionArg(tok))
+		if !ok || l.IsAuto() {
+			return trackSizing{}, false
+		}
+		return trackSizing{trackSize{kind: trackAuto}, trackSize{kind: trackFitContent, length: l}}, true
+	}
+	s, ok := parseTrackBreadth(tok)
+	if !ok {
+		return trackSizing{}, false
+	}
+	if s.kind == trackFlex {
+		return trackSizing{trackSize{kind: trackAuto}, s}, true
+	}
+	return trackSizing{s, s}, true
+}
+
+func parseTrackBreadth(v string) (trackSize, bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	switch v {
+	case "auto":
+		return trackSize{kind: trackAuto}, true
+	case "min-content":
+		return trackSize{kind: trackMinContent}, true
+	case "max-content":
+		return trackSize{kind: trackMaxContent}, true
+	}
+	if strings.HasSuffix(v, "fr") {
+		if f, ok := parseNumber(v[:len(v)-2]); ok && f >= 0 {
+			return trackSize{kind: trackFlex, fr: f}, true
+		}
+		return trackSize{}, false
+	}
+	if l, ok := ParseLength(v); ok && !l.IsAuto() && l.Value >= 0 {
+		return trackSize{kind: trackFixed, length: l}, true
+	}
+	return trackSize{}, false
+}
+
+// trackList is a parsed grid-template-rows or grid-template-columns value.
+type trackList struct {
+	tracks []trackSizing
+	// names holds the names of each line: one more than there are tracks,
+	// or two more when an auto repetition splits the line where it goes.
+	names [][]string
+	// repeat is the track list of repeat(auto-fill, ...) or
+	// repeat(auto-fit, ...), which goes before track at as many times as it
+	// fits.
+	repeat  *trackList
+	at      int
+	autoFit bool
+}
+
+func parseTrackList(v string) (*trackList, bool) {
+	l := &trackList{names: [][]string{nil}}
+	if v = strings.TrimSpace(v); v == "" || strings.EqualFold(v, "none") {
+		return l, true
+	}
+	for _, tok := range gridTokens(v) {
+		lower := strings.ToLower(tok)
+		switch {
+		case tok[0] == '[':
+			last := len(l.names) - 1
+			l.names[last] = append(l.names[last], strings.Fields(strings.Trim(tok, "[]"))...)
+		case strings.HasPrefix(lower, "repeat("):
+			args := splitTopLevel(functionArg(tok), ',')
+			if len(args) != 2 {
+				return nil, false
+			}
+			inner, ok := parseTrackList(args[1])
+			if !ok || inner.repeat != nil || len(inner.tracks) == 0 {
+				return nil, false
+			}
+			switch count := strings.ToLower(strings.TrimSpace(args[0])); count {
+			case "auto-fill", "auto-fit":
+				if l.repeat != nil {
+					return nil, false
+				}
+				l.repeat, l.at, l.autoFit = inner, len(l.tracks), count == "auto-fit"
+				l.names = append(l.names, nil)
+			default:
+				n, err := strconv.Atoi(count)
+				if err != nil || n < 1 {
+					return nil, false
+				}
+				for i := 0; i < n && len(l.tracks) < maxGridTracks; i++ {
+					l.appendList(inner)
+				}
+			}
+		default:
+			t, ok := parseTrackSize(tok)
+			if !ok {
+				return nil, false
+			}
+			l.tracks = append(l.tracks, t)
+			l.names = append(l.names, nil)
+		}
+	}
+	return l, true
+}
+
+// appendList appends the tracks of o, whose first line is l's last one.
+func (l *trackList) appendList(o *trackList) {
+	last := len(l.names) - 1
+	l.names[last] = append(append([]string(nil), l.names[last]...), o.names[0]...)
+	l.tracks = append(l.tracks, o.tracks...)
+	for _, names := range o.names[1:] {
+		l.names = append(l.names, append([]string(nil), names...))
+	}
+}
+
+// expand returns the track list with its auto repetition inserted count
+// times, and the range of tracks that the repetitions make up.
+func (l *trackList) expand(count int) (out *trackList, from, to int) {
+	if l.repeat == nil {
+		return l, 0, 0
+	}
+	out = &trackList{names: [][]string{nil}}
+	out.appendList(&trackList{tracks: l.tracks[:l.at], names: l.names[:l.at+1]})
+	for i := 0; i < count; i++ {
+		out.appendList(l.repeat)
+	}
+	out.appendList(&trackList{tracks: l.tracks[l.at:], names: l.names[l.at+1:]})
+	return out, l.at, l.at + count*len(l.repeat.tracks)
+}
+
+// autoRepetitions returns how many times the auto repetition fits in avail
+// along with the other tracks and the gaps, counting each track at its max
+// sizing function if that is fixed and its min otherwise; at least once.
+func (l *trackList) autoRepetitions(avail float32, definite bool, gap, fontSize float32, viewport Rect) int {
+	if l.repeat == nil || !definite {
+		return 1
+	}
+	fixed := func(t trackSizing) (float32, bool) {
+		switch {
+		case t.max.kind == trackFixed:
+			return t.max.length.ToPx(avail, fontSize, viewport), true
+		case t.min.kind == trackFixed:
+			return t.min.length.ToPx(avail, fontSize, viewport), true
+		}
+		return 0, false
+	}
+	var other, repeated float32
+	for _, t := range l.tracks {
+		v, _ := fixed(t)
+		other += v
+	}
+	for _, t := range l.repeat.tracks {
+		v, ok := fixed(t)
+		if !ok {
+			return 1
+		}
+		repeated += v
+	}
+	if repeated+gap <= 0 {
+		return 1
+	}
+	n, r := len(l.tracks), len(l.repeat.tracks)
+	count := 1
+	for count*r < maxGridTracks {
+		next := count + 1
+		if other+repeated*float32(next)+gap*float32(n+next*r-1) > avail+layoutEpsilon {
+			break
+		}
+		count = next
+	}
+	return count
+}
+
+// gridTokens splits a track list into sizes, functions, bracketed line
+// names and quoted strings.
+func gridTokens(s string) []string {
+	var toks []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch c {
+		case ' ', '\t', '\n', '\r', '\f':
+			i++
+			continue
+		case '[', '"', '\'':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			j := strings.IndexByte(s[i+1:], closing)
+			end := len(s)
+			if j >= 0 {
+				end = i + 1 + j + 1
+			}
+			toks = append(toks, s[i:end])
+			i = end
+			continue
+		}
+		j, depth := i, 0
+	scan:
+		for ; j < len(s); j++ {
+			switch s[j] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ' ', '\t', '\n', '\r', '\f', '[':
+				if depth <= 0 {
+					break scan
+				}
+			}
+		}
+		toks = append(toks, s[i:j])
+		i = j
+	}
+	return toks
+}
+
+// gridArea is a range of tracks, from line start to line end.
+type gridArea struct {
+	start, end int
+}
+
+// clamp limits a to the first maxGridTracks tracks, keeping it at least one
+// track long.
+func (a gridArea) clamp() gridArea {
+	a.start = max(0, min(a.start, maxGridTracks-1))
+	a.end = max(a.start+1, min(a.end, maxGridTracks))
+	return a
+}
+
+// parseGridAreas parses grid-template-areas into the column and row ranges
+// of each named area, and the number of columns and rows the strings span.
+// Rows of different lengths and areas that are not rectangles make the
+// value invalid.
+func parseGridAreas(v string) (areas map[string][2]gridArea, cols, rows int) {
+	var cells [][]string
+	for _, tok := range gridTokens(v) {
+		if tok[0] != '"' && tok[0] != '\'' {
+			if strings.EqualFold(tok, "none") {
+				continue
+			}
+			return nil, 0, 0
+		}
+		row := gridAreaCells(strings.Trim(tok, `"'`))
+		if len(cells) > 0 && len(row) != len(cells[0]) || len(row) == 0 {
+			return nil, 0, 0
+		}
+		cells = append(cells, row)
+	}
+	if len(cells) == 0 {
+		return nil, 0, 0
+	}
+	areas = make(map[string][2]gridArea)
+	count := make(map[string]int)
+	for r, row := range cells {
+		for c, name := range row {
+			if name == "." {
+				continue
+			}
+			count[name]++
+			a, ok := areas[name]
+			if !ok {
+				a = [2]gridArea{{c, c + 1}, {r, r + 1}}
+			}
+			a[0] = gridArea{min(a[0].start, c), max(a[0].end, c+1)}
+			a[1] = gridArea{min(a[1].start, r), max(a[1].end, r+1)}
+			areas[name] = a
+		}
+	}
+	for name, a := range areas {
+		if (a[0].end-a[0].start)*(a[1].end-a[1].start) != count[name] {
+			return nil, 0, 0
+		}
+	}
+	return areas, len(cells[0]), len(cells)
+}
+
+// gridAreaCells splits a row of grid-template-areas into names, with every
+// run of dots a null cell ".".
+func gridAreaCells(row string) []string {
+	var cells []string
+	var cur strings.Builder
+	dots := false
+	flush := func() {
+		if cur.Len() > 0 {
+			if dots {
+				cells = append(cells, ".")
+			} else {
+				cells = append(cells, cur.String())
+			}
+			cur.Reset()
+		}
+	}
+	for _, r := range row {
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f':
+			flush()
+			continue
+		case (r == '.') != dots:
+			flush()
+			dots = r == '.'
+		}
+		cur.WriteRune(r)
+	}
+	flush()
+	return cells
+}
+
+// gridLine is a parsed grid-row-start, grid-column-end or the like.
+type gridLine struct {
+	auto  bool
+	index int // line number, negative counting from the end; 0 when absent
+	span  int // for span values
+	name  string
+}
+
+func parseGridLine(v string) gridLine {
+	var l gridLine
+	span := false
+	for _, f := range strings.Fields(strings.TrimSpace(v)) {
+		lower := strings.ToLower(f)
+		if lower == "span" {
+			span = true
+			continue
+		}
+		if n, err := strconv.Atoi(f); err == nil {
+			l.index = n
+			continue
+		}
+		if lower == "auto" {
+			return gridLine{auto: true}
+		}
+		l.name = f
+	}
+	l.index = max(-maxGridTracks, min(l.index, maxGridTracks))
+	switch {
+	case span:
+		l.span = max(l.index, 1)
+		l.index = 0
+	case l.index == 0 && l.name == "":
+		return gridLine{auto: true}
+	}
+	return l
+}
+
+// gridTrack is a track of the implicit grid.
+type gridTrack struct {
+	sizing      trackSizing
+	base, limit float32
+	collapsed   bool
+	pos         float32
+}
+
+// gridAxis holds the columns or the rows of a grid container.
+type gridAxis struct {
+	list *trackList // the explicit tracks
+	auto []trackSizing
+	gap  float32
+	// offset counts the implicit tracks before the explicit grid, and
+	// fitFrom and fitTo are the explicit tracks that auto-fit may collapse.
+	offset         int
+	fitFrom, fitTo int
+	templateTracks int
+	tracks         []*gridTrack
+	// reference is what percentages refer to when definite is set.
+	reference float32
+	definite  bool
+	fontSize  float32
+	viewport  Rect
+}
+
+// newGridAxis reads the explicit tracks of an axis, "columns" or "rows",
+// extended to the tracks that grid-template-areas spans, whose implicit
+// line names it adds.
+func newGridAxis(style *StyledNode, name string, avail float32, definite bool, gap float32, areas map[string][2]gridArea, areaTracks, axis int) *gridAxis {
+	g := &gridAxis{gap: gap, reference: avail, definite: definite, fontSize: style.FontSize(), viewport: style.viewportRect()}
+	list, ok := parseTrackList(style.Value("grid-template-" + name))
+	if !ok {
+		list = &trackList{names: [][]string{nil}}
+	}
+	if list.repeat != nil {
+		var fit [2]int
+		count := list.autoRepetitions(avail, definite, gap, style.FontSize(), style.viewportRect())
+		autoFit := list.autoFit
+		list, fit[0], fit[1] = list.expand(count)
+		if autoFit {
+			g.fitFrom, g.fitTo = fit[0], fit[1]
+		}
+	}
+	for _, tok := range gridTokens(style.Value("grid-auto-" + name)) {
+		if t, ok := parseTrackSize(tok); ok {
+			g.auto = append(g.auto, t)
+		}
+	}
+	g.list = list
+	g.templateTracks = len(list.tracks)
+	for len(list.tracks) < areaTracks {
+		list.tracks = append(list.tracks, g.implicitTrack(len(list.tracks)-g.templateTracks))
+		list.names = append(list.names, nil)
+	}
+	for area, a := range areas {
+		list.names[a[axis].start] = append(list.names[a[axis].start], area+"-start")
+		list.names[a[axis].end] = append(list.names[a[axis].end], area+"-end")
+	}
+	return g
+}
+
+// implicitTrack returns the size of the i-th track after the explicit grid,
+// or before it when i is negative, from grid-auto-rows or -columns.
+func (g *gridAxis) implicitTrack(i int) trackSizing {
+	n := len(g.auto)
+	if n == 0 {
+		return autoTrack
+	}
+	return g.auto[(i%n+n)%n]
+}
+
+// line resolves a line value to a line index counted from the start of the
+// explicit grid. Named lines missing from the grid are assumed to be
+// implicit lines past its end.
+func (g *gridAxis) line(l gridLine, side string) (int, bool) {
+	if l.auto || l.span > 0 {
+		return 0, false
+	}
+	explicit := len(g.list.tracks) + 1
+	if l.name == "" {
+		if l.index > 0 {
+			return l.index - 1, true
+		}
+		return explicit + l.index, true
+	}
+	n := l.index
+	names := []string{l.name}
+	if n == 0 {
+		n = 1
+		// A plain name first refers to the start or end line of an area.
+		names = []string{l.name + "-" + side, l.name}
+	}
+	for _, name := range names {
+		var found []int
+		for i, lineNames := range g.list.names {
+			for _, ln := range lineNames {
+				if ln == name {
+					found = append(found, i)
+					break
+				}
+			}
+		}
+		if len(found) == 0 {
+			continue
+		}
+		if n > 0 {
+			if n <= len(found) {
+				return found[n-1], true
+			}
+			return explicit - 1 + n - len(found), true
+		}
+		if -n <= len(found) {
+			return found[len(found)+n], true
+		}
+		return n + len(found), true
+	}
+	if n > 0 {
+		return explicit - 1 + n, true
+	}
+	return n, true
+}
+
+// place resolves the placement of an item along the axis (CSS Grid section
+// 8.3). It reports whether the position is definite; the span is known
+// either way.
+func (g *gridAxis) place(start, end gridLine) (a gridArea, definite bool, span int) {
+	s, sok := g.line(start, "start")
+	e, eok := g.line(end, "end")
+	switch {
+	case sok && eok:
+		if e < s {
+			s, e = e, s
+		}
+		if e == s {
+			e++
+		}
+		return gridArea{s, e}, true, e - s
+	case sok:
+		span = max(end.span, 1)
+		return gridArea{s, s + span}, true, span
+	case eok:
+		span = max(start.span, 1)
+		return gridArea{e - span, e}, true, span
+	}
+	// Of two spans the one of the end line is ignored.
+	span = start.span
+	if span == 0 {
+		span = max(end.span, 1)
+	}
+	return gridArea{0, span}, false, span
+}
+
+// gridItem is a grid item during layout.
+type gridItem struct {
+	box      *LayoutBox
+	area     [2]gridArea // columns and rows
+	definite [2]bool
+	span     [2]int
+	margin   [4]float32
+	auto     [4]bool
+	// height is the margin box height at the width of the item's columns.
+	height   float32
+	baseline float32
+	justify  string
+	align    string
+}
+
+// layoutGrid lays out the children of a grid container following CSS Grid
+// Layout and sets its content height. The width and the vertical edges of
+// the container are already resolved.
+func (b *LayoutBox) layoutGrid() {
+	d := &b.Dimensions
+	style := b.StyledNode
+	b.Lines = nil
+	b.marginTop, b.marginBottom = marginOf(d.Margin.Top), marginOf(d.Margin.Bottom)
+	b.collapsesThrough = false
+
+	width := d.Content.Width
+	height, definite := b.definiteHeight()
+	container := *d
+	container.Content.Height = 0
+	for _, child := range b.Children {
+		switch child.StyledNode.Value("position") {
+		case "absolute", "fixed":
+			child.setStaticPosition(container.Content.X, container.Content.Y)
+		}
+	}
+	axes, items := b.gridPlacement(width, true, height, definite)
+	cols, rows := axes[0], axes[1]
+
+	cols.sizeTracks(items, 0, width, true, false, func(it *gridItem) (float32, float32) {
+		return it.box.outerWidths()
+	})
+	cols.align(width, style.Value("justify-content"))
+	rtl := style.Value("direction") == "rtl"
+
+	// Lay the items out at the width of their columns to learn their
+	// heights.
+	for _, it := range items {
+		it.layoutWidth(cols.span(it.area[0]), container)
+	}
+	rows.sizeTracks(items, 1, height, definite, false, func(it *gridItem) (float32, float32) {
+		return it.height, it.height
+	})
+	if !definite {
+		height = b.clampHeight(rows.total())
+	}
+	rows.align(height, style.Value("align-content"))
+
+	// Baseline-aligned items share the baseline of their first row.
+	baselines := make(map[int]float32)
+	for _, it := range items {
+		if it.align == "baseline" {
+			baselines[it.area[1].start] = max(baselines[it.area[1].start], it.baseline)
+		}
+	}
+	for _, it := range items {
+		areaW, areaH := cols.span(it.area[0]), rows.span(it.area[1])
+		it.stretch(areaH, container)
+		box := it.box
+		bb := box.flowBorderBox()
+		outerW := bb.Width + it.margin[sideLeft] + it.margin[sideRight]
+		outerH := bb.Height + it.margin[sideTop] + it.margin[sideBottom]
+		x := it.alignIn(areaW-outerW, sideLeft, sideRight, it.justify, 0)
+		y := it.alignIn(areaH-outerH, sideTop, sideBottom, it.align, baselines[it.area[1].start]-it.baseline)
+		x += cols.tracks[it.area[0].start].pos
+		y += rows.tracks[it.area[1].start].pos
+		outerW = bb.Width + it.margin[sideLeft] + it.margin[sideRight]
+		if rtl {
+			x = width - x - outerW
+		}
+		box.translate(d.Content.X+x+it.margin[sideLeft]-bb.X, d.Content.Y+y+it.margin[sideTop]-bb.Y)
+		box.Dimensions.Margin = EdgeSizes{
+			Top:    it.margin[sideTop],
+			Right:  it.margin[sideRight],
+			Bottom: it.margin[sideBottom],
+			Left:   it.margin[sideLeft],
+		}
+	}
+	d.Content.Height = height
+}
+
+// gridPlacement builds the axes of the grid and places the items in them.
+func (b *LayoutBox) gridPlacement(width float32, widthDefinite bool, height float32, definite bool) ([2]*gridAxis, []*gridItem) {
+	style := b.StyledNode
+	areas, areaCols, areaRows := parseGridAreas(style.Value("grid-template-areas"))
+	rowRef := float32(0)
+	if definite {
+		rowRef = height
+	}
+	axes := [2]*gridAxis{
+		newGridAxis(style, "columns", width, widthDefinite, style.gap("column-gap", width), areas, areaCols, 0),
+		newGridAxis(style, "rows", height, definite, style.gap("row-gap", rowRef), areas, areaRows, 1),
+	}
+	items := b.gridItems(axes)
+	placeGridItems(items, axes, style.Value("grid-auto-flow"))
+	for axis, g := range axes {
+		g.buildTracks(items, axis)
+	}
+	return axes, items
+}
+
+// gridItems returns the grid items of b in order-modified document order
+// with their line-based placement resolved. Absolutely positioned children
+// are not items.
+func (b *LayoutBox) gridItems(axes [2]*gridAxis) []*gridItem {
+	parent := b.StyledNode
+	var items []*gridItem
+	for _, child := range b.Children {
+		style := child.StyledNode
+		switch style.Value("position") {
+		case "absolute", "fixed":
+			continue
+		}
+		it := &gridItem{
+			box:     child,
+			justify: gridAlignment(style.Value("justify-self"), parent.Value("justify-items"), style),
+			align:   gridAlignment(style.Value("align-self"), parent.Value("align-items"), style),
+		}
+		for axis, name := range [2]string{"grid-column", "grid-row"} {
+			start := parseGridLine(style.Value(name + "-start"))
+			end := parseGridLine(style.Value(name + "-end"))
+			it.area[axis], it.definite[axis], it.span[axis] = axes[axis].place(start, end)
+			it.span[axis] = min(it.span[axis], maxGridTracks)
+		}
+		items = append(items, it)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return orderOf(items[i].box.StyledNode) < orderOf(items[j].box.StyledNode)
+	})
+
+	// Lines before the explicit grid add implicit tracks at the start. The
+	// grid then keeps to its first maxGridTracks tracks.
+	for axis, g := range axes {
+		for _, it := range items {
+			if it.definite[axis] {
+				g.offset = max(g.offset, -it.area[axis].start)
+			}
+		}
+		g.offset = min(g.offset, maxGridTracks)
+		for _, it := range items {
+			if it.definite[axis] {
+				it.area[axis].start += g.offset
+				it.area[axis].end += g.offset
+				it.area[axis] = it.area[axis].clamp()
+			}
+		}
+	}
+	return items
+}
+
+// gridAlignment resolves justify-self or align-self against the container's
+// justify-items or align-items to start, end, center, stretch or baseline.
+// normal stretches boxes that are not replaced elements.
+func gridAlignment(self, items string, style *StyledNode) string {
+	v := strings.ToLower(strings.TrimSpace(self))
+	if v == "" || v == "auto" {
+		v = strings.ToLower(strings.TrimSpace(items))
+	}
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "legacy "), "unsafe ")
+	v = strings.TrimPrefix(v, "safe ")
+	switch v {
+	case "start", "self-start", "flex-start", "left":
+		return "start"
+	case "end", "self-end", "flex-end", "right", "last baseline":
+		return "end"
+	case "center", "stretch":
+		return v
+	case "baseline", "first baseline":
+		return "baseline"
+	}
+	if style.isReplaced() {
+		return "start"
+	}
+	return "stretch"
+}
+
+// placeGridItems runs the grid item placement algorithm (CSS Grid section
+// 8.5): items with a definite position first, then those locked to a row
+// (or column), then the rest, in sparse or dense packing.
+func placeGridItems(items []*gridItem, axes [2]*gridAxis, flow string) {
+	major, minor := 1, 0
+	if strings.Contains(flow, "column") {
+		major, minor = 0, 1
+	}
+	dense := strings.Contains(flow, "dense")
+
+	occupied := make(map[[2]int]bool)
+	fits := func(a [2]gridArea) bool {
+		for c := a[0].start; c < a[0].end; c++ {
+			for r := a[1].start; r < a[1].end; r++ {
+				if occupied[[2]int{c, r}] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	occupy := func(it *gridItem) {
+		for c := it.area[0].start; c < it.area[0].end; c++ {
+			for r := it.area[1].start; r < it.area[1].end; r++ {
+				occupied[[2]int{c, r}] = true
+			}
+		}
+	}
+	minorCount := axes[minor].offset + len(axes[minor].list.tracks)
+	for _, it := range items {
+		if it.definite[minor] {
+			minorCount = max(minorCount, it.area[minor].end)
+		} else {
+			minorCount = max(minorCount, it.span[minor])
+		}
+		if it.definite[0] && it.definite[1] {
+			occupy(it)
+		}
+	}
+	minorCount = min(minorCount, maxGridTracks)
+
+	cursors := make(map[int]int)
+	for _, it := range items {
+		if !it.definite[major] || it.definite[minor] {
+			continue
+		}
+		c := 0
+		if !dense {
+			c = cursors[it.area[major].start]
+		}
+		for ; ; c++ {
+			it.area[minor] = gridArea{c, c + it.span[minor]}
+			if fits(it.area) || c >= maxGridTracks {
+				break
+			}
+		}
+		it.area[minor] = it.area[minor].clamp()
+		occupy(it)
+		cursors[it.area[major].start] = it.area[minor].end
+	}
+
+	var cur [2]int
+	for _, it := range items {
+		if it.definite[major] {
+			continue
+		}
+		if dense {
+			cur = [2]int{}
+		}
+		if it.definite[minor] {
+			if !dense && it.area[minor].start < cur[minor] {
+				cur[major]++
+			}
+			cur[minor] = it.area[minor].start
+			for {
+				it.area[major] = gridArea{cur[major], cur[major] + it.span[major]}
+				if fits(it.area) || cur[major] >= maxGridTracks {
+					break
+				}
+				cur[major]++
+			}
+		} else {
+			for {
+				if cur[minor] > 0 && cur[minor]+it.span[minor] > minorCount {
+					cur[major]++
+					cur[minor] = 0
+					continue
+				}
+				it.area[minor] = gridArea{cur[minor], cur[minor] + it.span[minor]}
+				it.area[major] = gridArea{cur[major], cur[major] + it.span[major]}
+				if fits(it.area) || cur[major] >= maxGridTracks {
+					break
+				}
+				cur[minor]++
+			}
+		}
+		it.area[major], it.area[minor] = it.area[major].clamp(), it.area[minor].clamp()
+		occupy(it)
+	}
+}
+
+// buildTracks creates the tracks of the implicit grid along axis: the
+// explicit ones, with empty auto-fit repetitions collapsed, and implicit
+// ones on either side for the items placed there.
+func (g *gridAxis) buildTracks(items []*gridItem, axis int) {
+	count := g.offset + len(g.list.tracks)
+	used := make(map[int]bool)
+	for _, it := range items {
+		count = max(count, it.area[axis].end)
+		for i := it.area[axis].start; i < it.area[axis].end; i++ {
+			used[i] = true
+		}
+	}
+	count = min(count, maxGridTracks)
+	g.tracks = make([]*gridTrack, count)
+	for i := range g.tracks {
+		explicit := i - g.offset
+		t := &gridTrack{}
+		switch {
+		case explicit < 0:
+			t.sizing = g.implicitTrack(explicit)
+		case explicit >= len(g.list.tracks):
+			t.sizing = g.implicitTrack(explicit - g.templateTracks)
+		default:
+			t.sizing = g.list.tracks[explicit]
+			t.collapsed = explicit >= g.fitFrom && explicit < g.fitTo && !used[i]
+		}
+		// Percentages of an indefinite size behave as auto.
+		if !g.definite {
+			if t.sizing.min.kind == trackFixed && t.sizing.min.length.Unit == "%" {
+				t.sizing.min = trackSize{kind: trackAuto}
+			}
+			if t.sizing.max.kind == trackFixed && t.sizing.max.length.Unit == "%" {
+				t.sizing.max = trackSize{kind: trackAuto}
+			}
+		}
+		g.tracks[i] = t
+	}
+}
+
+// gaps returns the space taken by the gaps between the tracks from start to
+// end, which collapsed tracks do not have.
+func (g *gridAxis) gaps(start, end int) float32 {
+	n := 0
+	for _, t := range g.tracks[start:end] {
+		if !t.collapsed {
+			n++
+		}
+	}
+	return g.gap * float32(max(n-1, 0))
+}
+
+// total returns the size of all tracks and gaps.
+func (g *gridAxis) total() float32 {
+	size := g.gaps(0, len(g.tracks))
+	for _, t := range g.tracks {
+		size += t.base
+	}
+	return size
+}
+
+// span returns the size of an area: its tracks and the gaps between them.
+func (g *gridAxis) span(a gridArea) float32 {
+	size := g.gaps(a.start, a.end)
+	for _, t := range g.tracks[a.start:a.end] {
+		size += t.base
+	}
+	return size
+}
+
+func (t *gridTrack) flexible() bool {
+	return t.sizing.max.kind == trackFlex
+}
+
+func (t *gridTrack) intrinsicMin() bool {
+	switch t.sizing.min.kind {
+	case trackAuto, trackMinContent, trackMaxContent:
+		return true
+	}
+	return false
+}
+
+func (t *gridTrack) intrinsicMax() bool {
+	switch t.sizing.max.kind {
+	case trackAuto, trackMinContent, trackMaxContent, trackFitContent:
+		return true
+	}
+	return false
+}
+
+// sizeTracks runs the track sizing algorithm (CSS Grid section 12) along
+// axis in avail, which is not known when definite is false. contribution
+// returns the min-content and max-content contributions of an item. With
+// minContent set the grid is sized under a min-content constraint.
+func (g *gridAxis) sizeTracks(items []*gridItem, axis int, avail float32, definite, minContent bool, contribution func(*gridItem) (float32, float32)) {
+	inf := float32(math.Inf(1))
+	resolve := func(s trackSize) float32 {
+		return s.length.ToPx(g.reference, g.fontSize, g.viewport)
+	}
+	// Initialize the base sizes and growth limits (12.4).
+	for _, t := range g.tracks {
+		t.base, t.limit = 0, inf
+		if t.collapsed {
+			t.limit = 0
+			continue
+		}
+		if t.sizing.min.kind == trackFixed {
+			t.base = resolve(t.sizing.min)
+		}
+		if t.sizing.max.kind == trackFixed {
+			t.limit = max(resolve(t.sizing.max), t.base)
+		}
+	}
+
+	// Resolve the intrinsic track sizes (12.5): items spanning one track,
+	// then wider ones in order of their span. Items that cross flexible
+	// tracks only raise the base sizes of those.
+	sorted := append([]*gridItem(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].area[axis].end-sorted[i].area[axis].start < sorted[j].area[axis].end-sorted[j].area[axis].start
+	})
+	var flexItems []*gridItem
+	for _, it := range sorted {
+		a := it.area[axis]
+		tracks := g.tracks[a.start:a.end]
+		crossesFlex := false
+		for _, t := range tracks {
+			crossesFlex = crossesFlex || t.flexible()
+		}
+		lo, hi := contribution(it)
+		if crossesFlex {
+			flexItems = append(flexItems, it)
+			var flex []*gridTrack
+			for _, t := range tracks {
+				if t.flexible() && t.intrinsicMin() {
+					flex = append(flex, t)
+				}
+			}
+			distributeBase(flex, lo-g.span(a))
+			continue
+		}
+		if len(tracks) == 1 {
+			t := tracks[0]
+			switch t.sizing.min.kind {
+			case trackAuto, trackMinContent:
+				t.base = max(t.base, lo)
+			case trackMaxContent:
+				t.base = max(t.base, hi)
+			}
+			grow := hi
+			switch t.sizing.max.kind {
+			case trackMinContent:
+				grow = lo
+			case trackFitContent:
+				grow = min(hi, max(lo, resolve(t.sizing.max)))
+			case trackFixed:
+				continue
+			}
+			if t.limit == inf {
+				t.limit = grow
+			} else {
+				t.limit = max(t.limit, grow)
+			}
+			continue
+		}
+		var intrinsic []*gridTrack
+		for _, t := range tracks {
+			if t.intrinsicMin() {
+				intrinsic = append(intrinsic, t)
+			}
+		}
+		distributeBase(intrinsic, lo-g.span(a))
+		intrinsic = intrinsic[:0]
+		limits := g.gaps(a.start, a.end)
+		for _, t := range tracks {
+			if t.limit == inf {
+				limits += t.base
+			} else {
+				limits += t.limit
+			}
+			if t.intrinsicMax() {
+				intrinsic = append(intrinsic, t)
+			}
+		}
+		if extra := hi - limits; extra > 0 && len(intrinsic) > 0 {
+			for _, t := range intrinsic {
+				if t.limit == inf {
+					t.limit = t.base
+				}
+				t.limit += extra / float32(len(intrinsic))
+			}
+		}
+	}
+	for _, t := range g.tracks {
+		if t.limit == inf || t.limit < t.base {
+			t.limit = t.base
+		}
+	}
+
+	if minContent {
+		return
+	}
+	// Maximize the tracks (12.6): grow them to their limits as far as the
+	// free space allows, all the way when it is not known.
+	if !definite {
+		for _, t := range g.tracks {
+			if !t.flexible() {
+				t.base = t.limit
+			}
+		}
+	} else {
+		for free := avail - g.total(); free > layoutEpsilon; {
+			var growable []*gridTrack
+			for _, t := range g.tracks {
+				if !t.flexible() && t.limit > t.base {
+					growable = append(growable, t)
+				}
+			}
+			if len(growable) == 0 {
+				break
+			}
+			share := free / float32(len(growable))
+			for _, t := range growable {
+				grow := min(share, t.limit-t.base)
+				t.base += grow
+				free -= grow
+			}
+		}
+	}
+
+	// Expand the flexible tracks (12.7).
+	var fr float32
+	if definite {
+		fr = g.frSize(g.tracks, avail-g.gaps(0, len(g.tracks)))
+	} else {
+		for _, t := range g.tracks {
+			if t.flexible() {
+				fr = max(fr, t.base/max(t.sizing.max.fr, 1))
+			}
+		}
+		for _, it := range flexItems {
+			a := it.area[axis]
+			_, hi := contribution(it)
+			fr = max(fr, g.frSize(g.tracks[a.start:a.end], hi-g.gaps(a.start, a.end)))
+		}
+	}
+	for _, t := range g.tracks {
+		if t.flexible() {
+			t.base = max(t.base, fr*t.sizing.max.fr)
+		}
+	}
+}
+
+// distributeBase spreads extra space equally over the base sizes of tracks.
+func distributeBase(tracks []*gridTrack, extra float32) {
+	if extra <= 0 || len(tracks) == 0 {
+		return
+	}
+	for _, t := range tracks {
+		t.base += extra / float32(len(tracks))
+	}
+}
+
+// frSize finds the size of a flex fraction that fills space with tracks
+// (12.7.1). Flexible tracks whose base size is larger than their share are
+// treated as inflexible.
+func (g *gridAxis) frSize(tracks []*gridTrack, space float32) float32 {
+	inflexible := make(map[*gridTrack]bool)
+	for {
+		leftover, factors := space, float32(0)
+		for _, t := range tracks {
+			if t.flexible() && !inflexible[t] {
+				factors += t.sizing.max.fr
+			} else {
+				leftover -= t.base
+			}
+		}
+		if factors == 0 {
+			return 0
+		}
+		fr := leftover / max(factors, 1)
+		done := true
+		for _, t := range tracks {
+			if t.flexible() && !inflexible[t] && fr*t.sizing.max.fr < t.base {
+				inflexible[t] = true
+				done = false
+			}
+		}
+		if done {
+			return max(fr, 0)
+		}
+	}
+}
+
+// align stretches auto tracks into the free space of a container of size
+// size, when the content distribution is normal or stretch, or else
+// distributes the free space according to justify-content or align-content,
+// and sets the position of each track.
+func (g *gridAxis) align(size float32, content string) {
+	value := flexAlignment(content, false, false)
+	free := size - g.total()
+	if value == "" || value == "normal" || value == "stretch" {
+		var auto []*gridTrack
+		for _, t := range g.tracks {
+			if t.sizing.max.kind == trackAuto && !t.collapsed {
+				auto = append(auto, t)
+			}
+		}
+		if free > 0 && len(auto) > 0 {
+			distributeBase(auto, free)
+			free = 0
+		}
+		value = "flex-start"
+	}
+	n := 0
+	for _, t := range g.tracks {
+		if !t.collapsed {
+			n++
+		}
+	}
+	pos, between := distribute(value, free, n)
+	for _, t := range g.tracks {
+		t.pos = pos
+		if !t.collapsed {
+			pos += t.base + g.gap + between
+		}
+	}
+}
+
+// layoutWidth lays out the item at the width it takes in columns that are
+// areaWidth wide, and records its height.
+func (it *gridItem) layoutWidth(areaWidth float32, container Dimensions) {
+	box := it.box
+	style := box.StyledNode
+	container.Content.Width = areaWidth
+	box.resolveInlineEdges(areaWidth)
+	fontSize := style.FontSize()
+	for i, side := range sides {
+		m := style.Length("margin-"+side, Length{Unit: "px"})
+		it.auto[i] = m.IsAuto()
+		it.margin[i] = m.ToPx(areaWidth, fontSize, style.viewportRect())
+	}
+	d := box.Dimensions
+	frameX := d.Padding.Left + d.Padding.Right + d.Border.Left + d.Border.Right
+	fill := areaWidth - frameX - it.margin[sideLeft] - it.margin[sideRight]
+	var width float32
+	switch {
+	case !style.Length("width", autoLength).IsAuto() || style.isReplaced():
+		width, _ = box.replacedOrSpecifiedWidth(areaWidth, frameX)
+	case it.justify == "stretch" && !it.auto[sideLeft] && !it.auto[sideRight]:
+		width = box.constrainWidth(fill, areaWidth, frameX)
+	default:
+		width = box.constrainWidth(box.shrinkToFit(fill), areaWidth, frameX)
+	}
+	box.layoutAssigned(container, sizeOverride{width: width, hasWidth: true})
+	bb := box.Dimensions.BorderBox()
+	it.height = bb.Height + it.margin[sideTop] + it.margin[sideBottom]
+	if y, ok := box.firstBaseline(); ok {
+		it.baseline = y - bb.Y + it.margin[sideTop]
+	} else {
+		it.baseline = bb.Height + it.margin[sideTop]
+	}
+}
+
+// replacedOrSpecifiedWidth returns the content width of a box whose width
+// does not depend on the space around it.
+func (b *LayoutBox) replacedOrSpecifiedWidth(cbWidth, frame float32) (float32, bool) {
+	style := b.StyledNode
+	if style.isReplaced() {
+		w, _ := b.replacedSize(cbWidth)
+		return w, true
+	}
+	w := style.Length("width", autoLength)
+	if w.IsAuto() {
+		return 0, false
+	}
+	return b.constrainWidth(b.contentSize(w.ToPx(cbWidth, style.FontSize(), style.viewportRect()), frame), cbWidth, frame), true
+}
+
+// stretch gives an item with align-self: stretch and an auto height the
+// height of its rows.
+func (it *gridItem) stretch(areaHeight float32, container Dimensions) {
+	box := it.box
+	style := box.StyledNode
+	if it.align != "stretch" || it.auto[sideTop] || it.auto[sideBottom] || style.isReplaced() {
+		return
+	}
+	if l := style.Length("height", autoLength); !l.IsAuto() && l.Unit != "%" {
+		return
+	}
+	d := box.Dimensions
+	frameY := d.Padding.Top + d.Padding.Bottom + d.Border.Top + d.Border.Bottom
+	lo, hi, _ := box.sizeLimits(false, 0, frameY)
+	h := max(lo, min(areaHeight-frameY-it.margin[sideTop]-it.margin[sideBottom], hi), 0)
+	container.Content.Width = box.container.Content.Width
+	box.layoutAssigned(container, sizeOverride{width: d.Content.Width, height: h, hasWidth: true, hasHeight: true})
+}
+
+// alignIn returns the offset of the item's margin box in its area along one
+// axis given the free space there: auto margins take positive free space,
+// or else the alignment value places the box.
+func (it *gridItem) alignIn(free float32, start, end int, value string, baselineShift float32) float32 {
+	if it.auto[start] || it.auto[end] {
+		if free > 0 {
+			switch {
+			case it.auto[start] && it.auto[end]:
+				it.margin[start] += free / 2
+				it.margin[end] += free / 2
+			case it.auto[start]:
+				it.margin[start] += free
+			default:
+				it.margin[end] += free
+			}
+		}
+		return 0
+	}
+	switch value {
+	case "end":
+		return free
+	case "center":
+		return free / 2
+	case "baseline":
+		return baselineShift
+	}
+	return 0
+}
+
+// gridContentWidths returns the intrinsic widths of a grid container: the
+// sum of its columns sized under a min-content and a max-content
+// constraint.
+func (b *LayoutBox) gridContentWidths() (minContent, maxContent float32) {
+	axes, items := b.gridPlacement(0, false, 0, false)
+	cols := axes[0]
+	widths := func(it *gridItem) (float32, float32) { return it.box.outerWidths() }
+	cols.sizeTracks(items, 0, 0, false, true, widths)
+	minContent = cols.total()
+	cols.sizeTracks(items, 0, 0, false, false, widths)
+	return minContent, cols.total()
+}
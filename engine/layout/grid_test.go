@@ -0,0 +1,115 @@
+package layout
+
+import "testing"
+
+func TestGridLayout(t *testing.T) {
+	const items = `<div id="g"><div id="a"></div><div id="b"></div><div id="d"></div><div id="e"></div></div>`
+	tests := []struct {
+		name string
+		css  string
+		want map[string]Rect
+	}{
+		{
+			name: "fr",
+			css:  "#g { grid-template-columns: 1fr 2fr; }",
+			want: map[string]Rect{"a": {0, 0, 100, 20}, "b": {100, 0, 200, 20}, "d": {0, 20, 100, 20}},
+		},
+		{
+			name: "fixed and fr with gaps",
+			css:  "#g { grid-template-columns: 50px 1fr 1fr; gap: 5px 10px; }",
+			want: map[string]Rect{"a": {0, 0, 50, 20}, "b": {60, 0, 115, 20}, "d": {185, 0, 115, 20}, "e": {0, 25, 50, 20}},
+		},
+		{
+			name: "minmax floor",
+			css:  "#g { grid-template-columns: minmax(100px, 1fr) 250px; }",
+			want: map[string]Rect{"a": {0, 0, 100, 20}, "b": {100, 0, 250, 20}},
+		},
+		{
+			name: "minmax cap",
+			css:  "#g { grid-template-columns: minmax(0, 80px) 1fr; }",
+			want: map[string]Rect{"a": {0, 0, 80, 20}, "b": {80, 0, 220, 20}},
+		},
+		{
+			name: "repeat",
+			css:  "#g { grid-template-columns: repeat(3, 1fr); }",
+			want: map[string]Rect{"d": {200, 0, 100, 20}, "e": {0, 20, 100, 20}},
+		},
+		{
+			name: "auto-fill",
+			css:  "#g { grid-template-columns: repeat(auto-fill, 70px); }",
+			want: map[string]Rect{"d": {140, 0, 70, 20}, "e": {210, 0, 70, 20}},
+		},
+		{
+			name: "span",
+			css:  "#g { grid-template-columns: repeat(3, 1fr); } #a { grid-column: span 2; }",
+			want: map[string]Rect{"a": {0, 0, 200, 20}, "b": {200, 0, 100, 20}, "d": {0, 20, 100, 20}},
+		},
+		{
+			name: "row span",
+			css:  "#g { grid-template-columns: repeat(3, 1fr); } #a { grid-row: span 2; }",
+			want: map[string]Rect{"a": {0, 0, 100, 40}, "e": {100, 20, 100, 20}},
+		},
+		{
+			name: "explicit lines",
+			css:  "#g { grid-template-columns: repeat(3, 1fr); } #b { grid-column: 3; grid-row: 2; }",
+			want: map[string]Rect{"a": {0, 0, 100, 20}, "b": {200, 20, 100, 20}, "d": {100, 0, 100, 20}},
+		},
+		{
+			name: "negative lines",
+			css:  "#g { grid-template-columns: repeat(3, 1fr); } #a { grid-column: 1 / -1; }",
+			want: map[string]Rect{"a": {0, 0, 300, 20}, "b": {0, 20, 100, 20}},
+		},
+		{
+			name: "areas",
+			css:  `#g { grid-template-columns: 100px 1fr; grid-template-areas: "h h" "s m"; } #a { grid-area: m; } #b { grid-area: h; }`,
+			want: map[string]Rect{"a": {100, 20, 200, 20}, "b": {0, 0, 300, 20}, "d": {0, 20, 100, 20}},
+		},
+		{
+			name: "sparse",
+			css:  "#g { grid-template-columns: repeat(3, 1fr); } #a, #b { grid-column: span 2; }",
+			want: map[string]Rect{"b": {0, 20, 200, 20}, "d": {200, 20, 100, 20}},
+		},
+		{
+			name: "dense",
+			css:  "#g { grid-template-columns: repeat(3, 1fr); grid-auto-flow: row dense; } #a, #b { grid-column: span 2; }",
+			want: map[string]Rect{"b": {0, 20, 200, 20}, "d": {200, 0, 100, 20}},
+		},
+		{
+			name: "self alignment",
+			css:  "#g { grid-template-columns: repeat(3, 1fr); } #a { width: 50px; justify-self: center; }",
+			want: map[string]Rect{"a": {25, 0, 50, 20}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkBoxes(t, "#g { display: grid; grid-auto-rows: 20px; }\n", tt.css, items, tt.want)
+		})
+	}
+}
+
+func TestGridPlacementLimits(t *testing.T) {
+	// Lines and spans past the limit on tracks are clamped to it rather than
+	// creating tracks without bound.
+	for _, css := range []string{
+		"#a { grid-column: 1001; }",
+		"#a { grid-column: span 2000; }",
+		"#a { grid-column: 1 / 5000; }",
+		"#a { grid-row: 999 / span 5; }",
+		"#a { grid-column: -5000; }",
+		"#a { grid-row: span 2000; } #b { grid-row: span 2000; }",
+		"#g { grid-auto-flow: column; } #a { grid-column: span 2000; } #b { grid-column: 3 / span 1200; }",
+	} {
+		page := layoutPage(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#g { display: grid; grid-template-columns: 10px 10px; }
+`+css+`</style></head><body><div id="g"><div id="a"></div><div id="b"></div></div></body></html>`, 300)
+		axes, items := boxOf(t, page, "g").gridPlacement(300, true, 0, false)
+		for _, it := range items {
+			for axis, a := range it.area {
+				if n := len(axes[axis].tracks); a.start < 0 || a.end > n || a.start >= a.end {
+					t.Errorf("%s: item placed at %v along axis %d of %d tracks", css, a, axis, n)
+				}
+			}
+		}
+	}
+}
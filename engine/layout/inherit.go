@@ -0,0 +1,137 @@
+package layout
+
+import (
+	"strconv"
+	"strings"
+)
+
+// inheritedProperties are the properties whose value defaults to the
+// parent's computed value.
+var inheritedProperties = map[string]bool{
+	"color": true, "direction": true, "visibility": true, "cursor": true,
+	"font-family": true, "font-size": true, "font-style": true,
+	"font-weight": true, "font-variant": true, "font-stretch": true,
+	"line-height": true, "letter-spacing": true, "word-spacing": true,
+	"text-align": true, "text-align-last": true, "text-indent": true,
+	"text-transform": true, "text-shadow": true, "white-space": true,
+	"word-break": true, "overflow-wrap": true, "word-wrap": true,
+	"line-break": true, "hyphens": true, "tab-size": true,
+	"list-style-type": true, "list-style-position": true,
+	"list-style-image": true, "quotes": true, "border-collapse": true,
+	"border-spacing": true, "caption-side": true, "empty-cells": true,
+	"writing-mode": true,
+}
+
+// inherit resolves the inherit and initial keywords in values and copies
+// inherited properties that are not specified from parent.
+func inherit(values, parent map[string]string) {
+	for name, v := range values {
+		switch strings.ToLower(v) {
+		case "inherit":
+			if pv, ok := parent[name]; ok {
+				values[name] = pv
+			} else {
+				delete(values, name)
+			}
+		case "initial", "unset":
+			delete(values, name)
+			if v == "unset" && inheritedProperties[name] {
+				if pv, ok := parent[name]; ok {
+					values[name] = pv
+				}
+			}
+		}
+	}
+	for name, pv := range parent {
+		if !inheritedProperties[name] {
+			continue
+		}
+		if _, ok := values[name]; !ok {
+			values[name] = pv
+		}
+	}
+}
+
+var fontSizeKeywords = map[string]float32{
+	"xx-small": 9, "x-small": 10, "small": 13, "medium": 16,
+	"large": 18, "x-large": 24, "xx-large": 32, "xxx-large": 48,
+}
+
+// computeFontValues turns font-size into pixels relative to the parent's
+// font size, and relative line heights into pixels, so that descendants
+// inherit the computed values rather than the specified ones.
+func computeFontValues(values, parent map[string]string, viewport Rect) {
+	parentSize := float32(defaultFontSize)
+	if l, ok := ParseLength(parent["font-size"]); ok && l.Unit == "px" {
+		parentSize = l.Value
+	}
+	size := parentSize
+	if v, ok := values["font-size"]; ok {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if px, ok := fontSizeKeywords[v]; ok {
+			size = px
+		} else if v == "larger" {
+			size = parentSize * 1.2
+		} else if v == "smaller" {
+			size = parentSize / 1.2
+		} else if l, ok := ParseLength(v); ok && !l.IsAuto() {
+			if l.Unit == "rem" {
+				size = l.Value * defaultFontSize
+			} else {
+				size = l.ToPx(parentSize, parentSize, viewport)
+			}
+		}
+		values["font-size"] = formatPx(size)
+	}
+	if v, ok := values["line-height"]; ok {
+		if l, ok := ParseLength(v); ok && !l.IsAuto() && l.Unit != "px" {
+			values["line-height"] = formatPx(l.ToPx(size, size, viewport))
+		}
+	}
+	if v, ok := values["font-weight"]; ok {
+		values["font-weight"] = strconv.Itoa(computeFontWeight(v, parent["font-weight"]))
+	}
+}
+
+// computeFontWeight resolves a font-weight to a number, bolder and lighter
+// relative to the parent's weight (CSS Fonts 4 section 2.2).
+func computeFontWeight(v, parent string) int {
+	inherited := 400
+	if parent != "" {
+		inherited = computeFontWeight(parent, "")
+	}
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "normal":
+		return 400
+	case "bold":
+		return 700
+	case "bolder":
+		switch {
+		case inherited < 350:
+			return 400
+		case inherited < 550:
+			return 700
+		case inherited < 900:
+			return 900
+		}
+		return inherited
+	case "lighter":
+		switch {
+		case inherited < 100:
+			return inherited
+		case inherited < 550:
+			return 100
+		case inherited < 750:
+			return 400
+		}
+		return 700
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n >= 1 && n <= 1000 {
+		return n
+	}
+	return inherited
+}
+
+func formatPx(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32) + "px"
+}
@@ -0,0 +1,889 @@
+package layout
+
+import (
+	"strings"
+
+	"prymis/engine/dom"
+)
+
+// LineBox is one line of an inline formatting context, in the same absolute
+// coordinates as the box dimensions.
+type LineBox struct {
+	Rect     Rect
+	Baseline float32
+	// Fragments are in paint order: the inline box fragments, outermost
+	// first, followed by text and atomic inlines in logical order.
+	Fragments []Fragment
+}
+
+type FragmentKind int
+
+const (
+	TextFragment FragmentKind = iota
+	InlineBoxFragment
+	AtomicFragment
+)
+
+// Fragment is the part of an inline-level box that lies on one line. Rect is
+// the content area for text and the border box for inline boxes and atomic
+// inlines. First and Last mark the inline box fragments that carry the
+// box's start and end edges.
+type Fragment struct {
+	Kind        FragmentKind
+	Box         *LayoutBox
+	Text        string
+	Rect        Rect
+	Baseline    float32
+	First, Last bool
+}
+
+type unitKind uint8
+
+const (
+	unitText unitKind = iota
+	unitOpen
+	unitClose
+	unitAtomic
+	unitFloat
+	unitAnchor
+)
+
+// inlineUnit is the smallest piece of inline content the line breaker
+// handles: a character, the start or end edge of an inline box, an atomic
+// inline, a float, or the anchor that gives an absolutely positioned box its
+// static position. Floats take no room on the line itself.
+type inlineUnit struct {
+	kind  unitKind
+	box   *LayoutBox
+	r     rune
+	width float32
+	// collapsible spaces are removed at the start and end of a line;
+	// hanging spaces do not count toward the width of the line they end.
+	collapsible, hangs bool
+	// brk is the break opportunity before this unit.
+	brk breakKind
+}
+
+// inlineContent is the flattened content of an inline formatting context.
+type inlineContent struct {
+	root   *LayoutBox
+	units  []inlineUnit
+	parent map[*LayoutBox]*LayoutBox
+}
+
+// hasInlineContent reports whether b is a block container whose children are
+// inline-level, so that it establishes an inline formatting context.
+// Floats and absolutely positioned children may sit among either kind.
+func (b *LayoutBox) hasInlineContent() bool {
+	for _, child := range b.Children {
+		if child.inFlow() {
+			return child.isInlineLevel()
+		}
+	}
+	return false
+}
+
+func (b *LayoutBox) isInlineLevel() bool {
+	return b.BoxType == InlineNode || b.BoxType == InlineBlockNode
+}
+
+// collectInline flattens the inline-level descendants of b. atomic is called
+// for each atomic inline and float and returns the width of its margin box.
+func (b *LayoutBox) collectInline(cbWidth float32, atomic func(*LayoutBox) float32) *inlineContent {
+	c := &inlineContent{root: b, parent: make(map[*LayoutBox]*LayoutBox)}
+	prevSpace := true
+	var text []rune
+	var textUnit []int
+	var rules []breakRules
+
+	var walk func(box *LayoutBox)
+	walk = func(box *LayoutBox) {
+		for _, child := range box.Children {
+			c.parent[child] = box
+			style := child.StyledNode
+			switch {
+			case child.isAbsolutelyPositioned():
+				c.units = append(c.units, inlineUnit{kind: unitAnchor, box: child})
+			case child.BoxType != InlineNode:
+				kind := unitAtomic
+				if child.isFloat() {
+					kind = unitFloat
+				}
+				c.units = append(c.units, inlineUnit{kind: kind, box: child, width: atomic(child)})
+				text = append(text, objectReplacement)
+				textUnit = append(textUnit, len(c.units)-1)
+				rules = append(rules, breakRulesOf(box.StyledNode))
+				prevSpace = false
+			case style.Node.NodeType == dom.TextNode:
+				ws := whiteSpaceOf(style)
+				data := transformText(style.Node.Text, style.Value("text-transform"))
+				runes := processWhiteSpace(data, ws, &prevSpace)
+				widths := advances(runes, style)
+				br := breakRulesOf(style)
+				for i, r := range runes {
+					u := inlineUnit{kind: unitText, box: child, r: r, width: widths[i]}
+					if r == '\t' {
+						u.width = tabWidth(style)
+					}
+					if isBreakSpace(r) {
+						u.collapsible = ws.collapse
+						u.hangs = ws.collapse || !ws.breakSpaces
+					}
+					c.units = append(c.units, u)
+					text = append(text, r)
+					textUnit = append(textUnit, len(c.units)-1)
+					rules = append(rules, br)
+				}
+			case style.Node.IsHTML() && style.Node.TagName == "br":
+				c.units = append(c.units, inlineUnit{kind: unitText, box: child, r: '\n'})
+				text = append(text, '\n')
+				textUnit = append(textUnit, len(c.units)-1)
+				rules = append(rules, breakRulesOf(style))
+				prevSpace = true
+			default:
+				child.resolveInlineEdges(cbWidth)
+				d := child.Dimensions
+				c.units = append(c.units, inlineUnit{kind: unitOpen, box: child, width: d.Margin.Left + d.Border.Left + d.Padding.Left})
+				walk(child)
+				c.units = append(c.units, inlineUnit{kind: unitClose, box: child, width: d.Margin.Right + d.Border.Right + d.Padding.Right})
+			}
+		}
+	}
+	walk(b)
+
+	breaks := lineBreaks(text, func(i int) breakRules { return rules[i] })
+	for i, brk := range breaks {
+		if brk == breakNone {
+			continue
+		}
+		// A break before a character goes before the start edges of the
+		// inline boxes it opens.
+		u := textUnit[i]
+		for u > 0 && c.units[u-1].kind == unitOpen {
+			u--
+		}
+		c.units[u].brk = brk
+	}
+	return c
+}
+
+func tabWidth(s *StyledNode) float32 {
+	size := float32(8)
+	if f, ok := parseNumber(s.Value("tab-size")); ok && f >= 0 {
+		size = f
+	}
+	return size * advances([]rune{' '}, s)[0]
+}
+
+// resolveInlineEdges sets the padding, borders and margins of an inline box.
+// Vertical padding and borders are painted but do not affect the line height.
+// The pieces of a split inline box only have the edges of their own side.
+func (b *LayoutBox) resolveInlineEdges(cbWidth float32) {
+	style := b.StyledNode
+	fontSize := style.FontSize()
+	zero := Length{Unit: "px"}
+	d := &b.Dimensions
+	d.Margin.Left = style.Length("margin-left", zero).ToPx(cbWidth, fontSize, style.viewportRect())
+	d.Margin.Right = style.Length("margin-right", zero).ToPx(cbWidth, fontSize, style.viewportRect())
+	d.Padding.Left = style.Length("padding-left", zero).ToPx(cbWidth, fontSize, style.viewportRect())
+	d.Padding.Right = style.Length("padding-right", zero).ToPx(cbWidth, fontSize, style.viewportRect())
+	d.Padding.Top = style.Length("padding-top", zero).ToPx(cbWidth, fontSize, style.viewportRect())
+	d.Padding.Bottom = style.Length("padding-bottom", zero).ToPx(cbWidth, fontSize, style.viewportRect())
+	for _, side := range sides {
+		w := style.BorderWidth(side)
+		switch side {
+		case "top":
+			d.Border.Top = w
+		case "right":
+			d.Border.Right = w
+		case "bottom":
+			d.Border.Bottom = w
+		case "left":
+			d.Border.Left = w
+		}
+	}
+	if b.continued&continuesBefore != 0 {
+		d.Margin.Left, d.Border.Left, d.Padding.Left = 0, 0, 0
+	}
+	if b.continued&continuesAfter != 0 {
+		d.Margin.Right, d.Border.Right, d.Padding.Right = 0, 0, 0
+	}
+}
+
+// nextLine returns the end of the line that starts at unit start when avail
+// pixels are available, breaking at the last opportunity that fits.
+func (c *inlineContent) nextLine(start int, avail float32) int {
+	units := c.units
+	width := float32(0)
+	lastBreak := -1
+	content := false
+	for i := start; i < len(units); i++ {
+		u := units[i]
+		if i > start {
+			if u.brk == breakMandatory {
+				return i
+			}
+			if u.brk == breakAllowed && content {
+				lastBreak = i
+			}
+		}
+		w := u.width
+		if u.collapsible && !content {
+			w = 0
+		}
+		if !u.hangs && content && width+w > avail+0.01 {
+			if lastBreak > start {
+				return lastBreak
+			}
+			if u.kind == unitText && canBreakAnywhere(u.box.StyledNode) {
+				return i
+			}
+		}
+		width += w
+		if (u.kind == unitText && !u.collapsible) || u.kind == unitAtomic {
+			content = true
+		}
+	}
+	return len(units)
+}
+
+// canBreakAnywhere reports whether overflow-wrap allows breaking a word that
+// does not fit on a line by itself.
+func canBreakAnywhere(s *StyledNode) bool {
+	switch s.Lookup("overflow-wrap", "word-wrap", "normal") {
+	case "anywhere", "break-word":
+		return true
+	}
+	return s.Value("word-break") == "break-word"
+}
+
+// layoutLines lays out the inline content of b in line boxes stacked from
+// the top of its content box and returns their total height. Lines get
+// shorter beside the floats in floats, or move below them when their content
+// does not fit; the floats among the content are placed there as well.
+func (b *LayoutBox) layoutLines(floats *floatContext) float32 {
+	d := &b.Dimensions
+	width := d.Content.Width
+	c := b.collectInline(width, func(atomic *LayoutBox) float32 {
+		if atomic.isFloat() {
+			return 0
+		}
+		atomic.layoutAtomic(*d)
+		return atomic.Dimensions.MarginBox().Width
+	})
+	style := b.StyledNode
+	indent := style.Length("text-indent", Length{Unit: "px"}).ToPx(width, style.FontSize(), style.viewportRect())
+	left, right := d.Content.X, d.Content.X+width
+	// Lines are assumed to be as tall as the strut when looking for space
+	// beside the floats.
+	strut := lineHeight(style, metricsOf(style))
+
+	b.Lines = b.Lines[:0]
+	var open, below []*LayoutBox
+	y := float32(0)
+	handled := 0
+	for start := 0; start < len(c.units); {
+		lineIndent := float32(0)
+		if len(b.Lines) == 0 {
+			lineIndent = indent
+		}
+		var end int
+		var l, r float32
+		for {
+			top := d.Content.Y + y
+			l, r = floats.band(top, strut, left, right)
+			end = c.nextLine(start, r-l-lineIndent)
+			i := c.nextFloat(max(start, handled), end)
+			if i < 0 {
+				if next, ok := floats.nextBottom(top); ok && r-l < width && c.measure(start, end) > r-l-lineIndent+layoutEpsilon {
+					y = next - d.Content.Y
+					continue
+				}
+				break
+			}
+			// A float goes beside the line if it fits next to the content
+			// before it, and below the line otherwise.
+			handled = i + 1
+			float := c.units[i].box
+			float.layoutFloat(*d)
+			if len(below) == 0 && c.measure(start, i)+float.Dimensions.MarginBox().Width <= r-l-lineIndent+layoutEpsilon {
+				floats.placeFloat(float, top, left, right)
+			} else {
+				below = append(below, float)
+			}
+		}
+		last := end == len(c.units) || c.units[end].brk == breakMandatory
+		line := c.buildLine(start, end, &open, r-l, lineIndent, last)
+		for i := start; i < end; i++ {
+			if u := c.units[i]; u.kind == unitAnchor {
+				// buildLine left the anchor's offset in the line.
+				if line == nil {
+					u.box.staticX = 0
+				}
+				u.box.setStaticPosition(l+u.box.staticX, d.Content.Y+y)
+			}
+		}
+		start = end
+		if line != nil {
+			line.translate(l, d.Content.Y+y)
+			for _, f := range line.Fragments {
+				if f.Kind == AtomicFragment {
+					bb := f.Box.Dimensions.BorderBox()
+					f.Box.translate(f.Rect.X-bb.X, f.Rect.Y-bb.Y)
+				}
+			}
+			y += line.Rect.Height
+			b.Lines = append(b.Lines, line)
+		}
+		for _, float := range below {
+			floats.placeFloat(float, d.Content.Y+y, left, right)
+		}
+		below = below[:0]
+	}
+	c.shiftRelative(b.Lines, width)
+	c.setInlineDimensions(b.Lines)
+	for box := range c.parent {
+		if box.BoxType == InlineNode {
+			box.layoutOutOfFlow()
+		}
+	}
+	return y
+}
+
+// shiftRelative moves the fragments of relatively positioned inline-level
+// boxes, and those of their descendants, by their offsets once the lines are
+// laid out.
+func (c *inlineContent) shiftRelative(lines []*LineBox, cbWidth float32) {
+	type offset struct{ dx, dy float32 }
+	offsets := make(map[*LayoutBox]offset)
+	var offsetOf func(box *LayoutBox) offset
+	offsetOf = func(box *LayoutBox) offset {
+		if box == c.root {
+			return offset{}
+		}
+		o, ok := offsets[box]
+		if !ok {
+			o = offsetOf(c.parent[box])
+			if box.StyledNode.Value("position") == "relative" {
+				dx, dy := box.relativeOffset(cbWidth)
+				o.dx, o.dy = o.dx+dx, o.dy+dy
+			}
+			offsets[box] = o
+		}
+		return o
+	}
+	for _, line := range lines {
+		for i := range line.Fragments {
+			f := &line.Fragments[i]
+			o := offsetOf(f.Box)
+			if o == (offset{}) {
+				continue
+			}
+			f.Rect.X += o.dx
+			f.Rect.Y += o.dy
+			f.Baseline += o.dy
+			if f.Kind == AtomicFragment {
+				f.Box.translate(o.dx, o.dy)
+			}
+		}
+	}
+}
+
+// nextFloat returns the index of the first float among the units [start,
+// end), or -1.
+func (c *inlineContent) nextFloat(start, end int) int {
+	for i := start; i < end; i++ {
+		if c.units[i].kind == unitFloat {
+			return i
+		}
+	}
+	return -1
+}
+
+// measure returns the width the units [start, end) take up on a line,
+// leaving out the spaces that collapse at its start or hang at its end.
+func (c *inlineContent) measure(start, end int) float32 {
+	var width, hanging float32
+	content := false
+	for _, u := range c.units[start:end] {
+		if u.collapsible && !content {
+			continue
+		}
+		width += u.width
+		if u.hangs {
+			hanging += u.width
+		} else {
+			hanging = 0
+		}
+		if u.kind == unitText && !u.collapsible || u.kind == unitAtomic {
+			content = true
+		}
+	}
+	return width - hanging
+}
+
+// layoutAtomic lays out an atomic inline in container. The line layout moves
+// it into place afterwards.
+func (b *LayoutBox) layoutAtomic(container Dimensions) {
+	container.Content.Height = 0
+	switch {
+	case b.StyledNode.isReplaced():
+		b.layoutReplaced(container, false)
+	case b.BoxType == InlineBlockNode:
+		b.layoutInlineBlock(container)
+	default:
+		b.layoutBlock(container)
+	}
+	b.layoutOutOfFlow()
+}
+
+// buildLine positions the units [start, end) on a line whose top left corner
+// is at the origin. It returns nil for a line without content, which takes up
+// no space. open holds the inline boxes that continue from the previous line
+// and is updated for the next one.
+func (c *inlineContent) buildLine(start, end int, open *[]*LayoutBox, width, indent float32, last bool) *LineBox {
+	units := c.units
+
+	// Collapsible spaces before the first and after the last solid unit are
+	// removed, and hanging spaces at the end of the line do not count.
+	first, final := end, start-1
+	hasContent := false
+	for i := start; i < end; i++ {
+		u := units[i]
+		solid := u.kind == unitAtomic || u.kind == unitText && !u.collapsible
+		if solid && first == end {
+			first = i
+		}
+		if solid && !(u.hangs && isBreakSpace(u.r)) {
+			final = i
+		}
+		if solid || u.width != 0 {
+			hasContent = true
+		}
+	}
+	if !hasContent {
+		*open = c.applyEdges(start, end, *open)
+		return nil
+	}
+	skip := func(i int) bool {
+		u := units[i]
+		if u.kind != unitText {
+			return false
+		}
+		return u.collapsible && (i < first || i > final) || u.hangs && i > final
+	}
+
+	var contentWidth float32
+	spaces := 0
+	for i := start; i < end; i++ {
+		if skip(i) {
+			continue
+		}
+		contentWidth += units[i].width
+		if units[i].kind == unitText && isBreakSpace(units[i].r) && i < final {
+			spaces++
+		}
+	}
+
+	style := c.root.StyledNode
+	rtl := style.Value("direction") == "rtl"
+	align := style.Value("text-align")
+	switch {
+	case align == "" || align == "start":
+		align = "left"
+		if rtl {
+			align = "right"
+		}
+	case align == "end":
+		align = "right"
+		if !rtl {
+			break
+		}
+		align = "left"
+	case align == "justify" && (last || spaces == 0):
+		align = "left"
+		if rtl {
+			align = "right"
+		}
+	}
+
+	free := width - indent - contentWidth
+	x := indent
+	if rtl {
+		x = 0
+	}
+	var extra float32
+	switch align {
+	case "justify":
+		if free > 0 {
+			extra = free / float32(spaces)
+		}
+	case "right":
+		x += free
+	case "center":
+		x += free / 2
+	}
+
+	var boxFrags, content []Fragment
+	boxIndex := make(map[*LayoutBox]int)
+	closed := make(map[*LayoutBox]bool)
+	startBox := func(box *LayoutBox, at float32, firstEdge bool) {
+		boxIndex[box] = len(boxFrags)
+		boxFrags = append(boxFrags, Fragment{Kind: InlineBoxFragment, Box: box, Rect: Rect{X: at}, First: firstEdge})
+	}
+	for _, box := range *open {
+		startBox(box, x, false)
+	}
+
+	var text strings.Builder
+	var textBox *LayoutBox
+	var textLeft float32
+	flushText := func(right float32) {
+		if textBox == nil {
+			return
+		}
+		content = append(content, Fragment{Kind: TextFragment, Box: textBox, Text: text.String(), Rect: Rect{X: textLeft, Width: right - textLeft}})
+		text.Reset()
+		textBox = nil
+	}
+
+	for i := start; i < end; i++ {
+		if skip(i) {
+			continue
+		}
+		u := units[i]
+		switch u.kind {
+		case unitOpen:
+			flushText(x)
+			startBox(u.box, x+u.box.Dimensions.Margin.Left, u.box.continued&continuesBefore == 0)
+			*open = append(*open, u.box)
+			x += u.width
+		case unitClose:
+			flushText(x)
+			d := u.box.Dimensions
+			if k, ok := boxIndex[u.box]; ok {
+				f := &boxFrags[k]
+				f.Rect.Width = x + d.Padding.Right + d.Border.Right - f.Rect.X
+				f.Last = u.box.continued&continuesAfter == 0
+				closed[u.box] = true
+			}
+			*open = removeBox(*open, u.box)
+			x += u.width
+		case unitAtomic:
+			flushText(x)
+			m := u.box.Dimensions.Margin
+			content = append(content, Fragment{Kind: AtomicFragment, Box: u.box, Rect: Rect{X: x + m.Left, Width: u.width - m.Left - m.Right}})
+			x += u.width
+		case unitAnchor:
+			flushText(x)
+			u.box.staticX = x
+		case unitText:
+			if textBox != u.box {
+				flushText(x)
+				textBox, textLeft = u.box, x
+			}
+			if u.r != '\n' {
+				text.WriteRune(u.r)
+			}
+			x += u.width
+			if extra != 0 && isBreakSpace(u.r) && i < final {
+				// Justification widens the spaces; each word becomes its
+				// own fragment so that it can be placed independently.
+				flushText(x)
+				x += extra
+			}
+		}
+	}
+	flushText(x)
+	for _, box := range *open {
+		// Boxes that continue on the next line end with the line.
+		if f := &boxFrags[boxIndex[box]]; !closed[box] {
+			f.Rect.Width = x - f.Rect.X
+		}
+	}
+
+	line := &LineBox{Fragments: append(boxFrags, content...)}
+	c.alignVertically(line)
+	line.Rect.Width = width
+	return line
+}
+
+// applyEdges tracks the inline boxes opened and closed by units that do not
+// produce a line.
+func (c *inlineContent) applyEdges(start, end int, open []*LayoutBox) []*LayoutBox {
+	for i := start; i < end; i++ {
+		switch c.units[i].kind {
+		case unitOpen:
+			open = append(open, c.units[i].box)
+		case unitClose:
+			open = removeBox(open, c.units[i].box)
+		}
+	}
+	return open
+}
+
+func removeBox(boxes []*LayoutBox, box *LayoutBox) []*LayoutBox {
+	for i := len(boxes) - 1; i >= 0; i-- {
+		if boxes[i] == box {
+			return append(boxes[:i:i], boxes[i+1:]...)
+		}
+	}
+	return boxes
+}
+
+// lineItem is a box on a line whose position relative to the baseline of the
+// root inline box is being resolved (CSS 2.1 section 10.8).
+type lineItem struct {
+	frag int // index into the line's fragments, or -1 for the strut
+	// y is the offset of the item's baseline from the root baseline; above
+	// and below are the extents of its aligned box around its baseline.
+	y, above, below float32
+	// anchor is the outermost box aligned with the top or bottom of the
+	// line that contains the item, if any.
+	anchor *LayoutBox
+}
+
+// alignVertically resolves vertical-align for the fragments of line, sets
+// their vertical positions and the line's height and baseline.
+func (c *inlineContent) alignVertically(line *LineBox) {
+	above, below := c.extents(c.root)
+	items := []lineItem{{frag: -1, above: above, below: below}}
+	for i, f := range line.Fragments {
+		it := lineItem{frag: i, y: c.shift(f.Box), anchor: c.anchor(f.Box)}
+		it.above, it.below = c.extents(f.Box)
+		items = append(items, it)
+	}
+
+	top, bottom := float32(0), float32(0)
+	first := true
+	for _, it := range items {
+		if it.anchor != nil {
+			continue
+		}
+		if first || it.y-it.above < top {
+			top = it.y - it.above
+		}
+		if first || it.y+it.below > bottom {
+			bottom = it.y + it.below
+		}
+		first = false
+	}
+
+	// Subtrees aligned with the top or bottom of the line may make it
+	// taller; then they are moved into place.
+	type group struct{ top, bottom float32 }
+	groups := make(map[*LayoutBox]*group)
+	for _, it := range items {
+		if it.anchor == nil {
+			continue
+		}
+		g := groups[it.anchor]
+		if g == nil {
+			g = &group{top: it.y - it.above, bottom: it.y + it.below}
+			groups[it.anchor] = g
+		}
+		g.top = min(g.top, it.y-it.above)
+		g.bottom = max(g.bottom, it.y+it.below)
+	}
+	for anchor, g := range groups {
+		if h := g.bottom - g.top; h > bottom-top {
+			if anchor.StyledNode.Value("vertical-align") == "top" {
+				bottom = top + h
+			} else {
+				top = bottom - h
+			}
+		}
+	}
+	for i := range items {
+		it := &items[i]
+		if g := groups[it.anchor]; g != nil {
+			if it.anchor.StyledNode.Value("vertical-align") == "top" {
+				it.y += top - g.top
+			} else {
+				it.y += bottom - g.bottom
+			}
+		}
+	}
+
+	baseline := -top
+	for _, it := range items[1:] {
+		f := &line.Fragments[it.frag]
+		f.Baseline = baseline + it.y
+		switch f.Kind {
+		case AtomicFragment:
+			d := f.Box.Dimensions
+			f.Rect.Y = f.Baseline - it.above + d.Margin.Top
+			f.Rect.Height = d.BorderBox().Height
+		case InlineBoxFragment:
+			m := metricsOf(f.Box.StyledNode)
+			d := f.Box.Dimensions
+			f.Rect.Y = f.Baseline - m.ascent - d.Padding.Top - d.Border.Top
+			f.Rect.Height = m.ascent + m.descent + d.Padding.Top + d.Padding.Bottom + d.Border.Top + d.Border.Bottom
+		default:
+			m := metricsOf(f.Box.StyledNode)
+			f.Rect.Y = f.Baseline - m.ascent
+			f.Rect.Height = m.ascent + m.descent
+		}
+	}
+	line.Baseline = baseline
+	line.Rect.Height = bottom - top
+}
+
+// extents returns the distances from a box's baseline to the top and bottom
+// of the box that is aligned in the line: the margin box of an atomic inline,
+// or the font's content area plus half-leading for an inline box.
+func (c *inlineContent) extents(box *LayoutBox) (above, below float32) {
+	if box != c.root && box.BoxType != InlineNode {
+		mb := box.Dimensions.MarginBox()
+		above = box.atomicBaseline() - mb.Y
+		return above, mb.Height - above
+	}
+	m := metricsOf(box.StyledNode)
+	half := (lineHeight(box.StyledNode, m) - m.ascent - m.descent) / 2
+	return m.ascent + half, m.descent + half
+}
+
+// atomicBaseline returns the baseline of an atomic inline: that of its last
+// line box, or the bottom margin edge when it has none or clips its content.
+// An inline table has the baseline of its first row.
+func (b *LayoutBox) atomicBaseline() float32 {
+	if b.wrapped != nil {
+		if y, ok := b.wrapped.firstBaseline(); ok {
+			return y
+		}
+	} else if b.BoxType == InlineBlockNode && !b.StyledNode.isReplaced() {
+		switch b.StyledNode.Value("overflow") {
+		case "", "visible":
+			if y, ok := b.lastBaseline(); ok {
+				return y
+			}
+		}
+	}
+	mb := b.Dimensions.MarginBox()
+	return mb.Y + mb.Height
+}
+
+func (b *LayoutBox) lastBaseline() (float32, bool) {
+	if n := len(b.Lines); n > 0 {
+		return b.Lines[n-1].Baseline, true
+	}
+	for i := len(b.Children) - 1; i >= 0; i-- {
+		child := b.Children[i]
+		if child.isInlineLevel() || !child.inFlow() {
+			continue
+		}
+		if y, ok := child.lastBaseline(); ok {
+			return y, true
+		}
+	}
+	return 0, false
+}
+
+// firstBaseline returns the baseline of the first line box in b or in its
+// first in-flow block-level descendant that has one.
+func (b *LayoutBox) firstBaseline() (float32, bool) {
+	if len(b.Lines) > 0 {
+		return b.Lines[0].Baseline, true
+	}
+	for _, child := range b.Children {
+		if child.isInlineLevel() || !child.inFlow() {
+			continue
+		}
+		if y, ok := child.firstBaseline(); ok {
+			return y, true
+		}
+	}
+	return 0, false
+}
+
+// shift returns the offset of a box's baseline from the root baseline when
+// top and bottom alignment are ignored.
+func (c *inlineContent) shift(box *LayoutBox) float32 {
+	if box == c.root {
+		return 0
+	}
+	parent := c.parent[box]
+	y := c.shift(parent)
+	ps := parent.StyledNode
+	pm := metricsOf(ps)
+	va := box.StyledNode.Value("vertical-align")
+	switch va {
+	case "", "baseline", "top", "bottom":
+		return y
+	case "sub":
+		return y + ps.FontSize()/5
+	case "super":
+		return y - ps.FontSize()/3
+	}
+	above, below := c.extents(box)
+	switch va {
+	case "text-top":
+		return y - pm.ascent + above
+	case "text-bottom":
+		return y + pm.descent - below
+	case "middle":
+		return y - pm.xHeight/2 + above - (above+below)/2
+	}
+	if l, ok := ParseLength(va); ok && !l.IsAuto() {
+		s := box.StyledNode
+		return y - l.ToPx(lineHeight(s, metricsOf(s)), s.FontSize(), s.viewportRect())
+	}
+	return y
+}
+
+// anchor returns the outermost inclusive ancestor of box inside the line that
+// has vertical-align top or bottom.
+func (c *inlineContent) anchor(box *LayoutBox) *LayoutBox {
+	var found *LayoutBox
+	for b := box; b != c.root && b != nil; b = c.parent[b] {
+		switch b.StyledNode.Value("vertical-align") {
+		case "top", "bottom":
+			found = b
+		}
+	}
+	return found
+}
+
+// setInlineDimensions gives every inline box the bounds of its fragments, so
+// that it has a position for later lookups; painting and hit testing use the
+// fragments themselves.
+func (c *inlineContent) setInlineDimensions(lines []*LineBox) {
+	seen := make(map[*LayoutBox]bool)
+	for _, line := range lines {
+		for _, f := range line.Fragments {
+			if f.Kind == AtomicFragment {
+				continue
+			}
+			d := &f.Box.Dimensions
+			r := f.Rect
+			if f.Kind == InlineBoxFragment {
+				r = Rect{X: r.X + d.Border.Left + d.Padding.Left, Y: r.Y + d.Border.Top + d.Padding.Top,
+					Width:  r.Width - d.Border.Left - d.Padding.Left - d.Border.Right - d.Padding.Right,
+					Height: r.Height - d.Border.Top - d.Padding.Top - d.Border.Bottom - d.Padding.Bottom}
+			}
+			if !seen[f.Box] {
+				d.Content = r
+				seen[f.Box] = true
+			} else {
+				d.Content = d.Content.union(r)
+			}
+		}
+	}
+}
+
+func (r Rect) union(o Rect) Rect {
+	x0, y0 := min(r.X, o.X), min(r.Y, o.Y)
+	x1, y1 := max(r.X+r.Width, o.X+o.Width), max(r.Y+r.Height, o.Y+o.Height)
+	return Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+func (l *LineBox) translate(dx, dy float32) {
+	l.Rect.X += dx
+	l.Rect.Y += dy
+	l.Baseline += dy
+	for i := range l.Fragments {
+		l.Fragments[i].Rect.X += dx
+		l.Fragments[i].Rect.Y += dy
+		l.Fragments[i].Baseline += dy
+	}
+}
@@ -0,0 +1,212 @@
+package layout
+
+import (
+	"strings"
+	"testing"
+
+	"prymis/engine/dom"
+)
+
+func TestInlineLayout(t *testing.T) {
+	// Three atomic inlines 40 pixels wide in a container 100 pixels wide;
+	// two fit on the first line.
+	const three = `<i id="a"></i><i id="b"></i><i id="c"></i>`
+	tests := []struct {
+		name string
+		css  string
+		html string
+		want map[string]Rect
+	}{
+		{
+			name: "wrapping",
+			html: three,
+			want: map[string]Rect{"a": {0, 0, 40, 20}, "b": {40, 0, 40, 20}, "c": {0, 20, 40, 20}},
+		},
+		{
+			name: "centered",
+			css:  "#p { text-align: center; }",
+			html: three,
+			want: map[string]Rect{"a": {10, 0, 40, 20}, "b": {50, 0, 40, 20}, "c": {30, 20, 40, 20}},
+		},
+		{
+			name: "right aligned",
+			css:  "#p { text-align: right; }",
+			html: three,
+			want: map[string]Rect{"a": {20, 0, 40, 20}, "b": {60, 0, 40, 20}, "c": {60, 20, 40, 20}},
+		},
+		{
+			name: "justified",
+			css:  "#p { text-align: justify; }",
+			html: `<i id="a"></i> <i id="b"></i> <i id="c"></i>`,
+			want: map[string]Rect{"a": {0, 0, 40, 20}, "b": {60, 0, 40, 20}, "c": {0, 20, 40, 20}},
+		},
+		{
+			name: "text-indent",
+			css:  "#p { text-indent: 20px; }",
+			html: three,
+			want: map[string]Rect{"a": {20, 0, 40, 20}, "b": {60, 0, 40, 20}, "c": {0, 20, 40, 20}},
+		},
+		{
+			name: "nowrap",
+			css:  "#p { white-space: nowrap; }",
+			html: three,
+			want: map[string]Rect{"c": {80, 0, 40, 20}},
+		},
+		{
+			name: "forced break",
+			html: `<i id="a"></i><br><i id="b"></i>`,
+			want: map[string]Rect{"a": {0, 0, 40, 20}, "b": {0, 20, 40, 20}},
+		},
+		{
+			name: "inline box edges",
+			css:  "#s { margin-left: 10px; padding-left: 5px; border-left: 5px solid; }",
+			html: `<span id="s"><i id="a"></i></span><i id="b"></i>`,
+			want: map[string]Rect{"a": {20, 0, 40, 20}, "b": {60, 0, 40, 20}},
+		},
+		{
+			name: "baseline",
+			css:  "i { vertical-align: baseline; } #b { height: 40px; }",
+			html: `<i id="a"></i><i id="b"></i>`,
+			want: map[string]Rect{"a": {0, 20, 40, 20}, "b": {40, 0, 40, 40}},
+		},
+		{
+			name: "bottom",
+			css:  "#a { vertical-align: bottom; } #b { height: 40px; }",
+			html: `<i id="a"></i><i id="b"></i>`,
+			want: map[string]Rect{"a": {0, 20, 40, 20}, "b": {40, 0, 40, 40}},
+		},
+		{
+			name: "atomic margins",
+			css:  "#a { margin: 5px 10px; }",
+			html: three,
+			want: map[string]Rect{"a": {10, 5, 40, 20}, "b": {60, 0, 40, 20}, "c": {0, 30, 40, 20}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkBoxes(t, `#p { width: 100px; line-height: 10px; }
+i { display: inline-block; width: 40px; height: 20px; vertical-align: top; }
+`, tt.css, `<div id="p">`+tt.html+`</div>`, tt.want)
+		})
+	}
+}
+
+func TestTextWrapping(t *testing.T) {
+	const words = "The quick brown fox jumps over the lazy dog again and again"
+	tests := []struct {
+		name string
+		css  string
+		text string
+		// lines is the number of line boxes wanted; 0 asks for more than
+		// one, or none for white space only.
+		lines int
+		// fits is whether every line must fit in the container.
+		fits bool
+	}{
+		{name: "words wrap", text: words, fits: true},
+		{name: "nowrap", css: "white-space: nowrap;", text: words, lines: 1},
+		{name: "preformatted lines", css: "white-space: pre;", text: "one\ntwo\nthree", lines: 3},
+		{name: "pre-line", css: "white-space: pre-line;", text: "one   two\nthree", lines: 2},
+		{name: "collapsed newlines", text: "a\nb\nc", lines: 1},
+		{name: "long word overflows", text: strings.Repeat("x", 40), lines: 1},
+		{name: "long word broken anywhere", css: "overflow-wrap: anywhere;", text: strings.Repeat("x", 40), fits: true},
+		{name: "break-all", css: "word-break: break-all;", text: strings.Repeat("x", 40), fits: true},
+		{name: "no content", text: "   ", lines: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := layoutPage(t, `<html><head><style>
+html, body { margin: 0; }
+#p { width: 80px; font-size: 16px; line-height: 20px; `+tt.css+` }
+</style></head><body><div id="p">`+tt.text+`</div></body></html>`, 300)
+			p := boxOf(t, page, "p")
+			switch {
+			case strings.TrimSpace(tt.text) == "":
+				if len(p.Lines) != 0 {
+					t.Errorf("got %d lines for white space", len(p.Lines))
+				}
+			case tt.lines == 0 && len(p.Lines) < 2:
+				t.Errorf("got %d lines, want several", len(p.Lines))
+			case tt.lines != 0 && len(p.Lines) != tt.lines:
+				t.Errorf("got %d lines, want %d", len(p.Lines), tt.lines)
+			}
+			content := p.Dimensions.Content
+			if got, want := content.Height, float32(len(p.Lines))*20; got != want {
+				t.Errorf("height %v, want %v for %d lines", got, want, len(p.Lines))
+			}
+			for i, line := range p.Lines {
+				if line.Rect.Y != content.Y+float32(i)*20 {
+					t.Errorf("line %d is at %v", i, line.Rect.Y)
+				}
+				if !tt.fits {
+					continue
+				}
+				for _, f := range line.Fragments {
+					if f.Kind == TextFragment && strings.TrimSpace(f.Text) != "" && f.Rect.X+f.Rect.Width > content.X+content.Width+0.01 {
+						t.Errorf("line %d: %q ends at %v, past %v", i, f.Text, f.Rect.X+f.Rect.Width, content.X+content.Width)
+					}
+				}
+			}
+		})
+	}
+}
+
+// boxTree describes the box tree under b, naming anonymous blocks "anon",
+// text "text" and elements by tag, with the children of each in brackets.
+func boxTree(b *LayoutBox) string {
+	name := b.StyledNode.Node.TagName
+	switch {
+	case b.BoxType == AnonymousBlock:
+		name = "anon"
+	case b.StyledNode.Node.NodeType == dom.TextNode:
+		name = "text"
+	}
+	if len(b.Children) == 0 {
+		return name
+	}
+	var children []string
+	for _, c := range b.Children {
+		children = append(children, boxTree(c))
+	}
+	return name + "[" + strings.Join(children, " ") + "]"
+}
+
+func TestAnonymousBlocks(t *testing.T) {
+	tests := []struct {
+		name, html, want string
+	}{
+		{"inline only", `a<span>b</span>`, "div[text span[text]]"},
+		{"blocks only", `<p>a</p> <p>b</p>`, "div[p[text] p[text]]"},
+		{"mixed", `a<p>b</p>c`, "div[anon[text] p[text] anon[text]]"},
+		{"white space dropped", ` <p>b</p> <em>c</em> `, "div[p[text] anon[text em[text] text]]"},
+		{"split inline", `<span>a<p>b</p>c</span>`, "div[anon[span[text]] p[text] anon[span[text]]]"},
+		{"split nested", `<span><em>a<p>b</p></em>c</span>`, "div[anon[span[em[text]]] p[text] anon[span[em text]]]"},
+		// Floats are out of flow and leave the inline content around them
+		// unwrapped.
+		{"float", `a<span class="f">b</span>`, "div[text span[text]]"},
+		{"inline-block stays inline", `a<span class="ib"><p>b</p></span>`, "div[text span[p[text]]]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := layoutPage(t, `<html><head><style>
+.f { float: left; } .ib { display: inline-block; }
+</style></head><body><div id="r">`+tt.html+`</div></body></html>`, 300)
+			if got := boxTree(boxOf(t, page, "r")); got != tt.want {
+				t.Errorf("boxes are %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitInlineEdges(t *testing.T) {
+	checkBoxes(t, `#r { line-height: 10px; }
+#s { padding: 0 10px; }
+i { display: inline-block; width: 40px; height: 20px; vertical-align: top; }
+#d { height: 5px; }
+`, "", `<div id="r"><span id="s"><i id="a"></i><div id="d"></div><i id="b"></i></span></div>`, map[string]Rect{
+		// Only the first piece has the start edge.
+		"a": {10, 0, 40, 20},
+		"d": {0, 20, 300, 5},
+		"b": {0, 25, 40, 20},
+	})
+}
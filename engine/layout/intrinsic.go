@@ -0,0 +1,119 @@
+package layout
+
+// shrinkToFit returns min(max(min-content, available), max-content), the
+// content width of an auto-sized inline-block, float or absolutely positioned
+// box (CSS 2.1 section 10.3.5).
+func (b *LayoutBox) shrinkToFit(available float32) float32 {
+	minContent, maxContent := b.contentWidths()
+	return min(max(minContent, available), maxContent)
+}
+
+// contentWidths returns the min-content and max-content widths of the
+// content box of b.
+func (b *LayoutBox) contentWidths() (minContent, maxContent float32) {
+	if b.StyledNode.isReplaced() {
+		w, _ := b.replacedSize(0)
+		return w, w
+	}
+	switch b.StyledNode.Value("display") {
+	case "flex", "inline-flex":
+		return b.flexContentWidths()
+	case "grid", "inline-grid":
+		return b.gridContentWidths()
+	case "table", "inline-table":
+		if b.wrapped != nil {
+			return b.tableWrapperWidths(0, false)
+		}
+		lo, hi := b.tableWidths(0, false)
+		frame := b.frameX(0)
+		return lo - frame, hi - frame
+	}
+	if b.hasInlineContent() {
+		return b.inlineContentWidths()
+	}
+	for _, child := range b.Children {
+		if child.isAbsolutelyPositioned() {
+			continue
+		}
+		lo, hi := child.outerWidths()
+		minContent = max(minContent, lo)
+		maxContent = max(maxContent, hi)
+	}
+	return minContent, maxContent
+}
+
+// outerWidths returns the min-content and max-content contributions of b:
+// its content widths, or its specified width, plus padding, borders and
+// margins. Percentages cannot be resolved and count as 0.
+func (b *LayoutBox) outerWidths() (minContent, maxContent float32) {
+	style := b.StyledNode
+	fontSize := style.FontSize()
+	edge := func(name string) float32 {
+		l := style.Length(name, Length{Unit: "px"})
+		if l.IsAuto() || l.Unit == "%" {
+			return 0
+		}
+		return l.ToPx(0, fontSize, style.viewportRect())
+	}
+	frame := edge("padding-left") + edge("padding-right") + style.BorderWidth("left") + style.BorderWidth("right")
+	if w := style.Length("width", autoLength); !w.IsAuto() && w.Unit != "%" && !style.isReplaced() {
+		minContent = b.contentSize(w.ToPx(0, fontSize, style.viewportRect()), frame)
+		maxContent = minContent
+	} else {
+		minContent, maxContent = b.contentWidths()
+	}
+	for _, name := range []string{"min-width", "max-width"} {
+		l, ok := ParseLength(style.Value(name))
+		if !ok || l.IsAuto() || l.Unit == "%" {
+			continue
+		}
+		v := b.contentSize(l.ToPx(0, fontSize, style.viewportRect()), frame)
+		if name == "min-width" {
+			minContent, maxContent = max(minContent, v), max(maxContent, v)
+		} else {
+			minContent, maxContent = min(minContent, v), min(maxContent, v)
+		}
+	}
+	outer := frame + edge("margin-left") + edge("margin-right")
+	return minContent + outer, maxContent + outer
+}
+
+// inlineContentWidths measures an inline formatting context: the widest
+// unbreakable run and the widest line when breaking only where required.
+func (b *LayoutBox) inlineContentWidths() (minContent, maxContent float32) {
+	minimal := b.collectInline(0, func(atomic *LayoutBox) float32 {
+		lo, _ := atomic.outerWidths()
+		return lo
+	})
+	maximal := b.collectInline(0, func(atomic *LayoutBox) float32 {
+		_, hi := atomic.outerWidths()
+		return hi
+	})
+	return minimal.widest(breakAllowed), maximal.widest(breakMandatory)
+}
+
+// widest returns the width of the widest segment between breaks of at least
+// the given kind, not counting spaces that hang at the end of a segment.
+func (c *inlineContent) widest(kind breakKind) float32 {
+	var widest, width, hanging float32
+	leading := true
+	for _, u := range c.units {
+		if u.brk >= kind {
+			widest = max(widest, width-hanging)
+			width, hanging, leading = 0, 0, true
+		}
+		if u.collapsible && leading {
+			continue
+		}
+		width += u.width
+		if u.hangs {
+			hanging += u.width
+		} else {
+			hanging = 0
+		}
+		if u.kind == unitText || u.kind == unitAtomic || u.kind == unitFloat {
+			leading = false
+		}
+	}
+	return max(widest, width-hanging)
+}
@@ -0,0 +1,310 @@
+package layout
+
+import (
+	"prymis/engine/dom"
+	"prymis/engine/parser"
+	"prymis/engine/text"
+	"strings"
+)
+
+// styleDirt records what has to be recomputed for a StyledNode.
+type styleDirt uint8
+
+const (
+	// styleSelf: the node's own specified values may have changed.
+	styleSelf styleDirt = 1 << iota
+	// styleSubtree: the node and all of its descendants need restyling.
+	styleSubtree
+	// styleChildren: the DOM children changed and must be re-synced.
+	styleChildren
+	// styleDescendant: some descendant carries one of the flags above.
+	styleDescendant
+)
+
+// Document keeps the style and layout trees of a DOM document up to date.
+// It observes the DOM and, on Update, restyles only the StyledNodes touched
+// by mutations and rebuilds and relays out only the LayoutBoxes above them;
+// untouched subtrees keep their boxes and cached geometry.
+type Document struct {
+	DOM    *dom.Node
+	Style  *StyledNode
+	Layout *LayoutBox
+
+	// url is the address of the document and author the style sheet it was
+	// opened with, which comes before the page's own sheets. fetch loads
+	// the linked style sheets and web fonts.
+	url    string
+	author []parser.StyleRule
+	fetch  Fetcher
+
+	rules    []*compiledRule
+	fonts    *text.FontSet
+	nodes    map[*dom.Node]*StyledNode
+	observer *dom.MutationObserver
+	// dirty is set when delivered mutations marked nodes for Update.
+	dirty bool
+
+	// viewport is the size of the area the document was last laid out in.
+	// viewportUnits is set when the rules may use vw or vh, so that the
+	// document has to be restyled when that size changes.
+	viewport      Rect
+	viewportUnits bool
+
+	// scrollX and scrollY are how far the viewport is scrolled.
+	scrollX, scrollY float32
+}
+
+// NewDocument styles and lays out doc, which was loaded from url, with the
+// author style sheet rules followed by the style sheets of the page. fetch
+// loads the subresources of the page; it may be nil.
+func NewDocument(doc *dom.Node, rules []parser.StyleRule, url string, fetch Fetcher) *Document {
+	d := &Document{DOM: doc, url: url, author: rules, fetch: fetch}
+	d.reset()
+	d.observer = dom.NewMutationObserver(func(records []dom.MutationRecord, _ *dom.MutationObserver) {
+		if d.Invalidate(records) {
+			d.dirty = true
+		}
+	})
+	d.observer.Observe(doc, dom.MutationObserverInit{
+		ChildList:     true,
+		Attributes:    true,
+		CharacterData: true,
+		Subtree:       true,
+	})
+	return d
+}
+
+// reset collects the style sheets and web fonts of the document, and
+// styles and lays out all of it from scratch.
+func (d *Document) reset() {
+	sheets := append([]styleSheet{{rules: d.author, base: d.url}}, pageStyleSheets(d.DOM, d.url, d.fetch)...)
+	var rules []parser.StyleRule
+	for _, sheet := range sheets {
+		rules = append(rules, sheet.rules...)
+	}
+	d.rules = authorStyles(rules)
+	d.viewportUnits = usesViewportUnits(d.rules)
+	d.fonts = text.NewFontSet()
+	loadFontFaces(sheets, d.fonts, d.fetch)
+
+	root := d.DOM
+	if root.NodeType == dom.DocumentNode {
+		root = root.DocumentElement()
+	}
+	d.nodes = make(map[*dom.Node]*StyledNode)
+	d.Style = styleTree(root, d.rules, nil, d.viewport)
+	d.Style.fonts, d.Style.viewport = d.fonts, d.viewport
+	d.index(d.Style)
+	d.Layout = NewLayoutTree(d.Style)
+}
+
+// usesViewportUnits reports whether any declaration of rules may hold a
+// length in vw or vh. It errs on the side of yes.
+func usesViewportUnits(rules []*compiledRule) bool {
+	for _, rule := range rules {
+		for _, decl := range rule.declarations {
+			v := strings.ToLower(decl.Value)
+			if strings.Contains(v, "vw") || strings.Contains(v, "vh") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Close stops observing the DOM.
+func (d *Document) Close() {
+	d.observer.Disconnect()
+}
+
+// Update applies pending DOM mutations and lays the tree out in viewport,
+// whose content box is the area the page is shown in, in the coordinates of
+// the document; renderers place it on screen. The
+// document starts at its top; its height is what fixed boxes and the initial
+// containing block get.
+func (d *Document) Update(viewport Dimensions) {
+	size := Rect{Width: viewport.Content.Width, Height: viewport.Content.Height}
+	resized := size != d.viewport && d.viewportUnits
+	d.viewport = size
+	d.Style.viewport = size
+	if d.Invalidate(d.observer.TakeRecords()) || d.dirty || resized {
+		d.dirty = false
+		if d.Style.Node != d.DOM.DocumentElement() && d.DOM.NodeType == dom.DocumentNode {
+			d.reset()
+		} else {
+			d.restyle(d.Style, resized)
+			d.rebuild()
+		}
+	}
+	root := d.Layout
+	if root.viewport != viewport.Content || root.scrollX != d.scrollX || root.scrollY != d.scrollY {
+		root.valid = false
+	}
+	root.viewport, root.scrollX, root.scrollY = viewport.Content, d.scrollX, d.scrollY
+	viewport.Content.Height = 0
+	root.Layout(viewport)
+	if x, y := root.clampScroll(d.scrollX, d.scrollY); x != d.scrollX || y != d.scrollY {
+		d.ScrollTo(x, y)
+	}
+}
+
+// ScrollTo scrolls the viewport to (x, y) in the document, limited to the
+// area the document covers. Fixed boxes keep their place in the viewport and
+// sticky boxes follow it within their containing blocks; the rest of the
+// layout is unaffected.
+func (d *Document) ScrollTo(x, y float32) {
+	root := d.Layout
+	d.scrollX, d.scrollY = root.clampScroll(x, y)
+	root.scrollX, root.scrollY = d.scrollX, d.scrollY
+	root.placeScrolled()
+}
+
+// Invalidate marks the styled nodes affected by records dirty and reports
+// whether anything needs to be recomputed.
+func (d *Document) Invalidate(records []dom.MutationRecord) bool {
+	changed := false
+	for _, r := range records {
+		if r.Target == d.DOM && r.Type == dom.MutationChildList {
+			// The document element itself was replaced.
+			changed = true
+			continue
+		}
+		target := d.nodes[r.Target]
+		if target == nil {
+			// Mutations inside subtrees that are not styled yet are picked
+			// up when their styled ancestor is rebuilt.
+			continue
+		}
+		changed = true
+		switch r.Type {
+		case dom.MutationCharacterData:
+			d.mark(target, styleSelf)
+		case dom.MutationAttributes:
+			// Sibling combinators can make later siblings depend on target.
+			d.mark(target, styleSubtree)
+			d.markFollowingSiblings(target)
+		case dom.MutationChildList:
+			for _, n := range r.RemovedNodes {
+				d.forget(n)
+			}
+			d.mark(target, styleSelf|styleChildren)
+			// Structural pseudo-classes depend on sibling positions.
+			for _, c := range target.Children {
+				c.dirty |= styleSubtree
+			}
+		}
+	}
+	return changed
+}
+
+func (d *Document) mark(s *StyledNode, flags styleDirt) {
+	s.dirty |= flags
+	for p := s.Parent; p != nil && p.dirty&styleDescendant == 0; p = p.Parent {
+		p.dirty |= styleDescendant
+	}
+}
+
+func (d *Document) markFollowingSiblings(s *StyledNode) {
+	if s.Parent == nil {
+		return
+	}
+	after := false
+	for _, c := range s.Parent.Children {
+		if after {
+			c.dirty |= styleSubtree
+		}
+		after = after || c == s
+	}
+	d.mark(s.Parent, styleDescendant)
+}
+
+// restyle recomputes dirty styled nodes. force restyles the whole subtree.
+func (d *Document) restyle(s *StyledNode, force bool) {
+	force = force || s.dirty&styleSubtree != 0
+	if force || s.dirty&styleSelf != 0 {
+		old := s.SpecifiedValues
+		// The box has to be regenerated from the new values.
+		s.dirty |= styleSelf
+		s.SpecifiedValues = specifiedValues(s.Node, d.rules, s.Parent, d.viewport)
+		if !sameInherited(old, s.SpecifiedValues) {
+			// Children inherit from the new values.
+			force = true
+		}
+	}
+	if s.dirty&styleChildren != 0 {
+		d.syncChildren(s)
+	}
+	if force || s.dirty&styleDescendant != 0 || s.dirty&styleChildren != 0 {
+		for _, c := range s.Children {
+			if force || c.dirty != 0 {
+				d.restyle(c, force)
+			}
+		}
+	}
+}
+
+func sameInherited(a, b map[string]string) bool {
+	for name := range inheritedProperties {
+		if a[name] != b[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// syncChildren matches s.Children to the DOM children of s.Node, keeping the
+// styled nodes of children that are still present.
+func (d *Document) syncChildren(s *StyledNode) {
+	old := make(map[*dom.Node]*StyledNode, len(s.Children))
+	for _, c := range s.Children {
+		old[c.Node] = c
+	}
+	s.Children = s.Children[:0]
+	for _, child := range s.Node.Children {
+		c := old[child]
+		if c == nil {
+			c = styleTree(child, d.rules, s, d.viewport)
+		}
+		if d.nodes[child] != c {
+			d.index(c)
+		}
+		c.Parent = s
+		s.Children = append(s.Children, c)
+	}
+}
+
+// rebuild regenerates the layout boxes above dirty styled nodes and clears
+// the dirty flags. Boxes of clean subtrees are reused as they are.
+func (d *Document) rebuild() {
+	d.Layout = buildLayoutTree(d.Style, func(s *StyledNode) *LayoutBox {
+		if s.dirty == 0 {
+			return s.box
+		}
+		return nil
+	})
+	clearDirt(d.Style)
+}
+
+func clearDirt(s *StyledNode) {
+	if s.dirty == 0 {
+		return
+	}
+	s.dirty = 0
+	for _, c := range s.Children {
+		clearDirt(c)
+	}
+}
+
+func (d *Document) index(s *StyledNode) {
+	d.nodes[s.Node] = s
+	for _, c := range s.Children {
+		d.index(c)
+	}
+}
+
+func (d *Document) forget(n *dom.Node) {
+	delete(d.nodes, n)
+	for _, c := range n.Children {
+		d.forget(c)
+	}
+}
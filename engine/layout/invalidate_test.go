@@ -0,0 +1,106 @@
+package layout
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"prymis/engine/dom"
+	"prymis/engine/parser"
+)
+
+const invalidateCSS = `
+html, body, div, p, ul, li, section { display: block; }
+body { margin: 8px; }
+.wide { width: 200px; }
+.on p { padding-left: 30px; }
+li:first-child { margin-left: 20px; }
+.a + p { padding-top: 10px; }
+#big { font-size: 30px; }
+.narrow { width: 60px; }
+`
+
+const invalidateHTML = `<html><body>
+<div id="box">short text</div>
+<section id="sec"><p id="p1">first paragraph</p><p id="p2">second</p></section>
+<ul id="list"><li id="l1">one</li><li id="l2">two</li></ul>
+<div id="inl"><span>inline <b id="bold">bold</b> text</span></div>
+<p id="last" class="narrow">some words that wrap in a narrow box</p>
+</body></html>`
+
+// describe writes the geometry of the layout tree rooted at b, one box or
+// fragment per line.
+func describe(sb *strings.Builder, b *LayoutBox, depth int) {
+	n := b.StyledNode.Node
+	fmt.Fprintf(sb, "%*s%s %q t%d %v\n", depth*2, "", n.TagName, n.GetAttribute("id"), b.BoxType, b.Dimensions)
+	for _, l := range b.Lines {
+		fmt.Fprintf(sb, "%*sline %v\n", depth*2+1, "", l.Rect)
+		for _, f := range l.Fragments {
+			fmt.Fprintf(sb, "%*sfrag %d %q %v\n", depth*2+2, "", f.Kind, f.Text, f.Rect)
+		}
+	}
+	for _, c := range b.Children {
+		describe(sb, c, depth+1)
+	}
+}
+
+func TestUpdateMatchesFreshLayout(t *testing.T) {
+	byID := func(doc *dom.Node, id string) *dom.Node { return doc.GetElementById(id) }
+	tests := []struct {
+		name   string
+		mutate func(doc *dom.Node)
+	}{
+		{"set class", func(doc *dom.Node) { byID(doc, "box").SetAttribute("class", "wide") }},
+		{"ancestor class", func(doc *dom.Node) { byID(doc, "sec").SetAttribute("class", "on") }},
+		{"sibling combinator", func(doc *dom.Node) { byID(doc, "p1").SetAttribute("class", "a") }},
+		{"set id", func(doc *dom.Node) { byID(doc, "box").SetAttribute("id", "big") }},
+		{"remove attribute", func(doc *dom.Node) { byID(doc, "last").RemoveAttribute("class") }},
+		{"text data", func(doc *dom.Node) {
+			byID(doc, "box").FirstChild().SetTextContent("a much longer text that now wraps onto more lines than before")
+		}},
+		{"element text", func(doc *dom.Node) { byID(doc, "p2").SetTextContent("replaced") }},
+		{"append", func(doc *dom.Node) {
+			byID(doc, "list").AppendChild(dom.Element("li", dom.AttrMap{}, []*dom.Node{dom.Text("three")}))
+		}},
+		{"insert first", func(doc *dom.Node) {
+			list := byID(doc, "list")
+			list.InsertBefore(dom.Element("li", dom.AttrMap{}, []*dom.Node{dom.Text("zero")}), list.FirstChild())
+		}},
+		{"remove", func(doc *dom.Node) { byID(doc, "sec").RemoveChild(byID(doc, "p1")) }},
+		{"replace", func(doc *dom.Node) {
+			byID(doc, "list").ReplaceChild(dom.Element("li", dom.AttrMap{}, []*dom.Node{dom.Text("new")}), byID(doc, "l2"))
+		}},
+		{"block in inline", func(doc *dom.Node) {
+			byID(doc, "bold").AppendChild(dom.Element("div", dom.AttrMap{}, []*dom.Node{dom.Text("block")}))
+		}},
+		{"several", func(doc *dom.Node) {
+			byID(doc, "box").SetAttribute("class", "wide")
+			byID(doc, "sec").RemoveChild(byID(doc, "p2"))
+			byID(doc, "l1").FirstChild().SetTextContent("uno")
+		}},
+	}
+	rules := parser.NewCSSParser(invalidateCSS).Parse()
+	viewport := Dimensions{Content: Rect{Width: 400, Height: 300}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := dom.NewDocument(parser.NewHTMLParser(invalidateHTML).Parse())
+			page := NewDocument(doc, rules, "", nil)
+			defer page.Close()
+			page.Update(viewport)
+			tt.mutate(doc)
+			// The event loop delivers records before every update.
+			dom.DeliverMutationRecords()
+			page.Update(viewport)
+
+			fresh := NewDocument(doc, rules, "", nil)
+			defer fresh.Close()
+			fresh.Update(viewport)
+			var got, want strings.Builder
+			describe(&got, page.Layout, 0)
+			describe(&want, fresh.Layout, 0)
+			if got.String() != want.String() {
+				t.Errorf("incremental layout differs from a fresh one\ngot:\n%s\nwant:\n%s", got.String(), want.String())
+			}
+		})
+	}
+}
@@ -17,11 +17,88 @@ type Dimensions struct {
 	Margin  EdgeSizes
 }
 
+func (r Rect) ExpandedBy(edge EdgeSizes) Rect {
+	return Rect{
+		X:      r.X - edge.Left,
+		Y:      r.Y - edge.Top,
+		Width:  r.Width + edge.Left + edge.Right,
+		Height: r.Height + edge.Top + edge.Bottom,
+	}
+}
+
+func (r Rect) Contains(x, y float32) bool {
+	return x >= r.X && x < r.X+r.Width && y >= r.Y && y < r.Y+r.Height
+}
+
+func (d Dimensions) PaddingBox() Rect {
+	return d.Content.ExpandedBy(d.Padding)
+}
+
+func (d Dimensions) BorderBox() Rect {
+	return d.PaddingBox().ExpandedBy(d.Border)
+}
+
+func (d Dimensions) MarginBox() Rect {
+	return d.BorderBox().ExpandedBy(d.Margin)
+}
+
 type LayoutBox struct {
 	Dimensions Dimensions
 	BoxType    BoxType
 	StyledNode *StyledNode
 	Children   []*LayoutBox
+	// Lines holds the line boxes of a block container with inline content.
+	Lines []*LineBox
+
+	// valid is set once the box has been laid out in container and cleared
+	// when the box is rebuilt after an invalidation.
+	valid     bool
+	container Dimensions
+
+	// Margins after collapsing with adjoining child margins, as seen by the
+	// parent. collapsesThrough marks an empty block whose top and bottom
+	// margins adjoin.
+	marginTop        collapsedMargin
+	marginBottom     collapsedMargin
+	collapsesThrough bool
+
+	// continued marks the pieces of an inline box split around block-level
+	// boxes; only the first piece has the start edge and only the last the
+	// end edge.
+	continued continuation
+
+	// override holds the content size a flex or grid container assigned to
+	// one of its items, which takes the place of width and height.
+	override sizeOverride
+
+	// wrapped is the table box inside a table wrapper box.
+	wrapped *LayoutBox
+	// collapsed holds the borders of a table or cell box in the collapsing
+	// border model, resolved against those of the adjoining table parts.
+	collapsed *[4]tableBorder
+
+	// staticX and staticY are the static position of an absolutely
+	// positioned box: where its margin box would start in normal flow.
+	staticX, staticY float32
+	// offsetX and offsetY are how far relative or sticky positioning has
+	// moved the box from its place in flow.
+	offsetX, offsetY float32
+	// viewport is the part of the canvas the root box is shown in. scrollX
+	// and scrollY are how far the viewport, for the root, or the content of
+	// a scroll container is scrolled.
+	viewport         Rect
+	scrollX, scrollY float32
+
+	// floats is the float context of the block formatting context the box
+	// takes part in when it does not establish one of its own; addsFloats
+	// records that laying the box out placed floats in it.
+	floats     *floatContext
+	addsFloats bool
+}
+
+type sizeOverride struct {
+	width, height       float32
+	hasWidth, hasHeight bool
 }
 
 type BoxType int
@@ -30,53 +107,152 @@ const (
 	BlockNode BoxType = iota
 	InlineNode
 	AnonymousBlock
+	// InlineBlockNode is an atomic inline: an inline-block or a replaced
+	// inline element, placed on a line as a single unit.
+	InlineBlockNode
 )
 
 func NewLayoutTree(node *StyledNode) *LayoutBox {
+	return buildLayoutTree(node, nil)
+}
+
+// buildLayoutTree generates the box tree for node. reuse, when non-nil, may
+// return an existing box for a styled subtree that has not changed.
+func buildLayoutTree(node *StyledNode, reuse func(*StyledNode) *LayoutBox) *LayoutBox {
+	if reuse != nil {
+		if box := reuse(node); box != nil {
+			return box
+		}
+	}
 	root := &LayoutBox{
 		StyledNode: node,
+		BoxType:    boxTypeOf(node),
 	}
-	if node.SpecifiedValues["display"] == "inline" {
-		root.BoxType = InlineNode
-	} else {
-		root.BoxType = BlockNode
+	node.box = root
+	if node.isReplaced() {
+		return root
 	}
 
 	for _, child := range node.Children {
-		if child.SpecifiedValues["display"] == "none" {
+		if child.Node.NodeType == dom.ElementNode && child.SpecifiedValues["display"] == "none" {
 			continue
 		}
-		root.Children = append(root.Children, NewLayoutTree(child))
+		root.Children = append(root.Children, buildLayoutTree(child, reuse))
+	}
+	root.Children = tableChildren(root, root.Children)
+	display := root.tableDisplay()
+	if root.BoxType != InlineNode && !isTabular(display) {
+		root.Children = wrapInlineRuns(node, root.Children)
+	}
+	if display == "table" || display == "inline-table" {
+		root = wrapTable(root)
+		node.box = root
 	}
 	return root
 }
 
 func (b *LayoutBox) Layout(containerDimensions Dimensions) {
-	switch b.BoxType {
-	case BlockNode:
-		b.layoutBlock(containerDimensions)
-	case InlineNode:
-		// Primitive: treat as block for move
+	// A box that flows around floats outside it, or places its floats
+	// among them, is laid out again every time.
+	n := 0
+	if b.floats != nil {
+		n = len(b.floats.floats)
+		if n > 0 || b.addsFloats {
+			b.valid = false
+		}
+	}
+	if b.valid {
+		if containerDimensions == b.container {
+			return
+		}
+		// Only the position changed: move the cached geometry.
+		if containerDimensions.Content.Width == b.container.Content.Width {
+			old := b.container.Content
+			now := containerDimensions.Content
+			b.translate(now.X-old.X, (now.Y+now.Height)-(old.Y+old.Height))
+			b.container = containerDimensions
+			return
+		}
+	}
+	b.valid = true
+	b.container = containerDimensions
+	b.offsetX, b.offsetY = 0, 0
+	switch {
+	case b.StyledNode.isReplaced():
+		b.layoutReplaced(containerDimensions, b.BoxType != InlineBlockNode)
+	case b.BoxType == InlineBlockNode:
+		b.layoutInlineBlock(containerDimensions)
+	default:
+		// Inline boxes are laid out by the line boxes of their container;
+		// one laid out on its own, such as an inline root, acts as a block.
 		b.layoutBlock(containerDimensions)
 	}
+	b.positionBox(containerDimensions)
+	b.addsFloats = b.floats != nil && len(b.floats.floats) > n
 }
 
-func (b *LayoutBox) layoutBlock(container Dimensions) {
-	// Child's width = container's width - margins/paddings/borders
-	// For simplicity, just use container width
-	b.Dimensions.Content.Width = container.Content.Width
-	b.Dimensions.Content.X = container.Content.X
-	b.Dimensions.Content.Y = container.Content.Y + container.Content.Height
-
-	var totalHeight float32
+func (b *LayoutBox) translate(dx, dy float32) {
+	b.Dimensions.Content.X += dx
+	b.Dimensions.Content.Y += dy
+	for _, line := range b.Lines {
+		line.translate(dx, dy)
+	}
 	for _, child := range b.Children {
-		child.Layout(b.Dimensions)
-		b.Dimensions.Content.Height += child.Dimensions.Content.Height
-		totalHeight += child.Dimensions.Content.Height
+		child.translate(dx, dy)
+		child.container.Content.X += dx
+		child.container.Content.Y += dy
+		child.staticX += dx
+		child.staticY += dy
 	}
+}
 
-	// If no children, give some default height if it's a div or something
-	if len(b.Children) == 0 && b.StyledNode.Node.NodeType == dom.ElementNode {
-		b.Dimensions.Content.Height = 20
+// HitTest returns the innermost box whose border box contains (x, y), or nil.
+// Later siblings are tested first since they paint on top. Inline content is
+// hit tested through the fragments of its line boxes, the content of a box
+// that clips its overflow only within its padding box, as scrolled, and a
+// transformed box where its transform has put it.
+func (b *LayoutBox) HitTest(x, y float32) *LayoutBox {
+	x, y, ok := b.localPoint(x, y)
+	if !ok {
+		return nil
+	}
+	if hit := b.hitContent(x, y); hit != nil {
+		return hit
+	}
+	if b.Dimensions.BorderBox().Contains(x, y) {
+		return b
+	}
+	return nil
+}
+
+func (b *LayoutBox) hitContent(x, y float32) *LayoutBox {
+	x, y, ok := b.contentPoint(x, y)
+	if !ok {
+		return nil
+	}
+	for i := len(b.Lines) - 1; i >= 0; i-- {
+		frags := b.Lines[i].Fragments
+		for j := len(frags) - 1; j >= 0; j-- {
+			f := frags[j]
+			// An atomic inline may be transformed away from its fragment.
+			if f.Kind == AtomicFragment {
+				if hit := f.Box.HitTest(x, y); hit != nil {
+					return hit
+				}
+				continue
+			}
+			if f.Rect.Contains(x, y) {
+				return f.Box
+			}
+		}
+	}
+	for i := len(b.Children) - 1; i >= 0; i-- {
+		if b.Children[i].isInlineLevel() {
+			continue
+		}
+		if hit := b.Children[i].HitTest(x, y); hit != nil {
+			return hit
+		}
 	}
+	return nil
 }
@@ -0,0 +1,180 @@
+package layout
+
+import "testing"
+
+// layoutPage lays out html, styled by the style sheets in it, in a viewport
+// width pixels wide.
+func layoutPage(t *testing.T, html string, width float32) *Document {
+	t.Helper()
+	page := openPage(html, nil, "", nil)
+	t.Cleanup(page.Close)
+	page.Update(Dimensions{Content: Rect{Width: width, Height: 600}})
+	return page
+}
+
+// boxOf returns the first box of the element with the given id.
+func boxOf(t *testing.T, page *Document, id string) *LayoutBox {
+	t.Helper()
+	n := page.DOM.GetElementById(id)
+	var find func(b *LayoutBox) *LayoutBox
+	find = func(b *LayoutBox) *LayoutBox {
+		if b.StyledNode != nil && b.StyledNode.Node == n {
+			return b
+		}
+		for _, c := range b.Children {
+			if f := find(c); f != nil {
+				return f
+			}
+		}
+		return nil
+	}
+	if n == nil || page.Layout == nil {
+		t.Fatalf("no element #%s", id)
+	}
+	b := find(page.Layout)
+	if b == nil {
+		t.Fatalf("no box for #%s", id)
+	}
+	return b
+}
+
+// checkBoxes lays out html in a page 300 pixels wide with the base style
+// sheet plus css, and checks the border boxes of the elements in want.
+func checkBoxes(t *testing.T, base, css, html string, want map[string]Rect) {
+	t.Helper()
+	page := layoutPage(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+`+base+css+`</style></head><body>`+html+`</body></html>`, 300)
+	for id, r := range want {
+		if got := boxOf(t, page, id).Dimensions.BorderBox(); !nearRect(got, r) {
+			t.Errorf("#%s is at %v, want %v", id, got, r)
+		}
+	}
+}
+
+// nearRect reports whether a and b agree to within rounding errors.
+func nearRect(a, b Rect) bool {
+	near := func(x, y float32) bool { return x-y < 0.01 && y-x < 0.01 }
+	return near(a.X, b.X) && near(a.Y, b.Y) && near(a.Width, b.Width) && near(a.Height, b.Height)
+}
+
+func TestBlockBoxModel(t *testing.T) {
+	tests := []struct {
+		name string
+		css  string
+		want map[string]Rect
+	}{
+		{
+			name: "auto width fills the container",
+			css:  "#a { margin: 10px 20px; padding: 5px; border: 2px solid; height: 30px; }",
+			want: map[string]Rect{"a": {20, 10, 260, 44}},
+		},
+		{
+			name: "auto margins center",
+			css:  "#a { width: 100px; margin: 0 auto; }",
+			want: map[string]Rect{"a": {100, 0, 100, 10}},
+		},
+		{
+			name: "auto left margin",
+			css:  "#a { width: 100px; margin-left: auto; }",
+			want: map[string]Rect{"a": {200, 0, 100, 10}},
+		},
+		{
+			name: "over-constrained",
+			css:  "#a { width: 100px; margin: 0 50px; }",
+			want: map[string]Rect{"a": {50, 0, 100, 10}},
+		},
+		{
+			name: "over-constrained right to left",
+			css:  "#a { width: 100px; margin: 0 50px; direction: rtl; }",
+			want: map[string]Rect{"a": {150, 0, 100, 10}},
+		},
+		{
+			name: "too wide drops auto margins",
+			css:  "#a { width: 400px; margin: 0 auto; }",
+			want: map[string]Rect{"a": {0, 0, 400, 10}},
+		},
+		{
+			name: "border-box sizing",
+			css:  "#a { box-sizing: border-box; width: 100px; height: 50px; padding: 10px; border: 5px solid; }",
+			want: map[string]Rect{"a": {0, 0, 100, 50}},
+		},
+		{
+			name: "max-width",
+			css:  "#a { max-width: 100px; }",
+			want: map[string]Rect{"a": {0, 0, 100, 10}},
+		},
+		{
+			name: "min-width beats max-width",
+			css:  "#a { width: 50px; min-width: 80px; max-width: 60px; }",
+			want: map[string]Rect{"a": {0, 0, 80, 10}},
+		},
+		{
+			name: "min-height",
+			css:  "#a { min-height: 40px; }",
+			want: map[string]Rect{"a": {0, 0, 300, 40}},
+		},
+		{
+			name: "max-height",
+			css:  "#a { height: 50px; max-height: 30px; }",
+			want: map[string]Rect{"a": {0, 0, 300, 30}},
+		},
+		{
+			name: "percentages of the container width",
+			css:  "#a { width: 50%; padding: 10% 0 0 10%; }",
+			want: map[string]Rect{"a": {0, 0, 180, 40}},
+		},
+		{
+			name: "font-relative units",
+			css:  "#a { font-size: 20px; width: 5em; height: 1em; margin-left: 1rem; }",
+			want: map[string]Rect{"a": {16, 0, 100, 20}},
+		},
+		{
+			name: "viewport units",
+			css:  "#b { width: 50vw; height: 10vh; margin: 0 0 0 5vw; }",
+			want: map[string]Rect{"o": {0, 20, 300, 60}, "b": {15, 20, 150, 60}},
+		},
+		{
+			name: "absolute units",
+			css:  "#a { width: 1in; height: 12pt; }",
+			want: map[string]Rect{"a": {0, 0, 96, 16}},
+		},
+		{
+			name: "nested in padding",
+			css:  "#o { padding: 10px; border: 1px solid; } #b { margin: 5px; }",
+			want: map[string]Rect{"o": {0, 20, 300, 42}, "b": {16, 36, 268, 10}},
+		},
+		{
+			name: "stacked siblings",
+			css:  "#a { margin-bottom: 10px; } #c { height: 20px; }",
+			want: map[string]Rect{"a": {0, 0, 300, 10}, "c": {0, 20, 300, 20}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkBoxes(t, "div { height: 10px; } #o { height: auto; }\n", tt.css,
+				`<div id="a"></div><div id="c"></div><div id="o"><div id="b"></div></div>`, tt.want)
+		})
+	}
+}
+
+func TestViewportUnits(t *testing.T) {
+	page := layoutPage(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#a { width: 10vw; height: 5vh; font-size: 2vw; }
+#b { width: 2em; height: 1em; }
+</style></head><body><div id="a"><div id="b"></div></div></body></html>`, 300)
+	check := func(want map[string]Rect) {
+		t.Helper()
+		for id, r := range want {
+			if got := boxOf(t, page, id).Dimensions.BorderBox(); !nearRect(got, r) {
+				t.Errorf("#%s is at %v, want %v", id, got, r)
+			}
+		}
+	}
+	check(map[string]Rect{"a": {0, 0, 30, 30}, "b": {0, 0, 12, 6}})
+
+	// Resizing the viewport resolves the units, font sizes included, again.
+	page.Update(Dimensions{Content: Rect{Width: 500, Height: 200}})
+	check(map[string]Rect{"a": {0, 0, 50, 10}, "b": {0, 0, 20, 10}})
+}
@@ -0,0 +1,180 @@
+package layout
+
+import (
+	"strings"
+	"unicode"
+)
+
+// objectReplacement stands for an atomic inline in the text of an inline
+// formatting context, so that line breaking treats it like a character.
+const objectReplacement = '\ufffc'
+
+// whiteSpace is how a white-space value treats spaces, newlines and wrapping
+// (CSS Text 3 section 3).
+type whiteSpace struct {
+	collapse         bool
+	preserveNewlines bool
+	wrap             bool
+	breakSpaces      bool
+}
+
+func whiteSpaceOf(s *StyledNode) whiteSpace {
+	switch s.Value("white-space") {
+	case "pre":
+		return whiteSpace{preserveNewlines: true}
+	case "nowrap":
+		return whiteSpace{collapse: true}
+	case "pre-wrap":
+		return whiteSpace{preserveNewlines: true, wrap: true}
+	case "break-spaces":
+		return whiteSpace{preserveNewlines: true, wrap: true, breakSpaces: true}
+	case "pre-line":
+		return whiteSpace{collapse: true, preserveNewlines: true, wrap: true}
+	}
+	return whiteSpace{collapse: true, wrap: true}
+}
+
+// processWhiteSpace applies the first phase of white space processing to the
+// text of one text node. prevSpace carries whether the preceding text in the
+// formatting context ended with a collapsible space. Newlines that survive
+// are forced line breaks.
+func processWhiteSpace(text string, ws whiteSpace, prevSpace *bool) []rune {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	out := make([]rune, 0, len(text))
+	for _, r := range text {
+		if !ws.collapse {
+			out = append(out, r)
+			*prevSpace = false
+			continue
+		}
+		switch {
+		case r == '\n' && ws.preserveNewlines:
+			// Spaces around a preserved newline are removed.
+			for len(out) > 0 && out[len(out)-1] == ' ' {
+				out = out[:len(out)-1]
+			}
+			out = append(out, '\n')
+			*prevSpace = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\f':
+			if !*prevSpace {
+				out = append(out, ' ')
+				*prevSpace = true
+			}
+		default:
+			out = append(out, r)
+			*prevSpace = false
+		}
+	}
+	return out
+}
+
+// transformText applies text-transform.
+func transformText(text string, transform string) string {
+	switch transform {
+	case "uppercase":
+		return strings.ToUpper(text)
+	case "lowercase":
+		return strings.ToLower(text)
+	case "capitalize":
+		runes := []rune(text)
+		start := true
+		for i, r := range runes {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				if start {
+					runes[i] = unicode.ToTitle(r)
+				}
+				start = false
+			} else {
+				start = unicode.IsSpace(r) || unicode.IsPunct(r) && r != '\'' && r != '\u2019'
+			}
+		}
+		return string(runes)
+	}
+	return text
+}
+
+type breakKind uint8
+
+const (
+	breakNone breakKind = iota
+	breakAllowed
+	breakMandatory
+)
+
+// breakRules are the properties of the text before a position that decide
+// whether a line may break there.
+type breakRules struct {
+	ws        whiteSpace
+	wordBreak string
+}
+
+func breakRulesOf(s *StyledNode) breakRules {
+	return breakRules{ws: whiteSpaceOf(s), wordBreak: s.Value("word-break")}
+}
+
+// lineBreaks returns, for every index i of text, whether a line may break
+// before text[i]. It is a reduced form of the Unicode line breaking algorithm
+// (UAX #14): breaks are allowed after spaces, after hyphens inside words,
+// around ideographs and atomic inlines, and are required after newlines.
+func lineBreaks(text []rune, rules func(i int) breakRules) []breakKind {
+	breaks := make([]breakKind, len(text))
+	for i := 1; i < len(text); i++ {
+		prev, cur := text[i-1], text[i]
+		r := rules(i - 1)
+		if prev == '\n' {
+			breaks[i] = breakMandatory
+			continue
+		}
+		if !r.ws.wrap || cur == '\n' {
+			continue
+		}
+		switch {
+		case isBreakSpace(prev):
+			if r.ws.breakSpaces || !isBreakSpace(cur) {
+				breaks[i] = breakAllowed
+			}
+		case isBreakSpace(cur):
+			// Never break before a space; it hangs at the end of the line.
+		case prev == '\u200b':
+			breaks[i] = breakAllowed
+		case isGlue(prev) || isGlue(cur) || isClosing(cur) || isOpening(prev):
+		case prev == objectReplacement || cur == objectReplacement:
+			breaks[i] = breakAllowed
+		case isHyphen(prev) && i >= 2 && isWordChar(text[i-2]) && unicode.IsLetter(cur):
+			breaks[i] = breakAllowed
+		case r.wordBreak == "break-all" && isWordChar(prev) && isWordChar(cur):
+			breaks[i] = breakAllowed
+		case r.wordBreak != "keep-all" && (isWide(prev) || isWide(cur)):
+			breaks[i] = breakAllowed
+		}
+	}
+	return breaks
+}
+
+func isBreakSpace(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// isGlue reports whether r prohibits breaks on both sides.
+func isGlue(r rune) bool {
+	return r == '\u00a0' || r == '\u202f' || r == '\u2060' || r == '\ufeff'
+}
+
+func isHyphen(r rune) bool {
+	return r == '-' || r == '\u2010' || r == '\u2013'
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isClosing reports closing punctuation that may not start a line.
+func isClosing(r rune) bool {
+	return strings.ContainsRune(")]},.;:!?、。，．！？：；」』）】〕〉》", r)
+}
+
+// isOpening reports opening punctuation that may not end a line.
+func isOpening(r rune) bool {
+	return strings.ContainsRune("([{「『（【〔〈《", r)
+}
@@ -0,0 +1,63 @@
+package layout
+
+// collapsedMargin accumulates adjoining margins (CSS 2.1 8.3.1): the largest
+// positive margin plus the most negative one.
+type collapsedMargin struct {
+	positive, negative float32
+}
+
+func marginOf(m float32) collapsedMargin {
+	if m < 0 {
+		return collapsedMargin{negative: m}
+	}
+	return collapsedMargin{positive: m}
+}
+
+func (c collapsedMargin) join(o collapsedMargin) collapsedMargin {
+	if o.positive > c.positive {
+		c.positive = o.positive
+	}
+	if o.negative < c.negative {
+		c.negative = o.negative
+	}
+	return c
+}
+
+func (c collapsedMargin) value() float32 {
+	return c.positive + c.negative
+}
+
+// establishesBFC reports whether the box is a block formatting context root,
+// whose margins never collapse with those of its children.
+func (b *LayoutBox) establishesBFC() bool {
+	style := b.StyledNode
+	if style.Parent == nil {
+		return true
+	}
+	if b.isScrollContainer() {
+		return true
+	}
+	switch style.Value("display") {
+	case "inline-block", "flow-root", "table", "inline-table", "table-cell", "table-caption", "flex", "inline-flex", "grid", "inline-grid":
+		return true
+	}
+	return !b.inFlow() || b.isFlexOrGridItem()
+}
+
+// inFlow reports whether the box takes part in normal flow. Floats and
+// absolutely positioned boxes do not, so their margins collapse with nothing.
+func (b *LayoutBox) inFlow() bool {
+	style := b.StyledNode
+	if f := style.Value("float"); f != "" && f != "none" {
+		return false
+	}
+	switch style.Value("position") {
+	case "absolute", "fixed":
+		return false
+	}
+	return true
+}
+
+func (b *LayoutBox) isFlexOrGridItem() bool {
+	return b.StyledNode.Parent != nil && b.StyledNode.Parent.isFlexOrGridContainer()
+}
@@ -0,0 +1,106 @@
+package layout
+
+import "testing"
+
+func TestMarginCollapsing(t *testing.T) {
+	tests := []struct {
+		name string
+		css  string
+		html string
+		// y is where the border box of #x starts.
+		y float32
+	}{
+		{
+			name: "siblings",
+			css:  "#a { margin-bottom: 20px; } #x { margin-top: 30px; }",
+			html: `<div id="a"></div><div id="x"></div>`,
+			y:    10 + 30,
+		},
+		{
+			name: "positive and negative",
+			css:  "#a { margin-bottom: 20px; } #x { margin-top: -5px; }",
+			html: `<div id="a"></div><div id="x"></div>`,
+			y:    10 + 15,
+		},
+		{
+			name: "both negative",
+			css:  "#a { margin-bottom: -10px; } #x { margin-top: -5px; }",
+			html: `<div id="a"></div><div id="x"></div>`,
+			y:    10 - 10,
+		},
+		{
+			name: "parent and first child",
+			css:  "#p { margin-top: 10px; } #x { margin-top: 25px; }",
+			html: `<div id="a"></div><div id="p"><div id="x"></div></div>`,
+			y:    10 + 25,
+		},
+		{
+			name: "parent border",
+			css:  "#p { margin-top: 10px; border-top: 1px solid; } #x { margin-top: 25px; }",
+			html: `<div id="a"></div><div id="p"><div id="x"></div></div>`,
+			y:    10 + 10 + 1 + 25,
+		},
+		{
+			name: "parent padding",
+			css:  "#p { margin-top: 10px; padding-top: 2px; } #x { margin-top: 25px; }",
+			html: `<div id="a"></div><div id="p"><div id="x"></div></div>`,
+			y:    10 + 10 + 2 + 25,
+		},
+		{
+			name: "parent formatting context",
+			css:  "#p { margin-top: 10px; overflow: hidden; } #x { margin-top: 25px; }",
+			html: `<div id="a"></div><div id="p"><div id="x"></div></div>`,
+			y:    10 + 10 + 25,
+		},
+		{
+			name: "through an empty block",
+			css:  "#a { margin-bottom: 10px; } #e { height: 0; margin: 20px 0 15px; } #x { margin-top: 5px; }",
+			html: `<div id="a"></div><div id="e"></div><div id="x"></div>`,
+			y:    10 + 20,
+		},
+		{
+			name: "last child and parent",
+			css:  "#c { margin-bottom: 30px; } #p { margin-bottom: 5px; } #x { margin-top: 10px; }",
+			html: `<div id="p"><div id="c"></div></div><div id="x"></div>`,
+			y:    10 + 30,
+		},
+		{
+			name: "empty clearing block",
+			css:  "#f { float: left; width: 10px; height: 50px; } #e { clear: both; }",
+			html: `<div id="p"><div id="f"></div><div id="e"></div></div><div id="x"></div>`,
+			y:    50,
+		},
+		{
+			// Clearance separates the margins of #e from those of #p above it;
+			// the bottom one still collapses through #p.
+			name: "clearing block with margins",
+			css:  "#f { float: left; width: 10px; height: 50px; } #e { clear: both; margin: 20px 0 15px; }",
+			html: `<div id="p"><div id="f"></div><div id="e"></div></div><div id="x"></div>`,
+			y:    50 + 15,
+		},
+		{
+			name: "empty block clearing nothing",
+			css:  "#f { float: right; width: 10px; height: 50px; } #e { clear: left; margin: 20px 0 15px; }",
+			html: `<div id="p"><div id="f"></div><div id="e"></div></div><div id="x"></div>`,
+			y:    20,
+		},
+		{
+			name: "inline-block",
+			css:  "#a { margin-bottom: 20px; } #i { display: inline-block; width: 10px; height: 10px; margin-top: 30px; }",
+			html: `<div id="a"></div><div id="x"><div id="i"></div></div>`,
+			y:    10 + 20,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := layoutPage(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+body > div, #c { height: 10px; }
+#p { height: auto; }
+`+tt.css+`</style></head><body>`+tt.html+`</body></html>`, 400)
+			if got := boxOf(t, page, "x").Dimensions.BorderBox().Y; got != tt.y {
+				t.Errorf("#x starts at %v, want %v", got, tt.y)
+			}
+		})
+	}
+}
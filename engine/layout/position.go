@@ -0,0 +1,315 @@
+package layout
+
+// isPositioned reports whether s has a position other than static, which
+// makes its box the containing block of absolutely positioned descendants.
+func (s *StyledNode) isPositioned() bool {
+	switch s.Value("position") {
+	case "relative", "absolute", "fixed", "sticky":
+		return true
+	}
+	return false
+}
+
+// isAbsolutelyPositioned reports whether b is taken out of flow by position:
+// absolute or fixed.
+func (b *LayoutBox) isAbsolutelyPositioned() bool {
+	switch b.StyledNode.Value("position") {
+	case "absolute", "fixed":
+		return true
+	}
+	return false
+}
+
+// setStaticPosition records where the margin box of an absolutely
+// positioned box would start in normal flow.
+func (b *LayoutBox) setStaticPosition(x, y float32) {
+	b.staticX, b.staticY = x, y
+}
+
+// flowBorderBox returns the border box b has in flow, before relative or
+// sticky positioning moves it. Containers place their children by it so that
+// the offsets survive being moved.
+func (b *LayoutBox) flowBorderBox() Rect {
+	r := b.Dimensions.BorderBox()
+	r.X -= b.offsetX
+	r.Y -= b.offsetY
+	return r
+}
+
+// positionBox finishes the layout of b in its container: it lays out the
+// absolutely positioned boxes it is the containing block of, keeps a scroll
+// container scrolled within its new content, applies position: relative, and
+// for the root places the boxes that depend on scrolling.
+func (b *LayoutBox) positionBox(container Dimensions) {
+	b.layoutOutOfFlow()
+	if b.StyledNode.Parent != nil && (b.scrollX != 0 || b.scrollY != 0) {
+		x, y := b.scrollX, b.scrollY
+		b.scrollX, b.scrollY = 0, 0
+		if b.isScrollContainer() {
+			b.scrollX, b.scrollY = b.clampScroll(x, y)
+		}
+	}
+	if b.StyledNode.Value("position") == "relative" {
+		b.offsetX, b.offsetY = b.relativeOffset(container.Content.Width)
+		b.translate(b.offsetX, b.offsetY)
+	}
+	if b.StyledNode.Parent == nil {
+		b.placeScrolled()
+	}
+}
+
+// relativeOffset returns how far position: relative moves b (CSS 2.1 9.4.3).
+// Of two opposite insets left (right in rtl) and top win. Percentages of top
+// and bottom count as 0 since the containing block height is not known yet.
+func (b *LayoutBox) relativeOffset(cbWidth float32) (dx, dy float32) {
+	style := b.StyledNode
+	left, right := style.inset("left", cbWidth), style.inset("right", cbWidth)
+	top, bottom := style.inset("top", 0), style.inset("bottom", 0)
+	switch {
+	case !left.auto && (right.auto || style.Value("direction") != "rtl"):
+		dx = left.px
+	case !right.auto:
+		dx = -right.px
+	}
+	switch {
+	case !top.auto:
+		dy = top.px
+	case !bottom.auto:
+		dy = -bottom.px
+	}
+	return dx, dy
+}
+
+// autoPx is a length resolved to pixels that may be auto, in which case px
+// is 0.
+type autoPx struct {
+	px   float32
+	auto bool
+}
+
+// inset resolves a property that may be auto against reference.
+func (s *StyledNode) inset(name string, reference float32) autoPx {
+	l := s.Length(name, autoLength)
+	if l.IsAuto() {
+		return autoPx{auto: true}
+	}
+	return autoPx{px: l.ToPx(reference, s.FontSize(), s.viewportRect())}
+}
+
+// layoutOutOfFlow lays out the absolutely positioned descendants b is the
+// containing block of when it is positioned, transformed or the root: those
+// not inside another positioned or transformed box. Fixed boxes belong to
+// the viewport and are placed by the root, unless a transformed box
+// contains them, which lays out all those not inside another.
+func (b *LayoutBox) layoutOutOfFlow() {
+	transformed := b.isTransformed()
+	if b.StyledNode.Parent != nil && !b.StyledNode.isPositioned() && !transformed {
+		return
+	}
+	cb := b.Dimensions.PaddingBox()
+	if b.StyledNode.Parent == nil {
+		cb = b.initialContainingBlock()
+	}
+	// abs reports whether the absolute boxes met still belong to b.
+	var walk func(box *LayoutBox, abs bool)
+	walk = func(box *LayoutBox, abs bool) {
+		for _, child := range box.Children {
+			position := child.StyledNode.Value("position")
+			switch {
+			case position == "absolute" && abs, position == "fixed" && transformed:
+				child.layoutAbsolute(cb, child.staticX, child.staticY)
+			}
+			switch {
+			case child.isTransformed():
+			case !child.StyledNode.isPositioned():
+				walk(child, abs)
+			case transformed:
+				walk(child, false)
+			}
+		}
+	}
+	walk(b, true)
+}
+
+// initialContainingBlock returns the containing block of the root: the
+// viewport the document was laid out for at its unscrolled position, or the
+// container of the root when it was laid out on its own.
+func (b *LayoutBox) initialContainingBlock() Rect {
+	if b.viewport.Width > 0 {
+		return b.viewport
+	}
+	c := b.container.Content
+	return Rect{X: c.X, Y: c.Y + c.Height, Width: c.Width}
+}
+
+// scrollport returns the part of the document visible through the viewport,
+// or through the scroll container b, given how far it is scrolled.
+func (b *LayoutBox) scrollport() Rect {
+	r := b.Dimensions.PaddingBox()
+	if b.StyledNode.Parent == nil {
+		r = b.initialContainingBlock()
+	}
+	r.X += b.scrollX
+	r.Y += b.scrollY
+	return r
+}
+
+// placeScrolled places the boxes of the tree rooted at b whose position
+// depends on scrolling: sticky boxes against their scroll container and fixed
+// boxes against the viewport, which keep their place on screen as the
+// document scrolls. Ancestors come first since they move their descendants.
+func (b *LayoutBox) placeScrolled() {
+	viewport := b.scrollport()
+	// contained reports whether a transformed box holds the fixed boxes met,
+	// which then scroll with it.
+	var walk func(box, block *LayoutBox, port Rect, contained bool)
+	walk = func(box, block *LayoutBox, port Rect, contained bool) {
+		for _, child := range box.Children {
+			childPort := port
+			switch child.StyledNode.Value("position") {
+			case "sticky":
+				if child.BoxType != InlineNode {
+					child.stick(block.Dimensions.Content, port)
+				}
+			case "fixed":
+				if !contained {
+					child.layoutAbsolute(viewport, child.staticX+b.scrollX, child.staticY+b.scrollY)
+					childPort = viewport
+				}
+			}
+			if child.isScrollContainer() {
+				childPort = child.scrollport()
+			}
+			childBlock := block
+			if child.BoxType != InlineNode {
+				childBlock = child
+			}
+			walk(child, childBlock, childPort, contained || child.isTransformed())
+		}
+	}
+	walk(b, b, viewport, false)
+}
+
+// stick applies position: sticky to b: it keeps its place in flow unless that
+// would take it closer than its insets to the edges of port, the visible part
+// of its scroll container, but it never leaves cb, the content box of its
+// containing block.
+func (b *LayoutBox) stick(cb, port Rect) {
+	style := b.StyledNode
+	m := b.Dimensions.Margin
+	r := b.flowBorderBox()
+	var dx, dy float32
+	if right := style.inset("right", port.Width); !right.auto && r.X+r.Width > port.X+port.Width-right.px {
+		dx = min(0, max(port.X+port.Width-right.px-r.X-r.Width, cb.X-r.X+m.Left))
+	}
+	if left := style.inset("left", port.Width); !left.auto && r.X < port.X+left.px {
+		dx = max(0, min(port.X+left.px-r.X, cb.X+cb.Width-r.X-r.Width-m.Right))
+	}
+	if bottom := style.inset("bottom", port.Height); !bottom.auto && r.Y+r.Height > port.Y+port.Height-bottom.px {
+		dy = min(0, max(port.Y+port.Height-bottom.px-r.Y-r.Height, cb.Y-r.Y+m.Top))
+	}
+	if top := style.inset("top", port.Height); !top.auto && r.Y < port.Y+top.px {
+		dy = max(0, min(port.Y+top.px-r.Y, cb.Y+cb.Height-r.Y-r.Height-m.Bottom))
+	}
+	b.translate(dx-b.offsetX, dy-b.offsetY)
+	b.offsetX, b.offsetY = dx, dy
+}
+
+// layoutAbsolute lays out an absolutely positioned box in cb, the padding box
+// of its containing block, following CSS 2.1 sections 10.3.7 and 10.6.4. Auto
+// insets fall back to the static position (staticX, staticY), and an auto
+// width shrinks to fit unless both horizontal insets are set.
+func (b *LayoutBox) layoutAbsolute(cb Rect, staticX, staticY float32) {
+	style := b.StyledNode
+	fontSize := style.FontSize()
+	zero := Length{Unit: "px"}
+	margin := func(name string) autoPx {
+		l := style.Length(name, zero)
+		if l.IsAuto() {
+			return autoPx{auto: true}
+		}
+		return autoPx{px: l.ToPx(cb.Width, fontSize, style.viewportRect())}
+	}
+	left, right := style.inset("left", cb.Width), style.inset("right", cb.Width)
+	top, bottom := style.inset("top", cb.Height), style.inset("bottom", cb.Height)
+	ml, mr := margin("margin-left"), margin("margin-right")
+	mt, mb := margin("margin-top"), margin("margin-bottom")
+
+	d := &b.Dimensions
+	d.Padding.Left, d.Border.Left = b.edge(sideLeft, cb.Width)
+	d.Padding.Right, d.Border.Right = b.edge(sideRight, cb.Width)
+	d.Padding.Top, d.Border.Top = b.edge(sideTop, cb.Width)
+	d.Padding.Bottom, d.Border.Bottom = b.edge(sideBottom, cb.Width)
+	frameX := d.Padding.Left + d.Padding.Right + d.Border.Left + d.Border.Right
+	frameY := d.Padding.Top + d.Padding.Bottom + d.Border.Top + d.Border.Bottom
+
+	size := sizeOverride{hasWidth: true}
+	width, height := style.Length("width", autoLength), style.Length("height", autoLength)
+	switch {
+	case style.isReplaced():
+		size.width, size.height = b.replacedSize(cb.Width)
+		size.hasHeight = true
+	case !width.IsAuto():
+		size.width = b.constrainWidth(b.contentSize(width.ToPx(cb.Width, fontSize, style.viewportRect()), frameX), cb.Width, frameX)
+	default:
+		avail := cb.Width - left.px - right.px - ml.px - mr.px - frameX
+		if left.auto || right.auto {
+			avail = b.shrinkToFit(avail)
+		}
+		size.width = b.constrainWidth(avail, cb.Width, frameX)
+	}
+	switch {
+	case style.isReplaced():
+	case !height.IsAuto():
+		size.height = b.clampHeight(b.contentSize(height.ToPx(cb.Height, fontSize, style.viewportRect()), frameY))
+		size.hasHeight = true
+	case !top.auto && !bottom.auto:
+		size.height = b.clampHeight(max(0, cb.Height-top.px-bottom.px-mt.px-mb.px-frameY))
+		size.hasHeight = true
+	}
+	b.layoutAssigned(Dimensions{Content: Rect{X: cb.X, Y: cb.Y, Width: cb.Width}}, size)
+
+	bb := d.BorderBox()
+	rtl := style.Value("direction") == "rtl"
+	x, marginLeft, marginRight := placeAbsolute(cb.Width, bb.Width, staticX-cb.X, left, right, ml, mr, rtl)
+	y, marginTop, marginBottom := placeAbsolute(cb.Height, bb.Height, staticY-cb.Y, top, bottom, mt, mb, false)
+	b.translate(cb.X+x+marginLeft-bb.X, cb.Y+y+marginTop-bb.Y)
+	d.Margin = EdgeSizes{Top: marginTop, Right: marginRight, Bottom: marginBottom, Left: marginLeft}
+}
+
+// placeAbsolute resolves one axis of an absolutely positioned box whose
+// border box size is known. It returns the offset of the margin box from the
+// start of a containing block of cbSize and the two margins. With both insets
+// auto the box stays at its static position; with one auto it is placed
+// against the other. With both set, auto margins share the free space,
+// otherwise the end inset, or the start one when endWins, is ignored.
+func placeAbsolute(cbSize, size, static float32, start, end, marginStart, marginEnd autoPx, endWins bool) (pos, ms, me float32) {
+	ms, me = marginStart.px, marginEnd.px
+	switch {
+	case start.auto && end.auto:
+		return static, ms, me
+	case start.auto:
+		return cbSize - end.px - me - size - ms, ms, me
+	case end.auto:
+		return start.px, ms, me
+	}
+	free := cbSize - start.px - end.px - size - ms - me
+	switch {
+	case marginStart.auto && marginEnd.auto:
+		switch {
+		case free >= 0:
+			ms, me = free/2, free/2
+		case endWins:
+			ms = free
+		default:
+			me = free
+		}
+	case marginStart.auto:
+		ms = free
+	case marginEnd.auto:
+		me = free
+	case endWins:
+		return start.px + free, ms, me
+	}
+	return start.px, ms, me
+}
@@ -0,0 +1,113 @@
+package layout
+
+import "testing"
+
+func TestPositioning(t *testing.T) {
+	// #p starts at y 10 after #a, with its padding box 280 by 120 pixels.
+	const page = `<div id="a"></div><div id="p"><div id="c"></div><div id="x"></div></div><div id="b"></div>`
+	tests := []struct {
+		name string
+		css  string
+		want map[string]Rect
+	}{
+		{
+			name: "relative",
+			css:  "#a { position: relative; left: 10px; top: 5px; }",
+			want: map[string]Rect{"a": {10, 5, 300, 10}, "p": {20, 10, 280, 120}},
+		},
+		{
+			name: "relative from the far sides",
+			css:  "#a { position: relative; right: 10px; bottom: 5px; }",
+			want: map[string]Rect{"a": {-10, -5, 300, 10}},
+		},
+		{
+			name: "relative over-constrained",
+			css:  "#a { position: relative; left: 10px; right: 30px; top: 5px; bottom: 30px; }",
+			want: map[string]Rect{"a": {10, 5, 300, 10}},
+		},
+		{
+			name: "absolute",
+			css:  "#x { position: absolute; top: 5px; left: 5px; }",
+			want: map[string]Rect{"x": {25, 15, 50, 20}, "b": {0, 130, 300, 10}},
+		},
+		{
+			name: "absolute from the far sides",
+			css:  "#x { position: absolute; right: 0; bottom: 0; }",
+			want: map[string]Rect{"x": {250, 110, 50, 20}},
+		},
+		{
+			name: "absolute at its static position",
+			css:  "#x { position: absolute; }",
+			want: map[string]Rect{"x": {20, 30, 50, 20}},
+		},
+		{
+			name: "absolute stretched",
+			css:  "#x { position: absolute; left: 10px; right: 20px; width: auto; }",
+			want: map[string]Rect{"x": {30, 30, 250, 20}},
+		},
+		{
+			name: "absolute centered",
+			css:  "#x { position: absolute; left: 0; right: 0; margin: 0 auto; }",
+			want: map[string]Rect{"x": {135, 30, 50, 20}},
+		},
+		{
+			name: "absolute shrinks to fit",
+			css:  "#x { position: absolute; top: 0; left: 0; width: auto; }",
+			want: map[string]Rect{"x": {20, 10, 0, 20}},
+		},
+		{
+			name: "initial containing block",
+			css:  "#p { position: static; } #x { position: absolute; bottom: 0; left: 0; }",
+			want: map[string]Rect{"x": {0, 580, 50, 20}},
+		},
+		{
+			name: "fixed",
+			css:  "#x { position: fixed; top: 10px; right: 10px; }",
+			want: map[string]Rect{"x": {240, 10, 50, 20}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkBoxes(t, `div { height: 10px; }
+#p { position: relative; margin-left: 20px; padding: 10px 0 0 0; height: 110px; }
+#x { width: 50px; height: 20px; }
+`, tt.css, page, tt.want)
+		})
+	}
+}
+
+func TestScrolledPositioning(t *testing.T) {
+	page := layoutPage(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#fixed { position: fixed; bottom: 10px; left: 0; width: 50px; height: 20px; }
+#c { height: 300px; margin-top: 100px; }
+#sticky { position: sticky; top: 5px; height: 50px; }
+#rel { position: relative; top: 10px; height: 10px; }
+#tail { height: 2000px; }
+</style></head><body><div id="fixed"></div><div id="c"><div id="sticky"></div></div><div id="rel"></div><div id="tail"></div></body></html>`, 300)
+	tests := []struct {
+		scroll       float32
+		fixed, stick float32
+	}{
+		{0, 570, 100},
+		// The sticky box starts sticking once it would come closer than 5
+		// pixels to the top of the viewport.
+		{95, 665, 100},
+		{150, 720, 155},
+		// It never leaves its containing block.
+		{400, 970, 350},
+		{0, 570, 100},
+	}
+	for _, tt := range tests {
+		page.ScrollTo(0, tt.scroll)
+		if got := boxOf(t, page, "fixed").Dimensions.BorderBox().Y; got != tt.fixed {
+			t.Errorf("scrolled to %v: fixed box at %v, want %v", tt.scroll, got, tt.fixed)
+		}
+		if got := boxOf(t, page, "sticky").Dimensions.BorderBox().Y; got != tt.stick {
+			t.Errorf("scrolled to %v: sticky box at %v, want %v", tt.scroll, got, tt.stick)
+		}
+		if got := boxOf(t, page, "rel").Dimensions.BorderBox().Y; got != 410 {
+			t.Errorf("scrolled to %v: relative box at %v, want 410", tt.scroll, got)
+		}
+	}
+}
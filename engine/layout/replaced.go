@@ -0,0 +1,136 @@
+package layout
+
+import (
+	"strings"
+
+	"prymis/engine/dom"
+)
+
+// isReplaced reports whether the element's content is outside the scope of
+// CSS, so that it generates no boxes for its children.
+func (s *StyledNode) isReplaced() bool {
+	n := s.Node
+	if n.NodeType != dom.ElementNode {
+		return false
+	}
+	if n.Namespace == dom.SVGNamespace {
+		// Only the outermost svg element is a box; the rest is SVG content.
+		return n.TagName == "svg" && (n.Parent == nil || n.Parent.Namespace != dom.SVGNamespace)
+	}
+	if !n.IsHTML() {
+		return false
+	}
+	switch strings.ToLower(n.TagName) {
+	case "img", "canvas", "video", "iframe", "embed", "object":
+		return true
+	}
+	return false
+}
+
+// replacedSize returns the used content width and height of a replaced
+// element (CSS 2.1 sections 10.3.2 and 10.6.2). Without a loaded resource
+// the intrinsic size comes from the width and height attributes, or the
+// 300x150 default for elements that have one.
+func (b *LayoutBox) replacedSize(cbWidth float32) (float32, float32) {
+	style := b.StyledNode
+	fontSize := style.FontSize()
+	intrinsicW, intrinsicH := float32(0), float32(0)
+	switch strings.ToLower(style.Node.TagName) {
+	case "svg", "canvas", "video", "iframe", "embed", "object":
+		intrinsicW, intrinsicH = 300, 150
+	}
+	if f, ok := parseNumber(style.Node.GetAttribute("width")); ok {
+		intrinsicW = f
+	}
+	if f, ok := parseNumber(style.Node.GetAttribute("height")); ok {
+		intrinsicH = f
+	}
+
+	frameX := b.Dimensions.Padding.Left + b.Dimensions.Padding.Right + b.Dimensions.Border.Left + b.Dimensions.Border.Right
+	frameY := b.Dimensions.Padding.Top + b.Dimensions.Padding.Bottom + b.Dimensions.Border.Top + b.Dimensions.Border.Bottom
+	width := style.Length("width", autoLength)
+	height := style.Length("height", autoLength)
+	if height.Unit == "%" {
+		height = autoLength
+	}
+	w, h := intrinsicW, intrinsicH
+	switch {
+	case !width.IsAuto() && !height.IsAuto():
+		w = b.contentSize(width.ToPx(cbWidth, fontSize, style.viewportRect()), frameX)
+		h = b.contentSize(height.ToPx(0, fontSize, style.viewportRect()), frameY)
+	case !width.IsAuto():
+		w = b.contentSize(width.ToPx(cbWidth, fontSize, style.viewportRect()), frameX)
+		if intrinsicW > 0 {
+			h = w * intrinsicH / intrinsicW
+		}
+	case !height.IsAuto():
+		h = b.contentSize(height.ToPx(0, fontSize, style.viewportRect()), frameY)
+		if intrinsicH > 0 {
+			w = h * intrinsicW / intrinsicH
+		}
+	}
+	return b.constrainWidth(w, cbWidth, frameX), h
+}
+
+// layoutReplaced lays out a replaced element. Block-level ones resolve their
+// horizontal margins like other blocks; auto margins of inline ones are 0.
+func (b *LayoutBox) layoutReplaced(container Dimensions, blockLevel bool) {
+	style := b.StyledNode
+	cbWidth := container.Content.Width
+	b.resolveInlineEdges(cbWidth)
+	d := &b.Dimensions
+	w, h := b.replacedSize(cbWidth)
+	if o := b.override; o.hasWidth {
+		// A flex item: keep the aspect ratio unless both sizes are given.
+		if !o.hasHeight && w > 0 && style.Length("height", autoLength).IsAuto() {
+			h = h * o.width / w
+		}
+		w = o.width
+		if o.hasHeight {
+			h = o.height
+		}
+	}
+
+	if blockLevel && !b.override.hasWidth {
+		outer := w + d.Padding.Left + d.Padding.Right + d.Border.Left + d.Border.Right
+		left, right := style.Length("margin-left", Length{Unit: "px"}), style.Length("margin-right", Length{Unit: "px"})
+		underflow := cbWidth - outer - d.Margin.Left - d.Margin.Right
+		switch {
+		case left.IsAuto() && right.IsAuto():
+			d.Margin.Left, d.Margin.Right = underflow/2, underflow/2
+		case left.IsAuto():
+			d.Margin.Left = underflow
+		case right.IsAuto() || style.Value("direction") != "rtl":
+			d.Margin.Right += underflow
+		default:
+			d.Margin.Left += underflow
+		}
+	}
+
+	b.calculateBlockPosition(container)
+	d.Content.Width = w
+	d.Content.Height = h
+	b.marginTop = marginOf(d.Margin.Top)
+	b.marginBottom = marginOf(d.Margin.Bottom)
+	b.collapsesThrough = false
+}
+
+// layoutInlineBlock lays out an inline-block: a block container whose auto
+// width shrinks to fit its content and whose auto margins are 0.
+func (b *LayoutBox) layoutInlineBlock(container Dimensions) {
+	style := b.StyledNode
+	cbWidth := container.Content.Width
+	b.resolveInlineEdges(cbWidth)
+	d := &b.Dimensions
+	frame := d.Padding.Left + d.Padding.Right + d.Border.Left + d.Border.Right
+
+	if width := style.Length("width", autoLength); !width.IsAuto() {
+		d.Content.Width = b.constrainWidth(b.contentSize(width.ToPx(cbWidth, style.FontSize(), style.viewportRect()), frame), cbWidth, frame)
+	} else {
+		d.Content.Width = b.constrainWidth(b.shrinkToFit(cbWidth-frame-d.Margin.Left-d.Margin.Right), cbWidth, frame)
+	}
+
+	b.calculateBlockPosition(container)
+	b.layoutContents()
+	b.calculateBlockHeight()
+}
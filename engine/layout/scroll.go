@@ -0,0 +1,279 @@
+package layout
+
+import (
+	"net/url"
+
+	"prymis/engine/dom"
+)
+
+// Overflow returns the used overflow of b: visible, hidden, scroll, auto or
+// clip. The root, or else the body, gives its value to the viewport and uses
+// visible itself; Overflow of the root box returns the viewport's, where clip
+// acts as hidden. Overflow does not apply to inline boxes.
+func (b *LayoutBox) Overflow() string {
+	s := b.StyledNode
+	if s.Parent == nil {
+		v := overflowValue(s)
+		if v == "visible" {
+			for _, c := range s.Children {
+				if c.Node.IsHTML() && c.Node.TagName == "body" {
+					v = overflowValue(c)
+					break
+				}
+			}
+		}
+		if v == "clip" {
+			return "hidden"
+		}
+		return v
+	}
+	if b.BoxType == InlineNode {
+		return "visible"
+	}
+	if root := s.Parent; root.Parent == nil && s.Node.IsHTML() && s.Node.TagName == "body" && overflowValue(root) == "visible" {
+		return "visible"
+	}
+	return overflowValue(s)
+}
+
+func overflowValue(s *StyledNode) string {
+	switch v := s.Value("overflow"); v {
+	case "hidden", "scroll", "auto", "clip":
+		return v
+	}
+	return "visible"
+}
+
+// isScrollContainer reports whether b clips its content and can be scrolled,
+// which makes it the scroll container of sticky descendants. The viewport
+// scrolls the root instead.
+func (b *LayoutBox) isScrollContainer() bool {
+	if b.StyledNode.Parent == nil {
+		return false
+	}
+	switch b.Overflow() {
+	case "hidden", "scroll", "auto":
+		return true
+	}
+	return false
+}
+
+// clipsOverflow reports whether b clips its content to its padding box.
+func (b *LayoutBox) clipsOverflow() bool {
+	return b.StyledNode.Parent != nil && b.Overflow() != "visible"
+}
+
+// isUserScrollable reports whether the user can scroll b, the root standing
+// for the viewport, with the wheel. Boxes with overflow: hidden scroll only
+// programmatically.
+func (b *LayoutBox) isUserScrollable() bool {
+	switch b.Overflow() {
+	case "scroll", "auto":
+		return true
+	case "visible":
+		return b.StyledNode.Parent == nil
+	}
+	return false
+}
+
+// ScrollOffset returns how far the viewport, for the root, or the scroll
+// container b is scrolled.
+func (b *LayoutBox) ScrollOffset() (x, y float32) {
+	return b.scrollX, b.scrollY
+}
+
+// ScrollSize returns the size of the scrollable overflow of b, the area its
+// scrollport can show, measured from the unscrolled scrollport. For the root
+// this is the area of the document the viewport scrolls over. It covers the
+// scrollport and the border boxes and line fragments of the descendants,
+// except for fixed boxes and the content clipped by inner scroll containers;
+// overflow above or left of the scrollport cannot be scrolled to.
+func (b *LayoutBox) ScrollSize() (w, h float32) {
+	port := b.scrollport()
+	port.X -= b.scrollX
+	port.Y -= b.scrollY
+	x1, y1 := port.X+port.Width, port.Y+port.Height
+	add := func(r Rect) {
+		if r.Width <= 0 && r.Height <= 0 {
+			return
+		}
+		x1, y1 = max(x1, r.X+r.Width), max(y1, r.Y+r.Height)
+	}
+	var walk func(box *LayoutBox)
+	walk = func(box *LayoutBox) {
+		for _, line := range box.Lines {
+			for _, f := range line.Fragments {
+				add(f.Rect)
+			}
+		}
+		for _, child := range box.Children {
+			if child.StyledNode.Value("position") == "fixed" {
+				continue
+			}
+			if child.BoxType != InlineNode {
+				add(child.Dimensions.BorderBox())
+			}
+			if !child.isScrollContainer() {
+				walk(child)
+			}
+		}
+	}
+	if b.StyledNode.Parent == nil {
+		add(b.Dimensions.MarginBox())
+	}
+	walk(b)
+	return x1 - port.X, y1 - port.Y
+}
+
+// clampScroll limits a scroll position of b to the range its scrollable
+// overflow allows.
+func (b *LayoutBox) clampScroll(x, y float32) (float32, float32) {
+	w, h := b.ScrollSize()
+	port := b.scrollport()
+	x = max(0, min(x, w-port.Width))
+	y = max(0, min(y, h-port.Height))
+	return x, y
+}
+
+// ScrollPosition returns how far the viewport is scrolled.
+func (d *Document) ScrollPosition() (x, y float32) {
+	return d.scrollX, d.scrollY
+}
+
+// ScrollBy scrolls the viewport by (dx, dy) and reports whether it moved.
+func (d *Document) ScrollBy(dx, dy float32) bool {
+	x, y := d.scrollX, d.scrollY
+	d.ScrollTo(x+dx, y+dy)
+	return d.scrollX != x || d.scrollY != y
+}
+
+// ScrollIntoView scrolls the scroll containers around the box of n, and then
+// the viewport, so that the box starts at their top left, or as close to it
+// as they can scroll. It reports false when n generates no box.
+func (d *Document) ScrollIntoView(n *dom.Node) bool {
+	r, path, ok := d.Layout.boxRect(n, nil)
+	if !ok {
+		return false
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		c := path[i]
+		if !c.isScrollContainer() {
+			continue
+		}
+		port := c.Dimensions.PaddingBox()
+		c.scrollX, c.scrollY = c.clampScroll(r.X-port.X, r.Y-port.Y)
+		r.X -= c.scrollX
+		r.Y -= c.scrollY
+	}
+	port := d.Layout.initialContainingBlock()
+	d.ScrollTo(r.X-port.X, r.Y-port.Y)
+	return true
+}
+
+// ScrollToFragment scrolls to the part of the document a URL fragment
+// indicates: the element with that id, else the a element with that name,
+// else the top of the document for an empty fragment or "top". It reports
+// whether the fragment was found.
+func (d *Document) ScrollToFragment(fragment string) bool {
+	if decoded, err := url.PathUnescape(fragment); err == nil {
+		fragment = decoded
+	}
+	if fragment != "" {
+		if el := d.DOM.GetElementById(fragment); el != nil {
+			return d.ScrollIntoView(el)
+		}
+		if anchors, err := d.DOM.QuerySelectorAll("a[name]"); err == nil {
+			for _, a := range anchors {
+				if a.GetAttribute("name") == fragment {
+					return d.ScrollIntoView(a)
+				}
+			}
+		}
+	}
+	if fragment == "" || fragment == "top" {
+		d.ScrollTo(0, 0)
+		return true
+	}
+	return false
+}
+
+// boxRect returns the border box of the first box n generates, or for an
+// inline box that of its first fragment, and the boxes it is inside of,
+// appended to path.
+func (b *LayoutBox) boxRect(n *dom.Node, path []*LayoutBox) (Rect, []*LayoutBox, bool) {
+	if b.StyledNode.Node == n && b.BoxType != InlineNode {
+		return b.Dimensions.BorderBox(), path, true
+	}
+	path = append(path, b)
+	for _, line := range b.Lines {
+		for _, f := range line.Fragments {
+			if f.Kind == InlineBoxFragment && f.Box.StyledNode.Node == n {
+				return f.Rect, path, true
+			}
+		}
+	}
+	for _, child := range b.Children {
+		if r, p, ok := child.boxRect(n, path); ok {
+			return r, p, true
+		}
+	}
+	return Rect{}, nil, false
+}
+
+// contentPoint maps (x, y) to the coordinates of the content of b, which a
+// box that clips its overflow shows scrolled within its padding box. It
+// reports false when the point is clipped away.
+func (b *LayoutBox) contentPoint(x, y float32) (float32, float32, bool) {
+	if !b.clipsOverflow() {
+		return x, y, true
+	}
+	if !b.Dimensions.PaddingBox().Contains(x, y) {
+		return 0, 0, false
+	}
+	return x + b.scrollX, y + b.scrollY, true
+}
+
+// scrollersAt appends the boxes the user can scroll under (x, y) in the
+// content of b to out, outermost first. Later siblings are searched first
+// since they paint on top.
+func (b *LayoutBox) scrollersAt(x, y float32, out []*LayoutBox) []*LayoutBox {
+	x, y, ok := b.contentPoint(x, y)
+	if !ok {
+		return out
+	}
+	for i := len(b.Children) - 1; i >= 0; i-- {
+		child := b.Children[i]
+		x, y, ok := child.localPoint(x, y)
+		if !ok {
+			continue
+		}
+		if child.isUserScrollable() && child.Dimensions.PaddingBox().Contains(x, y) {
+			return child.scrollersAt(x, y, append(out, child))
+		}
+		if found := child.scrollersAt(x, y, out); len(found) > len(out) {
+			return found
+		}
+	}
+	return out
+}
+
+// ScrollAt scrolls by (dx, dy) what the wheel turned at (x, y) in the
+// document scrolls: the innermost box under the point that can still move
+// that way, or else the viewport. It reports whether anything moved.
+func (d *Document) ScrollAt(x, y, dx, dy float32) bool {
+	root := d.Layout
+	scrollers := root.scrollersAt(x, y, nil)
+	for i := len(scrollers) - 1; i >= 0; i-- {
+		c := scrollers[i]
+		sx, sy := c.clampScroll(c.scrollX+dx, c.scrollY+dy)
+		if sx != c.scrollX || sy != c.scrollY {
+			c.scrollX, c.scrollY = sx, sy
+			root.placeScrolled()
+			return true
+		}
+	}
+	if !root.isUserScrollable() {
+		return false
+	}
+	return d.ScrollBy(dx, dy)
+}
@@ -0,0 +1,203 @@
+package layout
+
+import "testing"
+
+const scrollPage = `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#top { height: 100px; }
+#target { height: 50px; margin-left: 20px; }
+#fixed { position: fixed; top: 0; height: 5000px; }
+#tail { height: 1000px; }
+a { display: block; height: 10px; }
+</style></head><body><div id="top"></div><div id="target"></div><div id="my target"></div><div id="tail"><a name="anchor"></a></div><div id="fixed"></div></body></html>`
+
+func TestScrollSize(t *testing.T) {
+	page := layoutPage(t, scrollPage, 300)
+	// The fixed box does not add to the area the viewport scrolls over.
+	if w, h := page.Layout.ScrollSize(); w != 300 || h != 1150 {
+		t.Errorf("ScrollSize = %v, %v, want 300, 1150", w, h)
+	}
+}
+
+func TestScrollTo(t *testing.T) {
+	page := layoutPage(t, scrollPage, 300)
+	tests := []struct {
+		x, y         float32
+		wantX, wantY float32
+	}{
+		{0, 100, 0, 100},
+		{50, 100, 0, 100},
+		{0, -20, 0, 0},
+		// The document is 1150 pixels tall in a viewport of 600.
+		{0, 2000, 0, 550},
+	}
+	for _, tt := range tests {
+		page.ScrollTo(tt.x, tt.y)
+		if x, y := page.ScrollPosition(); x != tt.wantX || y != tt.wantY {
+			t.Errorf("ScrollTo(%v, %v) scrolled to %v, %v, want %v, %v", tt.x, tt.y, x, y, tt.wantX, tt.wantY)
+		}
+		if x, y := page.Layout.ScrollOffset(); x != tt.wantX || y != tt.wantY {
+			t.Errorf("ScrollTo(%v, %v): root box scrolled to %v, %v", tt.x, tt.y, x, y)
+		}
+	}
+
+	page.ScrollTo(0, 0)
+	if !page.ScrollBy(0, 40) || !page.ScrollBy(0, 40) {
+		t.Error("ScrollBy did not move")
+	}
+	if _, y := page.ScrollPosition(); y != 80 {
+		t.Errorf("scrolled by 40 twice to %v", y)
+	}
+	page.ScrollTo(0, 550)
+	if page.ScrollBy(0, 10) {
+		t.Error("ScrollBy moved past the end")
+	}
+
+	// A viewport that grows keeps the scroll position within the document.
+	page.ScrollTo(0, 550)
+	page.Update(Dimensions{Content: Rect{Width: 300, Height: 1000}})
+	if _, y := page.ScrollPosition(); y != 150 {
+		t.Errorf("after growing the viewport scrolled to %v, want 150", y)
+	}
+}
+
+func TestScrollToFragment(t *testing.T) {
+	tests := []struct {
+		fragment string
+		found    bool
+		y        float32
+	}{
+		{"target", true, 100},
+		{"anchor", true, 150},
+		{"my%20target", true, 150},
+		{"top", true, 0},
+		{"", true, 0},
+		{"missing", false, 300},
+	}
+	for _, tt := range tests {
+		page := layoutPage(t, scrollPage, 300)
+		page.ScrollTo(0, 300)
+		if got := page.ScrollToFragment(tt.fragment); got != tt.found {
+			t.Errorf("ScrollToFragment(%q) = %v", tt.fragment, got)
+		}
+		// The element comes to the top of the viewport; the viewport does
+		// not scroll sideways for it.
+		if x, y := page.ScrollPosition(); x != 0 || y != tt.y {
+			t.Errorf("ScrollToFragment(%q) scrolled to %v, %v, want 0, %v", tt.fragment, x, y, tt.y)
+		}
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	tests := []struct {
+		name           string
+		css            string
+		root, body, el string
+	}{
+		{"visible", "", "visible", "visible", "visible"},
+		{"element", "#x { overflow: auto; }", "visible", "visible", "auto"},
+		{"unknown value", "#x { overflow: sideways; }", "visible", "visible", "visible"},
+		{"root", "html { overflow: scroll; }", "scroll", "visible", "visible"},
+		{"from the body", "body { overflow: hidden; }", "hidden", "visible", "visible"},
+		{"root and body", "html { overflow: auto; } body { overflow: hidden; }", "auto", "hidden", "visible"},
+		{"clip on the viewport", "html { overflow: clip; }", "hidden", "visible", "visible"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := layoutPage(t, `<html><head><style>`+tt.css+`</style></head><body id="b"><div id="x"></div></body></html>`, 300)
+			if got := page.Layout.Overflow(); got != tt.root {
+				t.Errorf("viewport overflow %q, want %q", got, tt.root)
+			}
+			if got := boxOf(t, page, "b").Overflow(); got != tt.body {
+				t.Errorf("body overflow %q, want %q", got, tt.body)
+			}
+			if got := boxOf(t, page, "x").Overflow(); got != tt.el {
+				t.Errorf("element overflow %q, want %q", got, tt.el)
+			}
+		})
+	}
+}
+
+// nestedScrollers has #outer, 100 pixels tall over 300 of content, holding
+// #inner, 50 pixels tall over 100, at the top; the page is 1100 tall.
+const nestedScrollers = `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#outer { height: 100px; overflow: auto; }
+#inner { height: 50px; overflow: scroll; }
+#hidden { height: 50px; overflow: hidden; }
+.c1 { height: 100px; }
+.c3 { height: 300px; }
+#tail { height: 1000px; }
+</style></head><body><div id="outer"><div class="c3"><div id="inner"><div class="c1" id="ic"></div><div id="deep"></div></div></div></div><div id="hidden"><div class="c1"></div></div><div id="tail"></div></body></html>`
+
+func TestScrollAt(t *testing.T) {
+	page := layoutPage(t, nestedScrollers, 300)
+	outer, inner := boxOf(t, page, "outer"), boxOf(t, page, "inner")
+	scrolls := func() [3]float32 {
+		_, o := outer.ScrollOffset()
+		_, i := inner.ScrollOffset()
+		_, v := page.ScrollPosition()
+		return [3]float32{i, o, v}
+	}
+	tests := []struct {
+		name   string
+		x, y   float32
+		dy     float32
+		moved  bool
+		inner  float32
+		outer  float32
+		viewpt float32
+	}{
+		{"innermost first", 10, 10, 30, true, 30, 0, 0},
+		{"innermost to its end", 10, 10, 30, true, 50, 0, 0},
+		{"then the next one out", 10, 10, 30, true, 50, 30, 0},
+		{"outside the inner scroller", 10, 90, 30, true, 50, 60, 0},
+		{"outer to its end", 10, 90, 1000, true, 50, 200, 0},
+		{"then the viewport", 10, 90, 30, true, 50, 200, 30},
+		// The viewport has scrolled by 30, but points are in the document.
+		{"overflow hidden is passed over", 10, 130, 30, true, 50, 200, 60},
+		{"back up the outer one", 10, 60, -100, true, 50, 100, 60},
+	}
+	for _, tt := range tests {
+		moved := page.ScrollAt(tt.x, tt.y, 0, tt.dy)
+		if moved != tt.moved {
+			t.Errorf("%s: moved %v", tt.name, moved)
+		}
+		if got, want := scrolls(), [3]float32{tt.inner, tt.outer, tt.viewpt}; got != want {
+			t.Errorf("%s: inner, outer and viewport scrolled to %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestScrolledHitTest(t *testing.T) {
+	page := layoutPage(t, nestedScrollers, 300)
+	ic := page.DOM.GetElementById("ic")
+	// The content of #inner below its 50 pixel scrollport is clipped.
+	if b := page.Layout.HitTest(10, 60); b == nil || b.StyledNode.Node == ic {
+		t.Errorf("hit %v below the inner scroller", b)
+	}
+	page.ScrollAt(10, 10, 0, 50)
+	page.ScrollAt(10, 10, 0, 40)
+	// With #inner scrolled by 50 inside #outer scrolled by 40, the point 5
+	// pixels down is 95 pixels into the content of #inner.
+	if b := page.Layout.HitTest(10, 5); b == nil || b.StyledNode.Node != ic {
+		t.Errorf("hit %v in the scrolled inner scroller", b)
+	}
+}
+
+func TestScrollIntoViewThroughScrollers(t *testing.T) {
+	page := layoutPage(t, nestedScrollers, 300)
+	if !page.ScrollIntoView(page.DOM.GetElementById("deep")) {
+		t.Fatal("no box for #deep")
+	}
+	// #deep is 100 pixels into the content of #inner, which scrolls no
+	// further than 50; #outer then scrolls the rest of the way.
+	_, i := boxOf(t, page, "inner").ScrollOffset()
+	_, o := boxOf(t, page, "outer").ScrollOffset()
+	if i != 50 {
+		t.Errorf("inner scrolled to %v, want 50", i)
+	}
+	if o != 50 {
+		t.Errorf("outer scrolled to %v, want 50", o)
+	}
+}
@@ -0,0 +1,309 @@
+package layout
+
+import (
+	"strconv"
+	"strings"
+)
+
+var sides = [4]string{"top", "right", "bottom", "left"}
+
+var borderStyles = map[string]bool{
+	"none": true, "hidden": true, "dotted": true, "dashed": true, "solid": true,
+	"double": true, "groove": true, "ridge": true, "inset": true, "outset": true,
+}
+
+// expandShorthand writes the longhands of a shorthand declaration into
+// values. It reports false when name is not a shorthand it knows.
+func expandShorthand(name, value string, values map[string]string) bool {
+	switch name {
+	case "margin", "padding":
+		return expandSides(value, func(side string) string { return name + "-" + side }, values)
+	case "border-width", "border-style", "border-color":
+		part := strings.TrimPrefix(name, "border-")
+		return expandSides(value, func(side string) string { return "border-" + side + "-" + part }, values)
+	case "border":
+		for _, side := range sides {
+			expandBorderSide(side, value, values)
+		}
+		return true
+	case "border-top", "border-right", "border-bottom", "border-left":
+		expandBorderSide(strings.TrimPrefix(name, "border-"), value, values)
+		return true
+	case "border-radius":
+		expandBorderRadius(value, values)
+		return true
+	case "flex":
+		expandFlex(value, values)
+		return true
+	case "flex-flow":
+		for _, part := range splitValue(value) {
+			switch part = strings.ToLower(part); part {
+			case "row", "row-reverse", "column", "column-reverse":
+				values["flex-direction"] = part
+			default:
+				values["flex-wrap"] = part
+			}
+		}
+		return true
+	case "gap", "grid-gap":
+		return expandPair(value, "row-gap", "column-gap", values)
+	case "grid-row-gap", "grid-column-gap":
+		values[strings.TrimPrefix(name, "grid-")] = value
+		return true
+	case "grid-row", "grid-column":
+		parts := splitTopLevel(value, '/')
+		start, end := strings.TrimSpace(parts[0]), "auto"
+		if len(parts) > 1 {
+			end = strings.TrimSpace(parts[1])
+		} else if isGridIdent(start) {
+			end = start
+		}
+		values[name+"-start"], values[name+"-end"] = start, end
+		return true
+	case "grid-area":
+		expandGridArea(value, values)
+		return true
+	case "grid-template":
+		expandGridTemplate(value, values)
+		return true
+	case "place-content", "place-items", "place-self":
+		part := strings.TrimPrefix(name, "place-")
+		return expandPair(value, "align-"+part, "justify-"+part, values)
+	}
+	return false
+}
+
+// expandFlex splits "none", "auto" or "<grow> [<shrink>] || <basis>". A
+// basis omitted after a flex factor is 0, and factors omitted before a
+// basis are 1 (CSS Flexible Box Layout section 7.1).
+func expandFlex(value string, values map[string]string) {
+	grow, shrink, basis := "0", "1", "auto"
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "none":
+		shrink = "0"
+	case "auto":
+		grow = "1"
+	case "initial":
+	default:
+		factors := 0
+		basis = ""
+		for _, part := range splitValue(value) {
+			if _, ok := parseNumber(part); ok && factors < 2 && basis == "" {
+				if factors == 0 {
+					grow = part
+				} else {
+					shrink = part
+				}
+				factors++
+				continue
+			}
+			basis = strings.ToLower(part)
+		}
+		if factors == 0 {
+			grow = "1"
+		}
+		if basis == "" {
+			basis = "0%"
+		}
+	}
+	values["flex-grow"] = grow
+	values["flex-shrink"] = shrink
+	values["flex-basis"] = basis
+}
+
+// expandGridArea splits "<row-start> / <column-start> / <row-end> /
+// <column-end>". An omitted line repeats the one it pairs with when that is
+// a name, and is auto otherwise.
+func expandGridArea(value string, values map[string]string) {
+	parts := splitTopLevel(value, '/')
+	// Column start repeats row start; the ends repeat the starts.
+	from := [4]int{0, 0, 0, 1}
+	var v [4]string
+	for i := range v {
+		switch {
+		case i < len(parts):
+			v[i] = strings.TrimSpace(parts[i])
+		case isGridIdent(v[from[i]]):
+			v[i] = v[from[i]]
+		default:
+			v[i] = "auto"
+		}
+	}
+	values["grid-row-start"], values["grid-column-start"] = v[0], v[1]
+	values["grid-row-end"], values["grid-column-end"] = v[2], v[3]
+}
+
+// expandGridTemplate splits "none", "<rows> / <columns>" or the form that
+// lists the strings of grid-template-areas, each optionally followed by the
+// size of its row.
+func expandGridTemplate(value string, values map[string]string) {
+	rows, cols, areas := "none", "none", "none"
+	if !strings.EqualFold(strings.TrimSpace(value), "none") {
+		parts := splitTopLevel(value, '/')
+		if len(parts) > 2 {
+			return
+		}
+		if len(parts) == 2 {
+			cols = strings.TrimSpace(parts[1])
+		}
+		rows = strings.TrimSpace(parts[0])
+		if strings.ContainsAny(rows, `"'`) {
+			var strs, sizes []string
+			pending := false
+			for _, tok := range gridTokens(rows) {
+				switch tok[0] {
+				case '"', '\'':
+					if pending {
+						sizes = append(sizes, "auto")
+					}
+					strs = append(strs, tok)
+					pending = true
+					continue
+				case '[':
+					if pending {
+						sizes = append(sizes, "auto")
+					}
+				}
+				sizes = append(sizes, tok)
+				pending = false
+			}
+			if pending {
+				sizes = append(sizes, "auto")
+			}
+			rows, areas = strings.Join(sizes, " "), strings.Join(strs, " ")
+		}
+	}
+	values["grid-template-rows"] = rows
+	values["grid-template-columns"] = cols
+	values["grid-template-areas"] = areas
+}
+
+// isGridIdent reports whether a grid line value is a single name.
+func isGridIdent(v string) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "" || v == "auto" || v == "span" || strings.ContainsAny(v, " \t\n") {
+		return false
+	}
+	_, err := strconv.Atoi(v)
+	return err != nil
+}
+
+// expandPair applies the one-or-two value pattern of gap and place-*: the
+// second value defaults to the first.
+func expandPair(value, first, second string, values map[string]string) bool {
+	parts := splitValue(value)
+	switch len(parts) {
+	case 1:
+		values[first], values[second] = parts[0], parts[0]
+	case 2:
+		values[first], values[second] = parts[0], parts[1]
+	}
+	return true
+}
+
+// expandSides applies the one-to-four value top/right/bottom/left pattern.
+func expandSides(value string, longhand func(side string) string, values map[string]string) bool {
+	parts := splitValue(value)
+	var v [4]string
+	switch len(parts) {
+	case 1:
+		v = [4]string{parts[0], parts[0], parts[0], parts[0]}
+	case 2:
+		v = [4]string{parts[0], parts[1], parts[0], parts[1]}
+	case 3:
+		v = [4]string{parts[0], parts[1], parts[2], parts[1]}
+	case 4:
+		v = [4]string{parts[0], parts[1], parts[2], parts[3]}
+	default:
+		// Invalid declarations are ignored but still count as handled.
+		return true
+	}
+	for i, side := range sides {
+		values[longhand(side)] = v[i]
+	}
+	return true
+}
+
+// expandBorderSide splits "<width> <style> <color>" in any order; omitted
+// parts are reset to their initial values.
+func expandBorderSide(side, value string, values map[string]string) {
+	width, style, color := "medium", "none", "currentcolor"
+	for _, part := range splitValue(value) {
+		lower := strings.ToLower(part)
+		switch {
+		case borderStyles[lower]:
+			style = lower
+		case lower == "thin" || lower == "medium" || lower == "thick":
+			width = lower
+		default:
+			if _, ok := ParseLength(lower); ok {
+				width = lower
+			} else {
+				color = part
+			}
+		}
+	}
+	values["border-"+side+"-width"] = width
+	values["border-"+side+"-style"] = style
+	values["border-"+side+"-color"] = color
+}
+
+// corners are the corners of a box in the order of the sides they start.
+var corners = [4]string{"top-left", "top-right", "bottom-right", "bottom-left"}
+
+// expandBorderRadius splits "<horizontal radii> [/ <vertical radii>]", each
+// one to four values for the corners clockwise from the top left, into the
+// corner longhands, which hold "<horizontal> [<vertical>]".
+func expandBorderRadius(value string, values map[string]string) {
+	parts := splitTopLevel(value, '/')
+	if len(parts) > 2 {
+		return
+	}
+	same := func(side string) string { return side }
+	h := make(map[string]string)
+	expandSides(parts[0], same, h)
+	v := h
+	if len(parts) == 2 {
+		v = make(map[string]string)
+		expandSides(parts[1], same, v)
+	}
+	if len(h) == 0 || len(v) == 0 {
+		return
+	}
+	for i, side := range sides {
+		r := h[side]
+		if v[side] != r {
+			r += " " + v[side]
+		}
+		values["border-"+corners[i]+"-radius"] = r
+	}
+}
+
+// splitValue splits a declaration value at top-level whitespace, keeping
+// function arguments such as rgb(1, 2, 3) together.
+func splitValue(value string) []string {
+	var parts []string
+	depth, start := 0, -1
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			if start >= 0 {
+				parts = append(parts, value[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, value[start:])
+	}
+	return parts
+}
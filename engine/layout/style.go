@@ -3,50 +3,141 @@ package layout
 import (
 	"prymis/engine/dom"
 	"prymis/engine/parser"
+	"prymis/engine/text"
+	"sort"
 )
 
 type StyledNode struct {
-	Node             *dom.Node
-	SpecifiedValues  map[string]string
-	Children         []*StyledNode
+	Node            *dom.Node
+	SpecifiedValues map[string]string
+	Children        []*StyledNode
+	Parent          *StyledNode
+
+	// Invalidation state, see Document.
+	dirty styleDirt
+	box   *LayoutBox
+
+	// fonts are the web fonts of the document and viewport the area it is
+	// shown in, set on the root only.
+	fonts    *text.FontSet
+	viewport Rect
+}
+
+// compiledRule is a style rule whose selectors have been parsed by the DOM
+// selector engine.
+type compiledRule struct {
+	selectors    dom.SelectorList
+	declarations []parser.Declaration
+	order        int
+	origin       int
+}
+
+// Cascade origins in ascending precedence.
+const (
+	originUserAgent = iota
+	originAuthor
+)
+
+type matchedRule struct {
+	origin      int
+	specificity int
+	order       int
+	rule        *compiledRule
 }
 
 func NewStyledNode(node *dom.Node, rules []parser.StyleRule) *StyledNode {
-	values := make(map[string]string)
-	for _, rule := range rules {
+	return styleTree(node, authorStyles(rules), nil, Rect{})
+}
+
+// authorStyles compiles the author style sheet behind the user agent one.
+func authorStyles(rules []parser.StyleRule) []*compiledRule {
+	compiled := compileRules(rules)
+	for _, r := range compiled {
+		r.origin = originAuthor
+	}
+	return append(append([]*compiledRule(nil), userAgentStyles()...), compiled...)
+}
+
+func compileRules(rules []parser.StyleRule) []*compiledRule {
+	var compiled []*compiledRule
+	for i, rule := range rules {
+		if rule.AtRule != "" {
+			continue
+		}
+		var list dom.SelectorList
 		for _, selector := range rule.Selectors {
-			if matches(node, selector) {
-				for _, decl := range rule.Declarations {
-					values[decl.Name] = decl.Value
-				}
+			sel, err := dom.ParseSelectorNS(selector, rule.Namespaces)
+			if err != nil {
+				// An invalid selector drops the whole rule.
+				list = nil
+				break
 			}
+			list = append(list, sel...)
 		}
+		if len(list) == 0 {
+			continue
+		}
+		compiled = append(compiled, &compiledRule{selectors: list, declarations: rule.Declarations, order: i})
 	}
+	return compiled
+}
 
-	var children []*StyledNode
-	for _, child := range node.Children {
-		children = append(children, NewStyledNode(child, rules))
-	}
-
-	return &StyledNode{
+func styleTree(node *dom.Node, rules []*compiledRule, parent *StyledNode, viewport Rect) *StyledNode {
+	styled := &StyledNode{
 		Node:            node,
-		SpecifiedValues: values,
-		Children:        children,
+		SpecifiedValues: specifiedValues(node, rules, parent, viewport),
+		Parent:          parent,
+	}
+	for _, child := range node.Children {
+		c := styleTree(child, rules, styled, viewport)
+		styled.Children = append(styled.Children, c)
 	}
+	return styled
 }
 
-func matches(n *dom.Node, selector string) bool {
-	if n.NodeType != dom.ElementNode {
-		return false
+// specifiedValues applies the declarations of every matching rule in order of
+// origin, specificity and source order, then fills in inherited properties
+// from parent. Text nodes only carry inherited values. Font sizes in
+// viewport units refer to viewport.
+func specifiedValues(node *dom.Node, rules []*compiledRule, parent *StyledNode, viewport Rect) map[string]string {
+	values := make(map[string]string)
+	var inherited map[string]string
+	if parent != nil {
+		inherited = parent.SpecifiedValues
 	}
-	if selector == n.TagName {
-		return true
+	if node.NodeType != dom.ElementNode {
+		inherit(values, inherited)
+		return values
 	}
-	if selector[0] == '.' && n.Attributes["class"] == selector[1:] {
-		return true
+	var matched []matchedRule
+	for _, rule := range rules {
+		best := -1
+		for _, sel := range rule.selectors {
+			if sel.Specificity() > best && sel.Match(node) {
+				best = sel.Specificity()
+			}
+		}
+		if best >= 0 {
+			matched = append(matched, matchedRule{origin: rule.origin, specificity: best, order: rule.order, rule: rule})
+		}
 	}
-	if selector[0] == '#' && n.Attributes["id"] == selector[1:] {
-		return true
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].origin != matched[j].origin {
+			return matched[i].origin < matched[j].origin
+		}
+		if matched[i].specificity != matched[j].specificity {
+			return matched[i].specificity < matched[j].specificity
+		}
+		return matched[i].order < matched[j].order
+	})
+	for _, m := range matched {
+		for _, decl := range m.rule.declarations {
+			if !expandShorthand(decl.Name, decl.Value, values) {
+				values[decl.Name] = decl.Value
+			}
+		}
 	}
-	return false
+	inherit(values, inherited)
+	computeFontValues(values, inherited, viewport)
+	return values
 }
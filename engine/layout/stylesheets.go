@@ -0,0 +1,79 @@
+package layout
+
+import (
+	"net/url"
+	"strings"
+
+	"prymis/engine/dom"
+	"prymis/engine/parser"
+)
+
+// styleSheet is a list of rules with the URL that the relative URLs in it,
+// such as the sources of @font-face rules, resolve against.
+type styleSheet struct {
+	rules []parser.StyleRule
+	base  string
+}
+
+// pageStyleSheets returns the style sheets of doc in tree order: the
+// contents of its <style> elements and the sheets its <link
+// rel=stylesheet> elements load with fetch. docURL is the address of the
+// document, which the first <base href> may override.
+func pageStyleSheets(doc *dom.Node, docURL string, fetch Fetcher) []styleSheet {
+	base := docURL
+	if b, _ := doc.QuerySelector("base[href]"); b != nil {
+		base = resolveURL(docURL, b.GetAttribute("href"))
+	}
+	elements, _ := doc.QuerySelectorAll("style, link[href]")
+	var sheets []styleSheet
+	for _, e := range elements {
+		if e.TagName == "style" {
+			if t := e.GetAttribute("type"); t != "" && !strings.EqualFold(t, "text/css") {
+				continue
+			}
+			rules := parser.NewCSSParser(e.TextContent()).Parse()
+			sheets = append(sheets, styleSheet{rules: rules, base: base})
+			continue
+		}
+		if !hasToken(e.GetAttribute("rel"), "stylesheet") {
+			continue
+		}
+		href := resolveURL(base, e.GetAttribute("href"))
+		data, err := fetchURL(href, fetch)
+		if err != nil {
+			continue
+		}
+		rules := parser.NewCSSParser(string(data)).Parse()
+		sheets = append(sheets, styleSheet{rules: rules, base: href})
+	}
+	return sheets
+}
+
+// hasToken reports whether the space-separated list v contains token,
+// ignoring case.
+func hasToken(v, token string) bool {
+	for _, f := range strings.Fields(v) {
+		if strings.EqualFold(f, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveURL resolves ref against base. It returns ref as it is when either
+// does not parse or base is empty.
+func resolveURL(base, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if base == "" {
+		return ref
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
@@ -0,0 +1,1109 @@
+package layout
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"prymis/engine/dom"
+)
+
+// wrapperProperties are the non-inherited properties that apply to the
+// table wrapper box rather than the table box (CSS 2.1 17.4): those that
+// place the table among its siblings.
+var wrapperProperties = map[string]bool{
+	"position": true, "float": true, "clear": true, "z-index": true,
+	"top": true, "right": true, "bottom": true, "left": true,
+	"margin-top": true, "margin-right": true, "margin-bottom": true,
+	"margin-left": true, "vertical-align": true, "transform": true,
+	"transform-origin": true, "opacity": true, "mix-blend-mode": true,
+	"isolation": true, "order": true, "flex-grow": true, "flex-shrink": true,
+	"flex-basis": true, "align-self": true, "justify-self": true,
+	"grid-row-start": true, "grid-row-end": true, "grid-column-start": true,
+	"grid-column-end": true,
+}
+
+func (s *StyledNode) isTable() bool {
+	switch s.Value("display") {
+	case "table", "inline-table":
+		return true
+	}
+	return false
+}
+
+func isRowGroup(display string) bool {
+	switch display {
+	case "table-row-group", "table-header-group", "table-footer-group":
+		return true
+	}
+	return false
+}
+
+// isProperTableChild reports whether a box with the given display belongs
+// directly in a table box (CSS 2.1 17.2.1).
+func isProperTableChild(display string) bool {
+	switch display {
+	case "table-row", "table-column", "table-column-group", "table-caption":
+		return true
+	}
+	return isRowGroup(display)
+}
+
+// isTabular reports whether boxes with the given display only contain other
+// table parts, so that they never have inline content.
+func isTabular(display string) bool {
+	switch display {
+	case "table", "inline-table", "table-row", "table-column", "table-column-group":
+		return true
+	}
+	return isRowGroup(display)
+}
+
+// tableDisplay returns the display b takes part in table box generation
+// with. Text is inline, and internal table elements that are blockified,
+// by floating for example, are blocks.
+func (b *LayoutBox) tableDisplay() string {
+	style := b.StyledNode
+	if style.Node.NodeType != dom.ElementNode {
+		return "inline"
+	}
+	display := style.Value("display")
+	switch {
+	case display == "":
+		return "inline"
+	case strings.HasPrefix(display, "table-") && b.BoxType != AnonymousBlock && (style.Parent == nil || style.isBlockified()):
+		return "block"
+	}
+	return display
+}
+
+// tableChildren fixes up the children of parent so that every table part
+// sits in a well-formed table (CSS 2.1 17.2.1): columns lose their content,
+// white space between table parts is dropped, and runs of misparented boxes
+// are wrapped in anonymous cells, rows and tables.
+func tableChildren(parent *LayoutBox, children []*LayoutBox) []*LayoutBox {
+	var fits func(string) bool
+	var missing string
+	switch display := parent.tableDisplay(); {
+	case display == "table-column":
+		return nil
+	case display == "table-column-group":
+		var columns []*LayoutBox
+		for _, child := range children {
+			if child.tableDisplay() == "table-column" {
+				columns = append(columns, child)
+			}
+		}
+		return columns
+	case display == "table" || display == "inline-table":
+		fits, missing = isProperTableChild, "table-row"
+	case isRowGroup(display):
+		fits = func(d string) bool { return d == "table-row" }
+		missing = "table-row"
+	case display == "table-row":
+		fits = func(d string) bool { return d == "table-cell" }
+		missing = "table-cell"
+	default:
+		internal := func(d string) bool { return strings.HasPrefix(d, "table-") }
+		children = dropWhiteSpace(children, internal, false)
+		children = wrapRuns(children, func(d string) bool { return d == "table-cell" }, func(run []*LayoutBox) *LayoutBox {
+			return anonymousTablePart(parent, "table-row", run)
+		})
+		table := "table"
+		if parent.BoxType == InlineNode {
+			table = "inline-table"
+		}
+		return wrapRuns(children, isProperTableChild, func(run []*LayoutBox) *LayoutBox {
+			return anonymousTablePart(parent, table, run)
+		})
+	}
+	children = dropWhiteSpace(children, fits, true)
+	return wrapRuns(children, func(d string) bool { return !fits(d) }, func(run []*LayoutBox) *LayoutBox {
+		return anonymousTablePart(parent, missing, run)
+	})
+}
+
+// dropWhiteSpace removes the text boxes of collapsible white space whose
+// siblings on both sides satisfy adjacent. atEdges lets a missing sibling
+// count as one that does.
+func dropWhiteSpace(children []*LayoutBox, adjacent func(string) bool, atEdges bool) []*LayoutBox {
+	fits := func(i int) bool {
+		if i < 0 || i >= len(children) {
+			return atEdges
+		}
+		return adjacent(children[i].tableDisplay())
+	}
+	var kept []*LayoutBox
+	for i, child := range children {
+		if child.StyledNode.Node.NodeType == dom.TextNode && isCollapsibleWhiteSpace(children[i:i+1]) && fits(i-1) && fits(i+1) {
+			continue
+		}
+		kept = append(kept, child)
+	}
+	return kept
+}
+
+// wrapRuns replaces every run of consecutive children whose display
+// satisfies in by the box wrap makes of it.
+func wrapRuns(children []*LayoutBox, in func(string) bool, wrap func([]*LayoutBox) *LayoutBox) []*LayoutBox {
+	var out, run []*LayoutBox
+	flush := func() {
+		if run != nil {
+			out = append(out, wrap(run))
+			run = nil
+		}
+	}
+	for _, child := range children {
+		if in(child.tableDisplay()) {
+			run = append(run, child)
+			continue
+		}
+		flush()
+		out = append(out, child)
+	}
+	flush()
+	return out
+}
+
+// anonymousTablePart returns an anonymous table part with the given display
+// around children, fixing up its own content in turn.
+func anonymousTablePart(parent *LayoutBox, display string, children []*LayoutBox) *LayoutBox {
+	style := anonymousStyle(parent.StyledNode)
+	style.SpecifiedValues["display"] = display
+	box := &LayoutBox{BoxType: AnonymousBlock, StyledNode: style}
+	box.Children = tableChildren(box, children)
+	switch display {
+	case "table-cell":
+		box.Children = wrapInlineRuns(style, box.Children)
+	case "table", "inline-table":
+		return wrapTable(box)
+	}
+	return box
+}
+
+// wrapTable puts a table box in a table wrapper box (CSS 2.1 17.4). The
+// wrapper takes the captions and the properties that place the table; the
+// table box keeps the rest.
+func wrapTable(table *LayoutBox) *LayoutBox {
+	style := table.StyledNode
+	outer := make(map[string]string)
+	inner := make(map[string]string)
+	for name, v := range style.SpecifiedValues {
+		switch {
+		case name == "display" || inheritedProperties[name]:
+			outer[name], inner[name] = v, v
+		case wrapperProperties[name]:
+			outer[name] = v
+		default:
+			inner[name] = v
+		}
+	}
+	wrapper := &LayoutBox{
+		BoxType:    table.BoxType,
+		StyledNode: &StyledNode{Node: style.Node, SpecifiedValues: outer, Parent: style.Parent},
+		wrapped:    table,
+	}
+	table.StyledNode = &StyledNode{Node: style.Node, SpecifiedValues: inner, Parent: style.Parent}
+	switch {
+	case table.BoxType != AnonymousBlock:
+		table.BoxType = BlockNode
+	case style.Value("display") == "inline-table":
+		wrapper.BoxType = InlineBlockNode
+	}
+	var rest []*LayoutBox
+	for _, child := range table.Children {
+		if child.tableDisplay() == "table-caption" {
+			wrapper.Children = append(wrapper.Children, child)
+		} else {
+			rest = append(rest, child)
+		}
+	}
+	table.Children = rest
+	wrapper.Children = append(wrapper.Children, table)
+	return wrapper
+}
+
+// tableTrack is a row or a column of a table. Sizes are border-box widths
+// for columns and heights for rows.
+type tableTrack struct {
+	// box is the row or column, if any, and group its row or column group.
+	box, group *LayoutBox
+	// min, max and percent are what the cells ask of a column; fixed marks
+	// a column that has a specified width.
+	min, max, percent float32
+	fixed             bool
+	size, pos         float32
+	// baseline is the distance of a row's baseline from its top.
+	baseline float32
+}
+
+// tableGroup is a row or column group spanning tracks [start, end).
+type tableGroup struct {
+	box        *LayoutBox
+	start, end int
+}
+
+type tableCell struct {
+	box              *LayoutBox
+	row, col         int
+	rowSpan, colSpan int
+	// min, max and percent are the width contributions of the cell.
+	min, max, percent float32
+	fixed             bool
+	align             string
+	// height and baseline are measured at the width of the columns, from
+	// the top of the border box.
+	height, baseline float32
+}
+
+// tableLayout is the grid of a table box: its rows and columns and the
+// slots its cells take up (CSS 2.1 17.5).
+type tableLayout struct {
+	table                *LayoutBox
+	rows, cols           []*tableTrack
+	rowGroups, colGroups []tableGroup
+	cells                []*tableCell
+	collapse             bool
+	spacingX, spacingY   float32
+}
+
+// rowSection is a row group, or a run of rows directly in the table, whose
+// rows cells can span.
+type rowSection struct {
+	group *LayoutBox
+	rows  []*LayoutBox
+}
+
+// newTableLayout places the cells of the table box b in its grid, with the
+// header group first and the footer group last, and resolves the collapsed
+// borders of b and its cells.
+func newTableLayout(b *LayoutBox) *tableLayout {
+	style := b.StyledNode
+	t := &tableLayout{table: b, collapse: style.Value("border-collapse") == "collapse"}
+	if !t.collapse {
+		t.spacingX, t.spacingY = borderSpacing(style)
+	}
+	var sections []rowSection
+	var header, footer *rowSection
+	open := -1
+	for _, child := range b.Children {
+		display := child.tableDisplay()
+		if display == "table-row" {
+			if open < 0 {
+				open = len(sections)
+				sections = append(sections, rowSection{})
+			}
+			sections[open].rows = append(sections[open].rows, child)
+			continue
+		}
+		open = -1
+		switch {
+		case display == "table-column-group":
+			start := len(t.cols)
+			for _, col := range child.Children {
+				t.addColumns(col, child, columnSpan(col))
+			}
+			if len(child.Children) == 0 {
+				t.addColumns(nil, child, columnSpan(child))
+			}
+			t.colGroups = append(t.colGroups, tableGroup{box: child, start: start, end: len(t.cols)})
+		case display == "table-column":
+			t.addColumns(child, nil, columnSpan(child))
+		case display == "table-header-group" && header == nil:
+			header = &rowSection{group: child, rows: child.Children}
+		case display == "table-footer-group" && footer == nil:
+			footer = &rowSection{group: child, rows: child.Children}
+		case isRowGroup(display):
+			sections = append(sections, rowSection{group: child, rows: child.Children})
+		}
+	}
+	if header != nil {
+		sections = append([]rowSection{*header}, sections...)
+	}
+	if footer != nil {
+		sections = append(sections, *footer)
+	}
+
+	taken := make(map[[2]int]bool)
+	ncols := len(t.cols)
+	for _, s := range sections {
+		start := len(t.rows)
+		for _, row := range s.rows {
+			t.rows = append(t.rows, &tableTrack{box: row, group: s.group})
+		}
+		end := len(t.rows)
+		if s.group != nil {
+			t.rowGroups = append(t.rowGroups, tableGroup{box: s.group, start: start, end: end})
+		}
+		for r := start; r < end; r++ {
+			col := 0
+			for _, box := range t.rows[r].box.Children {
+				for taken[[2]int{r, col}] {
+					col++
+				}
+				colSpan, rowSpan := cellSpans(box)
+				if rowSpan == 0 || rowSpan > end-r {
+					rowSpan = end - r
+				}
+				c := &tableCell{box: box, row: r, col: col, rowSpan: rowSpan, colSpan: colSpan}
+				switch c.align = box.StyledNode.Value("vertical-align"); c.align {
+				case "top", "middle", "bottom":
+				default:
+					c.align = "baseline"
+				}
+				for i := r; i < r+rowSpan; i++ {
+					for j := col; j < col+colSpan; j++ {
+						taken[[2]int{i, j}] = true
+					}
+				}
+				t.cells = append(t.cells, c)
+				col += colSpan
+				ncols = max(ncols, col)
+			}
+		}
+	}
+	for len(t.cols) < ncols {
+		t.cols = append(t.cols, &tableTrack{})
+	}
+
+	if t.collapse {
+		t.collapseBorders()
+	} else {
+		b.setCollapsed(nil)
+		for _, c := range t.cells {
+			c.box.setCollapsed(nil)
+		}
+	}
+	return t
+}
+
+func (t *tableLayout) addColumns(box, group *LayoutBox, n int) {
+	for range n {
+		t.cols = append(t.cols, &tableTrack{box: box, group: group})
+	}
+}
+
+// cellSpans returns the colspan and rowspan of a cell. A rowspan of 0
+// spans the rest of the row group.
+func cellSpans(cell *LayoutBox) (cols, rows int) {
+	cols, rows = 1, 1
+	n := cell.StyledNode.Node
+	if cell.BoxType == AnonymousBlock || !n.IsHTML() {
+		return cols, rows
+	}
+	switch strings.ToLower(n.TagName) {
+	case "td", "th":
+	default:
+		return cols, rows
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(n.GetAttribute("colspan"))); err == nil && v > 0 {
+		cols = min(v, 1000)
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(n.GetAttribute("rowspan"))); err == nil && v >= 0 {
+		rows = min(v, 65534)
+	}
+	return cols, rows
+}
+
+// columnSpan returns the number of columns a column or column group
+// element stands for.
+func columnSpan(box *LayoutBox) int {
+	n := box.StyledNode.Node
+	if box.BoxType != AnonymousBlock && n.IsHTML() {
+		if v, err := strconv.Atoi(strings.TrimSpace(n.GetAttribute("span"))); err == nil && v > 0 {
+			return min(v, 1000)
+		}
+	}
+	return 1
+}
+
+// borderSpacing returns the horizontal and vertical border-spacing.
+func borderSpacing(s *StyledNode) (x, y float32) {
+	parts := strings.Fields(s.Value("border-spacing"))
+	if len(parts) == 0 || len(parts) > 2 {
+		return 0, 0
+	}
+	var v [2]float32
+	for i, p := range parts {
+		l, ok := ParseLength(p)
+		if !ok || l.IsAuto() || l.Unit == "%" {
+			return 0, 0
+		}
+		v[i] = max(0, l.ToPx(0, s.FontSize(), s.viewportRect()))
+	}
+	if len(parts) == 1 {
+		v[1] = v[0]
+	}
+	return v[0], v[1]
+}
+
+// Table parts in ascending precedence in border conflicts.
+const (
+	partTable = iota
+	partColumnGroup
+	partColumn
+	partRowGroup
+	partRow
+	partCell
+)
+
+// tableBorder is a border of a table part as it takes part in collapsing.
+type tableBorder struct {
+	width        float32
+	style, color string
+	part         int
+}
+
+var borderStyleRank = map[string]int{
+	"inset": 1, "groove": 2, "outset": 3, "ridge": 4,
+	"dotted": 5, "dashed": 6, "solid": 7, "double": 8,
+}
+
+func borderOf(s *StyledNode, side, part int) tableBorder {
+	name := "border-" + sides[side]
+	style := s.Value(name + "-style")
+	if style == "" {
+		style = "none"
+	}
+	return tableBorder{width: s.BorderWidth(sides[side]), style: style, color: s.Value(name + "-color"), part: part}
+}
+
+// beats reports whether a wins a border conflict with o (CSS 2.1 17.6.2.1):
+// hidden wins and none loses, then the wider border, the more conspicuous
+// style and the border of the innermost part win. On a tie o, the border
+// further up and to the left, stays.
+func (a tableBorder) beats(o tableBorder) bool {
+	switch {
+	case o.style == "hidden":
+		return false
+	case a.style == "hidden":
+		return true
+	case (a.style == "none") != (o.style == "none"):
+		return o.style == "none"
+	case a.width != o.width:
+		return a.width > o.width
+	case a.style != o.style:
+		return borderStyleRank[a.style] > borderStyleRank[o.style]
+	}
+	return a.part > o.part
+}
+
+// collapseBorders resolves the border on every grid line segment from the
+// borders of the cells, rows, row groups, columns, column groups and table
+// that meet there, and gives each cell, and the table, the widest of the
+// segments along each of its sides.
+func (t *tableLayout) collapseBorders() {
+	nr, nc := len(t.rows), len(t.cols)
+	none := tableBorder{style: "none"}
+	// h[r][c] is the segment above row r in column c, v[r][c] the one left
+	// of column c in row r.
+	h := make([][]tableBorder, nr+1)
+	for r := range h {
+		h[r] = make([]tableBorder, nc)
+		for c := range h[r] {
+			h[r][c] = none
+		}
+	}
+	v := make([][]tableBorder, nr)
+	for r := range v {
+		v[r] = make([]tableBorder, nc+1)
+		for c := range v[r] {
+			v[r][c] = none
+		}
+	}
+	horizontal := func(r, c0, c1 int, b tableBorder) {
+		for c := c0; c < c1; c++ {
+			if b.beats(h[r][c]) {
+				h[r][c] = b
+			}
+		}
+	}
+	vertical := func(r0, r1, c int, b tableBorder) {
+		for r := r0; r < r1; r++ {
+			if b.beats(v[r][c]) {
+				v[r][c] = b
+			}
+		}
+	}
+	frame := func(s *StyledNode, part, r0, r1, c0, c1 int) {
+		horizontal(r0, c0, c1, borderOf(s, sideTop, part))
+		horizontal(r1, c0, c1, borderOf(s, sideBottom, part))
+		vertical(r0, r1, c0, borderOf(s, sideLeft, part))
+		vertical(r0, r1, c1, borderOf(s, sideRight, part))
+	}
+	for _, c := range t.cells {
+		frame(c.box.StyledNode, partCell, c.row, c.row+c.rowSpan, c.col, c.col+c.colSpan)
+	}
+	for r, row := range t.rows {
+		frame(row.box.StyledNode, partRow, r, r+1, 0, nc)
+	}
+	for _, g := range t.rowGroups {
+		frame(g.box.StyledNode, partRowGroup, g.start, g.end, 0, nc)
+	}
+	for c, col := range t.cols {
+		if col.box != nil {
+			frame(col.box.StyledNode, partColumn, 0, nr, c, c+1)
+		}
+	}
+	for _, g := range t.colGroups {
+		frame(g.box.StyledNode, partColumnGroup, 0, nr, g.start, g.end)
+	}
+	table := t.table.StyledNode
+	frame(table, partTable, 0, nr, 0, nc)
+
+	widest := func(segments []tableBorder) tableBorder {
+		w := none
+		for _, s := range segments {
+			if s.width > w.width || w.style == "none" {
+				w = s
+			}
+		}
+		return w
+	}
+	column := func(r0, r1, c int) []tableBorder {
+		var s []tableBorder
+		for r := r0; r < r1; r++ {
+			s = append(s, v[r][c])
+		}
+		return s
+	}
+	for _, c := range t.cells {
+		var b [4]tableBorder
+		b[sideTop] = widest(h[c.row][c.col : c.col+c.colSpan])
+		b[sideBottom] = widest(h[c.row+c.rowSpan][c.col : c.col+c.colSpan])
+		b[sideLeft] = widest(column(c.row, c.row+c.rowSpan, c.col))
+		b[sideRight] = widest(column(c.row, c.row+c.rowSpan, c.col+c.colSpan))
+		c.box.setCollapsed(&b)
+	}
+	// The table has half of the outer borders of its first row at the sides
+	// and the widest ones at the top and bottom (CSS 2.1 17.6.2).
+	var b [4]tableBorder
+	if nr == 0 || nc == 0 {
+		for side := range b {
+			b[side] = borderOf(table, side, partTable)
+		}
+	} else {
+		b[sideTop], b[sideBottom] = widest(h[0]), widest(h[nr])
+		b[sideLeft], b[sideRight] = v[0][0], v[0][nc]
+	}
+	t.table.setCollapsed(&b)
+}
+
+// setCollapsed sets the collapsed borders of b, invalidating its layout when
+// they change.
+func (b *LayoutBox) setCollapsed(borders *[4]tableBorder) {
+	if b.collapsed == nil && borders == nil || b.collapsed != nil && borders != nil && *b.collapsed == *borders {
+		return
+	}
+	b.collapsed = borders
+	b.valid = false
+}
+
+// fixedLayout reports whether the table uses the fixed table layout
+// algorithm, which needs a width that does not depend on the content.
+func (t *tableLayout) fixedLayout(widthKnown bool) bool {
+	style := t.table.StyledNode
+	w := style.Length("width", autoLength)
+	return style.Value("table-layout") == "fixed" && !w.IsAuto() && (w.Unit != "%" || widthKnown)
+}
+
+// measureColumns sets the minimum and maximum widths of the columns. In the
+// fixed table layout (CSS 2.1 17.5.2.1) they come from the column elements
+// and the cells of the first row; in the automatic one (17.5.2.2) from all
+// the cells, with the demands of spanning cells spread over their columns.
+func (t *tableLayout) measureColumns(fixed bool) {
+	for _, col := range t.cols {
+		if col.box == nil {
+			continue
+		}
+		style := col.box.StyledNode
+		switch w := style.Length("width", autoLength); {
+		case w.Unit == "%":
+			col.percent = w.Value
+		case !w.IsAuto():
+			col.min = w.ToPx(0, style.FontSize(), style.viewportRect())
+			col.max, col.fixed = col.min, true
+		}
+	}
+	if fixed {
+		for _, c := range t.cells {
+			if c.row != 0 {
+				continue
+			}
+			c.measure(false)
+			if !c.fixed && c.percent == 0 {
+				continue
+			}
+			share := (c.min - t.spacingX*float32(c.colSpan-1)) / float32(c.colSpan)
+			for _, col := range t.cols[c.col : c.col+c.colSpan] {
+				if col.fixed || col.percent > 0 {
+					continue
+				}
+				if c.fixed {
+					col.min, col.max, col.fixed = share, share, true
+				} else {
+					col.percent = c.percent / float32(c.colSpan)
+				}
+			}
+		}
+		return
+	}
+
+	var spanning []*tableCell
+	for _, c := range t.cells {
+		c.measure(true)
+		if c.colSpan > 1 {
+			spanning = append(spanning, c)
+			continue
+		}
+		col := t.cols[c.col]
+		col.min = max(col.min, c.min)
+		col.max = max(col.max, c.max)
+		col.percent = max(col.percent, c.percent)
+		col.fixed = col.fixed || c.fixed
+	}
+	for _, col := range t.cols {
+		col.max = max(col.max, col.min)
+	}
+	sort.SliceStable(spanning, func(i, j int) bool { return spanning[i].colSpan < spanning[j].colSpan })
+	for _, c := range spanning {
+		cols := t.cols[c.col : c.col+c.colSpan]
+		gaps := t.spacingX * float32(c.colSpan-1)
+		spread(cols, c.min-gaps, func(col *tableTrack) *float32 { return &col.min })
+		spread(cols, c.max-gaps, func(col *tableTrack) *float32 { return &col.max })
+		for _, col := range cols {
+			col.max = max(col.max, col.min)
+		}
+	}
+}
+
+// spread raises the widths of cols that field selects so that they add up
+// to at least total, in proportion to the maximum widths of the columns, or
+// equally when those are all zero.
+func spread(cols []*tableTrack, total float32, field func(*tableTrack) *float32) {
+	var have, weights float32
+	for _, col := range cols {
+		have += *field(col)
+		weights += col.max
+	}
+	extra := total - have
+	if extra <= 0 {
+		return
+	}
+	for _, col := range cols {
+		share := extra / float32(len(cols))
+		if weights > 0 {
+			share = extra * col.max / weights
+		}
+		*field(col) += share
+	}
+}
+
+// measure sets the width contributions of the cell's border box: its
+// min-content and max-content widths, which a specified width replaces
+// unless it is smaller than the min-content width. Without content only a
+// specified width counts.
+func (c *tableCell) measure(content bool) {
+	box := c.box
+	style := box.StyledNode
+	frame := box.frameX(0)
+	var lo, hi float32
+	if content {
+		lo, hi = box.contentWidths()
+	}
+	c.fixed, c.percent = false, 0
+	switch w := style.Length("width", autoLength); {
+	case w.Unit == "%":
+		c.percent = w.Value
+	case !w.IsAuto():
+		lo = max(lo, box.contentSize(w.ToPx(0, style.FontSize(), style.viewportRect()), frame))
+		hi, c.fixed = lo, true
+	}
+	c.min, c.max = lo+frame, max(hi, lo)+frame
+}
+
+// spacingAround returns the border spacing around n tracks along one axis.
+func spacingAround(gap float32, n int) float32 {
+	if n == 0 {
+		return 0
+	}
+	return gap * float32(n+1)
+}
+
+// tableWidths returns the minimum and maximum border-box widths of a table
+// box. A specified width, with percentages resolved against cbWidth when it
+// is known, takes the place of the maximum and raises the minimum.
+func (b *LayoutBox) tableWidths(cbWidth float32, known bool) (lo, hi float32) {
+	t := newTableLayout(b)
+	fixed := t.fixedLayout(known)
+	t.measureColumns(fixed)
+	var percent, rest float32
+	for _, col := range t.cols {
+		lo += col.min
+		hi += col.max
+		if col.percent > 0 {
+			percent += col.percent
+			// A column that takes a share of the table makes the table as
+			// wide as it needs to be for the column to fit.
+			hi = max(hi, col.max*100/col.percent)
+		} else {
+			rest += col.max
+		}
+	}
+	if percent > 0 && percent < 100 {
+		hi = max(hi, rest*100/(100-percent))
+	}
+	extra := b.frameX(cbWidth) + spacingAround(t.spacingX, len(t.cols))
+	lo, hi = lo+extra, max(hi, lo)+extra
+	if fixed {
+		hi = lo
+	}
+	style := b.StyledNode
+	if w := style.Length("width", autoLength); !w.IsAuto() && (w.Unit != "%" || known) {
+		frame := b.frameX(cbWidth)
+		spec := b.contentSize(w.ToPx(cbWidth, style.FontSize(), style.viewportRect()), frame) + frame
+		lo = max(lo, spec)
+		hi = lo
+	}
+	return lo, hi
+}
+
+// tableWrapperWidths returns the min-content and max-content widths of a
+// table wrapper box: those of its table, and at least the min-content
+// widths of its captions.
+func (b *LayoutBox) tableWrapperWidths(cbWidth float32, known bool) (lo, hi float32) {
+	lo, hi = b.wrapped.tableWidths(cbWidth, known)
+	for _, child := range b.Children {
+		if child != b.wrapped {
+			captionMin, _ := child.outerWidths()
+			lo = max(lo, captionMin)
+		}
+	}
+	return lo, max(hi, lo)
+}
+
+// tableWrapperWidth returns the used width of a block-level table wrapper
+// box with fill pixels available: the table shrinks to fit like a float.
+func (b *LayoutBox) tableWrapperWidth(cbWidth, fill float32) float32 {
+	lo, hi := b.tableWrapperWidths(cbWidth, true)
+	return max(lo, min(fill, hi))
+}
+
+// sizeColumns gives the columns their used widths in avail pixels. Fixed
+// columns get what they ask for and the others share the rest equally. In
+// the automatic layout every column gets its minimum; the remaining space
+// then grows percentage columns toward their share and the others toward
+// their maximum, and what is left goes to the columns without a specified
+// width in proportion to their maximum widths.
+func (t *tableLayout) sizeColumns(avail float32, fixed bool) {
+	free := avail
+	var auto, pct, rest []*tableTrack
+	for _, col := range t.cols {
+		switch {
+		case fixed && col.percent > 0:
+			col.size = col.percent * avail / 100
+		case fixed && !col.fixed:
+			col.size = 0
+		default:
+			col.size = col.min
+		}
+		free -= col.size
+		switch {
+		case col.percent > 0:
+			pct = append(pct, col)
+		case !col.fixed:
+			auto = append(auto, col)
+		default:
+			rest = append(rest, col)
+		}
+	}
+	if free <= 0 {
+		return
+	}
+	if fixed {
+		if len(auto) > 0 {
+			share(auto, free, func(*tableTrack) float32 { return 0 })
+		} else {
+			share(t.cols, free, func(col *tableTrack) float32 { return col.size })
+		}
+		return
+	}
+	free -= grow(pct, free, func(col *tableTrack) float32 { return max(0, col.percent*avail/100-col.size) })
+	free -= grow(append(auto, rest...), free, func(col *tableTrack) float32 { return col.max - col.size })
+	if free <= 0 {
+		return
+	}
+	for _, cols := range [][]*tableTrack{auto, rest, pct} {
+		if len(cols) > 0 {
+			share(cols, free, func(col *tableTrack) float32 { return col.max })
+			return
+		}
+	}
+}
+
+// grow adds to the size of each track what want asks for it, scaled down
+// in proportion when the asks add up to more than free, and returns the
+// pixels added.
+func grow(tracks []*tableTrack, free float32, want func(*tableTrack) float32) float32 {
+	wants := make([]float32, len(tracks))
+	var total float32
+	for i, tr := range tracks {
+		wants[i] = max(0, want(tr))
+		total += wants[i]
+	}
+	if total <= 0 {
+		return 0
+	}
+	scale := min(1, free/total)
+	for i, tr := range tracks {
+		tr.size += wants[i] * scale
+	}
+	return total * scale
+}
+
+// share adds free pixels to the sizes of tracks in proportion to weight, or
+// equally when all weights are zero.
+func share(tracks []*tableTrack, free float32, weight func(*tableTrack) float32) {
+	var total float32
+	for _, tr := range tracks {
+		total += max(0, weight(tr))
+	}
+	for _, tr := range tracks {
+		if total > 0 {
+			tr.size += free * max(0, weight(tr)) / total
+		} else {
+			tr.size += free / float32(len(tracks))
+		}
+	}
+}
+
+// span returns the size of n tracks from start and the spacing between them.
+func span(tracks []*tableTrack, start, n int, gap float32) float32 {
+	size := gap * float32(n-1)
+	for _, tr := range tracks[start : start+n] {
+		size += tr.size
+	}
+	return size
+}
+
+// layoutTableWrapper stacks the captions of a table wrapper box above and
+// below its table box, which is as wide as the wrapper.
+func (b *LayoutBox) layoutTableWrapper() {
+	d := &b.Dimensions
+	b.Lines = nil
+	b.marginTop, b.marginBottom = marginOf(d.Margin.Top), marginOf(d.Margin.Bottom)
+	b.collapsesThrough = false
+	var top, bottom []*LayoutBox
+	for _, child := range b.Children {
+		switch {
+		case child == b.wrapped:
+		case child.StyledNode.Value("caption-side") == "bottom":
+			bottom = append(bottom, child)
+		default:
+			top = append(top, child)
+		}
+	}
+	container := *d
+	container.Content.Height = 0
+	for _, child := range append(append(top, b.wrapped), bottom...) {
+		if child == b.wrapped {
+			// The width of the wrapper resolved the collapsed borders.
+			width := max(0, d.Content.Width-child.frameX(d.Content.Width))
+			child.layoutAssigned(container, sizeOverride{width: width, hasWidth: true})
+		} else {
+			child.Layout(container)
+		}
+		container.Content.Height += child.Dimensions.MarginBox().Height
+	}
+	d.Content.Height = container.Content.Height
+}
+
+// layoutTable sizes the columns and rows of a table box, lays out its cells
+// in them and sets its content height (CSS 2.1 17.5). The width and the
+// edges of the table are already resolved.
+func (b *LayoutBox) layoutTable() {
+	d := &b.Dimensions
+	b.Lines = nil
+	b.marginTop, b.marginBottom = marginOf(d.Margin.Top), marginOf(d.Margin.Bottom)
+	b.collapsesThrough = false
+
+	t := newTableLayout(b)
+	fixed := t.fixedLayout(true)
+	t.measureColumns(fixed)
+	t.sizeColumns(d.Content.Width-spacingAround(t.spacingX, len(t.cols)), fixed)
+	pos := t.spacingX
+	for _, col := range t.cols {
+		col.pos = pos
+		pos += col.size + t.spacingX
+	}
+
+	// Lay every cell out in its columns to find the heights of the rows.
+	container := *d
+	container.Content.Height = 0
+	for _, c := range t.cells {
+		width := span(t.cols, c.col, c.colSpan, t.spacingX)
+		container.Content.Width = width
+		box := c.box
+		box.layoutAssigned(container, sizeOverride{width: max(0, width-box.frameX(width)), hasWidth: true})
+		bb := box.Dimensions.BorderBox()
+		c.height = bb.Height
+		c.baseline = box.cellBaseline() - bb.Y
+	}
+	t.sizeRows()
+	height := spacingAround(t.spacingY, len(t.rows))
+	for _, row := range t.rows {
+		height += row.size
+	}
+	if h, ok := b.definiteHeight(); ok && h > height && len(t.rows) > 0 {
+		// A table taller than its rows makes them taller.
+		share(t.rows, h-height, func(row *tableTrack) float32 { return row.size })
+		height = h
+	}
+	pos = t.spacingY
+	for _, row := range t.rows {
+		row.pos = pos
+		pos += row.size + t.spacingY
+	}
+
+	rtl := b.StyledNode.Value("direction") == "rtl"
+	columnX := func(start, end int) (x, width float32) {
+		x, width = t.cols[start].pos, span(t.cols, start, end-start, t.spacingX)
+		if rtl {
+			x = d.Content.Width - x - width
+		}
+		return d.Content.X + x, width
+	}
+	for _, c := range t.cells {
+		x, width := columnX(c.col, c.col+c.colSpan)
+		container.Content.Width = width
+		box := c.box
+		pt, bt := box.edge(sideTop, width)
+		pb, bb := box.edge(sideBottom, width)
+		h := max(0, span(t.rows, c.row, c.rowSpan, t.spacingY)-pt-bt-pb-bb)
+		box.layoutAssigned(container, sizeOverride{width: box.Dimensions.Content.Width, height: h, hasWidth: true, hasHeight: true})
+		border := box.flowBorderBox()
+		box.translate(x-border.X, d.Content.Y+t.rows[c.row].pos-border.Y)
+		box.Dimensions.Margin = EdgeSizes{}
+		// vertical-align moves the content within the cell.
+		var shift float32
+		switch c.align {
+		case "middle":
+			shift = (box.Dimensions.Content.Height - box.contentExtent()) / 2
+		case "bottom":
+			shift = box.Dimensions.Content.Height - box.contentExtent()
+		case "baseline":
+			shift = t.rows[c.row].baseline - c.baseline
+		}
+		if shift > 0 {
+			box.translate(0, shift)
+			box.Dimensions.Content.Y -= shift
+		}
+	}
+
+	// Rows, row groups, columns and column groups cover their cells.
+	inner := max(0, d.Content.Width-2*t.spacingX)
+	for _, row := range t.rows {
+		row.box.Dimensions = Dimensions{Content: Rect{X: d.Content.X + t.spacingX, Y: d.Content.Y + row.pos, Width: inner, Height: row.size}}
+	}
+	for _, g := range t.rowGroups {
+		r := Rect{X: d.Content.X + t.spacingX, Y: d.Content.Y, Width: inner}
+		if g.end > g.start {
+			first, last := t.rows[g.start], t.rows[g.end-1]
+			r.Y += first.pos
+			r.Height = last.pos + last.size - first.pos
+		}
+		g.box.Dimensions = Dimensions{Content: r}
+	}
+	rowsHeight := max(0, height-2*t.spacingY)
+	for i := 0; i < len(t.cols); {
+		j := i + 1
+		for j < len(t.cols) && t.cols[j].box == t.cols[i].box {
+			j++
+		}
+		if box := t.cols[i].box; box != nil {
+			x, width := columnX(i, j)
+			box.Dimensions = Dimensions{Content: Rect{X: x, Y: d.Content.Y + t.spacingY, Width: width, Height: rowsHeight}}
+		}
+		i = j
+	}
+	for _, g := range t.colGroups {
+		r := Rect{X: d.Content.X, Y: d.Content.Y + t.spacingY, Height: rowsHeight}
+		if g.end > g.start {
+			r.X, r.Width = columnX(g.start, g.end)
+		}
+		g.box.Dimensions = Dimensions{Content: r}
+	}
+	d.Content.Height = height
+}
+
+// sizeRows sets the heights of the rows and the baselines cells align to
+// (CSS 2.1 17.5.3). A specified height is a minimum, and cells spanning
+// several rows make them taller together when they need more space.
+func (t *tableLayout) sizeRows() {
+	for _, row := range t.rows {
+		style := row.box.StyledNode
+		row.size, row.baseline = 0, 0
+		if l := style.Length("height", autoLength); !l.IsAuto() && l.Unit != "%" {
+			row.size = l.ToPx(0, style.FontSize(), style.viewportRect())
+		}
+	}
+	for _, c := range t.cells {
+		if c.align == "baseline" {
+			row := t.rows[c.row]
+			row.baseline = max(row.baseline, c.baseline)
+		}
+	}
+	// need returns the height from the top of the cell's first row that
+	// the cell takes up.
+	need := func(c *tableCell) float32 {
+		if c.align == "baseline" {
+			return t.rows[c.row].baseline - c.baseline + c.height
+		}
+		return c.height
+	}
+	var spanning []*tableCell
+	for _, c := range t.cells {
+		if c.rowSpan > 1 {
+			spanning = append(spanning, c)
+			continue
+		}
+		row := t.rows[c.row]
+		row.size = max(row.size, need(c))
+	}
+	sort.SliceStable(spanning, func(i, j int) bool { return spanning[i].rowSpan < spanning[j].rowSpan })
+	for _, c := range spanning {
+		rows := t.rows[c.row : c.row+c.rowSpan]
+		extra := need(c) - span(t.rows, c.row, c.rowSpan, t.spacingY)
+		if extra <= 0 {
+			continue
+		}
+		share(rows, extra, func(row *tableTrack) float32 { return row.size })
+	}
+}
+
+// cellBaseline returns the baseline of a cell: that of its first line box,
+// or the bottom of its content box when it has none.
+func (b *LayoutBox) cellBaseline() float32 {
+	if y, ok := b.firstBaseline(); ok {
+		return y
+	}
+	return b.Dimensions.Content.Y + b.Dimensions.Content.Height
+}
+
+// contentExtent returns how far the in-flow content of a block container
+// reaches below the top of its content box.
+func (b *LayoutBox) contentExtent() float32 {
+	top := b.Dimensions.Content.Y
+	bottom := top
+	for _, line := range b.Lines {
+		bottom = max(bottom, line.Rect.Y+line.Rect.Height)
+	}
+	for _, child := range b.Children {
+		if child.isInlineLevel() || !child.inFlow() {
+			continue
+		}
+		mb := child.Dimensions.MarginBox()
+		bottom = max(bottom, mb.Y+mb.Height)
+	}
+	return bottom - top
+}
@@ -0,0 +1,98 @@
+package layout
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTableColumnWidths(t *testing.T) {
+	// cell returns a cell holding a block w pixels wide, whose min-content
+	// and max-content widths are both w.
+	cell := func(id string, w int, attrs string) string {
+		content := ""
+		if w > 0 {
+			content = fmt.Sprintf(`<div class="w%d"></div>`, w)
+		}
+		return fmt.Sprintf(`<td id="%s"%s>%s</td>`, id, attrs, content)
+	}
+	row := func(cells ...string) string {
+		s := "<tr>"
+		for _, c := range cells {
+			s += c
+		}
+		return s + "</tr>"
+	}
+	tests := []struct {
+		name string
+		css  string
+		rows string
+		want map[string]Rect
+	}{
+		{
+			name: "shrink to fit",
+			rows: row(cell("a", 50, ""), cell("b", 100, "")),
+			want: map[string]Rect{"t": {0, 0, 150, 10}, "a": {0, 0, 50, 10}, "b": {50, 0, 100, 10}},
+		},
+		{
+			name: "extra space by max width",
+			css:  "#t { width: 300px; }",
+			rows: row(cell("a", 50, ""), cell("b", 100, "")),
+			want: map[string]Rect{"a": {0, 0, 100, 10}, "b": {100, 0, 200, 10}},
+		},
+		{
+			name: "specified width kept",
+			css:  "#t { width: 300px; } #a { width: 80px; }",
+			rows: row(cell("a", 0, ""), cell("b", 20, "")),
+			want: map[string]Rect{"a": {0, 0, 80, 10}, "b": {80, 0, 220, 10}},
+		},
+		{
+			name: "percentage",
+			css:  "#t { width: 300px; } #a { width: 50%; }",
+			rows: row(cell("a", 0, ""), cell("b", 20, "")),
+			want: map[string]Rect{"a": {0, 0, 150, 10}, "b": {150, 0, 150, 10}},
+		},
+		{
+			name: "min width wins",
+			css:  "#t { width: 100px; }",
+			rows: row(cell("a", 80, ""), cell("b", 60, "")),
+			want: map[string]Rect{"t": {0, 0, 140, 10}, "b": {80, 0, 60, 10}},
+		},
+		{
+			name: "colspan spread",
+			rows: row(cell("s", 200, ` colspan="2"`)) + row(cell("a", 50, ""), cell("b", 50, "")),
+			want: map[string]Rect{"s": {0, 0, 200, 10}, "a": {0, 10, 100, 10}, "b": {100, 10, 100, 10}},
+		},
+		{
+			name: "colspan by max width",
+			rows: row(cell("s", 200, ` colspan="2"`)) + row(cell("a", 20, ""), cell("b", 80, "")),
+			want: map[string]Rect{"a": {0, 10, 40, 10}, "b": {40, 10, 160, 10}},
+		},
+		{
+			name: "border spacing",
+			css:  "#t { border-spacing: 10px 4px; }",
+			rows: row(cell("a", 50, ""), cell("b", 50, "")) + row(cell("c", 50, ""), cell("d", 50, "")),
+			want: map[string]Rect{"t": {0, 0, 130, 32}, "b": {70, 4, 50, 10}, "c": {10, 18, 50, 10}},
+		},
+		{
+			name: "fixed layout",
+			css:  "#t { width: 300px; table-layout: fixed; } #a { width: 100px; }",
+			rows: row(cell("a", 0, ""), cell("b", 0, "")) + row(cell("c", 250, ""), cell("d", 0, "")),
+			want: map[string]Rect{"b": {100, 0, 200, 0}, "c": {0, 0, 100, 10}},
+		},
+		{
+			name: "column element",
+			css:  "#t { width: 300px; } col { width: 120px; }",
+			rows: `<colgroup><col></colgroup>` + row(cell("a", 0, ""), cell("b", 0, "")),
+			want: map[string]Rect{"a": {0, 0, 120, 0}, "b": {120, 0, 180, 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkBoxes(t, `table { border-spacing: 0; } td { padding: 0; }
+.w20 { width: 20px; } .w50 { width: 50px; } .w60 { width: 60px; } .w80 { width: 80px; }
+.w100 { width: 100px; } .w200 { width: 200px; } .w250 { width: 250px; }
+td > div { height: 10px; }
+`, tt.css, `<table id="t">`+tt.rows+`</table>`, tt.want)
+		})
+	}
+}
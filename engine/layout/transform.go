@@ -0,0 +1,251 @@
+package layout
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// identityTransform maps every point to itself.
+var identityTransform = [6]float32{1, 0, 0, 1, 0, 0}
+
+// transformFunction is one function of a transform list with its arguments
+// as written.
+type transformFunction struct {
+	name string
+	args []string
+}
+
+// parseTransform splits a transform value into its functions. ok is false
+// when the value is invalid; none is an empty list.
+func parseTransform(s string) (fns []transformFunction, ok bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "none" {
+		return nil, true
+	}
+	for s != "" {
+		open := strings.IndexByte(s, '(')
+		end := strings.IndexByte(s, ')')
+		if open <= 0 || end < open {
+			return nil, false
+		}
+		f := transformFunction{name: strings.TrimSpace(s[:open])}
+		for _, a := range strings.FieldsFunc(s[open+1:end], func(r rune) bool { return r == ',' || r == ' ' }) {
+			f.args = append(f.args, a)
+		}
+		fns = append(fns, f)
+		s = strings.TrimSpace(s[end+1:])
+	}
+	return fns, true
+}
+
+// isTransformed reports whether b has a valid transform other than none,
+// which makes it a stacking context and the containing block of all its
+// positioned descendants.
+func (b *LayoutBox) isTransformed() bool {
+	_, ok := b.Transform()
+	return ok
+}
+
+// Transform returns the transform of b as a matrix [a b c d e f], mapping a
+// point (x, y) drawn for the box to (a*x + c*y + e, b*x + d*y + f), with
+// its transform-origin applied. Percentages refer to the border box. ok is
+// false when b is not transformed; inline boxes that are not atomic cannot
+// be.
+func (b *LayoutBox) Transform() (m [6]float32, ok bool) {
+	fns, ok := parseTransform(b.StyledNode.Value("transform"))
+	if !ok || len(fns) == 0 || b.BoxType == InlineNode {
+		return identityTransform, false
+	}
+	bb := b.Dimensions.BorderBox()
+	fontSize, viewport := b.StyledNode.FontSize(), b.StyledNode.viewportRect()
+	m = identityTransform
+	for _, f := range fns {
+		t, ok := f.matrix(bb.Width, bb.Height, fontSize, viewport)
+		if !ok {
+			return identityTransform, false
+		}
+		m = mulTransform(m, t)
+	}
+	ox, oy := b.transformOrigin(bb, fontSize, viewport)
+	m = mulTransform(mulTransform([6]float32{1, 0, 0, 1, ox, oy}, m), [6]float32{1, 0, 0, 1, -ox, -oy})
+	return m, true
+}
+
+// matrix returns the transform f stands for in a box of the given size.
+func (f transformFunction) matrix(w, h, fontSize float32, viewport Rect) ([6]float32, bool) {
+	length := func(i int, reference float32) (float32, bool) {
+		l, ok := ParseLength(f.args[i])
+		if !ok || l.IsAuto() {
+			return 0, false
+		}
+		return l.ToPx(reference, fontSize, viewport), true
+	}
+	number := func(i int) (float32, bool) {
+		if p, ok := strings.CutSuffix(f.args[i], "%"); ok {
+			v, ok := parseNumber(p)
+			return v / 100, ok
+		}
+		return parseNumber(f.args[i])
+	}
+	angle := func(i int) (float32, bool) { return parseAngle(f.args[i]) }
+	// pair reads one or two arguments, the second defaulting to def.
+	pair := func(read func(int) (float32, bool), def func(x float32) float32) (x, y float32, ok bool) {
+		switch len(f.args) {
+		case 1:
+			x, ok = read(0)
+			return x, def(x), ok
+		case 2:
+			x, ok1 := read(0)
+			y, ok2 := read(1)
+			return x, y, ok1 && ok2
+		}
+		return 0, 0, false
+	}
+	one := func(read func(int) (float32, bool)) (float32, bool) {
+		if len(f.args) != 1 {
+			return 0, false
+		}
+		return read(0)
+	}
+	switch f.name {
+	case "matrix":
+		if len(f.args) != 6 {
+			return identityTransform, false
+		}
+		var m [6]float32
+		for i := range m {
+			v, ok := parseNumber(f.args[i])
+			if !ok {
+				return identityTransform, false
+			}
+			m[i] = v
+		}
+		return m, true
+	case "translate", "translate3d":
+		if f.name == "translate3d" && len(f.args) == 3 {
+			f.args = f.args[:2]
+		}
+		x, y, ok := pair(func(i int) (float32, bool) {
+			if i == 0 {
+				return length(0, w)
+			}
+			return length(1, h)
+		}, func(float32) float32 { return 0 })
+		return [6]float32{1, 0, 0, 1, x, y}, ok
+	case "translatex":
+		x, ok := one(func(i int) (float32, bool) { return length(i, w) })
+		return [6]float32{1, 0, 0, 1, x, 0}, ok
+	case "translatey":
+		y, ok := one(func(i int) (float32, bool) { return length(i, h) })
+		return [6]float32{1, 0, 0, 1, 0, y}, ok
+	case "scale", "scale3d":
+		if f.name == "scale3d" && len(f.args) == 3 {
+			f.args = f.args[:2]
+		}
+		x, y, ok := pair(number, func(x float32) float32 { return x })
+		return [6]float32{x, 0, 0, y, 0, 0}, ok
+	case "scalex":
+		x, ok := one(number)
+		return [6]float32{x, 0, 0, 1, 0, 0}, ok
+	case "scaley":
+		y, ok := one(number)
+		return [6]float32{1, 0, 0, y, 0, 0}, ok
+	case "rotate", "rotatez":
+		a, ok := one(angle)
+		sin, cos := math.Sincos(float64(a))
+		return [6]float32{float32(cos), float32(sin), float32(-sin), float32(cos), 0, 0}, ok
+	case "skew":
+		x, y, ok := pair(angle, func(float32) float32 { return 0 })
+		return [6]float32{1, float32(math.Tan(float64(y))), float32(math.Tan(float64(x))), 1, 0, 0}, ok
+	case "skewx":
+		x, ok := one(angle)
+		return [6]float32{1, 0, float32(math.Tan(float64(x))), 1, 0, 0}, ok
+	case "skewy":
+		y, ok := one(angle)
+		return [6]float32{1, float32(math.Tan(float64(y))), 0, 1, 0, 0}, ok
+	}
+	return identityTransform, false
+}
+
+// parseAngle parses a CSS angle into radians; a unitless zero is allowed.
+func parseAngle(s string) (float32, bool) {
+	units := []struct {
+		suffix string
+		scale  float64
+	}{{"deg", math.Pi / 180}, {"grad", math.Pi / 200}, {"rad", 1}, {"turn", 2 * math.Pi}}
+	for _, u := range units {
+		if v, ok := strings.CutSuffix(s, u.suffix); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return 0, false
+			}
+			return float32(f * u.scale), true
+		}
+	}
+	if s == "0" {
+		return 0, true
+	}
+	return 0, false
+}
+
+// transformOrigin returns the point of the border box bb that transform-origin
+// places the transform around, its center by default.
+func (b *LayoutBox) transformOrigin(bb Rect, fontSize float32, viewport Rect) (float32, float32) {
+	values := strings.Fields(strings.ToLower(b.StyledNode.Value("transform-origin")))
+	x, y := "50%", "50%"
+	vertical := func(v string) bool { return v == "top" || v == "bottom" }
+	switch {
+	case len(values) == 1 && vertical(values[0]):
+		y = values[0]
+	case len(values) == 1:
+		x = values[0]
+	case len(values) >= 2 && (vertical(values[0]) || values[1] == "left" || values[1] == "right"):
+		x, y = values[1], values[0]
+	case len(values) >= 2:
+		x, y = values[0], values[1]
+	}
+	resolve := func(v string, reference float32) float32 {
+		switch v {
+		case "left", "top":
+			return 0
+		case "center":
+			return reference / 2
+		case "right", "bottom":
+			return reference
+		}
+		if l, ok := ParseLength(v); ok && !l.IsAuto() {
+			return l.ToPx(reference, fontSize, viewport)
+		}
+		return reference / 2
+	}
+	return bb.X + resolve(x, bb.Width), bb.Y + resolve(y, bb.Height)
+}
+
+// mulTransform returns the transform that applies n and then m.
+func mulTransform(m, n [6]float32) [6]float32 {
+	return [6]float32{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+// localPoint maps (x, y) to the coordinates b and its content are laid out
+// in, undoing its transform. It reports false when the transform flattens b
+// and nothing of it can be hit.
+func (b *LayoutBox) localPoint(x, y float32) (float32, float32, bool) {
+	m, ok := b.Transform()
+	if !ok {
+		return x, y, true
+	}
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 {
+		return 0, 0, false
+	}
+	x, y = x-m[4], y-m[5]
+	return (m[3]*x - m[2]*y) / det, (m[0]*y - m[1]*x) / det, true
+}
@@ -0,0 +1,79 @@
+package layout
+
+import (
+	"prymis/engine/parser"
+	"sync"
+)
+
+// userAgentCSS is the default style sheet. It is cascaded below the author
+// rules regardless of specificity. Rows directly in a table are aligned as
+// if they were in the tbody element an HTML parser would have inserted.
+const userAgentCSS = `
+html, body, div, p, h1, h2, h3, h4, h5, h6, ul, ol, dl, dt, dd, blockquote,
+pre, address, article, aside, footer, header, nav, section, main, figure,
+figcaption, form, fieldset, legend, hr, center, details, summary, menu, dir,
+hgroup, search, listing, plaintext, xmp { display: block; }
+li { display: list-item; }
+head, script, style, title, meta, link, base, template, noscript, datalist,
+param, area, [hidden] { display: none; }
+img, input, button, select, textarea, svg, video, canvas, iframe, embed,
+object { display: inline-block; }
+table { display: table; border-collapse: separate; border-spacing: 2px; box-sizing: border-box; text-indent: initial; }
+caption { display: table-caption; text-align: center; }
+colgroup { display: table-column-group; }
+col { display: table-column; }
+thead { display: table-header-group; vertical-align: middle; }
+tbody { display: table-row-group; vertical-align: middle; }
+tfoot { display: table-footer-group; vertical-align: middle; }
+tr { display: table-row; vertical-align: inherit; }
+table > tr { vertical-align: middle; }
+td, th { display: table-cell; vertical-align: inherit; padding: 1px; }
+th { text-align: center; }
+
+body { margin: 8px; }
+p, dl, figure, menu, dir { margin-top: 1em; margin-bottom: 1em; }
+ul, ol { margin-top: 1em; margin-bottom: 1em; padding-left: 40px; }
+blockquote { margin: 1em 40px; }
+figure { margin-left: 40px; margin-right: 40px; }
+dd { margin-left: 40px; }
+h1 { font-size: 2em; margin-top: 0.67em; margin-bottom: 0.67em; font-weight: bold; }
+h2 { font-size: 1.5em; margin-top: 0.83em; margin-bottom: 0.83em; font-weight: bold; }
+h3 { font-size: 1.17em; margin-top: 1em; margin-bottom: 1em; font-weight: bold; }
+h4 { margin-top: 1.33em; margin-bottom: 1.33em; font-weight: bold; }
+h5 { font-size: 0.83em; margin-top: 1.67em; margin-bottom: 1.67em; font-weight: bold; }
+h6 { font-size: 0.67em; margin-top: 2.33em; margin-bottom: 2.33em; font-weight: bold; }
+hr { border: 1px inset gray; margin: 0.5em auto; }
+pre, listing, plaintext, xmp { white-space: pre; margin-top: 1em; margin-bottom: 1em; }
+pre, code, kbd, samp, tt, listing, plaintext, xmp { font-family: monospace; }
+textarea { white-space: pre-wrap; }
+b, strong, th, dt { font-weight: bold; }
+i, em, cite, var, dfn, address { font-style: italic; }
+u, ins { text-decoration: underline; }
+s, strike, del { text-decoration: line-through; }
+small { font-size: smaller; }
+big { font-size: larger; }
+sub { vertical-align: sub; font-size: smaller; }
+sup { vertical-align: super; font-size: smaller; }
+center { text-align: center; }
+nobr { white-space: nowrap; }
+a:link { color: #0000ee; text-decoration: underline; }
+input, button, select { border: 2px inset gray; padding: 1px 2px; }
+textarea { border: 1px solid gray; padding: 2px; }
+`
+
+var (
+	userAgentOnce  sync.Once
+	userAgentRules []*compiledRule
+)
+
+// userAgentStyles returns the compiled default style sheet.
+func userAgentStyles() []*compiledRule {
+	userAgentOnce.Do(func() {
+		p := parser.NewCSSParser(userAgentCSS)
+		userAgentRules = compileRules(p.Parse())
+		for _, r := range userAgentRules {
+			r.origin = originUserAgent
+		}
+	})
+	return userAgentRules
+}
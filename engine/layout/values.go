@@ -0,0 +1,149 @@
+package layout
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Length is a CSS length or percentage as written in a specified value.
+type Length struct {
+	Value float32
+	Unit  string
+}
+
+var autoLength = Length{Unit: "auto"}
+
+const defaultFontSize = 16
+
+// ParseLength parses "auto", a number with a unit, a percentage or a
+// unitless zero.
+func ParseLength(s string) (Length, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "auto" {
+		return autoLength, true
+	}
+	i := len(s)
+	for i > 0 && (s[i-1] < '0' || s[i-1] > '9') && s[i-1] != '.' {
+		i--
+	}
+	v, err := strconv.ParseFloat(s[:i], 32)
+	if err != nil {
+		return Length{}, false
+	}
+	unit := s[i:]
+	switch unit {
+	case "":
+		if v != 0 {
+			return Length{}, false
+		}
+		unit = "px"
+	case "px", "%", "em", "rem", "pt", "pc", "in", "cm", "mm", "q", "ex", "ch", "vw", "vh":
+	default:
+		return Length{}, false
+	}
+	return Length{Value: float32(v), Unit: unit}, true
+}
+
+func (l Length) IsAuto() bool {
+	return l.Unit == "auto"
+}
+
+// ToPx resolves l against reference, the length percentages refer to, the
+// element's font size and the size of the viewport. auto resolves to 0.
+func (l Length) ToPx(reference, fontSize float32, viewport Rect) float32 {
+	switch l.Unit {
+	case "px":
+		return l.Value
+	case "%":
+		return l.Value * reference / 100
+	case "em":
+		return l.Value * fontSize
+	case "rem":
+		return l.Value * defaultFontSize
+	case "ex", "ch":
+		return l.Value * fontSize / 2
+	case "pt":
+		return l.Value * 96 / 72
+	case "pc":
+		return l.Value * 16
+	case "in":
+		return l.Value * 96
+	case "cm":
+		return l.Value * 96 / 2.54
+	case "mm":
+		return l.Value * 96 / 25.4
+	case "q":
+		return l.Value * 96 / 101.6
+	case "vw":
+		return l.Value * viewport.Width / 100
+	case "vh":
+		return l.Value * viewport.Height / 100
+	}
+	return 0
+}
+
+// Value returns the specified value of a property, or "".
+func (s *StyledNode) Value(name string) string {
+	return s.SpecifiedValues[name]
+}
+
+// Lookup returns the value of name, falling back to fallbackName and then to
+// def when neither is specified.
+func (s *StyledNode) Lookup(name, fallbackName, def string) string {
+	if v, ok := s.SpecifiedValues[name]; ok {
+		return v
+	}
+	if v, ok := s.SpecifiedValues[fallbackName]; ok {
+		return v
+	}
+	return def
+}
+
+// Length returns the parsed value of a length property, or def when it is
+// missing or invalid.
+func (s *StyledNode) Length(name string, def Length) Length {
+	if l, ok := ParseLength(s.SpecifiedValues[name]); ok {
+		return l
+	}
+	return def
+}
+
+// FontSize returns the font size of the node in pixels.
+func (s *StyledNode) FontSize() float32 {
+	l, ok := ParseLength(s.SpecifiedValues["font-size"])
+	if !ok || l.IsAuto() {
+		return defaultFontSize
+	}
+	return l.ToPx(defaultFontSize, defaultFontSize, s.viewportRect())
+}
+
+// BorderWidth returns the used width of one border side. A side whose style
+// is none or hidden has no width.
+func (s *StyledNode) BorderWidth(side string) float32 {
+	switch s.SpecifiedValues["border-"+side+"-style"] {
+	case "", "none", "hidden":
+		return 0
+	}
+	v := s.Lookup("border-"+side+"-width", "", "medium")
+	switch v {
+	case "thin":
+		return 1
+	case "medium":
+		return 3
+	case "thick":
+		return 5
+	}
+	if l, ok := ParseLength(v); ok && !l.IsAuto() && l.Unit != "%" {
+		return l.ToPx(0, s.FontSize(), s.viewportRect())
+	}
+	return 0
+}
+
+// parseNumber parses a plain CSS number such as a unitless line-height.
+func parseNumber(s string) (float32, bool) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 32)
+	if err != nil {
+		return 0, false
+	}
+	return float32(v), true
+}
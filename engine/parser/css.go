@@ -5,8 +5,14 @@ import (
 )
 
 type StyleRule struct {
+	// AtRule names the at-rule, such as "font-face", of rules that are not
+	// style rules; their Declarations are its descriptors.
+	AtRule       string
 	Selectors    []string
 	Declarations []Declaration
+	// Namespaces maps the prefixes declared by @namespace rules to their
+	// URIs; the empty prefix is the default namespace.
+	Namespaces map[string]string
 }
 
 type Declaration struct {
@@ -15,8 +21,9 @@ type Declaration struct {
 }
 
 type CSSParser struct {
-	pos   int
-	input string
+	pos        int
+	input      string
+	namespaces map[string]string
 }
 
 func NewCSSParser(input string) *CSSParser {
@@ -30,6 +37,12 @@ func (p *CSSParser) Parse() []StyleRule {
 		if p.eof() {
 			break
 		}
+		if p.input[p.pos] == '@' {
+			if rule, ok := p.parseAtRule(); ok {
+				rules = append(rules, rule)
+			}
+			continue
+		}
 		rules = append(rules, p.parseRule())
 	}
 	return rules
@@ -39,33 +52,138 @@ func (p *CSSParser) parseRule() StyleRule {
 	return StyleRule{
 		Selectors:    p.parseSelectors(),
 		Declarations: p.parseDeclarations(),
+		Namespaces:   p.namespaces,
+	}
+}
+
+// parseAtRule handles @namespace, returns @font-face rules and skips any
+// other at-rule, including its block.
+func (p *CSSParser) parseAtRule() (StyleRule, bool) {
+	p.consumeChar() // '@'
+	name := strings.ToLower(p.parseIdentifier())
+	start := p.pos
+	for !p.eof() && p.input[p.pos] != ';' && p.input[p.pos] != '{' {
+		if c := p.input[p.pos]; c == '"' || c == '\'' {
+			p.consumeString()
+			continue
+		}
+		p.consumeChar()
+	}
+	prelude := strings.TrimSpace(p.input[start:p.pos])
+	if !p.eof() && p.input[p.pos] == '{' {
+		if name == "font-face" {
+			p.consumeChar()
+			return StyleRule{AtRule: name, Declarations: p.parseDeclarations()}, true
+		}
+		p.skipBlock()
+	} else {
+		p.consumeChar() // ';'
+	}
+	if name == "namespace" {
+		p.addNamespace(prelude)
+	}
+	return StyleRule{}, false
+}
+
+// addNamespace records "[prefix] url(uri)" or "[prefix] 'uri'".
+func (p *CSSParser) addNamespace(prelude string) {
+	fields := strings.Fields(prelude)
+	if len(fields) == 0 || len(fields) > 2 {
+		return
+	}
+	prefix := ""
+	if len(fields) == 2 {
+		prefix = fields[0]
+	}
+	uri := fields[len(fields)-1]
+	if strings.HasPrefix(uri, "url(") && strings.HasSuffix(uri, ")") {
+		uri = uri[4 : len(uri)-1]
+	}
+	uri = strings.Trim(uri, "\"'")
+	// Rules already parsed keep the map they saw.
+	namespaces := make(map[string]string, len(p.namespaces)+1)
+	for k, v := range p.namespaces {
+		namespaces[k] = v
+	}
+	namespaces[prefix] = uri
+	p.namespaces = namespaces
+}
+
+func (p *CSSParser) skipBlock() {
+	depth := 0
+	for !p.eof() {
+		switch c := p.input[p.pos]; c {
+		case '"', '\'':
+			p.consumeString()
+			continue
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		p.consumeChar()
+		if depth == 0 {
+			return
+		}
 	}
 }
 
+// parseSelectors reads the comma separated selector texts before '{'.
+// Commas nested in brackets, parentheses or strings do not split.
 func (p *CSSParser) parseSelectors() []string {
 	var selectors []string
-	for {
-		p.consumeWhitespace()
-		selectors = append(selectors, p.parseIdentifier())
-		p.consumeWhitespace()
-		if p.input[p.pos] == '{' {
+	depth := 0
+	start := p.pos
+	for !p.eof() {
+		c := p.input[p.pos]
+		switch {
+		case c == '"' || c == '\'':
+			p.consumeString()
+			continue
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case depth <= 0 && (c == ',' || c == '{'):
+			if sel := strings.TrimSpace(p.input[start:p.pos]); sel != "" {
+				selectors = append(selectors, sel)
+			}
 			p.consumeChar()
-			break
+			if c == '{' {
+				return selectors
+			}
+			start = p.pos
+			continue
 		}
-		if p.input[p.pos] == ',' {
+		p.consumeChar()
+	}
+	return selectors
+}
+
+func (p *CSSParser) consumeString() {
+	quote := p.input[p.pos]
+	p.consumeChar()
+	for !p.eof() && p.input[p.pos] != quote {
+		if p.input[p.pos] == '\\' {
 			p.consumeChar()
-		} else {
-			// Safety break or consume
+		}
+		if !p.eof() {
 			p.consumeChar()
 		}
 	}
-	return selectors
+	// An unterminated string ends with the style sheet.
+	if !p.eof() {
+		p.consumeChar()
+	}
 }
 
 func (p *CSSParser) parseDeclarations() []Declaration {
 	var decls []Declaration
 	for {
 		p.consumeWhitespace()
+		if p.eof() {
+			break
+		}
 		if p.input[p.pos] == '}' {
 			p.consumeChar()
 			break
@@ -82,7 +200,9 @@ func (p *CSSParser) parseDeclaration() Declaration {
 	p.consumeWhitespace()
 	value := p.parseValue()
 	p.consumeWhitespace()
-	p.consumeChar() // ';'
+	if !p.eof() && p.input[p.pos] == ';' {
+		p.consumeChar()
+	}
 	return Declaration{Name: name, Value: value}
 }
 
@@ -94,9 +214,23 @@ func (p *CSSParser) parseIdentifier() string {
 	return p.input[start:p.pos]
 }
 
+// parseValue reads a value up to the next '}', or ';' outside of strings
+// and parentheses, so that url(data:...;base64,...) stays whole.
 func (p *CSSParser) parseValue() string {
 	start := p.pos
-	for !p.eof() && p.input[p.pos] != ';' && p.input[p.pos] != '}' {
+	depth := 0
+	for !p.eof() {
+		switch c := p.input[p.pos]; {
+		case c == '"' || c == '\'':
+			p.consumeString()
+			continue
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == '}' || c == ';' && depth == 0:
+			return strings.TrimSpace(p.input[start:p.pos])
+		}
 		p.consumeChar()
 	}
 	return strings.TrimSpace(p.input[start:p.pos])
@@ -0,0 +1,70 @@
+package parser
+
+import "testing"
+
+func TestNamespaceRules(t *testing.T) {
+	rules := NewCSSParser(`
+p { color: red; }
+@namespace svg url(http://www.w3.org/2000/svg);
+@namespace "http://www.w3.org/1999/xhtml";
+@media print { p { color: blue; } }
+svg|rect { fill: red; }
+`).Parse()
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	if len(rules[0].Namespaces) != 0 {
+		t.Errorf("rule ahead of @namespace sees %v", rules[0].Namespaces)
+	}
+	ns := rules[1].Namespaces
+	if ns["svg"] != "http://www.w3.org/2000/svg" || ns[""] != "http://www.w3.org/1999/xhtml" || len(ns) != 2 {
+		t.Errorf("namespaces = %v", ns)
+	}
+	if got := rules[1].Selectors; len(got) != 1 || got[0] != "svg|rect" {
+		t.Errorf("selectors = %q", got)
+	}
+}
+
+func TestUnterminatedStrings(t *testing.T) {
+	// A string left open runs to the end of the style sheet.
+	for _, css := range []string{
+		`p{font-family:'x}`,
+		`@media "x`,
+		`@namespace svg "http`,
+		`@font-face{src:url("a`,
+		`p{content:"a\`,
+		`"`,
+	} {
+		NewCSSParser(css).Parse()
+	}
+	rules := NewCSSParser(`p { color: red; content: "a; }`).Parse()
+	if len(rules) != 1 || len(rules[0].Declarations) != 2 {
+		t.Fatalf("rules = %+v", rules)
+	}
+	if d := rules[0].Declarations[1]; d.Name != "content" || d.Value != `"a; }` {
+		t.Errorf("declaration %+v", d)
+	}
+}
+
+func TestValuesAtEndOfInput(t *testing.T) {
+	tests := []struct {
+		css, want string
+	}{
+		{`p{color:red`, "red"},
+		{`p{font-family:'x`, `'x`},
+		{`p{font-family:'x}`, `'x}`},
+		{`p{content:"x\`, `"x\`},
+		{`p{src:url("a`, `url("a`},
+		{`p{src:url(a) format("woff`, `url(a) format("woff`},
+	}
+	for _, tt := range tests {
+		rules := NewCSSParser(tt.css).Parse()
+		if len(rules) != 1 || len(rules[0].Declarations) != 1 {
+			t.Errorf("%s: rules = %+v", tt.css, rules)
+			continue
+		}
+		if got := rules[0].Declarations[0].Value; got != tt.want {
+			t.Errorf("%s: value %q, want %q", tt.css, got, tt.want)
+		}
+	}
+}
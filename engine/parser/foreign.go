@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"prymis/engine/dom"
+	"strings"
+)
+
+// Foreign content rules from the HTML parsing algorithm, for inline <svg> and
+// <math>. Names are lowercased by the tokenizer and restored here.
+
+var svgTagNames = caseTable(
+	"altGlyph", "altGlyphDef", "altGlyphItem", "animateColor", "animateMotion",
+	"animateTransform", "clipPath", "feBlend", "feColorMatrix",
+	"feComponentTransfer", "feComposite", "feConvolveMatrix",
+	"feDiffuseLighting", "feDisplacementMap", "feDistantLight", "feDropShadow",
+	"feFlood", "feFuncA", "feFuncB", "feFuncG", "feFuncR", "feGaussianBlur",
+	"feImage", "feMerge", "feMergeNode", "feMorphology", "feOffset",
+	"fePointLight", "feSpecularLighting", "feSpotLight", "feTile",
+	"feTurbulence", "foreignObject", "glyphRef", "linearGradient",
+	"radialGradient", "textPath",
+)
+
+var svgAttributeNames = caseTable(
+	"attributeName", "attributeType", "baseFrequency", "baseProfile",
+	"calcMode", "clipPathUnits", "diffuseConstant", "edgeMode", "filterUnits",
+	"glyphRef", "gradientTransform", "gradientUnits", "kernelMatrix",
+	"kernelUnitLength", "keyPoints", "keySplines", "keyTimes",
+	"lengthAdjust", "limitingConeAngle", "markerHeight", "markerUnits",
+	"markerWidth", "maskContentUnits", "maskUnits", "numOctaves",
+	"pathLength", "patternContentUnits", "patternTransform", "patternUnits",
+	"pointsAtX", "pointsAtY", "pointsAtZ", "preserveAlpha",
+	"preserveAspectRatio", "primitiveUnits", "refX", "refY", "repeatCount",
+	"repeatDur", "requiredExtensions", "requiredFeatures",
+	"specularConstant", "specularExponent", "spreadMethod", "startOffset",
+	"stdDeviation", "stitchTiles", "surfaceScale", "systemLanguage",
+	"tableValues", "targetX", "targetY", "textLength", "viewBox",
+	"viewTarget", "xChannelSelector", "yChannelSelector", "zoomAndPan",
+)
+
+var mathMLAttributeNames = caseTable("definitionURL")
+
+// breakoutTags are HTML start tags that leave foreign content.
+var breakoutTags = setOf(
+	"b", "big", "blockquote", "body", "br", "center", "code", "dd", "div",
+	"dl", "dt", "em", "embed", "h1", "h2", "h3", "h4", "h5", "h6", "head",
+	"hr", "i", "img", "li", "listing", "menu", "meta", "nobr", "ol", "p",
+	"pre", "ruby", "s", "small", "span", "strong", "strike", "sub", "sup",
+	"table", "tt", "u", "ul", "var",
+)
+
+var mathMLTextIntegrationPoints = setOf("mi", "mo", "mn", "ms", "mtext")
+
+var svgHTMLIntegrationPoints = setOf("foreignObject", "desc", "title")
+
+func caseTable(names ...string) map[string]string {
+	table := make(map[string]string, len(names))
+	for _, name := range names {
+		table[strings.ToLower(name)] = name
+	}
+	return table
+}
+
+func setOf(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// openElement is the element whose children are being parsed.
+type openElement struct {
+	namespace string
+	name      string
+	attrs     dom.AttrMap
+}
+
+// namespaceFor returns the namespace of a start tag (lowercased name) that
+// appears inside parent.
+func namespaceFor(parent openElement, tag string) string {
+	switch parent.namespace {
+	case dom.SVGNamespace:
+		if svgHTMLIntegrationPoints[parent.name] {
+			return htmlNamespaceFor(tag)
+		}
+	case dom.MathMLNamespace:
+		if mathMLTextIntegrationPoints[parent.name] && tag != "mglyph" && tag != "malignmark" {
+			return htmlNamespaceFor(tag)
+		}
+		if parent.name == "annotation-xml" {
+			if tag == "svg" {
+				return dom.SVGNamespace
+			}
+			switch strings.ToLower(parent.attrs["encoding"]) {
+			case "text/html", "application/xhtml+xml":
+				return htmlNamespaceFor(tag)
+			}
+		}
+	default:
+		return htmlNamespaceFor(tag)
+	}
+	if breakoutTags[tag] || (tag == "font" && isBreakoutFont(parent.attrs)) {
+		// The full algorithm pops the foreign elements here; this parser
+		// keeps the nesting and only switches the namespace back.
+		return dom.HTMLNamespace
+	}
+	return parent.namespace
+}
+
+func htmlNamespaceFor(tag string) string {
+	switch tag {
+	case "svg":
+		return dom.SVGNamespace
+	case "math":
+		return dom.MathMLNamespace
+	}
+	return dom.HTMLNamespace
+}
+
+func isBreakoutFont(attrs dom.AttrMap) bool {
+	for _, name := range []string{"color", "face", "size"} {
+		if _, ok := attrs[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// adjustTagName restores the mixed case of SVG element names.
+func adjustTagName(namespace, tag string) string {
+	if namespace == dom.SVGNamespace {
+		if adjusted, ok := svgTagNames[tag]; ok {
+			return adjusted
+		}
+	}
+	return tag
+}
+
+// adjustAttributes restores the mixed case of SVG and MathML attribute
+// names. Prefixed attributes such as xlink:href keep their qualified names
+// and are resolved to namespaces by dom.Node.AttributeNamespace.
+func adjustAttributes(namespace string, attrs dom.AttrMap) {
+	table := svgAttributeNames
+	if namespace == dom.MathMLNamespace {
+		table = mathMLAttributeNames
+	} else if namespace != dom.SVGNamespace {
+		return
+	}
+	for name, value := range attrs {
+		if adjusted, ok := table[name]; ok && adjusted != name {
+			delete(attrs, name)
+			attrs[adjusted] = value
+		}
+	}
+}
@@ -6,9 +6,10 @@ import (
 )
 
 type HTMLParser struct {
-	pos   int
-	input string
-	depth int
+	pos    int
+	input  string
+	depth  int
+	parent openElement
 }
 
 func NewHTMLParser(input string) *HTMLParser {
@@ -16,8 +17,15 @@ func NewHTMLParser(input string) *HTMLParser {
 }
 
 func (p *HTMLParser) Parse() *dom.Node {
-	nodes := p.parseNodes()
-	if len(nodes) == 1 {
+	var nodes []*dom.Node
+	for _, n := range p.parseNodes() {
+		// Whitespace around the root element is not part of the document.
+		if n.NodeType == dom.TextNode && strings.TrimSpace(n.Text) == "" {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 && nodes[0].NodeType == dom.ElementNode {
 		return nodes[0]
 	}
 	return dom.Element("html", dom.AttrMap{}, nodes)
@@ -26,41 +34,73 @@ func (p *HTMLParser) Parse() *dom.Node {
 func (p *HTMLParser) parseNodes() []*dom.Node {
 	var nodes []*dom.Node
 	for {
-		p.consumeWhitespace()
+		// Whitespace between tags is kept as text; the inline formatting
+		// context collapses it or drops it where it does not render.
 		if p.eof() || strings.HasPrefix(p.input[p.pos:], "</") || p.depth > 1000 {
 			break
 		}
-		nodes = append(nodes, p.parseNode())
+		if node := p.parseNode(); node != nil {
+			nodes = append(nodes, node)
+		}
 	}
 	return nodes
 }
 
 func (p *HTMLParser) parseNode() *dom.Node {
-	if p.input[p.pos] == '<' {
+	if strings.HasPrefix(p.input[p.pos:], "<!") || strings.HasPrefix(p.input[p.pos:], "<?") {
+		p.skipMarkupDeclaration()
+		return nil
+	}
+	if p.input[p.pos] == '<' && p.pos+1 < len(p.input) && isLetter(p.input[p.pos+1]) {
 		return p.parseElement()
 	}
 	return p.parseText()
 }
 
+// skipMarkupDeclaration drops comments, doctypes and processing instructions.
+func (p *HTMLParser) skipMarkupDeclaration() {
+	end := ">"
+	if strings.HasPrefix(p.input[p.pos:], "<!--") {
+		end = "-->"
+	}
+	if i := strings.Index(p.input[p.pos:], end); i >= 0 {
+		p.pos += i + len(end)
+	} else {
+		p.pos = len(p.input)
+	}
+}
+
 func (p *HTMLParser) parseElement() *dom.Node {
 	p.depth++
 	defer func() { p.depth-- }()
 
 	// Start tag
 	p.consumeChar() // '<'
-	tagName := p.parseTagName()
+	tagName := strings.ToLower(p.parseTagName())
+	namespace := namespaceFor(p.parent, tagName)
+	tagName = adjustTagName(namespace, tagName)
 	attrs := p.parseAttributes()
+	selfClosing := false
+	if !p.eof() && p.input[p.pos] == '/' {
+		p.consumeChar()
+		selfClosing = true
+	}
 	if !p.eof() && p.input[p.pos] == '>' {
 		p.consumeChar()
 	}
+	adjustAttributes(namespace, attrs)
 
-	// Void elements (simplified)
-	if tagName == "img" || tagName == "br" || tagName == "hr" || tagName == "meta" || tagName == "link" || tagName == "input" {
-		return dom.Element(tagName, attrs, nil)
+	// Void elements (simplified); in foreign content any element may be
+	// self-closing.
+	if namespace == dom.HTMLNamespace && isVoidElement(tagName) || namespace != dom.HTMLNamespace && selfClosing {
+		return dom.ElementNS(namespace, tagName, attrs, nil)
 	}
 
 	// Children
+	parent := p.parent
+	p.parent = openElement{namespace: namespace, name: tagName, attrs: attrs}
 	children := p.parseNodes()
+	p.parent = parent
 
 	// End tag
 	if !p.eof() && p.input[p.pos] == '<' {
@@ -74,27 +114,38 @@ func (p *HTMLParser) parseElement() *dom.Node {
 		}
 	}
 
-	return dom.Element(tagName, attrs, children)
+	return dom.ElementNS(namespace, tagName, attrs, children)
+}
+
+func isVoidElement(tagName string) bool {
+	switch tagName {
+	case "area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param", "source", "track", "wbr":
+		return true
+	}
+	return false
 }
 
 func (p *HTMLParser) parseText() *dom.Node {
 	start := p.pos
+	p.consumeChar() // may be a '<' that does not open a tag
 	for !p.eof() && p.input[p.pos] != '<' {
 		p.consumeChar()
 	}
 	return dom.Text(p.input[start:p.pos])
 }
 
+// parseTagName reads a tag or attribute name, which runs until whitespace,
+// '/', '>' or '='.
 func (p *HTMLParser) parseTagName() string {
 	start := p.pos
-	for !p.eof() && isLetterOrDigit(p.input[p.pos]) {
+	for !p.eof() && isNameChar(p.input[p.pos]) {
 		p.consumeChar()
 	}
 	return p.input[start:p.pos]
 }
 
 func (p *HTMLParser) consumeTagName() {
-	for !p.eof() && isLetterOrDigit(p.input[p.pos]) {
+	for !p.eof() && isNameChar(p.input[p.pos]) {
 		p.consumeChar()
 	}
 }
@@ -106,14 +157,29 @@ func (p *HTMLParser) parseAttributes() dom.AttrMap {
 		if p.eof() || p.input[p.pos] == '>' {
 			break
 		}
+		if p.input[p.pos] == '/' {
+			if p.pos+1 < len(p.input) && p.input[p.pos+1] == '>' {
+				break
+			}
+			p.consumeChar()
+			continue
+		}
 		name, value := p.parseAttribute()
-		attrs[name] = value
+		if _, dup := attrs[name]; !dup {
+			attrs[name] = value
+		}
 	}
 	return attrs
 }
 
 func (p *HTMLParser) parseAttribute() (string, string) {
 	name := p.parseTagName()
+	if name == "" {
+		// A stray '=' still has to be consumed to make progress.
+		name = string(p.input[p.pos])
+		p.consumeChar()
+	}
+	name = strings.ToLower(name)
 	p.consumeWhitespace()
 	if !p.eof() && p.input[p.pos] == '=' {
 		p.consumeChar()
@@ -165,6 +231,14 @@ func (p *HTMLParser) eof() bool {
 	return p.pos >= len(p.input)
 }
 
+func isNameChar(c byte) bool {
+	return !isWhitespace(c) && c != '/' && c != '>' && c != '='
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
 func isLetterOrDigit(c byte) bool {
 	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
 }
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"testing"
+
+	"prymis/engine/dom"
+)
+
+func TestForeignContent(t *testing.T) {
+	doc := dom.NewDocument(NewHTMLParser(`<html><body>
+<div id="d">
+<svg id="s" viewbox="0 0 10 10"><lineargradient id="g"></lineargradient>
+<circle id="c" r="1"/><a id="sa" xlink:href="#g"></a>
+<foreignobject id="fo"><p id="fp"></p></foreignobject>
+<g id="sg"><div id="out"></div></g>
+</svg>
+<math id="m" definitionurl="x"><mi id="mi"><b id="mb"></b></mi><mo id="mo"></mo>
+<annotation-xml id="ax" encoding="text/html"><span id="as"></span></annotation-xml></math>
+<p id="after"></p>
+</div></body></html>`).Parse())
+	tests := []struct {
+		id, ns, tag string
+	}{
+		{"d", dom.HTMLNamespace, "div"},
+		{"s", dom.SVGNamespace, "svg"},
+		{"g", dom.SVGNamespace, "linearGradient"},
+		{"c", dom.SVGNamespace, "circle"},
+		{"sa", dom.SVGNamespace, "a"},
+		{"fo", dom.SVGNamespace, "foreignObject"},
+		// Integration points hold HTML again.
+		{"fp", dom.HTMLNamespace, "p"},
+		// So do HTML tags that break out of foreign content.
+		{"out", dom.HTMLNamespace, "div"},
+		{"m", dom.MathMLNamespace, "math"},
+		{"mi", dom.MathMLNamespace, "mi"},
+		{"mb", dom.HTMLNamespace, "b"},
+		{"mo", dom.MathMLNamespace, "mo"},
+		{"as", dom.HTMLNamespace, "span"},
+		{"after", dom.HTMLNamespace, "p"},
+	}
+	for _, tt := range tests {
+		n := doc.GetElementById(tt.id)
+		if n == nil {
+			t.Errorf("no element #%s", tt.id)
+			continue
+		}
+		if n.Namespace != tt.ns || n.TagName != tt.tag {
+			t.Errorf("#%s is %s in %s, want %s in %s", tt.id, n.TagName, n.Namespace, tt.tag, tt.ns)
+		}
+	}
+	// The self-closed circle holds nothing, so the next element is its
+	// sibling.
+	if c := doc.GetElementById("c"); c != nil && len(c.Children) != 0 {
+		t.Errorf("circle has %d children", len(c.Children))
+	}
+	if s := doc.GetElementById("s"); s == nil || s.GetAttribute("viewBox") != "0 0 10 10" {
+		t.Error("svg attribute names keep their lowercase form")
+	}
+	if m := doc.GetElementById("m"); m == nil || !m.HasAttribute("definitionURL") {
+		t.Error("MathML attribute names keep their lowercase form")
+	}
+	if a := doc.GetElementById("sa"); a == nil || a.GetAttributeNS(dom.XLinkNamespace, "href") != "#g" {
+		t.Error("xlink:href is not in the XLink namespace")
+	}
+}
@@ -0,0 +1,278 @@
+package raster
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// BlendMode is how the colors of a layer mix with those beneath it, as in
+// the CSS mix-blend-mode property.
+type BlendMode uint8
+
+const (
+	BlendNormal BlendMode = iota
+	BlendMultiply
+	BlendScreen
+	BlendOverlay
+	BlendDarken
+	BlendLighten
+	BlendColorDodge
+	BlendColorBurn
+	BlendHardLight
+	BlendSoftLight
+	BlendDifference
+	BlendExclusion
+	BlendHue
+	BlendSaturation
+	BlendColor
+	BlendLuminosity
+)
+
+var blendNames = [...]string{
+	BlendNormal:     "normal",
+	BlendMultiply:   "multiply",
+	BlendScreen:     "screen",
+	BlendOverlay:    "overlay",
+	BlendDarken:     "darken",
+	BlendLighten:    "lighten",
+	BlendColorDodge: "color-dodge",
+	BlendColorBurn:  "color-burn",
+	BlendHardLight:  "hard-light",
+	BlendSoftLight:  "soft-light",
+	BlendDifference: "difference",
+	BlendExclusion:  "exclusion",
+	BlendHue:        "hue",
+	BlendSaturation: "saturation",
+	BlendColor:      "color",
+	BlendLuminosity: "luminosity",
+}
+
+func (m BlendMode) String() string {
+	if int(m) < len(blendNames) {
+		return blendNames[m]
+	}
+	return "normal"
+}
+
+// ParseBlendMode returns the blend mode a keyword names.
+func ParseBlendMode(s string) (BlendMode, bool) {
+	for m, name := range blendNames {
+		if name == s {
+			return BlendMode(m), true
+		}
+	}
+	return BlendNormal, false
+}
+
+// DrawLayer composites src onto dst within clip. Every pixel of dst is
+// mapped back through m, which takes src to dst, and src is sampled there
+// bilinearly, transparent beyond its edges; the sample is faded by alpha
+// and blended with mode.
+func DrawLayer(dst *image.RGBA, clip image.Rectangle, src *image.RGBA, m [6]float32, alpha float32, mode BlendMode) {
+	alpha = max(0, min(1, alpha))
+	det := m[0]*m[3] - m[1]*m[2]
+	if alpha == 0 || det == 0 || src.Rect.Empty() {
+		return
+	}
+	// Whole-pixel moves without blending copy the pixels as they are.
+	if m[0] == 1 && m[1] == 0 && m[2] == 0 && m[3] == 1 && m[4] == float32(int(m[4])) && m[5] == float32(int(m[5])) && mode == BlendNormal {
+		d := image.Pt(int(m[4]), int(m[5]))
+		r := src.Rect.Add(d).Intersect(clip).Intersect(dst.Rect)
+		a := uint8(math.Round(float64(alpha) * 255))
+		draw.DrawMask(dst, r, src, r.Min.Sub(d), image.NewUniform(alphaColor(a)), image.Point{}, draw.Over)
+		return
+	}
+	lo, hi := Point{float32(src.Rect.Min.X), float32(src.Rect.Min.Y)}, Point{float32(src.Rect.Max.X), float32(src.Rect.Max.Y)}
+	var bounds Path
+	bounds.AddRect(lo.X, lo.Y, hi.X, hi.Y)
+	blo, bhi := bounds.Transform(m).Bounds()
+	area := image.Rect(int(math.Floor(float64(blo.X))), int(math.Floor(float64(blo.Y))), int(math.Ceil(float64(bhi.X))), int(math.Ceil(float64(bhi.Y))))
+	area = area.Intersect(clip).Intersect(dst.Rect)
+	// The inverse of m.
+	inv := [6]float32{m[3] / det, -m[1] / det, -m[2] / det, m[0] / det, 0, 0}
+	inv[4] = -(inv[0]*m[4] + inv[2]*m[5])
+	inv[5] = -(inv[1]*m[4] + inv[3]*m[5])
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		di := dst.PixOffset(area.Min.X, y)
+		for x := area.Min.X; x < area.Max.X; x, di = x+1, di+4 {
+			fx, fy := float32(x)+0.5, float32(y)+0.5
+			u := inv[0]*fx + inv[2]*fy + inv[4]
+			v := inv[1]*fx + inv[3]*fy + inv[5]
+			s := sample(src, u, v)
+			if s[3] == 0 {
+				continue
+			}
+			for i := range s {
+				s[i] *= alpha
+			}
+			p := dst.Pix[di : di+4 : di+4]
+			b := [4]float32{float32(p[0]) / 255, float32(p[1]) / 255, float32(p[2]) / 255, float32(p[3]) / 255}
+			o := blend(b, s, mode)
+			for i := range o {
+				p[i] = uint8(max(0, min(255, o[i]*255+0.5)))
+			}
+		}
+	}
+}
+
+// alphaColor is a uniform coverage for DrawMask.
+type alphaColor uint8
+
+func (a alphaColor) RGBA() (r, g, b, alpha uint32) {
+	v := uint32(a) * 0x101
+	return v, v, v, v
+}
+
+// sample returns the premultiplied color of src at (u, v), interpolated
+// between the centers of the four pixels around it, in the range 0 to 1.
+func sample(src *image.RGBA, u, v float32) [4]float32 {
+	u, v = u-0.5, v-0.5
+	x0, y0 := int(math.Floor(float64(u))), int(math.Floor(float64(v)))
+	tx, ty := u-float32(x0), v-float32(y0)
+	var out [4]float32
+	for _, c := range [4]struct {
+		x, y int
+		w    float32
+	}{{x0, y0, (1 - tx) * (1 - ty)}, {x0 + 1, y0, tx * (1 - ty)}, {x0, y0 + 1, (1 - tx) * ty}, {x0 + 1, y0 + 1, tx * ty}} {
+		if c.w == 0 || !image.Pt(c.x, c.y).In(src.Rect) {
+			continue
+		}
+		i := src.PixOffset(c.x, c.y)
+		for k := range out {
+			out[k] += float32(src.Pix[i+k]) / 255 * c.w
+		}
+	}
+	return out
+}
+
+// blend composites the premultiplied color s over b with mode, following
+// the W3C compositing model: where both are opaque the result is the mode's
+// mix of their colors, elsewhere each shows through as it is.
+func blend(b, s [4]float32, mode BlendMode) [4]float32 {
+	as, ab := s[3], b[3]
+	out := [4]float32{3: as + ab*(1-as)}
+	if mode == BlendNormal || ab == 0 {
+		for i := 0; i < 3; i++ {
+			out[i] = s[i] + b[i]*(1-as)
+		}
+		return out
+	}
+	var cs, cb [3]float32
+	for i := range cs {
+		cs[i] = s[i] / as
+		cb[i] = b[i] / ab
+	}
+	mixed := mix(cb, cs, mode)
+	for i := 0; i < 3; i++ {
+		out[i] = s[i]*(1-ab) + b[i]*(1-as) + as*ab*mixed[i]
+	}
+	return out
+}
+
+// mix returns the blending function of mode for the backdrop color cb and
+// the source color cs, both unpremultiplied.
+func mix(cb, cs [3]float32, mode BlendMode) [3]float32 {
+	switch mode {
+	case BlendHue:
+		return setLum(setSat(cs, sat(cb)), lum(cb))
+	case BlendSaturation:
+		return setLum(setSat(cb, sat(cs)), lum(cb))
+	case BlendColor:
+		return setLum(cs, lum(cb))
+	case BlendLuminosity:
+		return setLum(cb, lum(cs))
+	}
+	var out [3]float32
+	for i := range out {
+		out[i] = mixChannel(cb[i], cs[i], mode)
+	}
+	return out
+}
+
+// mixChannel is the blending function of a separable mode.
+func mixChannel(cb, cs float32, mode BlendMode) float32 {
+	switch mode {
+	case BlendMultiply:
+		return cb * cs
+	case BlendScreen:
+		return cb + cs - cb*cs
+	case BlendOverlay:
+		return mixChannel(cs, cb, BlendHardLight)
+	case BlendDarken:
+		return min(cb, cs)
+	case BlendLighten:
+		return max(cb, cs)
+	case BlendColorDodge:
+		switch {
+		case cb == 0:
+			return 0
+		case cs >= 1:
+			return 1
+		}
+		return min(1, cb/(1-cs))
+	case BlendColorBurn:
+		switch {
+		case cb >= 1:
+			return 1
+		case cs == 0:
+			return 0
+		}
+		return 1 - min(1, (1-cb)/cs)
+	case BlendHardLight:
+		if cs <= 0.5 {
+			return cb * 2 * cs
+		}
+		return mixChannel(cb, 2*cs-1, BlendScreen)
+	case BlendSoftLight:
+		if cs <= 0.5 {
+			return cb - (1-2*cs)*cb*(1-cb)
+		}
+		d := float32(math.Sqrt(float64(cb)))
+		if cb <= 0.25 {
+			d = ((16*cb-12)*cb + 4) * cb
+		}
+		return cb + (2*cs-1)*(d-cb)
+	case BlendDifference:
+		return float32(math.Abs(float64(cb - cs)))
+	case BlendExclusion:
+		return cb + cs - 2*cb*cs
+	}
+	return cs
+}
+
+func lum(c [3]float32) float32 { return 0.3*c[0] + 0.59*c[1] + 0.11*c[2] }
+
+func sat(c [3]float32) float32 { return max(c[0], c[1], c[2]) - min(c[0], c[1], c[2]) }
+
+// setLum returns c shifted to luminosity l, with channels pulled back into
+// range about it.
+func setLum(c [3]float32, l float32) [3]float32 {
+	d := l - lum(c)
+	c = [3]float32{c[0] + d, c[1] + d, c[2] + d}
+	l = lum(c)
+	lo, hi := min(c[0], c[1], c[2]), max(c[0], c[1], c[2])
+	for i := range c {
+		if lo < 0 {
+			c[i] = l + (c[i]-l)*l/(l-lo)
+		}
+		if hi > 1 {
+			c[i] = l + (c[i]-l)*(1-l)/(hi-l)
+		}
+	}
+	return c
+}
+
+// setSat returns c with its saturation set to s, keeping the order of its
+// channels.
+func setSat(c [3]float32, s float32) [3]float32 {
+	lo, hi := min(c[0], c[1], c[2]), max(c[0], c[1], c[2])
+	var out [3]float32
+	if hi == lo {
+		return out
+	}
+	for i := range c {
+		out[i] = (c[i] - lo) * s / (hi - lo)
+	}
+	return out
+}
@@ -0,0 +1,167 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func near(a, b float32) bool { return math.Abs(float64(a-b)) < 1e-4 }
+
+func TestMixChannel(t *testing.T) {
+	tests := []struct {
+		mode   BlendMode
+		cb, cs float32
+		want   float32
+	}{
+		{BlendNormal, 0.5, 0.25, 0.25},
+		{BlendMultiply, 0.5, 0.25, 0.125},
+		{BlendScreen, 0.5, 0.25, 0.625},
+		{BlendOverlay, 0.5, 0.25, 0.25},
+		{BlendOverlay, 0.75, 0.5, 0.75},
+		{BlendDarken, 0.5, 0.25, 0.25},
+		{BlendLighten, 0.5, 0.25, 0.5},
+		{BlendColorDodge, 0.5, 0.25, 2.0 / 3},
+		{BlendColorDodge, 0.5, 0.75, 1},
+		{BlendColorDodge, 0, 1, 0},
+		{BlendColorDodge, 0.5, 1, 1},
+		{BlendColorBurn, 0.5, 0.25, 0},
+		{BlendColorBurn, 0.75, 0.5, 0.5},
+		{BlendColorBurn, 1, 0, 1},
+		{BlendColorBurn, 0.5, 0, 0},
+		{BlendHardLight, 0.5, 0.25, 0.25},
+		{BlendHardLight, 0.5, 0.75, 0.75},
+		{BlendSoftLight, 0.5, 0.25, 0.375},
+		{BlendSoftLight, 0.25, 0.75, 0.375},
+		{BlendSoftLight, 0.64, 0.75, 0.72},
+		{BlendDifference, 0.25, 0.75, 0.5},
+		{BlendExclusion, 0.5, 0.25, 0.5},
+	}
+	for _, tt := range tests {
+		if got := mixChannel(tt.cb, tt.cs, tt.mode); !near(got, tt.want) {
+			t.Errorf("%s(%v, %v) = %v, want %v", tt.mode, tt.cb, tt.cs, got, tt.want)
+		}
+	}
+}
+
+func TestMixNonSeparable(t *testing.T) {
+	colors := [][3]float32{{0.5, 0.5, 0.5}, {1, 0, 0}, {0.2, 0.7, 0.1}, {0, 0, 1}, {0.9, 0.8, 0.95}}
+	for _, cb := range colors {
+		for _, cs := range colors {
+			for _, tt := range []struct {
+				mode BlendMode
+				// lum is the luminosity the result must have.
+				lum float32
+			}{
+				{BlendHue, lum(cb)},
+				{BlendSaturation, lum(cb)},
+				{BlendColor, lum(cb)},
+				{BlendLuminosity, lum(cs)},
+			} {
+				got := mix(cb, cs, tt.mode)
+				for _, c := range got {
+					if c < -1e-4 || c > 1+1e-4 {
+						t.Errorf("%s(%v, %v) = %v is out of range", tt.mode, cb, cs, got)
+					}
+				}
+				if !near(lum(got), tt.lum) {
+					t.Errorf("%s(%v, %v) = %v has luminosity %v, want %v", tt.mode, cb, cs, got, lum(got), tt.lum)
+				}
+			}
+		}
+	}
+	// A gray backdrop has no saturation to give, and a gray source no hue.
+	if got := mix([3]float32{0.5, 0.5, 0.5}, [3]float32{1, 0, 0}, BlendSaturation); got != [3]float32{0.5, 0.5, 0.5} {
+		t.Errorf("saturation of red over gray = %v", got)
+	}
+	if got := mix([3]float32{1, 0, 0}, [3]float32{0.5, 0.5, 0.5}, BlendHue); !near(got[0], got[1]) || !near(got[1], got[2]) {
+		t.Errorf("hue of gray over red = %v, want a gray", got)
+	}
+}
+
+func TestBlend(t *testing.T) {
+	tests := []struct {
+		name string
+		b, s [4]float32
+		mode BlendMode
+		want [4]float32
+	}{
+		{"normal", [4]float32{0, 0, 0.5, 0.5}, [4]float32{0.5, 0, 0, 0.5}, BlendNormal, [4]float32{0.5, 0, 0.25, 0.75}},
+		{"multiply opaque", [4]float32{0.5, 0.5, 0.5, 1}, [4]float32{1, 0.5, 0, 1}, BlendMultiply, [4]float32{0.5, 0.25, 0, 1}},
+		{"multiply onto nothing", [4]float32{}, [4]float32{0.5, 0.25, 0, 0.5}, BlendMultiply, [4]float32{0.5, 0.25, 0, 0.5}},
+		// Half of the source shows as it is, half multiplied.
+		{"multiply translucent", [4]float32{1, 1, 1, 1}, [4]float32{0.5, 0, 0, 0.5}, BlendMultiply, [4]float32{1, 0.5, 0.5, 1}},
+		{"screen translucent backdrop", [4]float32{0.25, 0, 0, 0.5}, [4]float32{0, 0, 1, 1}, BlendScreen, [4]float32{0.25, 0, 1, 1}},
+	}
+	for _, tt := range tests {
+		got := blend(tt.b, tt.s, tt.mode)
+		for i := range got {
+			if !near(got[i], tt.want[i]) {
+				t.Errorf("%s: blend = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseBlendMode(t *testing.T) {
+	for m := BlendNormal; m <= BlendLuminosity; m++ {
+		if got, ok := ParseBlendMode(m.String()); !ok || got != m {
+			t.Errorf("ParseBlendMode(%q) = %v, %v", m.String(), got, ok)
+		}
+	}
+	if _, ok := ParseBlendMode("plus-lighter"); ok {
+		t.Error("ParseBlendMode accepted plus-lighter")
+	}
+}
+
+func TestDrawLayer(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	src.SetRGBA(1, 0, color.RGBA{0, 128, 0, 128})
+	src.SetRGBA(1, 1, color.RGBA{0, 0, 255, 255})
+	draw := func(clip image.Rectangle, m [6]float32, alpha float32, mode BlendMode) *image.RGBA {
+		dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		DrawLayer(dst, clip, src, m, alpha, mode)
+		return dst
+	}
+	all := image.Rect(0, 0, 4, 4)
+	moved := [6]float32{1, 0, 0, 1, 1, 2}
+
+	// Whole-pixel moves copy the pixels; sampling at pixel centers, as
+	// other modes do, gives the same.
+	copied := draw(all, moved, 1, BlendNormal)
+	if got := copied.RGBAAt(1, 2); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("moved layer has %v at (1, 2)", got)
+	}
+	if got := copied.RGBAAt(2, 3); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("moved layer has %v at (2, 3)", got)
+	}
+	if sampled := draw(all, moved, 1, BlendMultiply); string(sampled.Pix) != string(copied.Pix) {
+		t.Error("multiplying onto nothing differs from copying")
+	}
+	faded := draw(all, moved, 0.5, BlendNormal)
+	if got := faded.RGBAAt(1, 2); got != (color.RGBA{128, 0, 0, 128}) {
+		t.Errorf("half-faded layer has %v at (1, 2)", got)
+	}
+	if sampled := draw(all, moved, 0.5, BlendScreen); string(sampled.Pix) != string(faded.Pix) {
+		t.Error("screening onto nothing differs from copying, faded")
+	}
+
+	clipped := draw(image.Rect(0, 0, 2, 4), moved, 1, BlendNormal)
+	if got := clipped.RGBAAt(2, 3); got.A != 0 {
+		t.Errorf("clipped layer has %v at (2, 3)", got)
+	}
+	// A layer scaled to nothing draws nothing.
+	if flat := draw(all, [6]float32{1, 0, 2, 0, 0, 0}, 1, BlendNormal); string(flat.Pix) != string(image.NewRGBA(all).Pix) {
+		t.Error("degenerate layer drew")
+	}
+	// Scaled up, the center of (3, 3) falls at (1.75, 1.75) in the layer,
+	// a quarter pixel from the blue one's center towards the transparent
+	// outside along either axis, so that 9/16 of it shows.
+	scaled := draw(all, [6]float32{2, 0, 0, 2, 0, 0}, 1, BlendNormal)
+	if got := scaled.RGBAAt(3, 3); got != (color.RGBA{0, 0, 143, 143}) {
+		t.Errorf("scaled layer has %v at (3, 3)", got)
+	}
+}
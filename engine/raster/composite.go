@@ -0,0 +1,59 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+)
+
+// Over composites c through mask onto dst with the Porter-Duff source-over
+// operator: every pixel becomes src×α + dst×(1 − src alpha×α), where α is
+// the coverage of the mask there and src the premultiplied color.
+func Over(dst *image.RGBA, mask *image.Alpha, c color.Color) {
+	if mask == nil || c == nil {
+		return
+	}
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return
+	}
+	rect := mask.Rect.Intersect(dst.Rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		mi := mask.PixOffset(rect.Min.X, y)
+		di := dst.PixOffset(rect.Min.X, y)
+		for x := rect.Min.X; x < rect.Max.X; x, mi, di = x+1, mi+1, di+4 {
+			m := uint32(mask.Pix[mi])
+			if m == 0 {
+				continue
+			}
+			// Channels are 16 bit; m*0x101 scales the coverage likewise.
+			m *= 0x101
+			k := 0xffff - a*m/0xffff
+			p := dst.Pix[di : di+4 : di+4]
+			p[0] = uint8((r*m/0xffff + uint32(p[0])*0x101*k/0xffff) >> 8)
+			p[1] = uint8((g*m/0xffff + uint32(p[1])*0x101*k/0xffff) >> 8)
+			p[2] = uint8((b*m/0xffff + uint32(p[2])*0x101*k/0xffff) >> 8)
+			p[3] = uint8((a*m/0xffff + uint32(p[3])*0x101*k/0xffff) >> 8)
+		}
+	}
+}
+
+// Intersect returns the coverage of both a and b: their product over the
+// pixels they share. It is nil when they share none.
+func Intersect(a, b *image.Alpha) *image.Alpha {
+	if a == nil || b == nil {
+		return nil
+	}
+	rect := a.Rect.Intersect(b.Rect)
+	if rect.Empty() {
+		return nil
+	}
+	out := image.NewAlpha(rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		ai, bi, oi := a.PixOffset(rect.Min.X, y), b.PixOffset(rect.Min.X, y), out.PixOffset(rect.Min.X, y)
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			out.Pix[oi] = uint8((uint32(a.Pix[ai])*uint32(b.Pix[bi]) + 127) / 255)
+			ai, bi, oi = ai+1, bi+1, oi+1
+		}
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestOver(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	tests := []struct {
+		name     string
+		dst      color.RGBA
+		c        color.Color
+		coverage uint8
+		want     color.RGBA
+	}{
+		{"opaque", white, color.RGBA{255, 0, 0, 255}, 255, color.RGBA{255, 0, 0, 255}},
+		{"half covered", white, color.RGBA{255, 0, 0, 255}, 128, color.RGBA{255, 127, 127, 255}},
+		{"uncovered", white, color.RGBA{255, 0, 0, 255}, 0, white},
+		{"translucent on clear", color.RGBA{}, color.NRGBA{0, 0, 255, 128}, 255, color.RGBA{0, 0, 128, 128}},
+		{"translucent on white", white, color.NRGBA{0, 0, 255, 128}, 255, color.RGBA{127, 127, 255, 255}},
+		{"transparent", white, color.NRGBA{255, 0, 0, 0}, 255, white},
+		{"onto translucent", color.RGBA{0, 0, 128, 128}, color.NRGBA{255, 0, 0, 128}, 255, color.RGBA{128, 0, 63, 192}},
+	}
+	for _, tt := range tests {
+		dst := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		dst.SetRGBA(0, 0, tt.dst)
+		mask := image.NewAlpha(dst.Rect)
+		mask.Pix[0] = tt.coverage
+		Over(dst, mask, tt.c)
+		if got := dst.RGBAAt(0, 0); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestOverStaysPremultiplied checks that no color channel ends up above
+// alpha, nor alpha off from the source-over sum, whatever the inputs.
+func TestOverStaysPremultiplied(t *testing.T) {
+	levels := []uint8{0, 1, 64, 127, 128, 200, 254, 255}
+	dst := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	mask := image.NewAlpha(dst.Rect)
+	for _, da := range levels {
+		for _, sa := range levels {
+			for _, m := range levels {
+				dst.Pix[0], dst.Pix[1], dst.Pix[2], dst.Pix[3] = da, da/2, 0, da
+				mask.Pix[0] = m
+				Over(dst, mask, color.NRGBA{255, 255, 0, sa})
+				p := dst.RGBAAt(0, 0)
+				if p.R > p.A || p.G > p.A || p.B > p.A {
+					t.Fatalf("dst alpha %d, src alpha %d, coverage %d: %v is not premultiplied", da, sa, m, p)
+				}
+				s := float64(sa) / 255 * float64(m) / 255
+				want := 255 * (s + float64(da)/255*(1-s))
+				if d := float64(p.A) - want; d < -1.5 || d > 1.5 {
+					t.Fatalf("dst alpha %d, src alpha %d, coverage %d: alpha %d, want %.1f", da, sa, m, p.A, want)
+				}
+			}
+		}
+	}
+}
+
+func TestOverClipsToDst(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	mask := image.NewAlpha(image.Rect(2, 2, 8, 8))
+	for i := range mask.Pix {
+		mask.Pix[i] = 255
+	}
+	Over(dst, mask, color.Black)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := uint8(0)
+			if x >= 2 && y >= 2 {
+				want = 255
+			}
+			if got := dst.RGBAAt(x, y).A; got != want {
+				t.Errorf("(%d, %d) alpha %d, want %d", x, y, got, want)
+			}
+		}
+	}
+	// Nothing to draw leaves dst alone.
+	before := string(dst.Pix)
+	Over(dst, nil, color.Black)
+	Over(dst, mask, nil)
+	if string(dst.Pix) != before {
+		t.Error("Over without a mask or color changed dst")
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	a := image.NewAlpha(image.Rect(0, 0, 3, 1))
+	copy(a.Pix, []uint8{255, 128, 128})
+	b := image.NewAlpha(image.Rect(1, 0, 5, 1))
+	copy(b.Pix, []uint8{255, 128, 0, 9})
+	got := Intersect(a, b)
+	if got == nil || got.Rect != image.Rect(1, 0, 3, 1) {
+		t.Fatalf("Intersect covers %v, want (1,0)-(3,1)", got)
+	}
+	if want := []uint8{128, 64}; string(got.Pix) != string(want) {
+		t.Errorf("Intersect = %v, want %v", got.Pix, want)
+	}
+	if got := Intersect(a, image.NewAlpha(image.Rect(3, 0, 4, 1))); got != nil {
+		t.Errorf("disjoint masks intersect in %v", got.Rect)
+	}
+	if Intersect(nil, b) != nil || Intersect(a, nil) != nil {
+		t.Error("Intersect with nil is not nil")
+	}
+}
@@ -0,0 +1,155 @@
+package raster
+
+import (
+	"image"
+	"math"
+)
+
+// FillRule decides which points a path with overlapping contours encloses.
+type FillRule uint8
+
+const (
+	// NonZero fills points the contours wind around at all.
+	NonZero FillRule = iota
+	// EvenOdd fills points the contours wind around an odd number of times.
+	EvenOdd
+)
+
+// Fill returns the coverage of the inside of p under rule within clip, a
+// mask whose bounds are the pixels p touches there. It is nil when there
+// are none. Open contours are closed implicitly.
+func (p *Path) Fill(rule FillRule, clip image.Rectangle) *image.Alpha {
+	lo, hi := p.Bounds()
+	b := image.Rect(int(math.Floor(float64(lo.X))), int(math.Floor(float64(lo.Y))),
+		int(math.Ceil(float64(hi.X))), int(math.Ceil(float64(hi.Y)))).Intersect(clip)
+	if b.Empty() {
+		return nil
+	}
+	r := newRasterizer(b)
+	for _, c := range p.flatten() {
+		for i := 1; i < len(c.pts); i++ {
+			r.line(c.pts[i-1], c.pts[i])
+		}
+		r.line(c.pts[len(c.pts)-1], c.pts[0])
+	}
+	return r.mask(rule)
+}
+
+// rasterizer computes anti-aliased coverage of closed paths by accumulating,
+// for every pixel, the signed area that edges cover to its left; a running
+// sum along each row then gives the winding-weighted coverage, which the
+// fill rule maps to alpha.
+type rasterizer struct {
+	bounds       image.Rectangle
+	w, h, stride int
+	acc          []float32
+}
+
+func newRasterizer(b image.Rectangle) *rasterizer {
+	w, h := b.Dx(), b.Dy()
+	// Two spare columns absorb the area of edges on the right border.
+	return &rasterizer{bounds: b, w: w, h: h, stride: w + 2, acc: make([]float32, (w+2)*h)}
+}
+
+// line accumulates the area of one edge. Edges left of the bounds add their
+// area to the first column, so that the running sums stay right.
+func (r *rasterizer) line(p0, p1 Point) {
+	ox, oy := float32(r.bounds.Min.X), float32(r.bounds.Min.Y)
+	p0 = Point{p0.X - ox, p0.Y - oy}
+	p1 = Point{p1.X - ox, p1.Y - oy}
+	if p0.Y == p1.Y {
+		return
+	}
+	dir := float32(1)
+	if p0.Y > p1.Y {
+		dir = -1
+		p0, p1 = p1, p0
+	}
+	dxdy := (p1.X - p0.X) / (p1.Y - p0.Y)
+	x := p0.X
+	if p0.Y < 0 {
+		x -= p0.Y * dxdy
+	}
+	yEnd := min(r.h, int(math.Ceil(float64(p1.Y))))
+	for y := max(0, int(p0.Y)); y < yEnd; y++ {
+		row := r.acc[y*r.stride : (y+1)*r.stride]
+		dy := min(float32(y+1), p1.Y) - max(float32(y), p0.Y)
+		xNext := x + dxdy*dy
+		d := dy * dir
+		x0, x1 := x, xNext
+		if x1 < x0 {
+			x0, x1 = x1, x0
+		}
+		x0 = max(0, x0)
+		x1 = max(0, x1)
+		x0floor := float32(math.Floor(float64(x0)))
+		x0i := int(x0floor)
+		x1ceil := float32(math.Ceil(float64(x1)))
+		x1i := int(x1ceil)
+		if x1i > r.w+1 {
+			x1i = r.w + 1
+		}
+		if x0i > r.w {
+			x0i = r.w
+		}
+		if x1i <= x0i+1 {
+			// The edge stays within one pixel of this row.
+			xmf := (x0+x1)/2 - x0floor
+			row[x0i] += d - d*xmf
+			if x0i+1 < len(row) {
+				row[x0i+1] += d * xmf
+			}
+		} else {
+			s := 1 / (x1 - x0)
+			x0f := x0 - x0floor
+			a0 := s * (1 - x0f) * (1 - x0f) / 2
+			x1f := x1 - x1ceil + 1
+			am := s * x1f * x1f / 2
+			row[x0i] += d * a0
+			if x1i == x0i+2 {
+				row[x0i+1] += d * (1 - a0 - am)
+			} else {
+				a1 := s * (1.5 - x0f)
+				row[x0i+1] += d * (a1 - a0)
+				for xi := x0i + 2; xi < x1i-1; xi++ {
+					row[xi] += d * s
+				}
+				a2 := a1 + float32(x1i-x0i-3)*s
+				row[x1i-1] += d * (1 - a2 - am)
+			}
+			if x1i < len(row) {
+				row[x1i] += d * am
+			}
+		}
+		x = xNext
+	}
+}
+
+// mask converts the accumulated area into an alpha mask. Under the nonzero
+// rule coverage saturates; under the even-odd rule every second full turn of
+// winding cancels out.
+func (r *rasterizer) mask(rule FillRule) *image.Alpha {
+	m := image.NewAlpha(r.bounds)
+	for y := 0; y < r.h; y++ {
+		var sum float32
+		row := r.acc[y*r.stride:]
+		pix := m.Pix[y*m.Stride:]
+		for x := 0; x < r.w; x++ {
+			sum += row[x]
+			a := sum
+			if a < 0 {
+				a = -a
+			}
+			if rule == EvenOdd {
+				a -= 2 * float32(math.Floor(float64(a/2)))
+				if a > 1 {
+					a = 2 - a
+				}
+			} else if a > 1 {
+				a = 1
+			}
+			pix[x] = uint8(a*255 + 0.5)
+		}
+	}
+	return m
+}
@@ -0,0 +1,433 @@
+// Package raster draws vector paths. Paths are filled by accumulating, for
+// every pixel, the signed area their edges cover, which gives exact
+// anti-aliased coverage under the nonzero or even-odd rule; strokes are
+// turned into outlines that are filled in turn. The coverage masks are
+// composited onto images with the Porter-Duff source-over operator.
+package raster
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errBadPath = errors.New("raster: malformed path data")
+
+// Point is a position in the coordinates of a path, y down.
+type Point struct{ X, Y float32 }
+
+type verb uint8
+
+const (
+	verbMove verb = iota
+	verbLine
+	verbQuad
+	verbCubic
+	verbClose
+)
+
+// Path is a sequence of contours made of lines and quadratic and cubic
+// Bézier curves. The zero value is an empty path.
+type Path struct {
+	verbs  []verb
+	points []Point
+
+	// start is the first point of the current contour, cur the pen.
+	start, cur Point
+}
+
+// MoveTo starts a new contour at (x, y).
+func (p *Path) MoveTo(x, y float32) {
+	p.verbs = append(p.verbs, verbMove)
+	p.points = append(p.points, Point{x, y})
+	p.start, p.cur = Point{x, y}, Point{x, y}
+}
+
+// begin starts a contour at the pen if none is open, so that drawing
+// commands need not follow a MoveTo.
+func (p *Path) begin() {
+	if len(p.verbs) == 0 || p.verbs[len(p.verbs)-1] == verbClose {
+		p.MoveTo(p.cur.X, p.cur.Y)
+	}
+}
+
+// LineTo adds a line from the pen to (x, y).
+func (p *Path) LineTo(x, y float32) {
+	p.begin()
+	p.verbs = append(p.verbs, verbLine)
+	p.points = append(p.points, Point{x, y})
+	p.cur = Point{x, y}
+}
+
+// QuadTo adds a quadratic Bézier curve from the pen to (x, y) with the
+// control point (x1, y1).
+func (p *Path) QuadTo(x1, y1, x, y float32) {
+	p.begin()
+	p.verbs = append(p.verbs, verbQuad)
+	p.points = append(p.points, Point{x1, y1}, Point{x, y})
+	p.cur = Point{x, y}
+}
+
+// CubicTo adds a cubic Bézier curve from the pen to (x, y) with the control
+// points (x1, y1) and (x2, y2).
+func (p *Path) CubicTo(x1, y1, x2, y2, x, y float32) {
+	p.begin()
+	p.verbs = append(p.verbs, verbCubic)
+	p.points = append(p.points, Point{x1, y1}, Point{x2, y2}, Point{x, y})
+	p.cur = Point{x, y}
+}
+
+// Close closes the current contour with a line back to its start.
+func (p *Path) Close() {
+	if len(p.verbs) == 0 || p.verbs[len(p.verbs)-1] == verbClose {
+		return
+	}
+	p.verbs = append(p.verbs, verbClose)
+	p.cur = p.start
+}
+
+// Current returns the pen position.
+func (p *Path) Current() Point { return p.cur }
+
+// Empty reports whether p has no contours.
+func (p *Path) Empty() bool { return len(p.verbs) == 0 }
+
+// ArcTo adds an elliptical arc from the pen to (x, y) as SVG path data
+// describes it: radii rx and ry, the x axis rotated by rotation degrees, and
+// the flags choosing one of the four arcs through both points. Radii too
+// small to reach are scaled up; zero radii make a line.
+func (p *Path) ArcTo(rx, ry, rotation float32, large, sweep bool, x, y float32) {
+	// Endpoint to center parameterization, SVG 1.1 appendix F.6.5.
+	x0, y0 := float64(p.cur.X), float64(p.cur.Y)
+	x1, y1 := float64(x), float64(y)
+	if x0 == x1 && y0 == y1 {
+		return
+	}
+	frx, fry := math.Abs(float64(rx)), math.Abs(float64(ry))
+	if frx == 0 || fry == 0 {
+		p.LineTo(x, y)
+		return
+	}
+	phi := float64(rotation) * math.Pi / 180
+	sin, cos := math.Sincos(phi)
+	dx, dy := (x0-x1)/2, (y0-y1)/2
+	px, py := cos*dx+sin*dy, -sin*dx+cos*dy
+	if l := px*px/(frx*frx) + py*py/(fry*fry); l > 1 {
+		frx, fry = frx*math.Sqrt(l), fry*math.Sqrt(l)
+	}
+	num := frx*frx*fry*fry - frx*frx*py*py - fry*fry*px*px
+	den := frx*frx*py*py + fry*fry*px*px
+	k := math.Sqrt(max(0, num/den))
+	if large == sweep {
+		k = -k
+	}
+	cxp, cyp := k*frx*py/fry, -k*fry*px/frx
+	cx := cos*cxp - sin*cyp + (x0+x1)/2
+	cy := sin*cxp + cos*cyp + (y0+y1)/2
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angle(1, 0, (px-cxp)/frx, (py-cyp)/fry)
+	delta := angle((px-cxp)/frx, (py-cyp)/fry, (-px-cxp)/frx, (-py-cyp)/fry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+	p.arc(cx, cy, frx, fry, phi, theta, delta)
+	p.cur = Point{x, y}
+}
+
+// arc adds the arc of the ellipse centered on (cx, cy) from angle theta
+// through delta radians, as cubic curves of at most a quarter turn each. The
+// pen is assumed to be at the start of the arc.
+func (p *Path) arc(cx, cy, rx, ry, phi, theta, delta float64) {
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	if n == 0 {
+		return
+	}
+	step := delta / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	sinPhi, cosPhi := math.Sincos(phi)
+	at := func(ux, uy float64) (float32, float32) {
+		ux, uy = ux*rx, uy*ry
+		return float32(cx + cosPhi*ux - sinPhi*uy), float32(cy + sinPhi*ux + cosPhi*uy)
+	}
+	for i := 0; i < n; i++ {
+		a := theta + float64(i)*step
+		b := a + step
+		sa, ca := math.Sincos(a)
+		sb, cb := math.Sincos(b)
+		x1, y1 := at(ca-k*sa, sa+k*ca)
+		x2, y2 := at(cb+k*sb, sb-k*cb)
+		x, y := at(cb, sb)
+		p.CubicTo(x1, y1, x2, y2, x, y)
+	}
+}
+
+// AddRect adds the rectangle from (x0, y0) to (x1, y1) as a closed contour.
+func (p *Path) AddRect(x0, y0, x1, y1 float32) {
+	p.MoveTo(x0, y0)
+	p.LineTo(x1, y0)
+	p.LineTo(x1, y1)
+	p.LineTo(x0, y1)
+	p.Close()
+}
+
+// AddRoundedRect adds the rectangle from (x0, y0) to (x1, y1) with elliptical
+// corners of the given horizontal and vertical radii, clockwise from the top
+// left, as a closed contour wound the same way as AddRect.
+func (p *Path) AddRoundedRect(x0, y0, x1, y1 float32, radii [4][2]float32) {
+	if radii == [4][2]float32{} {
+		p.AddRect(x0, y0, x1, y1)
+		return
+	}
+	// corner draws the corner at (cx, cy) from the angle theta, the center
+	// of its ellipse lying sx and sy radii away.
+	corner := func(i int, cx, cy, sx, sy float32, theta float64) {
+		rx, ry := radii[i][0], radii[i][1]
+		if rx <= 0 || ry <= 0 {
+			p.LineTo(cx, cy)
+			return
+		}
+		ecx, ecy := cx+sx*rx, cy+sy*ry
+		sin, cos := math.Sincos(theta)
+		p.LineTo(ecx+rx*float32(cos), ecy+ry*float32(sin))
+		p.arc(float64(ecx), float64(ecy), float64(rx), float64(ry), 0, theta, math.Pi/2)
+	}
+	p.MoveTo(x0+max(0, radii[0][0]), y0)
+	corner(1, x1, y0, -1, 1, -math.Pi/2)
+	corner(2, x1, y1, -1, -1, 0)
+	corner(3, x0, y1, 1, -1, math.Pi/2)
+	corner(0, x0, y0, 1, 1, math.Pi)
+	p.Close()
+}
+
+// AddEllipse adds the ellipse centered on (cx, cy) with radii rx and ry as a
+// closed contour wound the same way as AddRect.
+func (p *Path) AddEllipse(cx, cy, rx, ry float32) {
+	if rx <= 0 || ry <= 0 {
+		return
+	}
+	p.MoveTo(cx+rx, cy)
+	p.arc(float64(cx), float64(cy), float64(rx), float64(ry), 0, 0, 2*math.Pi)
+	p.Close()
+}
+
+// AddPolygon adds the closed contour through pts.
+func (p *Path) AddPolygon(pts ...Point) {
+	if len(pts) == 0 {
+		return
+	}
+	p.MoveTo(pts[0].X, pts[0].Y)
+	for _, pt := range pts[1:] {
+		p.LineTo(pt.X, pt.Y)
+	}
+	p.Close()
+}
+
+// Append adds the contours of q to p.
+func (p *Path) Append(q *Path) {
+	if q == nil || q.Empty() {
+		return
+	}
+	p.verbs = append(p.verbs, q.verbs...)
+	p.points = append(p.points, q.points...)
+	p.start, p.cur = q.start, q.cur
+}
+
+// Transform returns p mapped through the affine transform m = [a b c d e f],
+// which maps (x, y) to (a*x + c*y + e, b*x + d*y + f).
+func (p *Path) Transform(m [6]float32) *Path {
+	apply := func(pt Point) Point {
+		return Point{m[0]*pt.X + m[2]*pt.Y + m[4], m[1]*pt.X + m[3]*pt.Y + m[5]}
+	}
+	q := &Path{verbs: p.verbs, points: make([]Point, len(p.points)), start: apply(p.start), cur: apply(p.cur)}
+	for i, pt := range p.points {
+		q.points[i] = apply(pt)
+	}
+	return q
+}
+
+// Bounds returns the corners of the smallest rectangle holding the points of
+// p, control points included. Both are zero for an empty path.
+func (p *Path) Bounds() (lo, hi Point) {
+	if len(p.points) == 0 {
+		return Point{}, Point{}
+	}
+	lo, hi = p.points[0], p.points[0]
+	for _, pt := range p.points[1:] {
+		lo.X, lo.Y = min(lo.X, pt.X), min(lo.Y, pt.Y)
+		hi.X, hi.Y = max(hi.X, pt.X), max(hi.Y, pt.Y)
+	}
+	return lo, hi
+}
+
+// Equal reports whether p and q have the same contours.
+func (p *Path) Equal(q *Path) bool {
+	if p == nil || q == nil {
+		return p == q
+	}
+	if len(p.verbs) != len(q.verbs) || len(p.points) != len(q.points) {
+		return false
+	}
+	for i := range p.verbs {
+		if p.verbs[i] != q.verbs[i] {
+			return false
+		}
+	}
+	for i := range p.points {
+		if p.points[i] != q.points[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pointsPer is the number of points each verb takes.
+var pointsPer = [...]int{verbMove: 1, verbLine: 1, verbQuad: 2, verbCubic: 3, verbClose: 0}
+
+// MarshalBinary encodes p as the number of verbs, the verbs and then the
+// coordinates of the points, little-endian.
+func (p *Path) MarshalBinary() ([]byte, error) {
+	b := binary.AppendUvarint(nil, uint64(len(p.verbs)))
+	for _, v := range p.verbs {
+		b = append(b, byte(v))
+	}
+	for _, pt := range p.points {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(pt.X))
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(pt.Y))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a path encoded by MarshalBinary into p.
+func (p *Path) UnmarshalBinary(b []byte) error {
+	n, k := binary.Uvarint(b)
+	if k <= 0 || n > uint64(len(b)-k) {
+		return errBadPath
+	}
+	b = b[k:]
+	verbs := make([]verb, n)
+	count := 0
+	for i := range verbs {
+		if b[i] > byte(verbClose) {
+			return errBadPath
+		}
+		verbs[i] = verb(b[i])
+		count += pointsPer[verbs[i]]
+	}
+	b = b[n:]
+	if len(b) != 8*count {
+		return errBadPath
+	}
+	*p = Path{}
+	for _, v := range verbs {
+		pts := make([]float32, 2*pointsPer[v])
+		for i := range pts {
+			pts[i] = math.Float32frombits(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		}
+		switch v {
+		case verbMove:
+			p.MoveTo(pts[0], pts[1])
+		case verbLine:
+			p.LineTo(pts[0], pts[1])
+		case verbQuad:
+			p.QuadTo(pts[0], pts[1], pts[2], pts[3])
+		case verbCubic:
+			p.CubicTo(pts[0], pts[1], pts[2], pts[3], pts[4], pts[5])
+		case verbClose:
+			p.Close()
+		}
+	}
+	if len(p.verbs) != len(verbs) {
+		// Only the verbs the drawing methods produce decode to themselves.
+		return errBadPath
+	}
+	return nil
+}
+
+// contour is a path contour flattened to a polyline.
+type contour struct {
+	pts    []Point
+	closed bool
+}
+
+// flatness is the squared distance a curve may deviate from its chords, in
+// the units of the path.
+const flatness = 0.1
+
+// flatten approximates the curves of p with lines.
+func (p *Path) flatten() []contour {
+	var out []contour
+	var c contour
+	// A contour of a lone move draws nothing; one of a single point that
+	// was closed or drawn to is zero-length, which caps still draw.
+	finish := func() {
+		if len(c.pts) > 1 || c.closed {
+			out = append(out, c)
+		}
+		c = contour{}
+	}
+	var start Point
+	i := 0
+	for _, v := range p.verbs {
+		var cur Point
+		if n := len(c.pts); n > 0 {
+			cur = c.pts[n-1]
+		}
+		switch v {
+		case verbMove:
+			finish()
+			start = p.points[i]
+			c.pts = append(c.pts, start)
+			i++
+		case verbLine:
+			c.pts = append(c.pts, p.points[i])
+			i++
+		case verbQuad:
+			p0, p1, p2 := cur, p.points[i], p.points[i+1]
+			dx, dy := p0.X-2*p1.X+p2.X, p0.Y-2*p1.Y+p2.Y
+			n := segmentsFor(dx*dx + dy*dy)
+			for j := 1; j <= n; j++ {
+				t := float32(j) / float32(n)
+				mt := 1 - t
+				c.pts = append(c.pts, Point{
+					mt*mt*p0.X + 2*mt*t*p1.X + t*t*p2.X,
+					mt*mt*p0.Y + 2*mt*t*p1.Y + t*t*p2.Y,
+				})
+			}
+			i += 2
+		case verbCubic:
+			p0, p1, p2, p3 := cur, p.points[i], p.points[i+1], p.points[i+2]
+			dx1, dy1 := p0.X-2*p1.X+p2.X, p0.Y-2*p1.Y+p2.Y
+			dx2, dy2 := p1.X-2*p2.X+p3.X, p1.Y-2*p2.Y+p3.Y
+			n := segmentsFor(max(dx1*dx1+dy1*dy1, dx2*dx2+dy2*dy2) * 9 / 16)
+			for j := 1; j <= n; j++ {
+				t := float32(j) / float32(n)
+				mt := 1 - t
+				a, b, c3, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+				c.pts = append(c.pts, Point{
+					a*p0.X + b*p1.X + c3*p2.X + d*p3.X,
+					a*p0.Y + b*p1.Y + c3*p2.Y + d*p3.Y,
+				})
+			}
+			i += 3
+		case verbClose:
+			c.closed = true
+			finish()
+			// Drawing after a close continues from the contour's start.
+			c.pts = []Point{start}
+		}
+	}
+	finish()
+	return out
+}
+
+// segmentsFor returns how many chords approximate a curve whose second
+// difference has squared length devsq within flatness.
+func segmentsFor(devsq float32) int {
+	n := int(math.Ceil(math.Sqrt(math.Sqrt(float64(devsq) / (16 * flatness * flatness)))))
+	return max(1, min(n, 100))
+}
@@ -0,0 +1,403 @@
+package raster
+
+import (
+	"image"
+	"math"
+)
+
+// Join is the shape of the corners where a stroke turns.
+type Join uint8
+
+const (
+	MiterJoin Join = iota
+	RoundJoin
+	BevelJoin
+)
+
+// Cap is the shape of the ends of an open stroke.
+type Cap uint8
+
+const (
+	ButtCap Cap = iota
+	RoundCap
+	SquareCap
+)
+
+// StrokeStyle describes how a path is outlined.
+type StrokeStyle struct {
+	Width float32
+	Join  Join
+	Cap   Cap
+	// MiterLimit is the ratio of a miter's length to the width beyond which
+	// it is beveled instead; zero means 4.
+	MiterLimit float32
+	// Dashes alternates the lengths of dashes and gaps, starting DashOffset
+	// into the pattern; without any the stroke is solid.
+	Dashes     []float32
+	DashOffset float32
+	// Clip, when not empty, is the only area the outline will be filled in:
+	// dashes wholly outside it may be left out.
+	Clip image.Rectangle
+}
+
+// maxDashes bounds the dashes of one stroke; a pattern that would need
+// more, such as one far finer than a pixel, is stroked solid.
+const maxDashes = 1 << 17
+
+// Stroke returns the outline of p drawn with s, to be filled with the
+// nonzero rule. Segments, joins and caps are separate contours all wound the
+// same way, so that where they overlap coverage saturates and never cancels.
+func (p *Path) Stroke(s StrokeStyle) *Path {
+	st := stroker{out: &Path{}, hw: s.Width / 2, style: s, limit: s.MiterLimit}
+	if st.hw <= 0 {
+		return st.out
+	}
+	if st.limit <= 0 {
+		st.limit = 4
+	}
+	dashed := false
+	for _, d := range s.Dashes {
+		if d < 0 {
+			dashed = false
+			break
+		}
+		if d > 0 {
+			dashed = true
+		}
+	}
+	contours := p.flatten()
+	if dashed {
+		// Joins and caps reach at most this far from the path.
+		reach := float64(st.hw) * math.Max(float64(st.limit), 1.5)
+		var pieces []dashPiece
+		n := 0
+		for _, c := range contours {
+			d, ok := dash(dedupe(c.pts, c.closed), c.closed, s.Dashes, s.DashOffset, s.Clip, reach, maxDashes-n)
+			if !ok {
+				dashed = false
+				break
+			}
+			pieces = append(pieces, d...)
+			n += len(d)
+		}
+		if dashed {
+			for _, d := range pieces {
+				st.polyline(d.pts, false, d.dir)
+			}
+			return st.out
+		}
+	}
+	for _, c := range contours {
+		st.polyline(dedupe(c.pts, c.closed), c.closed, Point{1, 0})
+	}
+	return st.out
+}
+
+// dedupe drops the points of a polyline that repeat the one before, and the
+// last point of a closed one if it repeats the first.
+func dedupe(pts []Point, closed bool) []Point {
+	out := make([]Point, 0, len(pts))
+	for _, p := range pts {
+		if len(out) == 0 || p != out[len(out)-1] {
+			out = append(out, p)
+		}
+	}
+	if closed && len(out) > 1 && out[0] == out[len(out)-1] {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+type stroker struct {
+	out   *Path
+	hw    float32
+	style StrokeStyle
+	limit float32
+}
+
+// polyline outlines one polyline. A single point is a zero-length stroke,
+// drawn as a cap facing dir.
+func (st *stroker) polyline(pts []Point, closed bool, dir Point) {
+	pts = dedupe(pts, closed)
+	switch len(pts) {
+	case 0:
+		return
+	case 1:
+		switch st.style.Cap {
+		case RoundCap:
+			st.out.AddEllipse(pts[0].X, pts[0].Y, st.hw, st.hw)
+		case SquareCap:
+			n := scale(normal(dir), st.hw)
+			d := scale(dir, st.hw)
+			p := pts[0]
+			st.polygon(add(sub(p, d), n), add(add(p, d), n), sub(add(p, d), n), sub(sub(p, d), n))
+		}
+		return
+	}
+	n := len(pts)
+	segments := n - 1
+	if closed {
+		segments = n
+	}
+	for i := 0; i < segments; i++ {
+		a, b := pts[i], pts[(i+1)%n]
+		nv := scale(normal(unit(sub(b, a))), st.hw)
+		st.polygon(add(a, nv), add(b, nv), sub(b, nv), sub(a, nv))
+	}
+	for i := 1; i < n-1; i++ {
+		st.join(pts[i-1], pts[i], pts[i+1])
+	}
+	if closed {
+		st.join(pts[n-1], pts[0], pts[1])
+		if n > 2 {
+			st.join(pts[n-2], pts[n-1], pts[0])
+		}
+		return
+	}
+	st.cap(pts[0], unit(sub(pts[0], pts[1])))
+	st.cap(pts[n-1], unit(sub(pts[n-1], pts[n-2])))
+}
+
+// join fills the gap on the outside of the turn at v between the segments
+// from a and to b.
+func (st *stroker) join(a, v, b Point) {
+	d0, d1 := unit(sub(v, a)), unit(sub(b, v))
+	cross := d0.X*d1.Y - d0.Y*d1.X
+	dot := d0.X*d1.X + d0.Y*d1.Y
+	if cross == 0 && dot > 0 {
+		return
+	}
+	// The outside of the turn lies against the normals for turns towards
+	// them.
+	side := float32(-1)
+	if cross < 0 {
+		side = 1
+	}
+	n0, n1 := normal(d0), normal(d1)
+	p0, p1 := add(v, scale(n0, side*st.hw)), add(v, scale(n1, side*st.hw))
+	switch st.style.Join {
+	case RoundJoin:
+		st.pie(v, p0, p1, d0)
+		return
+	case MiterJoin:
+		// The miter's length relative to the width is 1/sin(θ/2) for the
+		// angle θ between the segments.
+		if 1+dot > 0 && 2/(1+dot) <= st.limit*st.limit {
+			tip := add(v, scale(add(n0, n1), side*st.hw/(1+dot)))
+			st.polygon(v, p0, tip, p1)
+			return
+		}
+	}
+	st.polygon(v, p0, p1)
+}
+
+// cap adds the cap at the end e of a stroke leaving in direction d.
+func (st *stroker) cap(e, d Point) {
+	n := scale(normal(d), st.hw)
+	switch st.style.Cap {
+	case RoundCap:
+		st.pie(e, add(e, n), sub(e, n), d)
+	case SquareCap:
+		ext := scale(d, st.hw)
+		st.polygon(add(e, n), add(add(e, n), ext), add(sub(e, n), ext), sub(e, n))
+	}
+}
+
+// pie adds the circular sector around c from p0 to p1, turning the short
+// way, or for half turns through the direction toward.
+func (st *stroker) pie(c, p0, p1, toward Point) {
+	a0 := math.Atan2(float64(p0.Y-c.Y), float64(p0.X-c.X))
+	a1 := math.Atan2(float64(p1.Y-c.Y), float64(p1.X-c.X))
+	delta := a1 - a0
+	for delta > math.Pi {
+		delta -= 2 * math.Pi
+	}
+	for delta < -math.Pi {
+		delta += 2 * math.Pi
+	}
+	if math.Abs(delta) > math.Pi-1e-6 {
+		sin, cos := math.Sincos(a0 + math.Pi/2)
+		delta = -math.Pi
+		if float32(cos)*toward.X+float32(sin)*toward.Y > 0 {
+			delta = math.Pi
+		}
+	}
+	// Chords deviate from the arc by at most a quarter pixel.
+	r := float64(st.hw)
+	step := math.Pi / 2
+	if r > 0.25 {
+		step = 2 * math.Acos(1-0.25/r)
+	}
+	n := max(1, min(100, int(math.Ceil(math.Abs(delta)/step))))
+	pts := make([]Point, 0, n+2)
+	pts = append(pts, c)
+	for i := 0; i <= n; i++ {
+		sin, cos := math.Sincos(a0 + delta*float64(i)/float64(n))
+		pts = append(pts, Point{c.X + float32(cos*r), c.Y + float32(sin*r)})
+	}
+	st.polygon(pts...)
+}
+
+// polygon adds a closed contour through pts wound the way AddRect winds.
+func (st *stroker) polygon(pts ...Point) {
+	var area float32
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		area += p.X*q.Y - q.X*p.Y
+	}
+	if area < 0 {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	st.out.AddPolygon(pts...)
+}
+
+// dashPiece is one dash of a dashed polyline, with the direction of the
+// segment it ends on for dashes of zero length.
+type dashPiece struct {
+	pts []Point
+	dir Point
+}
+
+// dash cuts a polyline into the dashes of pattern, starting offset into it.
+// Dashes further than reach from clip, when it is not empty, are skipped a
+// pattern at a time. It reports false if that takes more than max steps.
+func dash(pts []Point, closed bool, pattern []float32, offset float32, clip image.Rectangle, reach float64, max int) ([]dashPiece, bool) {
+	if len(pts) == 0 {
+		return nil, true
+	}
+	if len(pattern)%2 == 1 {
+		pattern = append(pattern[:len(pattern):len(pattern)], pattern...)
+	}
+	var total float64
+	for _, d := range pattern {
+		total += float64(d)
+	}
+	if closed {
+		pts = append(pts[:len(pts):len(pts)], pts[0])
+	}
+	i := 0
+	left := float64(pattern[0])
+	off := math.Mod(float64(offset), total)
+	if off < 0 {
+		off += total
+	}
+	for off > 0 {
+		if off < left {
+			left -= off
+			break
+		}
+		off -= left
+		i = (i + 1) % len(pattern)
+		left = float64(pattern[i])
+	}
+	var out []dashPiece
+	var cur []Point
+	if i%2 == 0 {
+		cur = []Point{pts[0]}
+	}
+	dir := Point{1, 0}
+	if len(pts) > 1 {
+		dir = unit(sub(pts[1], pts[0]))
+	}
+	steps := 0
+	for k := 1; k < len(pts); k++ {
+		a, b := pts[k-1], pts[k]
+		seg := float64(length(sub(b, a)))
+		dir = unit(sub(b, a))
+		enter, exit := 0.0, seg
+		if !clip.Empty() {
+			enter, exit = clipSpan(a, dir, seg, clip, reach)
+		}
+		var pos float64
+		for left <= seg-pos {
+			// Skip whole patterns that lie out of sight, before the segment
+			// enters the clip or after it leaves.
+			target := seg
+			if pos < enter {
+				target = enter
+			} else if pos <= exit {
+				target = pos
+			}
+			if skip := math.Floor((target - pos - left) / total); skip > 0 {
+				// A dash coming from earlier segments ends out of sight here.
+				if i%2 == 0 {
+					if len(cur) > 1 {
+						out = append(out, dashPiece{append(cur, at(a, dir, pos)), dir})
+					}
+					cur = []Point{at(a, dir, pos+skip*total)}
+				}
+				pos += skip * total
+			}
+			if steps++; steps > max {
+				return nil, false
+			}
+			pos += left
+			p := at(a, dir, pos)
+			if i%2 == 0 {
+				out = append(out, dashPiece{append(cur, p), dir})
+				cur = nil
+			} else {
+				cur = []Point{p}
+			}
+			i = (i + 1) % len(pattern)
+			left = float64(pattern[i])
+		}
+		left -= seg - pos
+		if i%2 == 0 {
+			cur = append(cur, b)
+		}
+	}
+	if i%2 == 0 && len(cur) > 1 {
+		out = append(out, dashPiece{cur, dir})
+	}
+	return out, true
+}
+
+// at returns the point d along the unit direction dir from a.
+func at(a, dir Point, d float64) Point {
+	return Point{a.X + float32(float64(dir.X)*d), a.Y + float32(float64(dir.Y)*d)}
+}
+
+// clipSpan returns the distances along the segment of length seg from a in
+// direction dir between which it is within reach of clip. When it never is,
+// both are seg.
+func clipSpan(a, dir Point, seg float64, clip image.Rectangle, reach float64) (enter, exit float64) {
+	enter, exit = 0, seg
+	lo := [2]float64{float64(clip.Min.X) - reach, float64(clip.Min.Y) - reach}
+	hi := [2]float64{float64(clip.Max.X) + reach, float64(clip.Max.Y) + reach}
+	o := [2]float64{float64(a.X), float64(a.Y)}
+	d := [2]float64{float64(dir.X), float64(dir.Y)}
+	for j := range 2 {
+		if d[j] == 0 {
+			if o[j] < lo[j] || o[j] > hi[j] {
+				return seg, seg
+			}
+			continue
+		}
+		t0, t1 := (lo[j]-o[j])/d[j], (hi[j]-o[j])/d[j]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		enter, exit = math.Max(enter, t0), math.Min(exit, t1)
+	}
+	if enter > exit {
+		return seg, seg
+	}
+	return enter, exit
+}
+
+func add(p, q Point) Point           { return Point{p.X + q.X, p.Y + q.Y} }
+func sub(p, q Point) Point           { return Point{p.X - q.X, p.Y - q.Y} }
+func scale(p Point, k float32) Point { return Point{p.X * k, p.Y * k} }
+func normal(d Point) Point           { return Point{-d.Y, d.X} }
+func length(p Point) float32         { return float32(math.Hypot(float64(p.X), float64(p.Y))) }
+
+func unit(p Point) Point {
+	l := length(p)
+	if l == 0 {
+		return Point{1, 0}
+	}
+	return Point{p.X / l, p.Y / l}
+}
@@ -0,0 +1,333 @@
+package render
+
+import (
+	"image/color"
+	"math"
+
+	"prymis/engine/layout"
+	"prymis/engine/raster"
+)
+
+// roundedRect is a rectangle with elliptical corners.
+type roundedRect struct {
+	x0, y0, x1, y1 float32
+	radii          Radii
+}
+
+// inset returns s shrunk by the given widths of its sides, top, right,
+// bottom and left, with the corner radii reduced to match.
+func (s roundedRect) inset(w [4]float32) roundedRect {
+	in := roundedRect{x0: s.x0 + w[3], y0: s.y0 + w[0], x1: s.x1 - w[1], y1: s.y1 - w[2]}
+	// The sides each corner lies between: horizontal radius, vertical one.
+	adjacent := [4][2]int{{3, 0}, {1, 0}, {1, 2}, {3, 2}}
+	for i, c := range s.radii {
+		rx, ry := max(0, c[0]-w[adjacent[i][0]]), max(0, c[1]-w[adjacent[i][1]])
+		if rx > 0 && ry > 0 {
+			in.radii[i] = [2]float32{rx, ry}
+		}
+	}
+	return in
+}
+
+// addTo adds s to p as a closed contour, unless it is empty.
+func (s roundedRect) addTo(p *raster.Path) {
+	if s.x1 > s.x0 && s.y1 > s.y0 {
+		p.AddRoundedRect(s.x0, s.y0, s.x1, s.y1, s.radii)
+	}
+}
+
+// FillRoundedRect fills the rounded rectangle with anti-aliased corners.
+func (r *Raster) FillRoundedRect(rect layout.Rect, radii Radii, c color.Color) {
+	if c == nil {
+		return
+	}
+	if radii.IsZero() {
+		r.FillRect(rect, c)
+		return
+	}
+	var p raster.Path
+	roundedRect{rect.X, rect.Y, rect.X + rect.Width, rect.Y + rect.Height, radii}.addTo(&p)
+	r.FillPath(&p, raster.NonZero, c)
+}
+
+// borderBand is a ring of a border between two fractions of its widths,
+// painted in one color on some of its sides. Sides sharing a band are
+// filled together, so that no seam shows where they meet.
+type borderBand struct {
+	from, to float32
+	color    color.NRGBA
+	sides    [4]bool
+}
+
+// StrokeBorder paints a border inside the edges of rect. Each side owns the
+// part of the border between the lines joining the outer corners to the
+// inner ones, and is drawn in its style; corners follow the radii.
+func (r *Raster) StrokeBorder(rect layout.Rect, b Border) {
+	s := borderShape{outer: roundedRect{rect.X, rect.Y, rect.X + rect.Width, rect.Y + rect.Height, b.Radii}, widths: b.Widths}
+	vis, ok := r.visible()
+	if !ok {
+		return
+	}
+	var bands []borderBand
+	for side, w := range b.Widths {
+		if w <= 0 || b.Colors[side] == nil {
+			continue
+		}
+		c := color.NRGBAModel.Convert(b.Colors[side]).(color.NRGBA)
+		// lit marks the top and left sides, which inset borders darken.
+		lit := side == 0 || side == 3
+		var parts []borderBand
+		switch b.Styles[side] {
+		case "none", "hidden":
+		case "dashed", "dotted":
+			r.strokeDashes(s, side, b.Styles[side], c, vis)
+		case "double":
+			parts = []borderBand{{from: 0, to: 1.0 / 3, color: c}, {from: 2.0 / 3, to: 1, color: c}}
+		case "inset":
+			parts = []borderBand{{from: 0, to: 1, color: shade(c, lit)}}
+		case "outset":
+			parts = []borderBand{{from: 0, to: 1, color: shade(c, !lit)}}
+		case "groove":
+			parts = []borderBand{{from: 0, to: 0.5, color: shade(c, lit)}, {from: 0.5, to: 1, color: shade(c, !lit)}}
+		case "ridge":
+			parts = []borderBand{{from: 0, to: 0.5, color: shade(c, !lit)}, {from: 0.5, to: 1, color: shade(c, lit)}}
+		default:
+			parts = []borderBand{{from: 0, to: 1, color: c}}
+		}
+	next:
+		for _, part := range parts {
+			for i := range bands {
+				if bands[i].from == part.from && bands[i].to == part.to && bands[i].color == part.color {
+					bands[i].sides[side] = true
+					continue next
+				}
+			}
+			part.sides[side] = true
+			bands = append(bands, part)
+		}
+	}
+	for _, band := range bands {
+		var regions raster.Path
+		for side, on := range band.sides {
+			if on {
+				regions.AddPolygon(s.region(side, vis)...)
+			}
+		}
+		m := raster.Intersect(r.mask(&regions, raster.NonZero), r.mask(s.ring(band.from, band.to), raster.EvenOdd))
+		raster.Over(r.dst, m, band.color)
+	}
+}
+
+// maxBorderDashes bounds the dashes or dots strokeDashes draws on a side;
+// beyond it the side is painted solid.
+const maxBorderDashes = 1 << 16
+
+// strokeDashes paints the part of a dashed or dotted side within vis:
+// dashes three widths long with gaps as long, or round dots a width across
+// and apart, counted clockwise from the side's outer corner.
+func (r *Raster) strokeDashes(s borderShape, side int, style string, c color.NRGBA, vis layout.Rect) {
+	w := s.widths[side]
+	o := s.outer
+	extent := o.x1 - o.x0
+	if side == 1 || side == 3 {
+		extent = o.y1 - o.y0
+	}
+	// at maps a position along the side and a depth inwards from its outer
+	// edge to a point.
+	at := func(along, depth float32) raster.Point {
+		switch side {
+		case 1:
+			return raster.Point{X: o.x1 - depth, Y: o.y0 + along}
+		case 2:
+			return raster.Point{X: o.x1 - along, Y: o.y1 - depth}
+		case 3:
+			return raster.Point{X: o.x0 + depth, Y: o.y1 - along}
+		}
+		return raster.Point{X: o.x0 + along, Y: o.y0 + depth}
+	}
+	// Dash k covers [k*period, k*period+length] along the side. Only the
+	// dashes reaching into vis are drawn, so that a side far longer
+	// than the page costs no more than its visible part; counting them by
+	// index keeps the positions exact however far along they are.
+	period, length := 6*float64(w), 3*float64(w)
+	if style == "dotted" {
+		period, length = 2*float64(w), float64(w)
+	}
+	lo, hi := visibleSpan(o, side, vis)
+	from := math.Max(math.Floor((lo-length)/period), 0)
+	to := math.Ceil(math.Min(hi, float64(extent)) / period)
+	if to < from {
+		return
+	}
+	if !(to-from <= maxBorderDashes) {
+		// So many dashes in view are far finer than a pixel.
+		var region raster.Path
+		region.AddPolygon(s.region(side, vis)...)
+		raster.Over(r.dst, raster.Intersect(r.mask(&region, raster.NonZero), r.mask(s.ring(0, 1), raster.EvenOdd)), c)
+		return
+	}
+	n := int(to - from)
+	var p raster.Path
+	if style == "dotted" {
+		for i := 0; i <= n; i++ {
+			along := float32((from+float64(i))*period) + w/2
+			if along >= extent {
+				break
+			}
+			pt := at(along, w/2)
+			p.AddEllipse(pt.X, pt.Y, w/2, w/2)
+		}
+		var region raster.Path
+		region.AddPolygon(s.region(side, vis)...)
+		raster.Over(r.dst, raster.Intersect(r.mask(&p, raster.NonZero), r.mask(&region, raster.NonZero)), c)
+		return
+	}
+	// Dashes run across the whole box and are cut to the side's region,
+	// then to the ring.
+	depth := (o.x1 - o.x0) + (o.y1 - o.y0)
+	for i := 0; i <= n; i++ {
+		along := float32((from + float64(i)) * period)
+		if along >= extent {
+			break
+		}
+		p.AddPolygon(s.clip(side, []raster.Point{at(along, -1), at(along+3*w, -1), at(along+3*w, depth), at(along, depth)})...)
+	}
+	raster.Over(r.dst, raster.Intersect(r.mask(&p, raster.NonZero), r.mask(s.ring(0, 1), raster.EvenOdd)), c)
+}
+
+// visible returns the area of user space that the clip shows, with a pixel
+// to spare for anti-aliased edges. ok is false when nothing can show, or
+// the transform is too degenerate to tell what does.
+func (r *Raster) visible() (v layout.Rect, ok bool) {
+	inv, ok := r.m.Invert()
+	if !ok || r.clip.Empty() {
+		return layout.Rect{}, false
+	}
+	c := r.clip
+	v = inv.Bounds(layout.Rect{X: float32(c.Min.X - 1), Y: float32(c.Min.Y - 1), Width: float32(c.Dx() + 2), Height: float32(c.Dy() + 2)})
+	for _, f := range []float32{v.X, v.Y, v.Width, v.Height} {
+		if f != f {
+			return layout.Rect{}, false
+		}
+	}
+	return v, true
+}
+
+// visibleSpan returns the stretch along a side of the rounded rectangle o,
+// measured clockwise from its outer corner as strokeDashes does, that falls
+// within v.
+func visibleSpan(o roundedRect, side int, v layout.Rect) (lo, hi float64) {
+	x0, y0, x1, y1 := float64(v.X), float64(v.Y), float64(v.X+v.Width), float64(v.Y+v.Height)
+	switch side {
+	case 1:
+		return y0 - float64(o.y0), y1 - float64(o.y0)
+	case 2:
+		return float64(o.x1) - x1, float64(o.x1) - x0
+	case 3:
+		return float64(o.y1) - y1, float64(o.y1) - y0
+	}
+	return x0 - float64(o.x0), x1 - float64(o.x0)
+}
+
+// borderShape is the outer edge of a border and the widths of its sides.
+type borderShape struct {
+	outer  roundedRect
+	widths [4]float32
+}
+
+// ring returns the band of the border between the fractions from and to of
+// its widths, to be filled with the even-odd rule.
+func (s borderShape) ring(from, to float32) *raster.Path {
+	var p raster.Path
+	frac := func(f float32) [4]float32 {
+		w := s.widths
+		return [4]float32{w[0] * f, w[1] * f, w[2] * f, w[3] * f}
+	}
+	s.outer.inset(frac(from)).addTo(&p)
+	s.outer.inset(frac(to)).addTo(&p)
+	return &p
+}
+
+// depth returns how far p lies inwards of the outer edge of a side.
+func (s borderShape) depth(side int, p raster.Point) float32 {
+	switch side {
+	case 1:
+		return s.outer.x1 - p.X
+	case 2:
+		return s.outer.y1 - p.Y
+	case 3:
+		return p.X - s.outer.x0
+	}
+	return p.Y - s.outer.y0
+}
+
+// region returns the polygon holding the part of the border a side owns:
+// the points it has gone the smallest fraction of the way through, which
+// splits the corners along the lines from the outer to the inner corners.
+// It reaches a pixel beyond the outer edge, so that only the ring
+// anti-aliases that, and is limited to vis, the visible area: cutting a
+// huge box at its corners would lose them to rounding.
+func (s borderShape) region(side int, vis layout.Rect) []raster.Point {
+	o := s.outer
+	x0, y0 := max(o.x0-1, vis.X), max(o.y0-1, vis.Y)
+	x1, y1 := min(o.x1+1, vis.X+vis.Width), min(o.y1+1, vis.Y+vis.Height)
+	if x0 >= x1 || y0 >= y1 {
+		return nil
+	}
+	return s.clip(side, []raster.Point{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}})
+}
+
+// clip cuts the polygon pts to the region of a side.
+func (s borderShape) clip(side int, pts []raster.Point) []raster.Point {
+	w := s.widths[side]
+	for other, wo := range s.widths {
+		if other == side || wo <= 0 {
+			continue
+		}
+		pts = clipPolygon(pts, func(p raster.Point) float32 {
+			return s.depth(side, p)*wo - s.depth(other, p)*w
+		})
+	}
+	// Nothing of the side lies deeper than its width or the corners it
+	// rounds.
+	r := s.outer.radii
+	reach := w
+	switch side {
+	case 0:
+		reach = max(reach, r[0][1], r[1][1])
+	case 1:
+		reach = max(reach, r[1][0], r[2][0])
+	case 2:
+		reach = max(reach, r[2][1], r[3][1])
+	case 3:
+		reach = max(reach, r[3][0], r[0][0])
+	}
+	return clipPolygon(pts, func(p raster.Point) float32 { return s.depth(side, p) - reach - 1 })
+}
+
+// clipPolygon returns the part of the convex polygon pts where f, a linear
+// function, is not positive.
+func clipPolygon(pts []raster.Point, f func(raster.Point) float32) []raster.Point {
+	var out []raster.Point
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		fp, fq := f(p), f(q)
+		if fp <= 0 {
+			out = append(out, p)
+		}
+		if (fp < 0 && fq > 0) || (fp > 0 && fq < 0) {
+			t := fp / (fp - fq)
+			out = append(out, raster.Point{X: p.X + (q.X-p.X)*t, Y: p.Y + (q.Y-p.Y)*t})
+		}
+	}
+	return out
+}
+
+// shade returns c lightened, or darkened when dark is set, for the sides of
+// the 3D border styles.
+func shade(c color.NRGBA, dark bool) color.NRGBA {
+	if dark {
+		return color.NRGBA{c.R / 2, c.G / 2, c.B / 2, c.A}
+	}
+	return color.NRGBA{c.R + (255-c.R)/2, c.G + (255-c.G)/2, c.B + (255-c.B)/2, c.A}
+}
@@ -0,0 +1,141 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"prymis/engine/layout"
+	"prymis/engine/raster"
+)
+
+func uniformBorder(style string, w float32) Border {
+	b := Border{Widths: [4]float32{w, w, w, w}}
+	for i := range b.Styles {
+		b.Styles[i] = style
+		b.Colors[i] = color.Black
+	}
+	return b
+}
+
+// strokeWithin strokes a border, failing the test if it takes longer than
+// a second.
+func strokeWithin(t *testing.T, r *Raster, rect layout.Rect, b Border) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		r.StrokeBorder(rect, b)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("stroking a %v border of %v did not finish", b.Styles[0], rect)
+	}
+}
+
+func TestStrokeBorderHugeSides(t *testing.T) {
+	tests := []struct {
+		name  string
+		style string
+		size  float32
+		m     Matrix
+	}{
+		{"solid", "solid", 1e9, Identity},
+		{"dashed", "dashed", 1e9, Identity},
+		{"dotted", "dotted", 1e9, Identity},
+		{"dashed beyond float32 steps", "dashed", 1e12, Identity},
+		{"scrolled far along", "dashed", 1e9, Translate(-5e8, -5e8)},
+		{"scaled", "dotted", 1e9, Matrix{4, 0, 0, 4, 0, 0}},
+		{"rotated", "dashed", 1e9, Matrix{0, 1, -1, 0, 200, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRaster(image.NewRGBA(image.Rect(0, 0, 200, 100)))
+			r.m = tt.m
+			strokeWithin(t, r, layout.Rect{Width: tt.size, Height: tt.size}, uniformBorder(tt.style, 4))
+		})
+	}
+}
+
+// TestStrokeBorderHugeBox checks that culling to the clip leaves what is
+// visible where it is: the top side of a huge box looks like that of a
+// small one.
+func TestStrokeBorderHugeBox(t *testing.T) {
+	for _, style := range []string{"solid", "double", "dashed", "dotted"} {
+		top := func(size float32) []byte {
+			img := image.NewRGBA(image.Rect(0, 0, 200, 40))
+			strokeWithin(t, NewRaster(img), layout.Rect{Width: size, Height: size}, uniformBorder(style, 4))
+			// Rows of the top side, clear of the left corner.
+			var rows []byte
+			for y := 0; y < 4; y++ {
+				rows = append(rows, img.Pix[img.PixOffset(20, y):img.PixOffset(200, y)]...)
+			}
+			return rows
+		}
+		small, huge := top(300), top(1e9)
+		if !bytes.Equal(small, huge) {
+			t.Errorf("%s: top side of a huge box differs from that of a small one", style)
+		}
+		if bytes.Count(small, []byte{0}) == len(small) {
+			t.Errorf("%s: nothing painted", style)
+		}
+	}
+}
+
+func TestStrokePathHugeDashes(t *testing.T) {
+	line := func(length float32) *raster.Path {
+		var p raster.Path
+		p.MoveTo(-length/2, 20)
+		p.LineTo(length/2, 20)
+		return &p
+	}
+	tests := []struct {
+		name   string
+		length float32
+		dashes []float32
+	}{
+		{"long", 1e9, []float32{4, 2}},
+		{"beyond float32 steps", 1e30, []float32{4, 2}},
+		{"finer than a pixel", 200, []float32{1e-6, 1e-6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan struct{})
+			go func() {
+				r := NewRaster(image.NewRGBA(image.Rect(0, 0, 200, 40)))
+				r.StrokePath(line(tt.length), raster.StrokeStyle{Width: 2, Dashes: tt.dashes}, color.Black)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("stroking did not finish")
+			}
+		})
+	}
+
+	// Dashes left out of sight do not move those in it.
+	draw := func(length float32) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, 200, 40))
+		r := NewRaster(img)
+		r.m = Translate(100, 0)
+		r.StrokePath(line(length), raster.StrokeStyle{Width: 2, Dashes: []float32{4, 2}, DashOffset: length / 2}, color.Black)
+		return img.Pix
+	}
+	if small, huge := draw(600), draw(6e6); !bytes.Equal(small, huge) {
+		t.Error("a huge dashed line looks different from a small one where both show")
+	}
+}
+
+func TestStrokeBorderDegenerateTransform(t *testing.T) {
+	for _, m := range []Matrix{{1e-30, 0, 0, 1e-30, 0, 0}, {1e30, 0, 0, 1e30, 0, 0}, {1e30, 1e30, 1e30, 1e30, 0, 0}} {
+		for _, style := range []string{"dashed", "dotted"} {
+			r := NewRaster(image.NewRGBA(image.Rect(0, 0, 200, 100)))
+			r.m = m
+			strokeWithin(t, r, layout.Rect{X: 9e21, Y: 200, Width: 30, Height: 30}, uniformBorder(style, 4))
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"prymis/engine/raster"
+	"prymis/engine/text"
+)
+
+// ChromeHeight is the height of the browser chrome above the page.
+const ChromeHeight = 100
+
+// ContentViewport returns the part of a window with the given bounds that
+// shows the page.
+func ContentViewport(bounds image.Rectangle) image.Rectangle {
+	r := bounds
+	r.Min.Y = min(r.Max.Y, r.Min.Y+ChromeHeight)
+	return r
+}
+
+// PaintChrome paints the browser chrome of a window filling dst, with url in
+// the address bar, and returns the rectangle left for the page.
+func PaintChrome(dst *image.RGBA, url string) image.Rectangle {
+	b := dst.Bounds()
+	x, y := b.Min.X, b.Min.Y
+
+	// Window frame (dark theme)
+	frameColor := color.RGBA{33, 37, 43, 255}
+	draw.Draw(dst, image.Rect(b.Min.X, b.Min.Y, b.Max.X, min(b.Max.Y, y+ChromeHeight)), &image.Uniform{frameColor}, image.Point{}, draw.Src)
+
+	// Window controls (red, yellow, green circles)
+	drawCircle(dst, x+20, y+20, 6, color.RGBA{255, 95, 87, 255})
+	drawCircle(dst, x+40, y+20, 6, color.RGBA{255, 189, 46, 255})
+	drawCircle(dst, x+60, y+20, 6, color.RGBA{39, 201, 63, 255})
+
+	// Window title
+	drawLabel(dst, x+84, y+25, "Prymis", text.FontSpec{Families: []string{"sans-serif"}, Size: 13, Weight: 700}, color.RGBA{200, 200, 200, 255})
+
+	// Address bar, between the controls and the logo
+	addressBarRect := image.Rect(x+100, y+50, max(x+200, b.Max.X-100), y+85)
+	drawField(dst, addressBarRect, color.RGBA{30, 33, 39, 255}, color.RGBA{100, 100, 100, 255})
+	bar := dst.SubImage(addressBarRect.Inset(1)).(*image.RGBA)
+	drawLabel(bar, x+112, y+72, url, uiFont, color.RGBA{180, 180, 180, 255})
+
+	// Prymis logo (stylized 'P')
+	drawLogo(dst, b.Max.X-50, y+25)
+
+	return ContentViewport(b)
+}
+
+// drawLogo draws the Prymis logo, a stylized 'P', with its bowl centered
+// at (x+5, y-5).
+func drawLogo(canvas *image.RGBA, x, y int) {
+	c := color.RGBA{100, 150, 255, 255}
+	fx, fy := float32(x), float32(y)
+	var p raster.Path
+	// The stem, and the bowl as a ring: a disc with a hole cut by the
+	// even-odd rule.
+	p.AddRect(fx-2, fy-10, fx+2, fy+15)
+	raster.Over(canvas, p.Fill(raster.NonZero, canvas.Bounds()), c)
+	p = raster.Path{}
+	p.AddEllipse(fx+5, fy-5, 7.5, 7.5)
+	p.AddEllipse(fx+5, fy-5, 4, 4)
+	raster.Over(canvas, p.Fill(raster.EvenOdd, canvas.Bounds()), c)
+}
+
+// uiFont is the font of the browser chrome.
+var uiFont = text.FontSpec{Families: []string{"sans-serif"}, Size: 13}
+
+// drawLabel draws a line of chrome text with its baseline at y.
+func drawLabel(canvas *image.RGBA, x, y int, s string, spec text.FontSpec, c color.Color) {
+	text.Draw(canvas, float32(x), float32(y), text.Shape(s, spec), spec.Size, c)
+}
+
+// drawCircle fills the circle of radius r centered on the pixel (x, y).
+func drawCircle(canvas *image.RGBA, x, y, r int, c color.Color) {
+	var p raster.Path
+	p.AddEllipse(float32(x)+0.5, float32(y)+0.5, float32(r)+0.5, float32(r)+0.5)
+	raster.Over(canvas, p.Fill(raster.NonZero, canvas.Bounds()), c)
+}
+
+// drawField draws a text field filling r with rounded corners, in bg with a
+// one pixel border in fg just inside its edges.
+func drawField(canvas *image.RGBA, r image.Rectangle, bg, fg color.Color) {
+	const radius = 4
+	corners := [4][2]float32{{radius, radius}, {radius, radius}, {radius, radius}, {radius, radius}}
+	var p raster.Path
+	p.AddRoundedRect(float32(r.Min.X), float32(r.Min.Y), float32(r.Max.X), float32(r.Max.Y), corners)
+	raster.Over(canvas, p.Fill(raster.NonZero, canvas.Bounds()), bg)
+	p = raster.Path{}
+	p.AddRoundedRect(float32(r.Min.X)+0.5, float32(r.Min.Y)+0.5, float32(r.Max.X)-0.5, float32(r.Max.Y)-0.5, corners)
+	outline := p.Stroke(raster.StrokeStyle{Width: 1})
+	raster.Over(canvas, outline.Fill(raster.NonZero, canvas.Bounds()), fg)
+}
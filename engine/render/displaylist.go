@@ -0,0 +1,462 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"prymis/engine/layout"
+	"prymis/engine/raster"
+	"prymis/engine/text"
+)
+
+// Op is the kind of a display list item.
+type Op uint8
+
+const (
+	OpFillRect Op = iota
+	OpFillRoundedRect
+	OpStrokeBorder
+	OpFillPath
+	OpStrokePath
+	OpDrawGlyphs
+	OpDrawImage
+	OpPushClip
+	OpPopClip
+	OpPushLayer
+	OpPopLayer
+	OpPushTransform
+	OpPopTransform
+)
+
+var opNames = [...]string{
+	OpFillRect:        "fill",
+	OpFillRoundedRect: "fill-rounded",
+	OpStrokeBorder:    "border",
+	OpFillPath:        "fill-path",
+	OpStrokePath:      "stroke-path",
+	OpDrawGlyphs:      "glyphs",
+	OpDrawImage:       "image",
+	OpPushClip:        "push-clip",
+	OpPopClip:         "pop-clip",
+	OpPushLayer:       "push-layer",
+	OpPopLayer:        "pop-layer",
+	OpPushTransform:   "push-transform",
+	OpPopTransform:    "pop-transform",
+}
+
+func (op Op) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("op(%d)", uint8(op))
+}
+
+// Radii are the horizontal and vertical radii of the corners of a rounded
+// rectangle, clockwise from the top left.
+type Radii [4][2]float32
+
+// IsZero reports whether all corners are square.
+func (r Radii) IsZero() bool {
+	return r == Radii{}
+}
+
+// Border describes a box border: the widths, colors and border-style
+// keywords of its sides in the order top, right, bottom, left, and the radii
+// of its outer corners.
+type Border struct {
+	Widths [4]float32
+	Colors [4]color.Color
+	Styles [4]string
+	Radii  Radii
+}
+
+// Matrix is a 2D affine transform [a b c d e f], which maps (x, y) to
+// (a*x + c*y + e, b*x + d*y + f).
+type Matrix [6]float32
+
+// Identity is the transform that maps every point to itself.
+var Identity = Matrix{1, 0, 0, 1, 0, 0}
+
+// Translate returns the transform that moves points by (dx, dy).
+func Translate(dx, dy float32) Matrix {
+	return Matrix{1, 0, 0, 1, dx, dy}
+}
+
+// Mul returns the transform that applies n and then m.
+func (m Matrix) Mul(n Matrix) Matrix {
+	return Matrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+// Invert returns the transform that undoes m; ok is false when m flattens
+// the plane and has none.
+func (m Matrix) Invert() (inv Matrix, ok bool) {
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 {
+		return Identity, false
+	}
+	inv = Matrix{m[3] / det, -m[1] / det, -m[2] / det, m[0] / det, 0, 0}
+	inv[4] = -(inv[0]*m[4] + inv[2]*m[5])
+	inv[5] = -(inv[1]*m[4] + inv[3]*m[5])
+	return inv, true
+}
+
+// Apply maps the point (x, y).
+func (m Matrix) Apply(x, y float32) (float32, float32) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// Bounds returns the smallest rectangle that holds r once mapped.
+func (m Matrix) Bounds(r layout.Rect) layout.Rect {
+	x0, y0 := m.Apply(r.X, r.Y)
+	minX, minY, maxX, maxY := x0, y0, x0, y0
+	for _, p := range [3][2]float32{{r.X + r.Width, r.Y}, {r.X, r.Y + r.Height}, {r.X + r.Width, r.Y + r.Height}} {
+		x, y := m.Apply(p[0], p[1])
+		minX, maxX = min(minX, x), max(maxX, x)
+		minY, maxY = min(minY, y), max(maxY, y)
+	}
+	return layout.Rect{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
+
+// Item is one drawing command of a display list. Which fields are used
+// depends on Op; Rect is the area an item covers, in the coordinates of the
+// transforms pushed around it.
+type Item struct {
+	Op   Op
+	Rect layout.Rect
+	// Color fills a rectangle, a path or a glyph run.
+	Color color.Color
+	// Radii round the corners of a filled rectangle.
+	Radii  Radii
+	Border Border
+	// Path is filled by Rule or outlined with Stroke.
+	Path   *raster.Path
+	Rule   raster.FillRule
+	Stroke raster.StrokeStyle
+	// Glyphs are drawn from X along the baseline Y at Size.
+	Glyphs []text.Glyph
+	X, Y   float32
+	Size   float32
+	Image  image.Image
+	// A layer is faded by Opacity and mixed in by Blend.
+	Opacity float32
+	Blend   raster.BlendMode
+	// Transform is applied to the items up to the matching pop.
+	Transform Matrix
+}
+
+// DisplayList is a flat, replayable record of what painting a layout tree
+// draws, in painting order. Push items nest and are closed by the matching
+// pop.
+type DisplayList struct {
+	Items []Item
+}
+
+// Backend draws the items of a display list.
+type Backend interface {
+	FillRect(r layout.Rect, c color.Color)
+	FillRoundedRect(r layout.Rect, radii Radii, c color.Color)
+	StrokeBorder(r layout.Rect, b Border)
+	FillPath(p *raster.Path, rule raster.FillRule, c color.Color)
+	StrokePath(p *raster.Path, s raster.StrokeStyle, c color.Color)
+	DrawGlyphs(x, y float32, glyphs []text.Glyph, size float32, c color.Color)
+	DrawImage(r layout.Rect, img image.Image)
+	PushClip(r layout.Rect)
+	PopClip()
+	PushLayer(opacity float32, m Matrix, blend raster.BlendMode)
+	PopLayer()
+	PushTransform(m Matrix)
+	PopTransform()
+}
+
+func (l *DisplayList) add(it Item) {
+	l.Items = append(l.Items, it)
+}
+
+// FillRect records a rectangle filled with c.
+func (l *DisplayList) FillRect(r layout.Rect, c color.Color) {
+	l.add(Item{Op: OpFillRect, Rect: r, Color: c})
+}
+
+// FillRoundedRect records a rectangle with rounded corners filled with c.
+func (l *DisplayList) FillRoundedRect(r layout.Rect, radii Radii, c color.Color) {
+	l.add(Item{Op: OpFillRoundedRect, Rect: r, Radii: radii, Color: c})
+}
+
+// StrokeBorder records a border drawn inside the edges of r.
+func (l *DisplayList) StrokeBorder(r layout.Rect, b Border) {
+	l.add(Item{Op: OpStrokeBorder, Rect: r, Border: b})
+}
+
+// FillPath records the inside of p under rule, which covers r, filled
+// with c.
+func (l *DisplayList) FillPath(r layout.Rect, p *raster.Path, rule raster.FillRule, c color.Color) {
+	l.add(Item{Op: OpFillPath, Rect: r, Path: p, Rule: rule, Color: c})
+}
+
+// StrokePath records p outlined with s, which covers r, in c.
+func (l *DisplayList) StrokePath(r layout.Rect, p *raster.Path, s raster.StrokeStyle, c color.Color) {
+	l.add(Item{Op: OpStrokePath, Rect: r, Path: p, Stroke: s, Color: c})
+}
+
+// DrawGlyphs records a run of shaped glyphs covering r.
+func (l *DisplayList) DrawGlyphs(r layout.Rect, x, y float32, glyphs []text.Glyph, size float32, c color.Color) {
+	l.add(Item{Op: OpDrawGlyphs, Rect: r, X: x, Y: y, Glyphs: glyphs, Size: size, Color: c})
+}
+
+// DrawImage records img scaled to r.
+func (l *DisplayList) DrawImage(r layout.Rect, img image.Image) {
+	l.add(Item{Op: OpDrawImage, Rect: r, Image: img})
+}
+
+// PushClip limits the items up to the matching PopClip to r.
+func (l *DisplayList) PushClip(r layout.Rect) { l.add(Item{Op: OpPushClip, Rect: r}) }
+
+// PopClip ends the innermost clip.
+func (l *DisplayList) PopClip() { l.add(Item{Op: OpPopClip}) }
+
+// PushLayer makes the items up to the matching PopLayer draw as a group
+// into a layer covering r, which is then mapped through m, faded by opacity
+// and blended into what lies beneath it.
+func (l *DisplayList) PushLayer(r layout.Rect, opacity float32, m Matrix, blend raster.BlendMode) {
+	l.add(Item{Op: OpPushLayer, Rect: r, Opacity: opacity, Transform: m, Blend: blend})
+}
+
+// PopLayer ends the innermost layer.
+func (l *DisplayList) PopLayer() { l.add(Item{Op: OpPopLayer}) }
+
+// PushTransform maps the items up to the matching PopTransform through m.
+func (l *DisplayList) PushTransform(m Matrix) { l.add(Item{Op: OpPushTransform, Transform: m}) }
+
+// PopTransform ends the innermost transform.
+func (l *DisplayList) PopTransform() { l.add(Item{Op: OpPopTransform}) }
+
+// Replay draws the items of l on b in order.
+func (l *DisplayList) Replay(b Backend) {
+	for _, it := range l.Items {
+		switch it.Op {
+		case OpFillRect:
+			b.FillRect(it.Rect, it.Color)
+		case OpFillRoundedRect:
+			b.FillRoundedRect(it.Rect, it.Radii, it.Color)
+		case OpStrokeBorder:
+			b.StrokeBorder(it.Rect, it.Border)
+		case OpFillPath:
+			b.FillPath(it.Path, it.Rule, it.Color)
+		case OpStrokePath:
+			b.StrokePath(it.Path, it.Stroke, it.Color)
+		case OpDrawGlyphs:
+			b.DrawGlyphs(it.X, it.Y, it.Glyphs, it.Size, it.Color)
+		case OpDrawImage:
+			b.DrawImage(it.Rect, it.Image)
+		case OpPushClip:
+			b.PushClip(it.Rect)
+		case OpPopClip:
+			b.PopClip()
+		case OpPushLayer:
+			b.PushLayer(it.Opacity, it.Transform, it.Blend)
+		case OpPopLayer:
+			b.PopLayer()
+		case OpPushTransform:
+			b.PushTransform(it.Transform)
+		case OpPopTransform:
+			b.PopTransform()
+		}
+	}
+}
+
+// String returns l as text, one item per line, indented by nesting. Glyph
+// runs are written as the text they draw and images by their size, so the
+// output is meant for inspecting and comparing paints; WriteTo encodes a
+// list in full.
+func (l *DisplayList) String() string {
+	var sb strings.Builder
+	depth := 0
+	for _, it := range l.Items {
+		switch it.Op {
+		case OpPopClip, OpPopLayer, OpPopTransform:
+			depth = max(0, depth-1)
+		}
+		fmt.Fprintf(&sb, "%*s%s%s\n", depth*2, "", it.Op, it.args())
+		switch it.Op {
+		case OpPushClip, OpPushLayer, OpPushTransform:
+			depth++
+		}
+	}
+	return sb.String()
+}
+
+func (it *Item) args() string {
+	r := fmt.Sprintf(" %g %g %g %g", it.Rect.X, it.Rect.Y, it.Rect.Width, it.Rect.Height)
+	switch it.Op {
+	case OpFillRect:
+		return r + " " + colorString(it.Color)
+	case OpFillRoundedRect:
+		return r + it.Radii.String() + " " + colorString(it.Color)
+	case OpStrokeBorder:
+		s := r
+		for i, w := range it.Border.Widths {
+			s += fmt.Sprintf(" %g %s %s", w, it.Border.Styles[i], colorString(it.Border.Colors[i]))
+		}
+		if !it.Border.Radii.IsZero() {
+			s += it.Border.Radii.String()
+		}
+		return s
+	case OpFillPath:
+		rule := "nonzero"
+		if it.Rule == raster.EvenOdd {
+			rule = "evenodd"
+		}
+		return r + " " + rule + " " + colorString(it.Color)
+	case OpStrokePath:
+		return fmt.Sprintf("%s %g %s", r, it.Stroke.Width, colorString(it.Color))
+	case OpDrawGlyphs:
+		runes := make([]rune, len(it.Glyphs))
+		for i, g := range it.Glyphs {
+			runes[i] = g.Rune
+		}
+		return fmt.Sprintf("%s %g %s %q", r, it.Size, colorString(it.Color), string(runes))
+	case OpDrawImage:
+		if it.Image == nil {
+			return r
+		}
+		b := it.Image.Bounds()
+		return fmt.Sprintf("%s %dx%d", r, b.Dx(), b.Dy())
+	case OpPushClip:
+		return r
+	case OpPushLayer:
+		m := it.Transform
+		return fmt.Sprintf("%s %g %g %g %g %g %g %g %s", r, it.Opacity, m[0], m[1], m[2], m[3], m[4], m[5], it.Blend)
+	case OpPushTransform:
+		m := it.Transform
+		return fmt.Sprintf(" %g %g %g %g %g %g", m[0], m[1], m[2], m[3], m[4], m[5])
+	}
+	return ""
+}
+
+func (r Radii) String() string {
+	s := ""
+	for _, c := range r {
+		s += fmt.Sprintf(" %g/%g", c[0], c[1])
+	}
+	return s
+}
+
+func colorString(c color.Color) string {
+	if c == nil {
+		return "none"
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+// Damage compares two display lists and returns the area of the canvas
+// that differs between them, so that only it needs repainting. full reports
+// that the lists differ in structure and everything must be repainted.
+func Damage(old, cur *DisplayList) (r layout.Rect, full bool) {
+	if len(old.Items) != len(cur.Items) {
+		return layout.Rect{}, true
+	}
+	stack := []Matrix{Identity}
+	for i := range cur.Items {
+		a, b := &old.Items[i], &cur.Items[i]
+		if a.Op != b.Op {
+			return layout.Rect{}, true
+		}
+		m := stack[len(stack)-1]
+		switch b.Op {
+		case OpPushClip, OpPushLayer, OpPushTransform:
+			if !a.equal(b) {
+				return layout.Rect{}, true
+			}
+			if b.Op == OpPushTransform || b.Op == OpPushLayer {
+				m = m.Mul(b.Transform)
+			}
+			stack = append(stack, m)
+		case OpPopClip, OpPopLayer, OpPopTransform:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			if !a.equal(b) {
+				r = union(union(r, m.Bounds(a.ink())), m.Bounds(b.ink()))
+			}
+		}
+	}
+	return r, false
+}
+
+// ink returns the area an item may paint. Glyphs can reach beyond the
+// fragment a glyph run covers, by an em at most.
+func (it *Item) ink() layout.Rect {
+	r := it.Rect
+	if it.Op == OpDrawGlyphs {
+		r = layout.Rect{X: r.X - it.Size, Y: r.Y - it.Size, Width: r.Width + 2*it.Size, Height: r.Height + 2*it.Size}
+	}
+	return r
+}
+
+func union(a, b layout.Rect) layout.Rect {
+	switch {
+	case a.Width <= 0 || a.Height <= 0:
+		return b
+	case b.Width <= 0 || b.Height <= 0:
+		return a
+	}
+	x0, y0 := min(a.X, b.X), min(a.Y, b.Y)
+	x1, y1 := max(a.X+a.Width, b.X+b.Width), max(a.Y+a.Height, b.Y+b.Height)
+	return layout.Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+// equal reports whether two items draw the same thing.
+func (it *Item) equal(o *Item) bool {
+	if it.Op != o.Op || it.Rect != o.Rect || it.X != o.X || it.Y != o.Y || it.Size != o.Size ||
+		it.Opacity != o.Opacity || it.Blend != o.Blend || it.Transform != o.Transform || it.Image != o.Image || it.Radii != o.Radii ||
+		it.Border.Widths != o.Border.Widths || it.Border.Styles != o.Border.Styles || it.Border.Radii != o.Border.Radii || !sameColor(it.Color, o.Color) || len(it.Glyphs) != len(o.Glyphs) {
+		return false
+	}
+	for i := range it.Border.Colors {
+		if !sameColor(it.Border.Colors[i], o.Border.Colors[i]) {
+			return false
+		}
+	}
+	for i := range it.Glyphs {
+		if it.Glyphs[i] != o.Glyphs[i] {
+			return false
+		}
+	}
+	if !it.Path.Equal(o.Path) || it.Rule != o.Rule || !sameStroke(it.Stroke, o.Stroke) {
+		return false
+	}
+	return true
+}
+
+func sameStroke(a, b raster.StrokeStyle) bool {
+	if a.Width != b.Width || a.Join != b.Join || a.Cap != b.Cap || a.MiterLimit != b.MiterLimit ||
+		a.DashOffset != b.DashOffset || len(a.Dashes) != len(b.Dashes) {
+		return false
+	}
+	for i := range a.Dashes {
+		if a.Dashes[i] != b.Dashes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameColor(a, b color.Color) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
@@ -0,0 +1,190 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"prymis/engine/dom"
+	"prymis/engine/layout"
+	"prymis/engine/parser"
+	"prymis/engine/raster"
+	"prymis/engine/text"
+)
+
+const samplePage = `<html><head><style>
+body { margin: 8px; }
+h1 { color: #a00; font-style: italic; }
+#box { border: 3px dashed #08f; border-radius: 6px; background-color: #eee; padding: 4px; }
+#box.on { border-color: #f08; background-color: #ffe; }
+.layer { opacity: 0.5; mix-blend-mode: multiply; transform: rotate(5deg); background-color: #0c0; width: 80px; height: 30px; }
+p { border: 4px dotted red; border-left: 6px double blue; }
+</style></head><body>
+<h1>Heading</h1>
+<div id="box">boxed <b>bold</b> text</div>
+<div class="layer"></div>
+<svg width="60" height="40"><path d="M5 5 L55 5 Q30 40 5 5 Z" fill="#f80" stroke="#000" stroke-width="2" stroke-dasharray="4 2"/></svg>
+<p>paragraph</p>
+</body></html>`
+
+// openPage lays out html in a viewport of width by height pixels.
+func openPage(t *testing.T, html string, width, height float32) *layout.Document {
+	t.Helper()
+	page := layout.NewDocument(dom.NewDocument(parser.NewHTMLParser(html).Parse()), nil, "", nil)
+	t.Cleanup(page.Close)
+	page.Update(layout.Dimensions{Content: layout.Rect{Width: width, Height: height}})
+	return page
+}
+
+func replay(l *DisplayList) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 300, 300))
+	l.Replay(NewRaster(img))
+	return img
+}
+
+func TestDisplayListRoundTrip(t *testing.T) {
+	list := BuildDisplayList(openPage(t, samplePage, 300, 300).Layout)
+	pic := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := range pic.Pix {
+		pic.Pix[i] = byte(40 * i)
+	}
+	list.DrawImage(layout.Rect{X: 200, Y: 10, Width: 30, Height: 20}, pic)
+	list.DrawImage(layout.Rect{X: 200, Y: 40, Width: 30, Height: 20}, nil)
+	list.FillPath(layout.Rect{}, &raster.Path{}, raster.EvenOdd, color.Black)
+	list.FillRect(layout.Rect{X: 1, Y: 2, Width: 3, Height: 4}, nil)
+
+	var buf bytes.Buffer
+	n, err := list.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo = %d, %v; wrote %d bytes", n, err, buf.Len())
+	}
+	encoded := buf.Bytes()
+	var got DisplayList
+	if n, err := got.ReadFrom(bytes.NewReader(encoded)); err != nil || n != int64(len(encoded)) {
+		t.Fatalf("ReadFrom = %d, %v; want %d bytes", n, err, len(encoded))
+	}
+
+	if got.String() != list.String() {
+		t.Errorf("decoded list differs:\n%s\nwant:\n%s", got.String(), list.String())
+	}
+	// Fonts and images decode to values of their own.
+	withoutFonts := func(glyphs []text.Glyph) []text.Glyph {
+		glyphs = append([]text.Glyph(nil), glyphs...)
+		for i := range glyphs {
+			glyphs[i].Font = nil
+		}
+		return glyphs
+	}
+	for i := range list.Items {
+		a, b := list.Items[i], got.Items[i]
+		a.Image, b.Image = nil, nil
+		a.Glyphs, b.Glyphs = withoutFonts(a.Glyphs), withoutFonts(b.Glyphs)
+		if !a.equal(&b) {
+			t.Errorf("item %d (%s) does not round-trip", i, list.Items[i].Op)
+		}
+	}
+	var again bytes.Buffer
+	got.WriteTo(&again)
+	if !bytes.Equal(again.Bytes(), encoded) {
+		t.Error("re-encoding the decoded list gives other bytes")
+	}
+	if !bytes.Equal(replay(&got).Pix, replay(list).Pix) {
+		t.Error("decoded list draws differently")
+	}
+}
+
+func TestDisplayListReadFromMalformed(t *testing.T) {
+	// Without text the list carries no fonts, which keeps it short enough
+	// to cut at every byte.
+	var list DisplayList
+	for _, it := range BuildDisplayList(openPage(t, samplePage, 300, 300).Layout).Items {
+		if it.Op != OpDrawGlyphs {
+			list.Items = append(list.Items, it)
+		}
+	}
+	gray := image.NewGray(image.Rect(0, 0, 2, 2))
+	gray.Pix = []byte{0, 80, 160, 240}
+	list.DrawImage(layout.Rect{X: 10, Y: 10, Width: 20, Height: 20}, gray)
+	var buf bytes.Buffer
+	list.WriteTo(&buf)
+	encoded := buf.Bytes()
+
+	read := func(b []byte) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("panic reading %d bytes: %v", len(b), r)
+			}
+		}()
+		var l DisplayList
+		_, err = l.ReadFrom(bytes.NewReader(b))
+		if err == nil {
+			replay(&l)
+		}
+		return err
+	}
+	// Cutting the list short anywhere past the fonts leaves it incomplete.
+	for n := range encoded {
+		if err := read(encoded[:n]); err == nil {
+			t.Fatalf("reading the first %d of %d bytes succeeded", n, len(encoded))
+		}
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		b := append([]byte(nil), encoded...)
+		for j := 0; j < 4; j++ {
+			b[rng.Intn(len(b))] = byte(rng.Intn(256))
+		}
+		read(b)
+	}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"bad magic", "PDL2\x00\x00"},
+		{"huge font", "PDL1\x01\xff\xff\xff\xff\x0f"},
+		{"unknown op", "PDL1\x00\x01\xff"},
+		{"too many items", "PDL1\x00\x05\x08"},
+		{"bad color", "PDL1\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02"},
+		{"premultiplied overflow", "PDL1\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\xff\xff\x00\x00\x00\x00\x00\x00"},
+	}
+	for _, tt := range tests {
+		if err := read([]byte(tt.data)); err != ErrInvalidDisplayList {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+}
+
+func TestScreenRepaintsDamage(t *testing.T) {
+	page := openPage(t, samplePage, 400, 300)
+	bounds := image.Rect(0, 0, 400, 360)
+	content := ContentViewport(bounds)
+	page.Update(layout.Dimensions{Content: layout.Rect{Width: float32(content.Dx()), Height: float32(content.Dy())}})
+	var s Screen
+
+	_, damage := s.Paint(page.Layout, bounds, "https://a.test/")
+	if damage != bounds {
+		t.Errorf("first frame damaged %v, want %v", damage, bounds)
+	}
+	if _, damage := s.Paint(page.Layout, bounds, "https://a.test/"); !damage.Empty() {
+		t.Errorf("unchanged frame damaged %v", damage)
+	}
+
+	page.DOM.GetElementById("box").SetAttribute("class", "on")
+	dom.DeliverMutationRecords()
+	page.Update(layout.Dimensions{Content: layout.Rect{Width: float32(content.Dx()), Height: float32(content.Dy())}})
+	canvas, damage := s.Paint(page.Layout, bounds, "https://a.test/")
+	if damage.Empty() || damage == content || !damage.In(content) {
+		t.Errorf("changing a box damaged %v of %v", damage, content)
+	}
+	if want := Paint(page.Layout, bounds, "https://a.test/"); !bytes.Equal(canvas.Pix, want.Pix) {
+		t.Error("repainting the damage gives another picture than painting it all")
+	}
+
+	if _, damage := s.Paint(page.Layout, bounds, "https://b.test/"); damage != bounds {
+		t.Errorf("new address damaged %v, want %v", damage, bounds)
+	}
+}
@@ -0,0 +1,444 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"prymis/engine/layout"
+	"prymis/engine/raster"
+	"prymis/engine/text"
+)
+
+// The encoding of a display list starts with displayListMagic, followed by
+// the fonts its glyph runs use and then its items. Numbers are
+// little-endian float32s, counts and indices uvarints. Fonts are stored as
+// the font files they were parsed from and images as PNG, so a decoded list
+// draws what the original does, with fonts and images of its own.
+const displayListMagic = "PDL1"
+
+// maxBlob bounds the size of a font, image or path in an encoded list.
+const maxBlob = 64 << 20
+
+// maxImagePixels bounds the size of a decoded image.
+const maxImagePixels = 1 << 26
+
+// ErrInvalidDisplayList is returned when decoding data that is not an
+// encoded display list.
+var ErrInvalidDisplayList = errors.New("render: malformed display list")
+
+// WriteTo encodes l to w, to be read back with ReadFrom.
+func (l *DisplayList) WriteTo(w io.Writer) (int64, error) {
+	e := &encoder{w: bufio.NewWriter(w), fonts: make(map[*text.Font]int)}
+	var fonts []*text.Font
+	for _, it := range l.Items {
+		for _, g := range it.Glyphs {
+			if _, ok := e.fonts[g.Font]; !ok && g.Font != nil {
+				fonts = append(fonts, g.Font)
+				e.fonts[g.Font] = len(fonts)
+			}
+		}
+	}
+	e.write([]byte(displayListMagic))
+	e.uvarint(uint64(len(fonts)))
+	for _, f := range fonts {
+		data, index := f.Data()
+		e.blob(data)
+		e.uvarint(uint64(index))
+	}
+	e.uvarint(uint64(len(l.Items)))
+	for i := range l.Items {
+		e.item(&l.Items[i])
+	}
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.n, e.err
+}
+
+// ReadFrom replaces the items of l with the display list encoded in r.
+func (l *DisplayList) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	d := &decoder{r: bufio.NewReader(cr)}
+	var items []Item
+	if magic := d.bytes(len(displayListMagic)); d.err == nil && string(magic) != displayListMagic {
+		d.fail()
+	}
+	for n := d.uvarint(); d.err == nil && n > 0; n-- {
+		data := d.blob()
+		index := d.uvarint()
+		if d.err != nil {
+			break
+		}
+		f, err := text.ParseIndex(data, int(min(index, math.MaxInt32)))
+		if err != nil {
+			d.fail()
+			break
+		}
+		d.fonts = append(d.fonts, f)
+	}
+	for n := d.uvarint(); d.err == nil && n > 0; n-- {
+		it := d.item()
+		if d.err == nil {
+			items = append(items, it)
+		}
+	}
+	if d.err != nil {
+		return cr.n, d.err
+	}
+	l.Items = items
+	return cr.n, nil
+}
+
+type encoder struct {
+	w     *bufio.Writer
+	n     int64
+	err   error
+	fonts map[*text.Font]int
+}
+
+func (e *encoder) write(b []byte) {
+	if e.err != nil {
+		return
+	}
+	k, err := e.w.Write(b)
+	e.n += int64(k)
+	e.err = err
+}
+
+func (e *encoder) uvarint(v uint64) { e.write(binary.AppendUvarint(nil, v)) }
+
+func (e *encoder) f32(v float32) {
+	e.write(binary.LittleEndian.AppendUint32(nil, math.Float32bits(v)))
+}
+
+func (e *encoder) blob(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.write(b)
+}
+
+func (e *encoder) rect(r layout.Rect) {
+	e.f32(r.X)
+	e.f32(r.Y)
+	e.f32(r.Width)
+	e.f32(r.Height)
+}
+
+func (e *encoder) matrix(m Matrix) {
+	for _, v := range m {
+		e.f32(v)
+	}
+}
+
+func (e *encoder) radii(r Radii) {
+	for _, c := range r {
+		e.f32(c[0])
+		e.f32(c[1])
+	}
+}
+
+// color writes whether c is set and then its premultiplied 16-bit
+// components, which is all that drawing uses of it.
+func (e *encoder) color(c color.Color) {
+	if c == nil {
+		e.write([]byte{0})
+		return
+	}
+	r, g, b, a := c.RGBA()
+	e.write([]byte{1})
+	for _, v := range [4]uint32{r, g, b, a} {
+		e.write(binary.LittleEndian.AppendUint16(nil, uint16(v)))
+	}
+}
+
+func (e *encoder) item(it *Item) {
+	e.write([]byte{byte(it.Op)})
+	switch it.Op {
+	case OpFillRect:
+		e.rect(it.Rect)
+		e.color(it.Color)
+	case OpFillRoundedRect:
+		e.rect(it.Rect)
+		e.radii(it.Radii)
+		e.color(it.Color)
+	case OpStrokeBorder:
+		e.rect(it.Rect)
+		for i := range 4 {
+			e.f32(it.Border.Widths[i])
+			e.color(it.Border.Colors[i])
+			e.blob([]byte(it.Border.Styles[i]))
+		}
+		e.radii(it.Border.Radii)
+	case OpFillPath, OpStrokePath:
+		e.rect(it.Rect)
+		var path []byte
+		if it.Path != nil {
+			path, _ = it.Path.MarshalBinary()
+		}
+		e.blob(path)
+		e.color(it.Color)
+		if it.Op == OpFillPath {
+			e.write([]byte{byte(it.Rule)})
+			break
+		}
+		s := it.Stroke
+		e.f32(s.Width)
+		e.write([]byte{byte(s.Join), byte(s.Cap)})
+		e.f32(s.MiterLimit)
+		e.uvarint(uint64(len(s.Dashes)))
+		for _, d := range s.Dashes {
+			e.f32(d)
+		}
+		e.f32(s.DashOffset)
+	case OpDrawGlyphs:
+		e.rect(it.Rect)
+		e.f32(it.X)
+		e.f32(it.Y)
+		e.f32(it.Size)
+		e.color(it.Color)
+		e.uvarint(uint64(len(it.Glyphs)))
+		for _, g := range it.Glyphs {
+			e.uvarint(uint64(e.fonts[g.Font]))
+			e.uvarint(uint64(g.ID))
+			e.uvarint(uint64(uint32(g.Rune)))
+			e.write([]byte{byte(g.Synthesis)})
+			e.f32(g.X)
+			e.f32(g.Advance)
+		}
+	case OpDrawImage:
+		e.rect(it.Rect)
+		var buf bytes.Buffer
+		if it.Image != nil && e.err == nil {
+			e.err = png.Encode(&buf, it.Image)
+		}
+		e.blob(buf.Bytes())
+	case OpPushClip:
+		e.rect(it.Rect)
+	case OpPushLayer:
+		e.rect(it.Rect)
+		e.f32(it.Opacity)
+		e.matrix(it.Transform)
+		e.write([]byte{byte(it.Blend)})
+	case OpPushTransform:
+		e.matrix(it.Transform)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	k, err := c.r.Read(p)
+	c.n += int64(k)
+	return k, err
+}
+
+// decoder reads an encoded display list. After the first error, which it
+// keeps, it reads only zeros.
+type decoder struct {
+	r     *bufio.Reader
+	err   error
+	fonts []*text.Font
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = ErrInvalidDisplayList
+	}
+}
+
+func (d *decoder) bytes(n int) []byte {
+	b := make([]byte, n)
+	if d.err != nil {
+		return b
+	}
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.fail()
+	}
+	return b
+}
+
+func (d *decoder) byte() byte { return d.bytes(1)[0] }
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.fail()
+	}
+	return v
+}
+
+func (d *decoder) f32() float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(d.bytes(4)))
+}
+
+// blob reads a length and that many bytes, which grow as they arrive so
+// that a short input cannot claim a huge blob.
+func (d *decoder) blob() []byte {
+	n := d.uvarint()
+	if n > maxBlob {
+		d.fail()
+	}
+	if d.err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		d.fail()
+	}
+	return buf.Bytes()
+}
+
+func (d *decoder) rect() layout.Rect {
+	return layout.Rect{X: d.f32(), Y: d.f32(), Width: d.f32(), Height: d.f32()}
+}
+
+func (d *decoder) matrix() Matrix {
+	var m Matrix
+	for i := range m {
+		m[i] = d.f32()
+	}
+	return m
+}
+
+func (d *decoder) radii() Radii {
+	var r Radii
+	for i := range r {
+		r[i] = [2]float32{d.f32(), d.f32()}
+	}
+	return r
+}
+
+func (d *decoder) color() color.Color {
+	switch d.byte() {
+	case 0:
+		return nil
+	case 1:
+		b := d.bytes(8)
+		c := color.RGBA64{
+			R: binary.LittleEndian.Uint16(b),
+			G: binary.LittleEndian.Uint16(b[2:]),
+			B: binary.LittleEndian.Uint16(b[4:]),
+			A: binary.LittleEndian.Uint16(b[6:]),
+		}
+		if c.R > c.A || c.G > c.A || c.B > c.A {
+			d.fail()
+		}
+		return c
+	}
+	d.fail()
+	return nil
+}
+
+func (d *decoder) item() Item {
+	it := Item{Op: Op(d.byte())}
+	switch it.Op {
+	case OpFillRect:
+		it.Rect = d.rect()
+		it.Color = d.color()
+	case OpFillRoundedRect:
+		it.Rect = d.rect()
+		it.Radii = d.radii()
+		it.Color = d.color()
+	case OpStrokeBorder:
+		it.Rect = d.rect()
+		for i := range 4 {
+			it.Border.Widths[i] = d.f32()
+			it.Border.Colors[i] = d.color()
+			it.Border.Styles[i] = string(d.blob())
+		}
+		it.Border.Radii = d.radii()
+	case OpFillPath, OpStrokePath:
+		it.Rect = d.rect()
+		it.Path = new(raster.Path)
+		if b := d.blob(); len(b) > 0 && it.Path.UnmarshalBinary(b) != nil {
+			d.fail()
+		}
+		it.Color = d.color()
+		if it.Op == OpFillPath {
+			if it.Rule = raster.FillRule(d.byte()); it.Rule > raster.EvenOdd {
+				d.fail()
+			}
+			break
+		}
+		it.Stroke.Width = d.f32()
+		it.Stroke.Join, it.Stroke.Cap = raster.Join(d.byte()), raster.Cap(d.byte())
+		if it.Stroke.Join > raster.BevelJoin || it.Stroke.Cap > raster.SquareCap {
+			d.fail()
+		}
+		it.Stroke.MiterLimit = d.f32()
+		for n := d.uvarint(); d.err == nil && n > 0; n-- {
+			it.Stroke.Dashes = append(it.Stroke.Dashes, d.f32())
+		}
+		it.Stroke.DashOffset = d.f32()
+	case OpDrawGlyphs:
+		it.Rect = d.rect()
+		it.X, it.Y, it.Size = d.f32(), d.f32(), d.f32()
+		it.Color = d.color()
+		for n := d.uvarint(); d.err == nil && n > 0; n-- {
+			var g text.Glyph
+			if font := d.uvarint(); font > uint64(len(d.fonts)) {
+				d.fail()
+			} else if font > 0 {
+				g.Font = d.fonts[font-1]
+			}
+			id, r := d.uvarint(), d.uvarint()
+			if id > math.MaxUint16 || r > math.MaxInt32 {
+				d.fail()
+			}
+			g.ID, g.Rune = text.GlyphID(id), rune(r)
+			g.Synthesis = text.Synthesis(d.byte())
+			g.X, g.Advance = d.f32(), d.f32()
+			it.Glyphs = append(it.Glyphs, g)
+		}
+	case OpDrawImage:
+		it.Rect = d.rect()
+		it.Image = d.image()
+	case OpPushClip:
+		it.Rect = d.rect()
+	case OpPushLayer:
+		it.Rect = d.rect()
+		it.Opacity = d.f32()
+		it.Transform = d.matrix()
+		if it.Blend = raster.BlendMode(d.byte()); it.Blend > raster.BlendLuminosity {
+			d.fail()
+		}
+	case OpPushTransform:
+		it.Transform = d.matrix()
+	case OpPopClip, OpPopLayer, OpPopTransform:
+	default:
+		d.fail()
+	}
+	return it
+}
+
+// image decodes a PNG image, checking its size before the pixels are
+// allocated.
+func (d *decoder) image() image.Image {
+	b := d.blob()
+	if d.err != nil || len(b) == 0 {
+		return nil
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(b))
+	if err != nil || cfg.Width*cfg.Height > maxImagePixels {
+		d.fail()
+		return nil
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		d.fail()
+		return nil
+	}
+	return img
+}
@@ -4,126 +4,215 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"prymis/engine/dom"
+	"math"
 	"prymis/engine/layout"
+	"prymis/engine/text"
+	"strconv"
+	"strings"
 )
 
+// Paint renders the browser window: the chrome with url in the address bar
+// and, below it, the page laid out in root. The page must have been laid out
+// for the size of ContentViewport(bounds).
 func Paint(root *layout.LayoutBox, bounds image.Rectangle, url string) *image.RGBA {
 	canvas := image.NewRGBA(bounds)
+	content := PaintChrome(canvas, url)
+	PaintPage(canvas, content, root)
+	return canvas
+}
 
-	// 1. Draw Browser Window Frame (Dark Theme)
-	frameColor := color.RGBA{33, 37, 43, 255}
-	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{frameColor}, image.Point{}, draw.Src)
-
-	// 2. Draw Window Controls (Red, Yellow, Green circles)
-	drawCircle(canvas, 20, 20, 6, color.RGBA{255, 95, 87, 255})
-	drawCircle(canvas, 40, 20, 6, color.RGBA{255, 189, 46, 255})
-	drawCircle(canvas, 60, 20, 6, color.RGBA{39, 201, 63, 255})
-
-	// 4. Draw Address Bar
-	addressBarRect := image.Rect(100, 50, 700, 85)
-	draw.Draw(canvas, addressBarRect, &image.Uniform{color.RGBA{30, 33, 39, 255}}, image.Point{}, draw.Src)
-	drawBorder(canvas, addressBarRect, color.RGBA{100, 100, 100, 255})
-
-	// Draw URL simulation (dots/lines representing text)
-	drawTextSimulation(canvas, 120, 67, url, color.RGBA{180, 180, 180, 255})
+// RenderPage renders the page laid out in root on its own, into an image of
+// the given size, as seen through a viewport of that size at its current
+// scroll position.
+func RenderPage(root *layout.LayoutBox, width, height int) *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	PaintPage(canvas, canvas.Bounds(), root)
+	return canvas
+}
 
-	// 5. Draw Prymis Logo (Stylized 'P')
-	drawLogo(canvas, 750, 25)
+// PaintPage paints the page laid out in root into viewport, a rectangle of
+// dst, with scrollbars where it overflows. The page is laid out with the
+// viewport at the origin; the root box holds how far it is scrolled.
+func PaintPage(dst *image.RGBA, viewport image.Rectangle, root *layout.LayoutBox) {
+	var list *DisplayList
+	if root != nil {
+		list = BuildDisplayList(root)
+	}
+	paintPage(dst, viewport, viewport, root, list)
+}
 
-	// 5. Draw Content Area Background
-	contentArea := image.Rect(0, 100, 800, 600)
-	draw.Draw(canvas, contentArea, &image.Uniform{color.White}, image.Point{}, draw.Src)
+// paintPage paints the part within area of the page laid out in root, whose
+// display list is list, as PaintPage does.
+func paintPage(dst *image.RGBA, viewport, area image.Rectangle, root *layout.LayoutBox, list *DisplayList) {
+	draw.Draw(dst, area, &image.Uniform{color.White}, image.Point{}, draw.Src)
+	if root == nil {
+		return
+	}
+	sx, sy := root.ScrollOffset()
+	port := layout.Rect{X: float32(viewport.Min.X), Y: float32(viewport.Min.Y), Width: float32(viewport.Dx()), Height: float32(viewport.Dy())}
+	r := NewRaster(dst)
+	r.clip = area
+	r.PushClip(port)
+	r.PushTransform(Translate(port.X-sx, port.Y-sy))
+	list.Replay(r)
+	r.PopTransform()
 
-	// 6. Offset rendering to content area
-	viewport := layout.Dimensions{
-		Content: layout.Rect{X: 0, Y: 100, Width: 800, Height: 0},
+	var bars DisplayList
+	if overflow := root.Overflow(); overflow != "hidden" {
+		w, h := root.ScrollSize()
+		bars.scrollbars(port, sx, sy, w, h, overflow == "scroll")
 	}
-	root.Layout(viewport)
+	bars.Replay(r)
+	r.PopClip()
+}
 
-	renderBox(canvas, root)
-	return canvas
+// Screen paints the browser window over and over. It keeps the last frame
+// and the display list of the page in it, so that a repaint redraws only
+// what Damage finds changed.
+type Screen struct {
+	canvas *image.RGBA
+	list   *DisplayList
+	// url, scroll and overflow are what the last frame showed.
+	url      string
+	scroll   [4]float32
+	overflow string
 }
 
-func renderBox(canvas *image.RGBA, box *layout.LayoutBox) {
-	// Draw background color
-	if bg := box.StyledNode.SpecifiedValues["background-color"]; bg != "" {
-		c := parseColor(bg)
-		rect := image.Rect(
-			int(box.Dimensions.Content.X),
-			int(box.Dimensions.Content.Y),
-			int(box.Dimensions.Content.X+box.Dimensions.Content.Width),
-			int(box.Dimensions.Content.Y+box.Dimensions.Content.Height),
-		)
-		draw.Draw(canvas, rect, &image.Uniform{c}, image.Point{}, draw.Src)
-	}
-
-	// Draw border (simple 1px black border for visibility)
-	if box.StyledNode.Node.NodeType == dom.ElementNode {
-		rect := image.Rect(
-			int(box.Dimensions.Content.X),
-			int(box.Dimensions.Content.Y),
-			int(box.Dimensions.Content.X+box.Dimensions.Content.Width),
-			int(box.Dimensions.Content.Y+box.Dimensions.Content.Height),
-		)
-		drawBorder(canvas, rect, color.Black)
-	}
-
-	for _, child := range box.Children {
-		renderBox(canvas, child)
+// Paint renders the browser window as the function Paint does. It returns
+// the canvas, which it reuses between frames, and the area of it that
+// changed since the last call, which is empty when nothing did.
+func (s *Screen) Paint(root *layout.LayoutBox, bounds image.Rectangle, url string) (*image.RGBA, image.Rectangle) {
+	var list *DisplayList
+	var scroll [4]float32
+	var overflow string
+	if root != nil {
+		list = BuildDisplayList(root)
+		scroll[0], scroll[1] = root.ScrollOffset()
+		scroll[2], scroll[3] = root.ScrollSize()
+		overflow = root.Overflow()
+	}
+	prev := s.list
+	s.list = list
+	if s.canvas == nil || s.canvas.Bounds() != bounds || url != s.url {
+		s.canvas, s.url, s.scroll, s.overflow = image.NewRGBA(bounds), url, scroll, overflow
+		content := PaintChrome(s.canvas, url)
+		paintPage(s.canvas, content, content, root, list)
+		return s.canvas, bounds
 	}
+	content := ContentViewport(bounds)
+	if prev == nil || list == nil || scroll != s.scroll || overflow != s.overflow {
+		s.scroll, s.overflow = scroll, overflow
+		paintPage(s.canvas, content, content, root, list)
+		return s.canvas, content
+	}
+	r, full := Damage(prev, list)
+	if full {
+		paintPage(s.canvas, content, content, root, list)
+		return s.canvas, content
+	}
+	if r.Width <= 0 || r.Height <= 0 {
+		return s.canvas, image.Rectangle{}
+	}
+	// r is in page coordinates; a pixel more on each side takes in the
+	// anti-aliased edges.
+	x, y := r.X-scroll[0]+float32(content.Min.X), r.Y-scroll[1]+float32(content.Min.Y)
+	area := image.Rect(int(math.Floor(float64(x)))-1, int(math.Floor(float64(y)))-1,
+		int(math.Ceil(float64(x+r.Width)))+1, int(math.Ceil(float64(y+r.Height)))+1).Intersect(content)
+	if !area.Empty() {
+		paintPage(s.canvas, content, area, root, list)
+	}
+	return s.canvas, area
 }
 
-func drawLogo(canvas *image.RGBA, x, y int) {
-	// Stylized 'P'
-	c := color.RGBA{100, 150, 255, 255}
-	// Vertical bar
-	for i := -10; i < 15; i++ {
-		for j := -2; j < 2; j++ {
-			canvas.Set(x+j, y+i, c)
+// paintBackground paints the background and border of a block-level box or
+// atomic inline, and the content of an inline SVG; inline boxes are painted
+// through their fragments. The background fills the border box, clipped to
+// its rounded corners.
+func (p *painter) paintBackground(box *layout.LayoutBox) {
+	if box.BoxType == layout.InlineNode {
+		return
+	}
+	bb := box.Dimensions.BorderBox()
+	radii := Radii(box.BorderRadii())
+	if bg := box.StyledNode.SpecifiedValues["background-color"]; bg != "" && box.BoxType != layout.AnonymousBlock {
+		if c := parseColor(bg); radii.IsZero() {
+			p.list.FillRect(bb, c)
+		} else {
+			p.list.FillRoundedRect(bb, radii, c)
 		}
 	}
-	// Curve
-	drawCircle(canvas, x+5, y-5, 7, c)
-	drawCircle(canvas, x+5, y-5, 4, color.RGBA{33, 37, 43, 255})
+	p.paintBorder(box.StyledNode, bb, box.Borders(), radii)
+	if isSVG(box) {
+		p.paintSVG(box)
+	}
 }
 
-func drawTextSimulation(canvas *image.RGBA, x, y int, text string, c color.Color) {
-	// Draw dots for characters
-	for i := 0; i < len(text); i++ {
-		charX := x + i*8
-		// Randomize height slightly for 'text-like' feel
-		h := 2
-		if text[i] == '/' || text[i] == '.' {
-			h = 4
+// paintBorder paints the border sides of a box whose border box is rect.
+func (p *painter) paintBorder(style *layout.StyledNode, rect layout.Rect, sides [4]layout.BorderSide, radii Radii) {
+	b := Border{Radii: radii}
+	visible := false
+	for i, side := range sides {
+		switch side.Style {
+		case "", "none", "hidden":
+			continue
 		}
-		rect := image.Rect(charX, y-h, charX+5, y+1)
-		draw.Draw(canvas, rect, &image.Uniform{c}, image.Point{}, draw.Src)
+		if side.Width <= 0 {
+			continue
+		}
+		b.Widths[i], b.Styles[i] = side.Width, side.Style
+		if c := strings.ToLower(side.Color); c == "" || c == "currentcolor" {
+			b.Colors[i] = textColor(style)
+		} else {
+			b.Colors[i] = parseColor(c)
+		}
+		visible = true
+	}
+	if visible {
+		p.list.StrokeBorder(rect, b)
 	}
 }
 
-func drawCircle(canvas *image.RGBA, x, y, r int, c color.Color) {
-	for i := x - r; i <= x+r; i++ {
-		for j := y - r; j <= y+r; j++ {
-			if (i-x)*(i-x)+(j-y)*(j-y) <= r*r {
-				canvas.Set(i, j, c)
-			}
+// paintFragment paints the background and border of an inline box fragment,
+// which has the start and end borders only where the box starts and ends, or
+// the glyphs of a text fragment.
+func (p *painter) paintFragment(f layout.Fragment) {
+	switch f.Kind {
+	case layout.InlineBoxFragment:
+		if bg := f.Box.StyledNode.SpecifiedValues["background-color"]; bg != "" {
+			p.list.FillRect(f.Rect, parseColor(bg))
+		}
+		sides := f.Box.Borders()
+		start, end := 3, 1
+		if f.Box.StyledNode.Value("direction") == "rtl" {
+			start, end = end, start
+		}
+		if !f.First {
+			sides[start] = layout.BorderSide{}
+		}
+		if !f.Last {
+			sides[end] = layout.BorderSide{}
 		}
+		p.paintBorder(f.Box.StyledNode, f.Rect, sides, Radii{})
+	case layout.TextFragment:
+		if f.Box.StyledNode.SpecifiedValues["visibility"] == "hidden" {
+			return
+		}
+		spec := f.Box.StyledNode.FontSpec()
+		p.list.DrawGlyphs(f.Rect, f.Rect.X, f.Baseline, text.Shape(f.Text, spec), spec.Size, textColor(f.Box.StyledNode))
 	}
 }
 
-func drawBorder(canvas *image.RGBA, r image.Rectangle, c color.Color) {
-	for x := r.Min.X; x < r.Max.X; x++ {
-		canvas.Set(x, r.Min.Y, c)
-		canvas.Set(x, r.Max.Y-1, c)
-	}
-	for y := r.Min.Y; y < r.Max.Y; y++ {
-		canvas.Set(r.Min.X, y, c)
-		canvas.Set(r.Max.X-1, y, c)
+// textColor returns the color property of a style; text is black unless
+// a color is inherited or set.
+func textColor(style *layout.StyledNode) color.Color {
+	if c := style.SpecifiedValues["color"]; c != "" {
+		return parseColor(c)
 	}
+	return color.Black
 }
 
 func parseColor(s string) color.Color {
+	s = strings.ToLower(strings.TrimSpace(s))
 	switch s {
 	case "white":
 		return color.White
@@ -135,9 +224,108 @@ func parseColor(s string) color.Color {
 		return color.RGBA{100, 255, 100, 255}
 	case "black":
 		return color.Black
-	case "gray":
+	case "gray", "grey":
 		return color.RGBA{200, 200, 200, 255}
+	case "transparent":
+		return color.Transparent
+	}
+	if c, ok := namedColors[s]; ok {
+		return c
+	}
+	if strings.HasPrefix(s, "#") {
+		if c, ok := parseHexColor(s[1:]); ok {
+			return c
+		}
+	}
+	if strings.HasPrefix(s, "rgb(") || strings.HasPrefix(s, "rgba(") {
+		if c, ok := parseRGBFunction(s[strings.IndexByte(s, '(')+1:]); ok {
+			return c
+		}
+	}
+	return color.White
+}
+
+var namedColors = map[string]color.RGBA{
+	"silver": {192, 192, 192, 255}, "maroon": {128, 0, 0, 255},
+	"purple": {128, 0, 128, 255}, "fuchsia": {255, 0, 255, 255},
+	"magenta": {255, 0, 255, 255}, "lime": {0, 255, 0, 255},
+	"olive": {128, 128, 0, 255}, "yellow": {255, 255, 0, 255},
+	"navy": {0, 0, 128, 255}, "teal": {0, 128, 128, 255},
+	"aqua": {0, 255, 255, 255}, "cyan": {0, 255, 255, 255},
+	"orange": {255, 165, 0, 255}, "brown": {165, 42, 42, 255},
+	"pink": {255, 192, 203, 255}, "gold": {255, 215, 0, 255},
+	"darkgray": {169, 169, 169, 255}, "lightgray": {211, 211, 211, 255},
+	"darkgrey": {169, 169, 169, 255}, "lightgrey": {211, 211, 211, 255},
+	"whitesmoke": {245, 245, 245, 255}, "gainsboro": {220, 220, 220, 255},
+	"dimgray": {105, 105, 105, 255}, "dimgrey": {105, 105, 105, 255},
+	"indigo": {75, 0, 130, 255}, "violet": {238, 130, 238, 255},
+	"crimson": {220, 20, 60, 255}, "tomato": {255, 99, 71, 255},
+	"coral": {255, 127, 80, 255}, "salmon": {250, 128, 114, 255},
+	"khaki": {240, 230, 140, 255}, "beige": {245, 245, 220, 255},
+	"ivory": {255, 255, 240, 255}, "tan": {210, 180, 140, 255},
+	"skyblue": {135, 206, 235, 255}, "steelblue": {70, 130, 180, 255},
+	"royalblue": {65, 105, 225, 255}, "darkblue": {0, 0, 139, 255},
+	"darkgreen": {0, 100, 0, 255}, "darkred": {139, 0, 0, 255},
+	"lightblue": {173, 216, 230, 255}, "lightgreen": {144, 238, 144, 255},
+	"lightyellow": {255, 255, 224, 255}, "aliceblue": {240, 248, 255, 255},
+	"slategray": {112, 128, 144, 255}, "slategrey": {112, 128, 144, 255},
+}
+
+// parseHexColor parses the digits of #rgb, #rgba, #rrggbb and #rrggbbaa.
+func parseHexColor(hex string) (color.Color, bool) {
+	var digits []uint8
+	for i := 0; i < len(hex); i++ {
+		v, err := strconv.ParseUint(hex[i:i+1], 16, 8)
+		if err != nil {
+			return nil, false
+		}
+		digits = append(digits, uint8(v))
+	}
+	var c [4]uint8
+	c[3] = 255
+	switch len(digits) {
+	case 3, 4:
+		for i, d := range digits {
+			c[i] = d*16 + d
+		}
+	case 6, 8:
+		for i := 0; i < len(digits); i += 2 {
+			c[i/2] = digits[i]*16 + digits[i+1]
+		}
 	default:
-		return color.White
+		return nil, false
+	}
+	return color.NRGBA{c[0], c[1], c[2], c[3]}, true
+}
+
+// parseRGBFunction parses the arguments of rgb() and rgba(), with commas or
+// spaces and an optional alpha after a comma or slash.
+func parseRGBFunction(args string) (color.Color, bool) {
+	args = strings.TrimSuffix(strings.TrimSpace(args), ")")
+	fields := strings.FieldsFunc(args, func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+	if len(fields) != 3 && len(fields) != 4 {
+		return nil, false
+	}
+	var c [4]uint8
+	c[3] = 255
+	for i, f := range fields {
+		scale := 255.0
+		if i == 3 {
+			scale = 1
+		}
+		var v float64
+		var err error
+		if strings.HasSuffix(f, "%") {
+			v, err = strconv.ParseFloat(strings.TrimSuffix(f, "%"), 64)
+			v = v / 100 * 255
+		} else {
+			v, err = strconv.ParseFloat(f, 64)
+			v = v * 255 / scale
+		}
+		if err != nil {
+			return nil, false
+		}
+		c[i] = uint8(math.Max(0, math.Min(255, math.Round(v))))
 	}
+	return color.NRGBA{c[0], c[1], c[2], c[3]}, true
 }
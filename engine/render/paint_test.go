@@ -0,0 +1,231 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+const boxPage = `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#a { width: 50px; height: 40px; background-color: #ff0000; }
+#tail { height: 1000px; }
+</style></head><body><div id="a"></div><div id="tail"></div></body></html>`
+
+func TestRenderPage(t *testing.T) {
+	for _, size := range []image.Point{{300, 200}, {120, 80}, {40, 30}} {
+		page := openPage(t, boxPage, float32(size.X), float32(size.Y))
+		img := RenderPage(page.Layout, size.X, size.Y)
+		if got := img.Bounds(); got != image.Rect(0, 0, size.X, size.Y) {
+			t.Errorf("%v: image bounds %v", size, got)
+		}
+		// The page is drawn at the origin with no chrome around it.
+		if got := img.RGBAAt(20, 20); got != red {
+			t.Errorf("%v: pixel at 20, 20 is %v, want the box", size, got)
+		}
+		// Keep clear of the scrollbar along the right edge.
+		if size.X > 60 {
+			if got := img.RGBAAt(size.X-15, 20); got != (color.RGBA{255, 255, 255, 255}) {
+				t.Errorf("%v: pixel right of the box is %v, want white", size, got)
+			}
+		}
+	}
+	if got := RenderPage(nil, 10, 10).RGBAAt(5, 5); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("an empty page is %v, want white", got)
+	}
+}
+
+func TestRenderScrolledPage(t *testing.T) {
+	page := openPage(t, boxPage, 300, 200)
+	page.ScrollTo(0, 30)
+	img := RenderPage(page.Layout, 300, 200)
+	// The bottom 10 pixels of the box are left at the top of the viewport.
+	if got := img.RGBAAt(20, 5); got != red {
+		t.Errorf("pixel at 20, 5 is %v, want the box", got)
+	}
+	if got := img.RGBAAt(20, 15); got == red {
+		t.Error("the box did not scroll")
+	}
+}
+
+func TestPaintChrome(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 300)
+	if got, want := ContentViewport(bounds), image.Rect(0, ChromeHeight, 400, 300); got != want {
+		t.Errorf("ContentViewport = %v, want %v", got, want)
+	}
+	if got := ContentViewport(image.Rect(0, 0, 400, 50)); !got.Empty() {
+		t.Errorf("a window shorter than the chrome shows the page in %v", got)
+	}
+	dst := image.NewRGBA(bounds)
+	if got := PaintChrome(dst, "http://example.com/"); got != ContentViewport(bounds) {
+		t.Errorf("PaintChrome left %v for the page", got)
+	}
+
+	// Paint puts the page below the chrome, laid out for the content size.
+	content := ContentViewport(bounds)
+	page := openPage(t, boxPage, float32(content.Dx()), float32(content.Dy()))
+	img := Paint(page.Layout, bounds, "http://example.com/")
+	if got := img.RGBAAt(20, ChromeHeight+20); got != red {
+		t.Errorf("pixel at 20, %d is %v, want the box", ChromeHeight+20, got)
+	}
+	if got := img.RGBAAt(20, ChromeHeight-5); got == red {
+		t.Error("the page was painted over the chrome")
+	}
+}
+
+func TestScrollbars(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	tests := []struct {
+		name       string
+		css        string
+		scroll     float32
+		vertical   bool
+		horizontal bool
+		// thumbY is a point on the vertical thumb.
+		thumbY int
+	}{
+		{name: "fits", css: "#tail { height: 10px; }"},
+		{name: "tall", css: "#tail { height: 1000px; }", vertical: true, thumbY: 5},
+		{name: "tall scrolled to the end", css: "#tail { height: 1000px; }", scroll: 1000, vertical: true, thumbY: 195},
+		{name: "wide", css: "#tail { width: 1000px; height: 10px; }", horizontal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := openPage(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+`+tt.css+`</style></head><body><div id="tail"></div></body></html>`, 300, 200)
+			page.ScrollTo(0, tt.scroll)
+			img := RenderPage(page.Layout, 300, 200)
+			// Sample inside the track, clear of the page's borders.
+			if got := img.RGBAAt(300-scrollbarWidth+3, 100) != white; got != tt.vertical {
+				t.Errorf("vertical scrollbar shown: %v, want %v", got, tt.vertical)
+			}
+			if got := img.RGBAAt(150, 200-scrollbarWidth+3) != white; got != tt.horizontal {
+				t.Errorf("horizontal scrollbar shown: %v, want %v", got, tt.horizontal)
+			}
+			if tt.vertical {
+				track := img.RGBAAt(300-scrollbarWidth+3, 100)
+				if got := img.RGBAAt(300-scrollbarWidth+3, tt.thumbY); got == track {
+					t.Errorf("no thumb at y %d", tt.thumbY)
+				}
+			}
+		})
+	}
+}
+
+func TestOverflowClip(t *testing.T) {
+	// #box is 100 pixels square at the origin, holding #in, a red square 200
+	// pixels across; the pixel at 150, 50 is past the edge of #box.
+	tests := []struct {
+		name    string
+		css     string
+		clipped bool
+	}{
+		{name: "visible"},
+		{name: "hidden", css: "#box { overflow: hidden; }", clipped: true},
+		{name: "clip", css: "#box { overflow: clip; }", clipped: true},
+		{name: "auto", css: "#box { overflow: auto; }", clipped: true},
+		{name: "absolute escapes a static clipper", css: "#box { overflow: hidden; } #in { position: absolute; top: 0; left: 0; }"},
+		{name: "absolute inside a positioned clipper", css: "#box { overflow: hidden; position: relative; } #in { position: absolute; top: 0; left: 0; }", clipped: true},
+		{name: "fixed escapes", css: "#box { overflow: hidden; position: relative; } #in { position: fixed; top: 0; left: 0; }"},
+		{name: "relative is clipped", css: "#box { overflow: hidden; } #in { position: relative; }", clipped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := renderHTML(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#box { width: 100px; height: 100px; }
+#in { width: 200px; height: 200px; background-color: #ff0000; }
+`+tt.css+`</style></head><body><div id="box"><div id="in"></div></div></body></html>`)
+			if got := img.RGBAAt(50, 50); got != red {
+				t.Errorf("pixel inside the box is %v", got)
+			}
+			if got := img.RGBAAt(150, 50) != red; got != tt.clipped {
+				t.Errorf("clipped %v, want %v", got, tt.clipped)
+			}
+		})
+	}
+}
+
+func TestScrolledOverflow(t *testing.T) {
+	page := openPage(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#box { width: 100px; height: 100px; overflow: hidden; }
+#top { height: 60px; }
+#red { height: 100px; background-color: #ff0000; }
+</style></head><body><div id="box"><div id="top"></div><div id="red"></div></div></body></html>`, 300, 200)
+	if got := RenderPage(page.Layout, 300, 200).RGBAAt(50, 30); got == red {
+		t.Error("red box shown before scrolling")
+	}
+	// Content in a box with overflow: hidden still scrolls programmatically.
+	page.ScrollIntoView(page.DOM.GetElementById("red"))
+	img := RenderPage(page.Layout, 300, 200)
+	if got := img.RGBAAt(50, 30); got != red {
+		t.Errorf("pixel at 50, 30 after scrolling is %v, want the red box", got)
+	}
+	if got := img.RGBAAt(50, 110); got == red {
+		t.Error("the scrolled content is not clipped")
+	}
+}
+
+func TestPaintBorders(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	// #b has its border box at 10, 10, 100 by 60 pixels, with a 4 pixel
+	// border.
+	tests := []struct {
+		name string
+		css  string
+		want map[image.Point]color.RGBA
+	}{
+		{
+			name: "solid sides",
+			css:  "border: 4px solid #0000ff; border-left-color: #ff0000;",
+			want: map[image.Point]color.RGBA{{12, 40}: red, {60, 12}: blue, {107, 40}: blue, {60, 67}: blue, {60, 40}: white, {5, 40}: white},
+		},
+		{
+			name: "missing side",
+			css:  "border: 4px solid #0000ff; border-top-style: none;",
+			want: map[image.Point]color.RGBA{{60, 12}: white, {12, 40}: blue},
+		},
+		{
+			name: "current color",
+			css:  "border: 4px solid; color: #00ff00;",
+			want: map[image.Point]color.RGBA{{60, 12}: green},
+		},
+		{
+			// Dashes are three times the width long with gaps as long.
+			name: "dashed",
+			css:  "border: 4px dashed #0000ff;",
+			want: map[image.Point]color.RGBA{{15, 12}: blue, {27, 12}: white, {39, 12}: blue},
+		},
+		{
+			// The middle third of a double border is a gap.
+			name: "double",
+			css:  "border: 6px double #0000ff;",
+			want: map[image.Point]color.RGBA{{60, 10}: blue, {60, 12}: white, {60, 15}: blue},
+		},
+		{
+			name: "inset",
+			css:  "border: 4px inset #0000ff;",
+			want: map[image.Point]color.RGBA{{60, 12}: {0, 0, 127, 255}, {60, 67}: {127, 127, 255, 255}},
+		},
+		{
+			name: "rounded",
+			css:  "border: 4px solid #0000ff; border-radius: 20px; background-color: #ff0000;",
+			want: map[image.Point]color.RGBA{{11, 11}: white, {60, 12}: blue, {16, 16}: blue, {60, 40}: red},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := renderHTML(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#b { margin: 10px; width: 92px; height: 52px; `+tt.css+` }
+</style></head><body><div id="b"></div></body></html>`)
+			for p, want := range tt.want {
+				if got := img.RGBAAt(p.X, p.Y); got != want {
+					t.Errorf("pixel at %v is %v, want %v", p, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"prymis/engine/layout"
+	"prymis/engine/raster"
+	"prymis/engine/text"
+)
+
+// Raster is a Backend that draws into an RGBA image. Layers are drawn into
+// an offscreen image and composited in when popped, so that they can be
+// faded, blended and turned as a whole. Transforms move and scale what is
+// drawn; rectangles take the bounds of their mapped corners.
+type Raster struct {
+	dst   *image.RGBA
+	clip  image.Rectangle
+	m     Matrix
+	saved []rasterState
+}
+
+// rasterState is what a push item replaces and its pop restores.
+type rasterState struct {
+	op    Op
+	dst   *image.RGBA
+	clip  image.Rectangle
+	m     Matrix
+	alpha float32
+	blend raster.BlendMode
+	// layer maps the pixels of a layer to those of dst.
+	layer Matrix
+}
+
+// NewRaster returns a backend drawing into dst.
+func NewRaster(dst *image.RGBA) *Raster {
+	return &Raster{dst: dst, clip: dst.Bounds(), m: Identity}
+}
+
+// pixels returns the device pixels covered by r, a rectangle in user
+// coordinates, within the clip.
+func (r *Raster) pixels(rect layout.Rect) image.Rectangle {
+	b := r.m.Bounds(rect)
+	return image.Rect(int(b.X), int(b.Y), int(b.X+b.Width), int(b.Y+b.Height)).Intersect(r.clip)
+}
+
+// target returns the part of the destination inside the clip.
+func (r *Raster) target() *image.RGBA {
+	return r.dst.SubImage(r.clip).(*image.RGBA)
+}
+
+func (r *Raster) FillRect(rect layout.Rect, c color.Color) {
+	if c == nil {
+		return
+	}
+	draw.Draw(r.dst, r.pixels(rect), image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// mask returns the coverage of p, in user coordinates, within the clip.
+func (r *Raster) mask(p *raster.Path, rule raster.FillRule) *image.Alpha {
+	return p.Transform(r.m).Fill(rule, r.clip)
+}
+
+func (r *Raster) FillPath(p *raster.Path, rule raster.FillRule, c color.Color) {
+	raster.Over(r.dst, r.mask(p, rule), c)
+}
+
+// StrokePath outlines p in device pixels, so that the curves of the
+// outline stay smooth however the transform scales them.
+func (r *Raster) StrokePath(p *raster.Path, s raster.StrokeStyle, c color.Color) {
+	scale := r.scale()
+	s.Width *= scale
+	s.DashOffset *= scale
+	if s.Dashes != nil {
+		dashes := make([]float32, len(s.Dashes))
+		for i, d := range s.Dashes {
+			dashes[i] = d * scale
+		}
+		s.Dashes = dashes
+	}
+	s.Clip = r.clip
+	outline := p.Transform(r.m).Stroke(s)
+	raster.Over(r.dst, outline.Fill(raster.NonZero, r.clip), c)
+}
+
+func (r *Raster) DrawGlyphs(x, y float32, glyphs []text.Glyph, size float32, c color.Color) {
+	x, y = r.m.Apply(x, y)
+	scale := r.scale()
+	if scale != 1 {
+		scaled := make([]text.Glyph, len(glyphs))
+		for i, g := range glyphs {
+			g.X *= scale
+			g.Advance *= scale
+			scaled[i] = g
+		}
+		glyphs, size = scaled, size*scale
+	}
+	text.Draw(r.target(), x, y, glyphs, size, c)
+}
+
+// scale returns how much the current transform scales lengths on average.
+func (r *Raster) scale() float32 {
+	m := r.m
+	return float32(math.Sqrt(math.Abs(float64(m[0]*m[3] - m[1]*m[2]))))
+}
+
+// DrawImage scales img to rect with nearest-neighbour sampling.
+func (r *Raster) DrawImage(rect layout.Rect, img image.Image) {
+	if img == nil {
+		return
+	}
+	dst := r.pixels(rect)
+	full := r.m.Bounds(rect)
+	src := img.Bounds()
+	if full.Width <= 0 || full.Height <= 0 {
+		return
+	}
+	sx := float32(src.Dx()) / full.Width
+	sy := float32(src.Dy()) / full.Height
+	for y := dst.Min.Y; y < dst.Max.Y; y++ {
+		v := src.Min.Y + int((float32(y)+0.5-full.Y)*sy)
+		for x := dst.Min.X; x < dst.Max.X; x++ {
+			u := src.Min.X + int((float32(x)+0.5-full.X)*sx)
+			if !image.Pt(u, v).In(src) {
+				continue
+			}
+			p := image.Rect(x, y, x+1, y+1)
+			draw.Draw(r.dst, p, image.NewUniform(img.At(u, v)), image.Point{}, draw.Over)
+		}
+	}
+}
+
+func (r *Raster) push(op Op, alpha float32) {
+	r.saved = append(r.saved, rasterState{op: op, dst: r.dst, clip: r.clip, m: r.m, alpha: alpha})
+}
+
+// pop restores the state saved by the innermost push of op; pops that do not
+// match are ignored.
+func (r *Raster) pop(op Op) (rasterState, bool) {
+	n := len(r.saved)
+	if n == 0 || r.saved[n-1].op != op {
+		return rasterState{}, false
+	}
+	s := r.saved[n-1]
+	r.saved = r.saved[:n-1]
+	r.dst, r.clip, r.m = s.dst, s.clip, s.m
+	return s, true
+}
+
+func (r *Raster) PushClip(rect layout.Rect) {
+	r.push(OpPushClip, 0)
+	b := r.m.Bounds(rect)
+	x0, y0 := int(math.Floor(float64(b.X))), int(math.Floor(float64(b.Y)))
+	x1, y1 := int(math.Ceil(float64(b.X+b.Width))), int(math.Ceil(float64(b.Y+b.Height)))
+	r.clip = r.clip.Intersect(image.Rect(x0, y0, x1, y1))
+}
+
+func (r *Raster) PopClip() { r.pop(OpPushClip) }
+
+// PushLayer starts drawing into an offscreen image. Its pixels keep the
+// scale of the transform at the layer but not its rotation or skew, which
+// are applied when the layer is composited, so that the layer stays as
+// sharp as drawing straight to the image would be.
+func (r *Raster) PushLayer(opacity float32, m Matrix, blend raster.BlendMode) {
+	a := r.m.Mul(m)
+	r.push(OpPushLayer, opacity)
+	s := &r.saved[len(r.saved)-1]
+	s.blend = blend
+	k := float32(math.Sqrt(math.Abs(float64(a[0]*a[3] - a[1]*a[2]))))
+	toLayer := Matrix{k, 0, 0, k, a[4], a[5]}
+	inv, ok := a.Invert()
+	if k == 0 || !ok {
+		// A layer flattened to nothing draws nowhere.
+		r.dst = image.NewRGBA(image.Rectangle{})
+		r.clip = image.Rectangle{}
+		return
+	}
+	// Only the part of the layer that lands inside the clip can show.
+	fromDevice := toLayer.Mul(inv)
+	s.layer, _ = fromDevice.Invert()
+	b := fromDevice.Bounds(layout.Rect{X: float32(r.clip.Min.X), Y: float32(r.clip.Min.Y), Width: float32(r.clip.Dx()), Height: float32(r.clip.Dy())})
+	rect := image.Rect(int(math.Floor(float64(b.X))), int(math.Floor(float64(b.Y))), int(math.Ceil(float64(b.X+b.Width))), int(math.Ceil(float64(b.Y+b.Height))))
+	r.dst = image.NewRGBA(rect)
+	r.clip = rect
+	r.m = toLayer
+}
+
+// PopLayer composites the layer drawn since the matching push into the image
+// below it.
+func (r *Raster) PopLayer() {
+	layer := r.dst
+	s, ok := r.pop(OpPushLayer)
+	if !ok {
+		return
+	}
+	raster.DrawLayer(r.dst, r.clip, layer, s.layer, s.alpha, s.blend)
+}
+
+func (r *Raster) PushTransform(m Matrix) {
+	r.push(OpPushTransform, 0)
+	r.m = r.m.Mul(m)
+}
+
+func (r *Raster) PopTransform() { r.pop(OpPushTransform) }
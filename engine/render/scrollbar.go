@@ -0,0 +1,55 @@
+package render
+
+import (
+	"image/color"
+
+	"prymis/engine/layout"
+)
+
+// scrollbarWidth is the thickness of the scrollbars, which are drawn over the
+// edge of the content rather than taking room from it.
+const scrollbarWidth = 8
+
+// minThumb is the shortest a scrollbar thumb gets, so that it stays visible
+// on long documents.
+const minThumb = 20
+
+var (
+	trackColor = color.NRGBA{0, 0, 0, 24}
+	thumbColor = color.NRGBA{0, 0, 0, 110}
+)
+
+// scrollbars records the scrollbars of the scrollport port, scrolled to
+// (sx, sy) over scrollable overflow of w by h: along each axis that
+// overflows, or both when always is set, a track with a thumb that shows the
+// visible part.
+func (l *DisplayList) scrollbars(port layout.Rect, sx, sy, w, h float32, always bool) {
+	vertical, horizontal := always || h > port.Height, always || w > port.Width
+	corner := float32(0)
+	if vertical && horizontal {
+		corner = scrollbarWidth
+	}
+	if vertical {
+		track := layout.Rect{X: port.X + port.Width - scrollbarWidth, Y: port.Y, Width: scrollbarWidth, Height: port.Height - corner}
+		pos, size := thumb(track.Height, sy, port.Height, h)
+		l.FillRect(track, trackColor)
+		l.FillRect(layout.Rect{X: track.X + 1, Y: track.Y + pos, Width: track.Width - 2, Height: size}, thumbColor)
+	}
+	if horizontal {
+		track := layout.Rect{X: port.X, Y: port.Y + port.Height - scrollbarWidth, Width: port.Width - corner, Height: scrollbarWidth}
+		pos, size := thumb(track.Width, sx, port.Width, w)
+		l.FillRect(track, trackColor)
+		l.FillRect(layout.Rect{X: track.X + pos, Y: track.Y + 1, Width: size, Height: track.Height - 2}, thumbColor)
+	}
+}
+
+// thumb returns the offset and length of a scrollbar thumb in a track of the
+// given length, for a scrollport of length visible scrolled to scroll over
+// overflow of length total.
+func thumb(track, scroll, visible, total float32) (pos, size float32) {
+	size = min(track, max(minThumb, track*visible/total))
+	if total > visible {
+		pos = (track - size) * scroll / (total - visible)
+	}
+	return pos, size
+}
@@ -0,0 +1,356 @@
+package render
+
+import (
+	"sort"
+	"strconv"
+
+	"prymis/engine/dom"
+	"prymis/engine/layout"
+	"prymis/engine/raster"
+)
+
+// paintLayer is a box painted as a unit on top of the normal flow of its
+// stacking context: a positioned box, or a box that establishes a stacking
+// context of its own.
+type paintLayer struct {
+	box *layout.LayoutBox
+	z   int
+	// context marks a stacking context, which paints the layers inside it
+	// with itself. Positioned boxes with z-index auto do not; their
+	// positioned descendants belong to the enclosing context.
+	context bool
+	layers  []*paintLayer
+	// owner is the block container whose line boxes hold the fragments of
+	// a positioned inline box.
+	owner *layout.LayoutBox
+	// clips are the boxes around the layer that clip it, outermost first.
+	clips []*layout.LayoutBox
+}
+
+// clipChain is what collect knows about the boxes that clip their overflow
+// around the current box: all of them, and how many of those clip absolutely
+// and fixed positioned descendants, whose containing blocks may be further
+// out.
+type clipChain struct {
+	boxes      []*layout.LayoutBox
+	abs, fixed int
+}
+
+// painter records the display list of a layout tree in the painting order
+// of CSS 2.1 Appendix E.
+type painter struct {
+	list *DisplayList
+	// layered holds the boxes that are painted as layers.
+	layered map[*layout.LayoutBox]bool
+	// lineLayer maps the boxes inside a positioned inline box to its layer,
+	// which paints their fragments instead of the line boxes.
+	lineLayer map[*layout.LayoutBox]*paintLayer
+}
+
+// BuildDisplayList records how the layout tree rooted at root, the root
+// stacking context, is painted.
+func BuildDisplayList(root *layout.LayoutBox) *DisplayList {
+	p := &painter{
+		list:      &DisplayList{},
+		layered:   make(map[*layout.LayoutBox]bool),
+		lineLayer: make(map[*layout.LayoutBox]*paintLayer),
+	}
+	top := &paintLayer{box: root, context: true, owner: root}
+	p.collect(root, top, nil, root, clipChain{})
+	p.paintContext(top)
+	return p.list
+}
+
+// collect finds the layers among the descendants of box and adds them to
+// ctx, the stacking context they belong to. inline is the layer of the
+// positioned inline box the children are inside, if any, owner the block
+// container of their line boxes, and clips the boxes that clip them.
+func (p *painter) collect(box *layout.LayoutBox, ctx, inline *paintLayer, owner *layout.LayoutBox, clips clipChain) {
+	for _, child := range box.Children {
+		childCtx, childInline, childOwner := ctx, inline, owner
+		if inline != nil {
+			p.lineLayer[child] = inline
+		}
+		chain, position := clips.boxes, child.StyledNode.Value("position")
+		switch position {
+		case "absolute":
+			chain = chain[:clips.abs]
+		case "fixed":
+			chain = chain[:clips.fixed]
+		}
+		childClips := clipChain{boxes: chain, abs: clips.abs, fixed: clips.fixed}
+		if clipsOverflow(child) {
+			childClips.boxes = append(chain[:len(chain):len(chain)], child)
+		}
+		switch position {
+		case "relative", "absolute", "fixed", "sticky":
+			childClips.abs = len(childClips.boxes)
+		}
+		// A transformed box contains all positioned descendants.
+		if _, ok := child.Transform(); ok {
+			childClips.abs, childClips.fixed = len(childClips.boxes), len(childClips.boxes)
+		}
+		if isLayered(child) {
+			l := &paintLayer{box: child, z: zIndex(child), context: isStackingContext(child), owner: owner, clips: chain}
+			ctx.layers = append(ctx.layers, l)
+			p.layered[child] = true
+			if l.context {
+				childCtx = l
+			}
+			if child.BoxType == layout.InlineNode {
+				childInline = l
+				p.lineLayer[child] = l
+			}
+		}
+		if child.BoxType != layout.InlineNode {
+			childInline, childOwner = nil, child
+		}
+		p.collect(child, childCtx, childInline, childOwner, childClips)
+	}
+}
+
+// isLayered reports whether box is painted as a layer.
+func isLayered(box *layout.LayoutBox) bool {
+	if box.StyledNode.Node.NodeType != dom.ElementNode {
+		return false
+	}
+	switch box.StyledNode.Value("position") {
+	case "relative", "absolute", "fixed", "sticky":
+		return true
+	}
+	return isStackingContext(box)
+}
+
+// isStackingContext reports whether box establishes a stacking context: a
+// positioned box with an integer z-index, a fixed or sticky box, or one with
+// opacity below 1, a transform, a blend mode or isolation.
+func isStackingContext(box *layout.LayoutBox) bool {
+	style := box.StyledNode
+	if style.Parent == nil {
+		return true
+	}
+	switch style.Value("position") {
+	case "fixed", "sticky":
+		return true
+	case "relative", "absolute":
+		if _, err := strconv.Atoi(style.Value("z-index")); err == nil {
+			return true
+		}
+	}
+	if v, err := strconv.ParseFloat(style.Value("opacity"), 64); err == nil && v < 1 {
+		return true
+	}
+	if _, ok := box.Transform(); ok {
+		return true
+	}
+	if m := style.Value("mix-blend-mode"); m != "" && m != "normal" {
+		return true
+	}
+	return style.Value("isolation") == "isolate"
+}
+
+// zIndex returns the stack level of a layer; auto counts as 0.
+func zIndex(box *layout.LayoutBox) int {
+	switch box.StyledNode.Value("position") {
+	case "relative", "absolute", "fixed", "sticky":
+		if z, err := strconv.Atoi(box.StyledNode.Value("z-index")); err == nil {
+			return z
+		}
+	}
+	return 0
+}
+
+// paintContext paints a stacking context: the background of its root, the
+// layers with negative z-index, its normal flow, and then the layers with
+// z-index auto, 0 and above, ordered by z-index and then tree order.
+// A context with opacity below 1, a transform or a blend mode is drawn as
+// a layer.
+func (p *painter) paintContext(l *paintLayer) {
+	style := l.box.StyledNode
+	opacity := float32(1)
+	if v, err := strconv.ParseFloat(style.Value("opacity"), 32); err == nil && v < 1 {
+		opacity = float32(max(0, v))
+	}
+	m, transformed := l.box.Transform()
+	blend, _ := raster.ParseBlendMode(style.Value("mix-blend-mode"))
+	if opacity < 1 || transformed || blend != raster.BlendNormal {
+		p.list.PushLayer(l.box.Dimensions.BorderBox(), opacity, Matrix(m), blend)
+		defer p.list.PopLayer()
+	}
+	sort.SliceStable(l.layers, func(i, j int) bool { return l.layers[i].z < l.layers[j].z })
+	p.paintBackground(l.box)
+	for _, c := range l.layers {
+		if c.z < 0 {
+			p.paintLayer(l, c)
+		}
+	}
+	p.clipped(l.box, func() { p.paintFlow(l) })
+	for _, c := range l.layers {
+		if c.z >= 0 {
+			p.paintLayer(l, c)
+		}
+	}
+	p.paintScrollbars(l.box)
+}
+
+// paintLayer paints l in its stacking context ctx, clipped by the boxes
+// between them that clip it.
+func (p *painter) paintLayer(ctx, l *paintLayer) {
+	clips := l.clips[min(len(l.clips), len(ctx.clips)):]
+	for _, c := range clips {
+		p.pushClip(c)
+	}
+	if l.context {
+		p.paintContext(l)
+	} else {
+		p.paintBackground(l.box)
+		p.clipped(l.box, func() { p.paintFlow(l) })
+		p.paintScrollbars(l.box)
+	}
+	for range clips {
+		p.popClip()
+	}
+}
+
+// paintFlow paints the content of a layer that is not itself layered: the
+// backgrounds of block-level descendants, then floats, then inline content.
+// A positioned inline box paints its fragments from the lines it is on.
+func (p *painter) paintFlow(l *paintLayer) {
+	box := l.box
+	if box.BoxType == layout.InlineNode {
+		p.paintFloats(box)
+		p.paintLines(l.owner, l)
+		return
+	}
+	p.paintBlocks(box)
+	p.paintFloats(box)
+	p.paintInlines(box)
+}
+
+// paintAtomic paints a float or an atomic inline as if it were a stacking
+// context, except that its layers belong to the enclosing one.
+func (p *painter) paintAtomic(box *layout.LayoutBox) {
+	p.paintBackground(box)
+	p.clipped(box, func() {
+		p.paintBlocks(box)
+		p.paintFloats(box)
+		p.paintInlines(box)
+	})
+	p.paintScrollbars(box)
+}
+
+// paintBlocks paints the backgrounds of the block-level descendants of box
+// in normal flow, in tree order.
+func (p *painter) paintBlocks(box *layout.LayoutBox) {
+	for _, child := range box.Children {
+		if p.layered[child] || isFloat(child) || isInlineLevel(child) {
+			continue
+		}
+		p.paintBackground(child)
+		p.clipped(child, func() { p.paintBlocks(child) })
+	}
+}
+
+// paintFloats paints the floats among the descendants of box. Atomic inlines
+// and floats paint the floats inside them themselves.
+func (p *painter) paintFloats(box *layout.LayoutBox) {
+	for _, child := range box.Children {
+		switch {
+		case p.layered[child]:
+		case isFloat(child):
+			p.paintAtomic(child)
+		case child.BoxType != layout.InlineBlockNode:
+			p.clipped(child, func() { p.paintFloats(child) })
+		}
+	}
+}
+
+// paintInlines paints the line boxes of box and its block-level descendants
+// in normal flow.
+func (p *painter) paintInlines(box *layout.LayoutBox) {
+	p.paintLines(box, nil)
+	for _, child := range box.Children {
+		if p.layered[child] || isFloat(child) || isInlineLevel(child) {
+			continue
+		}
+		p.clipped(child, func() { p.paintInlines(child) })
+		p.paintScrollbars(child)
+	}
+}
+
+// paintLines paints the fragments on the line boxes of box that belong to
+// the positioned inline box layer, or to the normal flow when layer is nil.
+func (p *painter) paintLines(box *layout.LayoutBox, layer *paintLayer) {
+	for _, line := range box.Lines {
+		for _, f := range line.Fragments {
+			if p.lineLayer[f.Box] != layer {
+				continue
+			}
+			if f.Kind == layout.AtomicFragment {
+				if !p.layered[f.Box] {
+					p.paintAtomic(f.Box)
+				}
+				continue
+			}
+			p.paintFragment(f)
+		}
+	}
+}
+
+func isFloat(box *layout.LayoutBox) bool {
+	switch box.StyledNode.Value("float") {
+	case "left", "right":
+		return box.BoxType != layout.InlineNode
+	}
+	return false
+}
+
+func isInlineLevel(box *layout.LayoutBox) bool {
+	return box.BoxType == layout.InlineNode || box.BoxType == layout.InlineBlockNode
+}
+
+// clipsOverflow reports whether box clips its content to its padding box.
+// The overflow of the root applies to the viewport instead.
+func clipsOverflow(box *layout.LayoutBox) bool {
+	return box.StyledNode.Parent != nil && box.Overflow() != "visible"
+}
+
+// clipped paints the content of box with paint, clipped and scrolled when box
+// clips its overflow.
+func (p *painter) clipped(box *layout.LayoutBox, paint func()) {
+	if !clipsOverflow(box) {
+		paint()
+		return
+	}
+	p.pushClip(box)
+	paint()
+	p.popClip()
+}
+
+// pushClip clips what follows to the padding box of box and shifts it by how
+// far box is scrolled.
+func (p *painter) pushClip(box *layout.LayoutBox) {
+	sx, sy := box.ScrollOffset()
+	p.list.PushClip(box.Dimensions.PaddingBox())
+	p.list.PushTransform(Translate(-sx, -sy))
+}
+
+func (p *painter) popClip() {
+	p.list.PopTransform()
+	p.list.PopClip()
+}
+
+// paintScrollbars paints the scrollbars of a scroll container the user can
+// scroll: always with overflow: scroll, and where it overflows with auto.
+func (p *painter) paintScrollbars(box *layout.LayoutBox) {
+	if box.StyledNode.Parent == nil {
+		return
+	}
+	overflow := box.Overflow()
+	if overflow != "scroll" && overflow != "auto" {
+		return
+	}
+	sx, sy := box.ScrollOffset()
+	w, h := box.ScrollSize()
+	p.list.scrollbars(box.Dimensions.PaddingBox(), sx, sy, w, h, overflow == "scroll")
+}
@@ -0,0 +1,149 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"prymis/engine/dom"
+	"prymis/engine/layout"
+	"prymis/engine/parser"
+)
+
+// renderHTML lays out html in a page 300 by 200 pixels and paints it.
+func renderHTML(t *testing.T, html string) *image.RGBA {
+	t.Helper()
+	return RenderPage(openPage(t, html, 300, 200).Layout, 300, 200)
+}
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+)
+
+func TestStackingOrder(t *testing.T) {
+	// #a covers 0-100 and #b 50-150 in both directions; the pixel at 75, 75
+	// shows whichever is painted last.
+	tests := []struct {
+		name string
+		css  string
+		html string
+		want color.RGBA
+	}{
+		{
+			name: "tree order",
+			css:  "#a, #b { position: absolute; }",
+			html: `<div id="a"></div><div id="b"></div>`,
+			want: blue,
+		},
+		{
+			name: "z-index",
+			css:  "#a, #b { position: absolute; } #a { z-index: 1; }",
+			html: `<div id="a"></div><div id="b"></div>`,
+			want: red,
+		},
+		{
+			name: "equal z-index keeps tree order",
+			css:  "#a, #b { position: absolute; z-index: 3; }",
+			html: `<div id="a"></div><div id="b"></div>`,
+			want: blue,
+		},
+		{
+			name: "negative z-index under the flow",
+			css:  "#a { position: relative; z-index: -1; } #b { margin-top: -50px; margin-left: 50px; }",
+			html: `<div id="a"></div><div id="b"></div>`,
+			want: blue,
+		},
+		{
+			name: "positioned over the flow",
+			css:  "#a { position: relative; } #b { margin-top: -50px; margin-left: 50px; }",
+			html: `<div id="a"></div><div id="b"></div>`,
+			want: red,
+		},
+		{
+			name: "float over block backgrounds",
+			css:  "#a { float: left; } #b { margin-left: 50px; padding-top: 50px; height: 50px; }",
+			html: `<div id="a"></div><div id="b"></div>`,
+			want: red,
+		},
+		{
+			name: "stacking contexts confine their layers",
+			css: "#c { position: absolute; z-index: 1; } #a { position: absolute; z-index: 100; }" +
+				" #b { position: absolute; z-index: 2; }",
+			html: `<div id="c"><div id="a"></div></div><div id="b"></div>`,
+			want: blue,
+		},
+		{
+			name: "z-index auto does not confine",
+			css: "#c { position: absolute; } #a { position: absolute; z-index: 100; }" +
+				" #b { position: absolute; z-index: 2; }",
+			html: `<div id="c"><div id="a"></div></div><div id="b"></div>`,
+			want: red,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canvas := renderHTML(t, `<html><head><style>
+html, body, div { display: block; margin: 0; }
+#a, #b { width: 100px; height: 100px; }
+#a { background-color: #ff0000; }
+#b { background-color: #0000ff; top: 50px; left: 50px; }
+#c { top: 0; left: 0; }
+`+tt.css+`</style></head><body>`+tt.html+`</body></html>`)
+			if got := canvas.RGBAAt(75, 75); got != tt.want {
+				t.Errorf("pixel at 75, 75 is %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStackingContexts(t *testing.T) {
+	tests := []struct {
+		css     string
+		layered bool
+		context bool
+	}{
+		{"", false, false},
+		{"position: relative;", true, false},
+		{"position: relative; z-index: 0;", true, true},
+		{"position: static; z-index: 1;", false, false},
+		{"position: absolute; z-index: -2;", true, true},
+		{"position: fixed;", true, true},
+		{"position: sticky;", true, true},
+		{"opacity: 0.5;", true, true},
+		{"opacity: 1;", false, false},
+		{"transform: rotate(10deg);", true, true},
+		{"mix-blend-mode: multiply;", true, true},
+		{"isolation: isolate;", true, true},
+	}
+	for _, tt := range tests {
+		doc := dom.NewDocument(parser.NewHTMLParser(`<html><body><div id="x"></div></body></html>`).Parse())
+		page := layout.NewDocument(doc, parser.NewCSSParser("#x { "+tt.css+" }").Parse(), "", nil)
+		page.Update(layout.Dimensions{Content: layout.Rect{Width: 300, Height: 200}})
+		box := findBox(page.Layout, doc.GetElementById("x"))
+		if box == nil {
+			t.Fatalf("%q: no box for #x", tt.css)
+		}
+		if got := isLayered(box); got != tt.layered {
+			t.Errorf("%q: isLayered = %v", tt.css, got)
+		}
+		if got := isStackingContext(box); got != tt.context {
+			t.Errorf("%q: isStackingContext = %v", tt.css, got)
+		}
+		page.Close()
+	}
+}
+
+// findBox returns the first box of n in the tree rooted at b.
+func findBox(b *layout.LayoutBox, n *dom.Node) *layout.LayoutBox {
+	if b.StyledNode != nil && b.StyledNode.Node == n {
+		return b
+	}
+	for _, c := range b.Children {
+		if f := findBox(c, n); f != nil {
+			return f
+		}
+	}
+	return nil
+}
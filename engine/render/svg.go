@@ -0,0 +1,539 @@
+package render
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"prymis/engine/dom"
+	"prymis/engine/layout"
+	"prymis/engine/raster"
+)
+
+// isSVG reports whether box is the outermost element of an inline SVG
+// fragment, which it paints as replaced content.
+func isSVG(box *layout.LayoutBox) bool {
+	n := box.StyledNode.Node
+	return n.NodeType == dom.ElementNode && n.Namespace == dom.SVGNamespace && n.TagName == "svg"
+}
+
+// svgStyle is the inherited presentation of SVG content: how its shapes
+// are filled and stroked.
+type svgStyle struct {
+	fill, stroke               color.Color
+	fillOpacity, strokeOpacity float32
+	opacity                    float32
+	rule                       raster.FillRule
+	line                       raster.StrokeStyle
+	current                    color.Color
+}
+
+// svgViewport is the size of the coordinate system percentages resolve
+// against.
+type svgViewport struct{ w, h float32 }
+
+// paintSVG records the shapes of an inline SVG fragment, scaled from its
+// viewBox into the content box and clipped to it.
+func (p *painter) paintSVG(box *layout.LayoutBox) {
+	n := box.StyledNode.Node
+	content := box.Dimensions.Content
+	if content.Width <= 0 || content.Height <= 0 {
+		return
+	}
+	vp := svgViewport{content.Width, content.Height}
+	m := Translate(content.X, content.Y)
+	if vb := svgNumbers(n.GetAttribute("viewBox")); len(vb) == 4 && vb[2] > 0 && vb[3] > 0 {
+		sx, sy := content.Width/vb[2], content.Height/vb[3]
+		var dx, dy float32
+		if strings.TrimSpace(n.GetAttribute("preserveAspectRatio")) != "none" {
+			// xMidYMid meet: the whole viewBox shows, centered.
+			s := min(sx, sy)
+			dx, dy = (content.Width-vb[2]*s)/2, (content.Height-vb[3]*s)/2
+			sx, sy = s, s
+		}
+		m = m.Mul(Matrix{sx, 0, 0, sy, dx - vb[0]*sx, dy - vb[1]*sy})
+		vp = svgViewport{vb[2], vb[3]}
+	}
+	style := svgStyle{
+		fill:          color.Black,
+		fillOpacity:   1,
+		strokeOpacity: 1,
+		opacity:       1,
+		line:          raster.StrokeStyle{Width: 1},
+		current:       textColor(box.StyledNode),
+	}
+	p.list.PushClip(content)
+	p.list.PushTransform(m)
+	p.paintSVGChildren(n, style, vp)
+	p.list.PopTransform()
+	p.list.PopClip()
+}
+
+func (p *painter) paintSVGChildren(n *dom.Node, style svgStyle, vp svgViewport) {
+	for _, child := range n.Children {
+		if child.NodeType == dom.ElementNode && child.Namespace == dom.SVGNamespace {
+			p.paintSVGElement(child, style, vp)
+		}
+	}
+}
+
+// paintSVGElement records a shape or the content of a group.
+func (p *painter) paintSVGElement(n *dom.Node, style svgStyle, vp svgViewport) {
+	switch n.TagName {
+	case "g", "a", "svg", "switch":
+	case "rect", "circle", "ellipse", "line", "polyline", "polygon", "path":
+	default:
+		// defs, title, text and the rest draw nothing of their own.
+		return
+	}
+	if svgAttr(n, "display") == "none" {
+		return
+	}
+	style = style.apply(n)
+	transformed := false
+	if m, ok := parseSVGTransform(n.GetAttribute("transform")); ok {
+		p.list.PushTransform(m)
+		transformed = true
+	}
+	switch n.TagName {
+	case "g", "a", "svg", "switch":
+		p.paintSVGChildren(n, style, vp)
+	default:
+		if path := svgShape(n, vp); path != nil && svgAttr(n, "visibility") != "hidden" {
+			p.paintSVGShape(path, style)
+		}
+	}
+	if transformed {
+		p.list.PopTransform()
+	}
+}
+
+// paintSVGShape fills and then strokes a shape.
+func (p *painter) paintSVGShape(path *raster.Path, style svgStyle) {
+	lo, hi := path.Bounds()
+	// Lines and other shapes without area have nothing to fill.
+	if c := withAlpha(style.fill, style.fillOpacity*style.opacity); c != nil && hi.X > lo.X && hi.Y > lo.Y {
+		p.list.FillPath(layout.Rect{X: lo.X, Y: lo.Y, Width: hi.X - lo.X, Height: hi.Y - lo.Y}, path, style.rule, c)
+	}
+	if c := withAlpha(style.stroke, style.strokeOpacity*style.opacity); c != nil && style.line.Width > 0 {
+		pad := style.line.Width / 2
+		if style.line.Join == raster.MiterJoin {
+			limit := style.line.MiterLimit
+			if limit <= 0 {
+				limit = 4
+			}
+			pad *= max(1, limit)
+		}
+		r := layout.Rect{X: lo.X - pad, Y: lo.Y - pad, Width: hi.X - lo.X + 2*pad, Height: hi.Y - lo.Y + 2*pad}
+		p.list.StrokePath(r, path, style.line, c)
+	}
+}
+
+// withAlpha returns c with its alpha scaled by a, or nil for no paint.
+func withAlpha(c color.Color, a float32) color.Color {
+	if c == nil || a <= 0 {
+		return nil
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(float32(n.A)*min(1, a) + 0.5)
+	return n
+}
+
+// apply returns s with the presentation attributes of n applied. Group
+// opacity is approximated by multiplying it into the paints.
+func (s svgStyle) apply(n *dom.Node) svgStyle {
+	if v := svgAttr(n, "color"); v != "" && v != "inherit" {
+		s.current = parseColor(v)
+	}
+	paint := func(v string, c color.Color) color.Color {
+		switch strings.ToLower(v) {
+		case "", "inherit":
+			return c
+		case "none":
+			return nil
+		case "currentcolor":
+			return s.current
+		}
+		return parseColor(v)
+	}
+	s.fill = paint(svgAttr(n, "fill"), s.fill)
+	s.stroke = paint(svgAttr(n, "stroke"), s.stroke)
+	number := func(name string, f *float32) {
+		if v, ok := svgNumber(svgAttr(n, name)); ok {
+			*f = v
+		}
+	}
+	number("fill-opacity", &s.fillOpacity)
+	number("stroke-opacity", &s.strokeOpacity)
+	number("stroke-width", &s.line.Width)
+	number("stroke-miterlimit", &s.line.MiterLimit)
+	number("stroke-dashoffset", &s.line.DashOffset)
+	if v, ok := svgNumber(svgAttr(n, "opacity")); ok {
+		s.opacity *= v
+	}
+	switch svgAttr(n, "fill-rule") {
+	case "nonzero":
+		s.rule = raster.NonZero
+	case "evenodd":
+		s.rule = raster.EvenOdd
+	}
+	switch svgAttr(n, "stroke-linejoin") {
+	case "miter":
+		s.line.Join = raster.MiterJoin
+	case "round":
+		s.line.Join = raster.RoundJoin
+	case "bevel":
+		s.line.Join = raster.BevelJoin
+	}
+	switch svgAttr(n, "stroke-linecap") {
+	case "butt":
+		s.line.Cap = raster.ButtCap
+	case "round":
+		s.line.Cap = raster.RoundCap
+	case "square":
+		s.line.Cap = raster.SquareCap
+	}
+	switch v := svgAttr(n, "stroke-dasharray"); v {
+	case "", "inherit":
+	case "none":
+		s.line.Dashes = nil
+	default:
+		s.line.Dashes = svgNumbers(v)
+	}
+	return s
+}
+
+// svgAttr returns a presentation property of n, from its style attribute
+// if set there.
+func svgAttr(n *dom.Node, name string) string {
+	for _, decl := range strings.Split(n.GetAttribute("style"), ";") {
+		if k, v, ok := strings.Cut(decl, ":"); ok && strings.TrimSpace(k) == name {
+			return strings.TrimSpace(v)
+		}
+	}
+	return strings.TrimSpace(n.GetAttribute(name))
+}
+
+// svgShape returns the outline of a basic shape or path element, or nil if
+// it has none.
+func svgShape(n *dom.Node, vp svgViewport) *raster.Path {
+	diag := float32(math.Sqrt(float64(vp.w*vp.w+vp.h*vp.h) / 2))
+	length := func(name string, base float32) float32 {
+		v := strings.TrimSpace(n.GetAttribute(name))
+		if pct, ok := strings.CutSuffix(v, "%"); ok {
+			f, _ := svgNumber(pct)
+			return f * base / 100
+		}
+		f, _ := svgNumber(strings.TrimSuffix(v, "px"))
+		return f
+	}
+	var p raster.Path
+	switch n.TagName {
+	case "rect":
+		x, y := length("x", vp.w), length("y", vp.h)
+		w, h := length("width", vp.w), length("height", vp.h)
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		rx, ry := length("rx", vp.w), length("ry", vp.h)
+		// A missing radius takes the value of the other.
+		if !n.HasAttribute("rx") {
+			rx = ry
+		}
+		if !n.HasAttribute("ry") {
+			ry = rx
+		}
+		rx, ry = max(0, min(rx, w/2)), max(0, min(ry, h/2))
+		p.AddRoundedRect(x, y, x+w, y+h, [4][2]float32{{rx, ry}, {rx, ry}, {rx, ry}, {rx, ry}})
+	case "circle":
+		p.AddEllipse(length("cx", vp.w), length("cy", vp.h), length("r", diag), length("r", diag))
+	case "ellipse":
+		p.AddEllipse(length("cx", vp.w), length("cy", vp.h), length("rx", vp.w), length("ry", vp.h))
+	case "line":
+		p.MoveTo(length("x1", vp.w), length("y1", vp.h))
+		p.LineTo(length("x2", vp.w), length("y2", vp.h))
+	case "polyline", "polygon":
+		pts := svgNumbers(n.GetAttribute("points"))
+		if len(pts) < 2 {
+			return nil
+		}
+		p.MoveTo(pts[0], pts[1])
+		for i := 2; i+1 < len(pts); i += 2 {
+			p.LineTo(pts[i], pts[i+1])
+		}
+		if n.TagName == "polygon" {
+			p.Close()
+		}
+	case "path":
+		return parsePathData(n.GetAttribute("d"))
+	}
+	if p.Empty() {
+		return nil
+	}
+	return &p
+}
+
+// svgNumber parses one SVG number.
+func svgNumber(s string) (float32, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 32)
+	if err != nil {
+		return 0, false
+	}
+	return float32(f), true
+}
+
+// svgNumbers parses a list of numbers separated by spaces or commas.
+func svgNumbers(s string) []float32 {
+	sc := pathScanner{s: s}
+	var out []float32
+	for {
+		f, ok := sc.number()
+		if !ok {
+			return out
+		}
+		out = append(out, f)
+	}
+}
+
+// pathScanner reads the numbers and flags of SVG path data.
+type pathScanner struct {
+	s string
+	i int
+}
+
+// skip passes over white space and a comma.
+func (sc *pathScanner) skip() {
+	comma := false
+	for sc.i < len(sc.s) {
+		switch c := sc.s[sc.i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+		case c == ',' && !comma:
+			comma = true
+		default:
+			return
+		}
+		sc.i++
+	}
+}
+
+// number reads a number, which may run into the next one without a
+// separator, as in "1.5.5" or "1-2".
+func (sc *pathScanner) number() (float32, bool) {
+	sc.skip()
+	start := sc.i
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '+' || sc.s[sc.i] == '-') {
+		sc.i++
+	}
+	digits, dot := false, false
+	for sc.i < len(sc.s) {
+		c := sc.s[sc.i]
+		if c >= '0' && c <= '9' {
+			digits = true
+		} else if c == '.' && !dot {
+			dot = true
+		} else {
+			break
+		}
+		sc.i++
+	}
+	if digits && sc.i < len(sc.s) && (sc.s[sc.i] == 'e' || sc.s[sc.i] == 'E') {
+		j := sc.i + 1
+		if j < len(sc.s) && (sc.s[j] == '+' || sc.s[j] == '-') {
+			j++
+		}
+		if j < len(sc.s) && sc.s[j] >= '0' && sc.s[j] <= '9' {
+			for j < len(sc.s) && sc.s[j] >= '0' && sc.s[j] <= '9' {
+				j++
+			}
+			sc.i = j
+		}
+	}
+	if !digits {
+		sc.i = start
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(sc.s[start:sc.i], 32)
+	if err != nil {
+		sc.i = start
+		return 0, false
+	}
+	return float32(f), true
+}
+
+// flag reads an arc flag, a single 0 or 1.
+func (sc *pathScanner) flag() (bool, bool) {
+	sc.skip()
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '0' || sc.s[sc.i] == '1') {
+		sc.i++
+		return sc.s[sc.i-1] == '1', true
+	}
+	return false, false
+}
+
+// pathArity is the number of arguments of each path command.
+var pathArity = map[byte]int{'m': 2, 'l': 2, 'h': 1, 'v': 1, 'c': 6, 's': 4, 'q': 4, 't': 2, 'a': 7, 'z': 0}
+
+// parsePathData parses the d attribute of a path element. As SVG requires,
+// data after an error is ignored and what came before it is drawn.
+func parsePathData(d string) *raster.Path {
+	var p raster.Path
+	sc := pathScanner{s: d}
+	var cmd byte
+	var start, cur, ctrl raster.Point
+	// The control point reflected by S and T when the previous command was
+	// a curve of the same kind.
+	var lastCurve byte
+	for {
+		sc.skip()
+		if sc.i >= len(sc.s) {
+			break
+		}
+		if c := sc.s[sc.i]; c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+			cmd = c
+			sc.i++
+		} else if cmd == 0 || cmd == 'z' || cmd == 'Z' {
+			break
+		}
+		rel := cmd >= 'a'
+		var args [7]float32
+		lower := cmd | 0x20
+		n, ok := pathArity[lower]
+		if !ok {
+			break
+		}
+		for i := 0; i < n; i++ {
+			var good bool
+			if lower == 'a' && (i == 3 || i == 4) {
+				var f bool
+				f, good = sc.flag()
+				if f {
+					args[i] = 1
+				}
+			} else {
+				args[i], good = sc.number()
+			}
+			if !good {
+				return &p
+			}
+		}
+		pt := func(x, y float32) raster.Point {
+			if rel {
+				return raster.Point{X: cur.X + x, Y: cur.Y + y}
+			}
+			return raster.Point{X: x, Y: y}
+		}
+		curve := byte(0)
+		switch lower {
+		case 'm':
+			cur = pt(args[0], args[1])
+			start = cur
+			p.MoveTo(cur.X, cur.Y)
+			// Further coordinate pairs are implicit line commands.
+			cmd = 'L'
+			if rel {
+				cmd = 'l'
+			}
+		case 'l':
+			cur = pt(args[0], args[1])
+			p.LineTo(cur.X, cur.Y)
+		case 'h':
+			if rel {
+				args[0] += cur.X
+			}
+			cur.X = args[0]
+			p.LineTo(cur.X, cur.Y)
+		case 'v':
+			if rel {
+				args[0] += cur.Y
+			}
+			cur.Y = args[0]
+			p.LineTo(cur.X, cur.Y)
+		case 'c', 's':
+			var c1, c2, end raster.Point
+			if lower == 'c' {
+				c1, c2, end = pt(args[0], args[1]), pt(args[2], args[3]), pt(args[4], args[5])
+			} else {
+				c1 = cur
+				if lastCurve == 'c' {
+					c1 = raster.Point{X: 2*cur.X - ctrl.X, Y: 2*cur.Y - ctrl.Y}
+				}
+				c2, end = pt(args[0], args[1]), pt(args[2], args[3])
+			}
+			p.CubicTo(c1.X, c1.Y, c2.X, c2.Y, end.X, end.Y)
+			cur, ctrl, curve = end, c2, 'c'
+		case 'q', 't':
+			var c1, end raster.Point
+			if lower == 'q' {
+				c1, end = pt(args[0], args[1]), pt(args[2], args[3])
+			} else {
+				c1 = cur
+				if lastCurve == 'q' {
+					c1 = raster.Point{X: 2*cur.X - ctrl.X, Y: 2*cur.Y - ctrl.Y}
+				}
+				end = pt(args[0], args[1])
+			}
+			p.QuadTo(c1.X, c1.Y, end.X, end.Y)
+			cur, ctrl, curve = end, c1, 'q'
+		case 'a':
+			end := pt(args[5], args[6])
+			p.ArcTo(args[0], args[1], args[2], args[3] != 0, args[4] != 0, end.X, end.Y)
+			cur = end
+		case 'z':
+			p.Close()
+			cur = start
+		}
+		lastCurve = curve
+	}
+	if p.Empty() {
+		return nil
+	}
+	return &p
+}
+
+// parseSVGTransform parses the transform attribute of an SVG element, a
+// list of matrix, translate, scale, rotate, skewX and skewY functions
+// applied right to left.
+func parseSVGTransform(s string) (Matrix, bool) {
+	m := Identity
+	found := false
+	for {
+		s = strings.TrimLeft(s, " \t\n\r,")
+		open := strings.IndexByte(s, '(')
+		end := strings.IndexByte(s, ')')
+		if open < 0 || end < open {
+			break
+		}
+		name := strings.TrimSpace(s[:open])
+		args := svgNumbers(s[open+1 : end])
+		s = s[end+1:]
+		var t Matrix
+		switch {
+		case name == "matrix" && len(args) == 6:
+			t = Matrix{args[0], args[1], args[2], args[3], args[4], args[5]}
+		case name == "translate" && len(args) == 1:
+			t = Translate(args[0], 0)
+		case name == "translate" && len(args) == 2:
+			t = Translate(args[0], args[1])
+		case name == "scale" && len(args) == 1:
+			t = Matrix{args[0], 0, 0, args[0], 0, 0}
+		case name == "scale" && len(args) == 2:
+			t = Matrix{args[0], 0, 0, args[1], 0, 0}
+		case name == "rotate" && (len(args) == 1 || len(args) == 3):
+			sin, cos := math.Sincos(float64(args[0]) * math.Pi / 180)
+			t = Matrix{float32(cos), float32(sin), float32(-sin), float32(cos), 0, 0}
+			if len(args) == 3 {
+				t = Translate(args[1], args[2]).Mul(t).Mul(Translate(-args[1], -args[2]))
+			}
+		case name == "skewX" && len(args) == 1:
+			t = Matrix{1, 0, float32(math.Tan(float64(args[0]) * math.Pi / 180)), 1, 0, 0}
+		case name == "skewY" && len(args) == 1:
+			t = Matrix{1, float32(math.Tan(float64(args[0]) * math.Pi / 180)), 0, 1, 0, 0}
+		default:
+			return Identity, false
+		}
+		m = m.Mul(t)
+		found = true
+	}
+	return m, found
+}
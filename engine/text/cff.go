@@ -0,0 +1,471 @@
+package text
+
+// cffFont holds the parts of a CFF table (OpenType fonts with PostScript
+// outlines) that are needed to decode Type 2 charstrings.
+type cffFont struct {
+	charStrings [][]byte
+	globalSubrs [][]byte
+	// localSubrs has one entry for a name-keyed font and one per font DICT
+	// for a CID-keyed font, selected per glyph by fdSelect.
+	localSubrs [][][]byte
+	fdSelect   []byte
+}
+
+// cffIndex reads an INDEX structure at off, returning its items and the
+// offset just past it.
+func cffIndex(b []byte, off int) ([][]byte, int, bool) {
+	count := int(u16(b, off))
+	if off+2 > len(b) {
+		return nil, 0, false
+	}
+	if count == 0 {
+		return nil, off + 2, true
+	}
+	offSize := 0
+	if off+2 < len(b) {
+		offSize = int(b[off+2])
+	}
+	if offSize < 1 || offSize > 4 {
+		return nil, 0, false
+	}
+	offsets := off + 3
+	data := offsets + (count+1)*offSize - 1
+	read := func(i int) int {
+		v := 0
+		for _, c := range slice(b, offsets+i*offSize, offSize) {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	items := make([][]byte, count)
+	for i := range items {
+		start, end := read(i), read(i+1)
+		item := slice(b, data+start, end-start)
+		if item == nil {
+			return nil, 0, false
+		}
+		items[i] = item
+	}
+	return items, data + read(count), true
+}
+
+// cffDict parses a DICT into its operators, keyed by the operator byte or
+// 1200+n for the escaped operator 12 n.
+func cffDict(b []byte) map[int][]float64 {
+	dict := make(map[int][]float64)
+	var operands []float64
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c <= 21:
+			op := int(c)
+			i++
+			if c == 12 && i < len(b) {
+				op = 1200 + int(b[i])
+				i++
+			}
+			dict[op] = operands
+			operands = nil
+		case c == 28:
+			operands = append(operands, float64(int16(u16(b, i+1))))
+			i += 3
+		case c == 29:
+			operands = append(operands, float64(int32(u32(b, i+1))))
+			i += 5
+		case c == 30:
+			// A real number in nibbles; only its integer part matters for
+			// the offsets and sizes used here.
+			i++
+			for i < len(b) && b[i]&0x0f != 0x0f && b[i]>>4 != 0x0f {
+				i++
+			}
+			i++
+			operands = append(operands, 0)
+		case c >= 32 && c <= 246:
+			operands = append(operands, float64(int(c)-139))
+			i++
+		case c >= 247 && c <= 250:
+			operands = append(operands, float64((int(c)-247)*256+int(at(b, i+1))+108))
+			i += 2
+		case c >= 251 && c <= 254:
+			operands = append(operands, float64(-(int(c)-251)*256-int(at(b, i+1))-108))
+			i += 2
+		default:
+			i++
+		}
+	}
+	return dict
+}
+
+func at(b []byte, i int) byte {
+	if i < 0 || i >= len(b) {
+		return 0
+	}
+	return b[i]
+}
+
+func dictInt(d map[int][]float64, op, i int) int {
+	if v := d[op]; i < len(v) {
+		return int(v[i])
+	}
+	return 0
+}
+
+// parseCFF reads the CFF table. Only the first font of the FontSet is used,
+// as OpenType requires.
+func parseCFF(b []byte) (*cffFont, error) {
+	if len(b) < 4 {
+		return nil, ErrInvalidFont
+	}
+	_, off, ok := cffIndex(b, int(b[2])) // Name INDEX
+	if !ok {
+		return nil, ErrInvalidFont
+	}
+	topDicts, off, ok := cffIndex(b, off)
+	if !ok || len(topDicts) == 0 {
+		return nil, ErrInvalidFont
+	}
+	_, off, ok = cffIndex(b, off) // String INDEX
+	if !ok {
+		return nil, ErrInvalidFont
+	}
+	cff := &cffFont{}
+	if cff.globalSubrs, _, ok = cffIndex(b, off); !ok {
+		return nil, ErrInvalidFont
+	}
+
+	top := cffDict(topDicts[0])
+	if v := top[1206]; len(v) > 0 && v[0] != 2 {
+		return nil, ErrUnsupportedFont
+	}
+	if cff.charStrings, _, ok = cffIndex(b, dictInt(top, 17, 0)); !ok || len(cff.charStrings) == 0 {
+		return nil, ErrInvalidFont
+	}
+
+	if _, cid := top[1230]; cid {
+		fontDicts, _, ok := cffIndex(b, dictInt(top, 1236, 0))
+		if !ok {
+			return nil, ErrInvalidFont
+		}
+		for _, fd := range fontDicts {
+			cff.localSubrs = append(cff.localSubrs, privateSubrs(b, cffDict(fd)))
+		}
+		cff.fdSelect = b[min(dictInt(top, 1237, 0), len(b)):]
+	} else {
+		cff.localSubrs = [][][]byte{privateSubrs(b, top)}
+	}
+	return cff, nil
+}
+
+// privateSubrs returns the local subroutines of the Private DICT that dict
+// points to.
+func privateSubrs(b []byte, dict map[int][]float64) [][]byte {
+	size, off := dictInt(dict, 18, 0), dictInt(dict, 18, 1)
+	private := slice(b, off, size)
+	if private == nil {
+		return nil
+	}
+	subrs := dictInt(cffDict(private), 19, 0)
+	if subrs == 0 {
+		return nil
+	}
+	items, _, _ := cffIndex(b, off+subrs)
+	return items
+}
+
+// fontDict returns the font DICT index of glyph g in a CID-keyed font.
+func (c *cffFont) fontDict(g GlyphID) int {
+	if c.fdSelect == nil {
+		return 0
+	}
+	switch at(c.fdSelect, 0) {
+	case 0:
+		return int(at(c.fdSelect, 1+int(g)))
+	case 3:
+		n := int(u16(c.fdSelect, 1))
+		for i := 0; i < n; i++ {
+			r := 3 + 3*i
+			if GlyphID(u16(c.fdSelect, r+3)) > g {
+				return int(at(c.fdSelect, r+2))
+			}
+		}
+	}
+	return 0
+}
+
+func subrBias(n int) int {
+	switch {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	}
+	return 32768
+}
+
+// maxSubrDepth is the subroutine nesting limit of the Type 2 format.
+const maxSubrDepth = 10
+
+// charStringDecoder interprets a Type 2 charstring into a path.
+type charStringDecoder struct {
+	cff       *cffFont
+	local     [][]byte
+	stack     []float32
+	stems     int
+	haveWidth bool
+	done      bool
+	x, y      float32
+	segs      []segment
+}
+
+func (f *Font) cffOutline(g GlyphID) ([]segment, error) {
+	if int(g) >= len(f.cff.charStrings) {
+		return nil, nil
+	}
+	d := &charStringDecoder{cff: f.cff}
+	if fd := f.cff.fontDict(g); fd < len(f.cff.localSubrs) {
+		d.local = f.cff.localSubrs[fd]
+	}
+	if err := d.run(f.cff.charStrings[g], 0); err != nil {
+		return nil, err
+	}
+	return d.segs, nil
+}
+
+func (d *charStringDecoder) moveTo(dx, dy float32) {
+	d.x += dx
+	d.y += dy
+	d.segs = append(d.segs, segment{op: segMove, p: [3]point{{d.x, d.y}}})
+}
+
+func (d *charStringDecoder) lineTo(dx, dy float32) {
+	d.x += dx
+	d.y += dy
+	d.segs = append(d.segs, segment{op: segLine, p: [3]point{{d.x, d.y}}})
+}
+
+func (d *charStringDecoder) curveTo(dx1, dy1, dx2, dy2, dx3, dy3 float32) {
+	p1 := point{d.x + dx1, d.y + dy1}
+	p2 := point{p1.x + dx2, p1.y + dy2}
+	d.x, d.y = p2.x+dx3, p2.y+dy3
+	d.segs = append(d.segs, segment{op: segCubic, p: [3]point{p1, p2, {d.x, d.y}}})
+}
+
+// takeWidth drops the optional advance width that precedes the arguments of
+// the first stack-clearing operator; want is the number of arguments the
+// operator takes, or -1 for stem operators, which take pairs.
+func (d *charStringDecoder) takeWidth(want int) {
+	if d.haveWidth {
+		return
+	}
+	d.haveWidth = true
+	n := len(d.stack)
+	if (want < 0 && n%2 == 1) || (want >= 0 && n > want) {
+		d.stack = d.stack[1:]
+	}
+}
+
+func (d *charStringDecoder) run(cs []byte, depth int) error {
+	if depth > maxSubrDepth {
+		return ErrInvalidFont
+	}
+	for i := 0; i < len(cs) && !d.done; {
+		c := cs[i]
+		i++
+		switch {
+		case c == 28:
+			d.stack = append(d.stack, float32(int16(u16(cs, i))))
+			i += 2
+			continue
+		case c >= 32 && c <= 246:
+			d.stack = append(d.stack, float32(int(c)-139))
+			continue
+		case c >= 247 && c <= 250:
+			d.stack = append(d.stack, float32((int(c)-247)*256+int(at(cs, i))+108))
+			i++
+			continue
+		case c >= 251 && c <= 254:
+			d.stack = append(d.stack, float32(-(int(c)-251)*256-int(at(cs, i))-108))
+			i++
+			continue
+		case c == 255:
+			d.stack = append(d.stack, float32(int32(u32(cs, i)))/65536)
+			i += 4
+			continue
+		}
+		if len(d.stack) > 48 {
+			return ErrInvalidFont
+		}
+
+		s := d.stack
+		switch c {
+		case 1, 3, 18, 23: // hstem, vstem, hstemhm, vstemhm
+			d.takeWidth(-1)
+			d.stems += len(d.stack) / 2
+		case 19, 20: // hintmask, cntrmask
+			d.takeWidth(-1)
+			d.stems += len(d.stack) / 2
+			i += (d.stems + 7) / 8
+		case 21: // rmoveto
+			d.takeWidth(2)
+			s = d.stack
+			if len(s) >= 2 {
+				d.moveTo(s[0], s[1])
+			}
+		case 22: // hmoveto
+			d.takeWidth(1)
+			s = d.stack
+			if len(s) >= 1 {
+				d.moveTo(s[0], 0)
+			}
+		case 4: // vmoveto
+			d.takeWidth(1)
+			s = d.stack
+			if len(s) >= 1 {
+				d.moveTo(0, s[0])
+			}
+		case 5: // rlineto
+			for ; len(s) >= 2; s = s[2:] {
+				d.lineTo(s[0], s[1])
+			}
+		case 6, 7: // hlineto, vlineto alternate horizontal and vertical lines
+			horizontal := c == 6
+			for ; len(s) >= 1; s = s[1:] {
+				if horizontal {
+					d.lineTo(s[0], 0)
+				} else {
+					d.lineTo(0, s[0])
+				}
+				horizontal = !horizontal
+			}
+		case 8: // rrcurveto
+			for ; len(s) >= 6; s = s[6:] {
+				d.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			}
+		case 24: // rcurveline
+			for ; len(s) >= 8; s = s[6:] {
+				d.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			}
+			if len(s) >= 2 {
+				d.lineTo(s[0], s[1])
+			}
+		case 25: // rlinecurve
+			for ; len(s) >= 8; s = s[2:] {
+				d.lineTo(s[0], s[1])
+			}
+			if len(s) >= 6 {
+				d.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			}
+		case 26: // vvcurveto
+			var dx1 float32
+			if len(s)%4 == 1 {
+				dx1, s = s[0], s[1:]
+			}
+			for ; len(s) >= 4; s = s[4:] {
+				d.curveTo(dx1, s[0], s[1], s[2], 0, s[3])
+				dx1 = 0
+			}
+		case 27: // hhcurveto
+			var dy1 float32
+			if len(s)%4 == 1 {
+				dy1, s = s[0], s[1:]
+			}
+			for ; len(s) >= 4; s = s[4:] {
+				d.curveTo(s[0], dy1, s[1], s[2], s[3], 0)
+				dy1 = 0
+			}
+		case 30, 31: // vhcurveto, hvcurveto alternate start tangents
+			horizontal := c == 31
+			for len(s) >= 4 {
+				var last float32
+				if len(s) == 5 {
+					last = s[4]
+				}
+				if horizontal {
+					d.curveTo(s[0], 0, s[1], s[2], last, s[3])
+				} else {
+					d.curveTo(0, s[0], s[1], s[2], s[3], last)
+				}
+				horizontal = !horizontal
+				s = s[4:]
+				if len(s) == 1 {
+					s = nil
+				}
+			}
+		case 10, 29: // callsubr, callgsubr
+			if len(s) == 0 {
+				return ErrInvalidFont
+			}
+			subrs := d.local
+			if c == 29 {
+				subrs = d.cff.globalSubrs
+			}
+			n := int(s[len(s)-1]) + subrBias(len(subrs))
+			d.stack = s[:len(s)-1]
+			if n < 0 || n >= len(subrs) {
+				return ErrInvalidFont
+			}
+			if err := d.run(subrs[n], depth+1); err != nil {
+				return err
+			}
+			continue
+		case 11: // return
+			return nil
+		case 14: // endchar
+			d.takeWidth(0)
+			d.done = true
+		case 12:
+			op := at(cs, i)
+			i++
+			d.flex(op, s)
+		}
+		d.stack = d.stack[:0]
+	}
+	return nil
+}
+
+// flex draws the two curves of the flex operators, which only differ from
+// rrcurveto pairs in hinting.
+func (d *charStringDecoder) flex(op byte, s []float32) {
+	switch op {
+	case 35: // flex
+		if len(s) >= 12 {
+			d.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			d.curveTo(s[6], s[7], s[8], s[9], s[10], s[11])
+		}
+	case 34: // hflex
+		if len(s) >= 7 {
+			y := d.y
+			d.curveTo(s[0], 0, s[1], s[2], s[3], 0)
+			d.curveTo(s[4], 0, s[5], y-d.y, s[6], 0)
+		}
+	case 36: // hflex1
+		if len(s) >= 9 {
+			y := d.y
+			d.curveTo(s[0], s[1], s[2], s[3], s[4], 0)
+			d.curveTo(s[5], 0, s[6], s[7], s[8], y-d.y-s[7])
+		}
+	case 37: // flex1
+		if len(s) >= 11 {
+			x, y := d.x, d.y
+			d.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			d.curveTo(s[6], s[7], s[8], s[9], 0, 0)
+			dx, dy := d.x-x, d.y-y
+			if dx < 0 {
+				dx = -dx
+			}
+			if dy < 0 {
+				dy = -dy
+			}
+			// The last point moves along the dominant direction only and
+			// returns to the start in the other one.
+			last := &d.segs[len(d.segs)-1].p[2]
+			if dx > dy {
+				last.x, last.y = last.x+s[10], y
+			} else {
+				last.x, last.y = x, last.y+s[10]
+			}
+			d.x, d.y = last.x, last.y
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package text
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// indexBytes encodes items as a CFF INDEX with 2-byte offsets.
+func indexBytes(items ...[]byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(items)))
+	if len(items) == 0 {
+		return b
+	}
+	b = append(b, 2)
+	off := 1
+	b = binary.BigEndian.AppendUint16(b, uint16(off))
+	for _, it := range items {
+		off += len(it)
+		b = binary.BigEndian.AppendUint16(b, uint16(off))
+	}
+	for _, it := range items {
+		b = append(b, it...)
+	}
+	return b
+}
+
+// dictInt32 encodes v as a 5-byte DICT operand, so that offsets can be
+// filled in once the table around them is laid out.
+func dictInt32(v int) []byte {
+	return binary.BigEndian.AppendUint32([]byte{29}, uint32(v))
+}
+
+// cffTable builds a name-keyed CFF table with the given charstrings and
+// global and local subroutines.
+func cffTable(charStrings, global, local [][]byte) []byte {
+	header := []byte{1, 0, 4, 1}
+	names := indexBytes([]byte("A"))
+	// Top DICT: CharStrings offset (17) and Private size and offset (18).
+	topLen := len(indexBytes(make([]byte, 5+1+5+5+1)))
+	strs := indexBytes()
+	globals := indexBytes(global...)
+	chars := indexBytes(charStrings...)
+	charsOff := len(header) + len(names) + topLen + len(strs) + len(globals)
+	privateOff := charsOff + len(chars)
+	// Private DICT: Subrs (19) relative to its start, just past it.
+	private := append(dictInt32(6), 19)
+	top := append(dictInt32(charsOff), 17)
+	top = append(top, dictInt32(len(private))...)
+	top = append(top, dictInt32(privateOff)...)
+	top = append(top, 18)
+
+	var b []byte
+	for _, part := range [][]byte{header, names, indexBytes(top), strs, globals, chars, private, indexBytes(local...)} {
+		b = append(b, part...)
+	}
+	return b
+}
+
+// charString encodes numbers as operands and ops, given as strings such
+// as "rmoveto" or raw bytes, as operators.
+func charString(args ...any) []byte {
+	ops := map[string][]byte{
+		"hstem": {1}, "vstem": {3}, "vmoveto": {4}, "rlineto": {5}, "hlineto": {6}, "vlineto": {7},
+		"rrcurveto": {8}, "callsubr": {10}, "return": {11}, "endchar": {14}, "hstemhm": {18},
+		"hintmask": {19}, "rmoveto": {21}, "hmoveto": {22}, "rcurveline": {24}, "rlinecurve": {25},
+		"vvcurveto": {26}, "hhcurveto": {27}, "callgsubr": {29}, "vhcurveto": {30}, "hvcurveto": {31},
+		"hflex": {12, 34}, "flex": {12, 35}, "hflex1": {12, 36}, "flex1": {12, 37},
+	}
+	var b []byte
+	for _, a := range args {
+		switch v := a.(type) {
+		case int:
+			if v >= -107 && v <= 107 {
+				b = append(b, byte(v+139))
+			} else {
+				b = binary.BigEndian.AppendUint16(append(b, 28), uint16(int16(v)))
+			}
+		case float64:
+			b = binary.BigEndian.AppendUint32(append(b, 255), uint32(int32(v*65536)))
+		case string:
+			op, ok := ops[v]
+			if !ok {
+				panic("unknown operator " + v)
+			}
+			b = append(b, op...)
+		case []byte:
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+// outlineString writes an outline as SVG-like path data.
+func outlineString(segs []segment) string {
+	var parts []string
+	for _, s := range segs {
+		switch s.op {
+		case segMove:
+			parts = append(parts, fmt.Sprintf("M%g,%g", s.p[0].x, s.p[0].y))
+		case segLine:
+			parts = append(parts, fmt.Sprintf("L%g,%g", s.p[0].x, s.p[0].y))
+		case segCubic:
+			parts = append(parts, fmt.Sprintf("C%g,%g %g,%g %g,%g", s.p[0].x, s.p[0].y, s.p[1].x, s.p[1].y, s.p[2].x, s.p[2].y))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestCFFIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		want [][]byte
+		next int
+		ok   bool
+	}{
+		{"empty", []byte{0, 0}, nil, 2, true},
+		{"two items", []byte{0, 2, 1, 1, 3, 4, 'a', 'b', 'c'}, [][]byte{[]byte("ab"), []byte("c")}, 9, true},
+		{"empty item", []byte{0, 2, 1, 1, 1, 2, 'x'}, [][]byte{{}, []byte("x")}, 7, true},
+		{"wide offsets", []byte{0, 1, 3, 0, 0, 1, 0, 0, 2, 'z'}, [][]byte{[]byte("z")}, 10, true},
+		{"truncated count", []byte{0}, nil, 0, false},
+		{"offset size 0", []byte{0, 1, 0, 1, 2, 'a'}, nil, 0, false},
+		{"offset size 5", []byte{0, 1, 5, 1, 2, 'a'}, nil, 0, false},
+		{"past the end", []byte{0, 1, 1, 1, 9, 'a'}, nil, 0, false},
+		{"backwards", []byte{0, 2, 1, 2, 1, 3, 'a', 'b'}, nil, 0, false},
+	}
+	for _, tt := range tests {
+		items, next, ok := cffIndex(tt.b, 0)
+		if ok != tt.ok || ok && (next != tt.next || !reflect.DeepEqual(items, tt.want)) {
+			t.Errorf("%s: cffIndex = %q, %d, %v, want %q, %d, %v", tt.name, items, next, ok, tt.want, tt.next, tt.ok)
+		}
+	}
+}
+
+func TestCFFDict(t *testing.T) {
+	b := []byte{
+		139, 247, 0, 251, 0, 17, // 0 108 -108 CharStrings
+		28, 0x12, 0x34, 29, 0xff, 0xff, 0xff, 0xfe, 18, // 4660 -2 Private
+		30, 0x2a, 0x5f, 12, 7, // 2.5 FontMatrix
+		12, 30, // ROS without operands
+	}
+	want := map[int][]float64{17: {0, 108, -108}, 18: {4660, -2}, 1207: {0}, 1230: nil}
+	if got := cffDict(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("cffDict = %v, want %v", got, want)
+	}
+}
+
+func TestCFFCharStrings(t *testing.T) {
+	global := [][]byte{
+		charString(5, 5, "rlineto", "return"),
+		charString("rlineto", "return"),
+		charString(-105, "callgsubr", "return"),
+	}
+	local := [][]byte{charString(10, 20, "rlineto", "return")}
+	tests := []struct {
+		name string
+		cs   []byte
+		want string
+	}{
+		{"width before rmoveto", charString(50, 10, 20, "rmoveto", 30, 40, "rlineto", "endchar"), "M10,20 L40,60"},
+		{"width before endchar", charString(0, 0, "rmoveto", 50, "endchar"), "M0,0"},
+		{"hmoveto and vmoveto", charString(5, "hmoveto", 7, "vmoveto", "endchar"), "M5,0 M5,7"},
+		{"hlineto", charString(0, 0, "rmoveto", 10, 20, 30, "hlineto", "endchar"), "M0,0 L10,0 L10,20 L40,20"},
+		{"vlineto", charString(0, 0, "rmoveto", 10, 20, "vlineto", "endchar"), "M0,0 L0,10 L20,10"},
+		{"rrcurveto", charString(0, 0, "rmoveto", 1, 2, 3, 4, 5, 6, "rrcurveto", "endchar"), "M0,0 C1,2 4,6 9,12"},
+		{"hhcurveto", charString(0, 0, "rmoveto", 7, 1, 2, 3, 4, "hhcurveto", "endchar"), "M0,0 C1,7 3,10 7,10"},
+		{"vvcurveto", charString(0, 0, "rmoveto", 7, 1, 2, 3, 4, "vvcurveto", "endchar"), "M0,0 C7,1 9,4 9,8"},
+		{"vhcurveto", charString(0, 0, "rmoveto", 1, 2, 3, 4, 5, "vhcurveto", "endchar"), "M0,0 C0,1 2,4 6,9"},
+		{"hvcurveto pair", charString(0, 0, "rmoveto", 1, 2, 3, 4, 5, 6, 7, 8, "hvcurveto", "endchar"), "M0,0 C1,0 3,3 3,7 C3,12 9,19 17,19"},
+		{"rcurveline", charString(0, 0, "rmoveto", 1, 1, 1, 1, 1, 1, 5, 0, "rcurveline", "endchar"), "M0,0 C1,1 2,2 3,3 L8,3"},
+		{"rlinecurve", charString(0, 0, "rmoveto", 5, 0, 1, 1, 1, 1, 1, 1, "rlinecurve", "endchar"), "M0,0 L5,0 C6,1 7,2 8,3"},
+		{"fixed point", charString(0.5, 1.25, "rmoveto", "endchar"), "M0.5,1.25"},
+		{"flex", charString(0, 0, "rmoveto", 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 50, "flex", "endchar"), "M0,0 C1,0 2,0 3,0 C4,0 5,0 6,0"},
+		{"hflex", charString(0, 0, "rmoveto", 1, 1, 2, 1, 1, 1, 1, "hflex", "endchar"), "M0,0 C1,0 2,2 3,2 C4,2 5,0 6,0"},
+		{"hflex1", charString(0, 0, "rmoveto", 1, 1, 1, 1, 1, 1, 1, 1, 1, "hflex1", "endchar"), "M0,0 C1,1 2,2 3,2 C4,2 5,3 6,0"},
+		{"hintmask", charString(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, "hstemhm", 13, 14, 15, 16, 17, 18, "hintmask", []byte{14, 14}, 0, 0, "rmoveto", "endchar"), "M0,0"},
+		{"local subroutine", charString(0, 0, "rmoveto", -107, "callsubr", "endchar"), "M0,0 L10,20"},
+		{"global subroutine", charString(0, 0, "rmoveto", -107, "callgsubr", "endchar"), "M0,0 L5,5"},
+		{"arguments on the stack", charString(0, 0, "rmoveto", 3, 4, -106, "callgsubr", "endchar"), "M0,0 L3,4"},
+		{"endchar ends", charString(0, 0, "rmoveto", "endchar", 5, 5, "rlineto"), "M0,0"},
+	}
+	var charStrings [][]byte
+	for _, tt := range tests {
+		charStrings = append(charStrings, tt.cs)
+	}
+	cff, err := parseCFF(cffTable(charStrings, global, local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Font{cff: cff}
+	for i, tt := range tests {
+		segs, err := f.cffOutline(GlyphID(i))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := outlineString(segs); got != tt.want {
+			t.Errorf("%s: outline %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if segs, err := f.cffOutline(GlyphID(len(tests))); segs != nil || err != nil {
+		t.Errorf("glyph past the end = %v, %v", segs, err)
+	}
+
+	bad := []struct {
+		name string
+		cs   []byte
+	}{
+		{"endless recursion", charString(-105, "callgsubr")},
+		{"missing subroutine", charString(500, "callsubr")},
+		{"subroutine without number", charString("callsubr")},
+		{"stack overflow", charString(repeat(1, 49)...)},
+	}
+	for _, tt := range bad {
+		// An rlineto after each makes the stack limit apply.
+		cff, err := parseCFF(cffTable([][]byte{append(tt.cs, 5)}, global, local))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := (&Font{cff: cff}).cffOutline(0); err != ErrInvalidFont {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+}
+
+func repeat(v any, n int) []any {
+	s := make([]any, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+func TestParseCFFMalformed(t *testing.T) {
+	table := cffTable([][]byte{charString(0, 0, "rmoveto", "endchar")}, nil, nil)
+	if _, err := parseCFF(table); err != nil {
+		t.Fatalf("well-formed table: %v", err)
+	}
+	for n := 0; n < len(table)-1; n++ {
+		cff, err := parseCFF(table[:n])
+		if err == nil {
+			// What is left must still decode without panicking.
+			(&Font{cff: cff}).cffOutline(0)
+		}
+	}
+	noCharStrings := cffTable(nil, nil, nil)
+	if _, err := parseCFF(noCharStrings); err != ErrInvalidFont {
+		t.Errorf("table without charstrings: err = %v", err)
+	}
+}
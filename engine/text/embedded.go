@@ -0,0 +1,29 @@
+package text
+
+import "embed"
+
+// The DejaVu faces compiled into the binary, so that text can be measured
+// and drawn without any fonts installed.
+//
+//go:embed fonts/*.ttf
+var embeddedFS embed.FS
+
+// loadEmbedded registers the embedded faces the first time fonts are
+// needed. They are registered before installed fonts so that they win over
+// installed copies of the same faces.
+func (d *fontDB) loadEmbedded() {
+	if d.embeddedLoaded {
+		return
+	}
+	d.embeddedLoaded = true
+	entries, _ := embeddedFS.ReadDir("fonts")
+	for _, e := range entries {
+		data, err := embeddedFS.ReadFile("fonts/" + e.Name())
+		if err != nil {
+			continue
+		}
+		if f, err := Parse(data); err == nil {
+			d.add(d.installed, NewFace(f))
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package text
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Face is a font registered under a family name, with the range of styles
+// it is used for. Fonts from files cover a single weight and width; faces
+// declared by @font-face may cover ranges.
+type Face struct {
+	Family  string
+	Font    *Font
+	Weight  [2]int
+	Stretch [2]float32
+	Italic  bool
+	// Ranges restricts the face to some characters; nil means all.
+	Ranges []RuneRange
+}
+
+// RuneRange is an inclusive range of code points.
+type RuneRange struct{ Lo, Hi rune }
+
+// NewFace returns a face for font under its own family name and style.
+func NewFace(font *Font) *Face {
+	return &Face{
+		Family:  font.family,
+		Font:    font,
+		Weight:  [2]int{font.weight, font.weight},
+		Stretch: [2]float32{font.stretch, font.stretch},
+		Italic:  font.italic,
+	}
+}
+
+// covers reports whether the face can draw r.
+func (f *Face) covers(r rune) bool {
+	if f.Ranges != nil {
+		in := false
+		for _, rr := range f.Ranges {
+			if r >= rr.Lo && r <= rr.Hi {
+				in = true
+				break
+			}
+		}
+		if !in {
+			return false
+		}
+	}
+	return f.Font.GlyphIndex(r) != 0
+}
+
+func (f *Face) sameStyle(o *Face) bool {
+	return f.Weight == o.Weight && f.Stretch == o.Stretch && f.Italic == o.Italic
+}
+
+// Synthesis records the styles that are faked because the chosen face
+// lacks them.
+type Synthesis uint8
+
+const (
+	SyntheticBold Synthesis = 1 << iota
+	SyntheticOblique
+)
+
+// match is a face chosen for a FontSpec.
+type match struct {
+	face  *Face
+	synth Synthesis
+}
+
+// fontDB holds the installed faces that text can be set in, by lowercased
+// family.
+type fontDB struct {
+	sync.Mutex
+	installed      map[string][]*Face
+	embeddedLoaded bool
+	systemLoaded   bool
+
+	// chains and fallbacks cache matching results until faces change, which
+	// bumps generation.
+	chains     map[string][]match
+	fallbacks  map[fallbackKey]*match
+	generation int
+}
+
+// FontSet holds the faces a document declares with @font-face, by
+// lowercased family. Its families hide installed fonts of the same name;
+// other families, and fallback for characters its faces lack, come from the
+// installed fonts. Sets are independent, so the faces of one page never
+// reach another or the browser interface.
+type FontSet struct {
+	faces map[string][]*Face
+	// chains caches the chains of specs naming the set, valid while
+	// generation matches that of the installed fonts.
+	chains     map[string][]match
+	generation int
+}
+
+// NewFontSet returns an empty set of faces.
+func NewFontSet() *FontSet {
+	return &FontSet{faces: map[string][]*Face{}}
+}
+
+// Add makes face available to FontSpecs using the set that name its family,
+// replacing a face previously added with the same family and descriptors.
+func (s *FontSet) Add(face *Face) {
+	db.Lock()
+	defer db.Unlock()
+	s.chains = nil
+	key := strings.ToLower(face.Family)
+	for i, f := range s.faces[key] {
+		if f.sameStyle(face) && sameRanges(f.Ranges, face.Ranges) {
+			s.faces[key][i] = face
+			return
+		}
+	}
+	s.faces[key] = append(s.faces[key], face)
+}
+
+type fallbackKey struct {
+	r       rune
+	weight  int
+	italic  bool
+	stretch float32
+}
+
+var db = fontDB{installed: map[string][]*Face{}}
+
+func (d *fontDB) invalidate() {
+	d.chains, d.fallbacks = nil, nil
+	d.generation++
+}
+
+func (d *fontDB) add(tier map[string][]*Face, face *Face) {
+	key := strings.ToLower(face.Family)
+	tier[key] = append(tier[key], face)
+	d.invalidate()
+}
+
+func sameRanges(a, b []RuneRange) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LocalFont returns an installed font by its full name, PostScript name or
+// family name, as the local() source of @font-face names it.
+func LocalFont(name string) *Font {
+	db.Lock()
+	defer db.Unlock()
+	db.loadInstalled()
+	name = strings.ToLower(name)
+	for _, faces := range db.installed {
+		for _, f := range faces {
+			for _, n := range f.Font.names {
+				if strings.ToLower(n) == name {
+					return f.Font
+				}
+			}
+		}
+	}
+	if faces := db.installed[name]; len(faces) > 0 {
+		return faces[0].Font
+	}
+	return nil
+}
+
+// genericFamilies maps the CSS generic families to the families standing in
+// for them. The embedded DejaVu faces come first so that layout does not
+// depend on the fonts of the machine.
+var genericFamilies = map[string][]string{
+	"sans-serif":    {"DejaVu Sans"},
+	"serif":         {"DejaVu Serif"},
+	"monospace":     {"DejaVu Sans Mono"},
+	"cursive":       {"DejaVu Serif"},
+	"fantasy":       {"DejaVu Sans"},
+	"system-ui":     {"DejaVu Sans"},
+	"ui-sans-serif": {"DejaVu Sans"},
+	"ui-serif":      {"DejaVu Serif"},
+	"ui-monospace":  {"DejaVu Sans Mono"},
+	"ui-rounded":    {"DejaVu Sans"},
+	"emoji":         {"Noto Emoji", "Symbola", "DejaVu Sans"},
+	"math":          {"DejaVu Serif"},
+}
+
+// defaultFamily is used when none of the requested families is available.
+const defaultFamily = "DejaVu Sans"
+
+// family returns the faces of a family in set, if it has any, or else
+// among the installed fonts, loading those the first time a family is not
+// found among the faces already known.
+func (d *fontDB) family(set *FontSet, name string) []*Face {
+	key := strings.ToLower(name)
+	if faces := set.family(key); len(faces) > 0 {
+		return faces
+	}
+	if faces := d.installed[key]; len(faces) > 0 {
+		return faces
+	}
+	if !d.systemLoaded {
+		d.loadInstalled()
+		return d.installed[key]
+	}
+	return nil
+}
+
+// chain returns the faces to try for spec: the matching face of every
+// available family in the list, then of the default family.
+func (d *fontDB) chain(spec FontSpec) []match {
+	key := spec.key()
+	cache := &d.chains
+	if set := spec.Faces; set != nil {
+		if set.generation != d.generation {
+			set.chains, set.generation = nil, d.generation
+		}
+		cache = &set.chains
+	}
+	if c, ok := (*cache)[key]; ok {
+		return c
+	}
+	d.loadEmbedded()
+	var chain []match
+	addFamily := func(name string) {
+		faces := d.family(spec.Faces, name)
+		best := matchFace(faces, spec)
+		if best == nil {
+			return
+		}
+		// Faces with the same descriptors and different unicode-range
+		// values together make up one composite face, the last declared
+		// first.
+		for i := len(faces) - 1; i >= 0; i-- {
+			if f := faces[i]; f == best || f.Ranges != nil && f.sameStyle(best) {
+				chain = append(chain, match{f, synthesisFor(f, spec)})
+			}
+		}
+	}
+	for _, name := range spec.Families {
+		if generic, ok := genericFamilies[strings.ToLower(name)]; ok {
+			for _, g := range generic {
+				addFamily(g)
+			}
+			continue
+		}
+		addFamily(name)
+	}
+	addFamily(defaultFamily)
+	if spec.Faces != nil {
+		// Loading installed fonts on the way moved the generation on.
+		spec.Faces.generation = d.generation
+	}
+	if *cache == nil {
+		*cache = make(map[string][]match)
+	}
+	(*cache)[key] = chain
+	return chain
+}
+
+// family returns the faces of a lowercased family in s, which may be nil.
+func (s *FontSet) family(key string) []*Face {
+	if s == nil {
+		return nil
+	}
+	return s.faces[key]
+}
+
+// forRune picks the face in chain that draws r, falling back to any known
+// family that covers it, and to the primary face when none does.
+func (d *fontDB) forRune(chain []match, r rune, spec FontSpec) match {
+	for _, m := range chain {
+		if m.face.covers(r) {
+			return m
+		}
+	}
+	key := fallbackKey{r, spec.weight(), spec.Italic, spec.stretch()}
+	if m, ok := d.fallbacks[key]; ok {
+		if m == nil {
+			return chain[0]
+		}
+		return *m
+	}
+	d.loadInstalled()
+	var found *match
+	for _, faces := range d.installed {
+		// Ties go to the first family by name, so that the choice does not
+		// depend on map order.
+		if f := matchFace(faces, spec); f != nil && f.covers(r) {
+			if found == nil || f.Family < found.face.Family {
+				found = &match{f, synthesisFor(f, spec)}
+			}
+		}
+	}
+	if d.fallbacks == nil {
+		d.fallbacks = make(map[fallbackKey]*match)
+	}
+	d.fallbacks[key] = found
+	if found == nil {
+		return chain[0]
+	}
+	return *found
+}
+
+// synthesisFor returns the styles to fake when face stands in for spec.
+func synthesisFor(face *Face, spec FontSpec) Synthesis {
+	var s Synthesis
+	if spec.weight() >= 600 && face.Weight[1] <= 500 {
+		s |= SyntheticBold
+	}
+	if spec.Italic && !face.Italic {
+		s |= SyntheticOblique
+	}
+	return s
+}
+
+// matchFace implements the font matching algorithm of CSS Fonts 4 section
+// 5.2: narrow the faces by stretch, then style, then weight.
+func matchFace(faces []*Face, spec FontSpec) *Face {
+	if len(faces) == 0 {
+		return nil
+	}
+	stretch := spec.stretch()
+	faces = closest(faces, stretch, stretch <= 100, func(f *Face) (float32, float32) {
+		return f.Stretch[0], f.Stretch[1]
+	})
+
+	var styled []*Face
+	for _, f := range faces {
+		if f.Italic == spec.Italic {
+			styled = append(styled, f)
+		}
+	}
+	if len(styled) > 0 {
+		faces = styled
+	}
+
+	weight := float32(spec.weight())
+	weightOf := func(f *Face) (float32, float32) { return float32(f.Weight[0]), float32(f.Weight[1]) }
+	if weight >= 400 && weight <= 500 {
+		// Between 400 and 500, heavier weights up to 500 come first.
+		var up []*Face
+		for _, f := range faces {
+			if lo, _ := weightOf(f); lo > weight && lo <= 500 {
+				up = append(up, f)
+			}
+		}
+		if len(up) > 0 && !containsValue(faces, weight, weightOf) {
+			faces = up
+		}
+	}
+	faces = closest(faces, weight, weight <= 500, weightOf)
+	return faces[0]
+}
+
+func containsValue(faces []*Face, v float32, rangeOf func(*Face) (float32, float32)) bool {
+	for _, f := range faces {
+		if lo, hi := rangeOf(f); lo <= v && v <= hi {
+			return true
+		}
+	}
+	return false
+}
+
+// closest returns the faces whose range contains want, or else the faces
+// nearest to it on the preferred side, or else on the other side.
+func closest(faces []*Face, want float32, lowerFirst bool, rangeOf func(*Face) (float32, float32)) []*Face {
+	var exact, below, above []*Face
+	var belowBest, aboveBest float32
+	for _, f := range faces {
+		lo, hi := rangeOf(f)
+		switch {
+		case lo <= want && want <= hi:
+			exact = append(exact, f)
+		case hi < want:
+			if len(below) == 0 || hi > belowBest {
+				below, belowBest = nil, hi
+			}
+			if hi == belowBest {
+				below = append(below, f)
+			}
+		default:
+			if len(above) == 0 || lo < aboveBest {
+				above, aboveBest = nil, lo
+			}
+			if lo == aboveBest {
+				above = append(above, f)
+			}
+		}
+	}
+	switch {
+	case len(exact) > 0:
+		return exact
+	case lowerFirst && len(below) > 0, len(above) == 0:
+		return below
+	}
+	return above
+}
+
+// fontDirs are the directories searched for installed fonts.
+func fontDirs() []string {
+	dirs := []string{"/usr/share/fonts", "/usr/local/share/fonts"}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".local/share/fonts"), filepath.Join(home, ".fonts"))
+	}
+	return dirs
+}
+
+// loadInstalled registers the fonts installed on the system, once.
+func (d *fontDB) loadInstalled() {
+	if d.systemLoaded {
+		return
+	}
+	d.systemLoaded = true
+	d.loadEmbedded()
+	for _, dir := range fontDirs() {
+		filepath.WalkDir(dir, func(path string, e os.DirEntry, err error) error {
+			if err != nil || e.IsDir() {
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".ttf", ".otf", ".ttc", ".otc":
+			default:
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			for i := 0; i < NumFonts(data); i++ {
+				if f, err := ParseIndex(data, i); err == nil && f.family != "" {
+					d.add(d.installed, NewFace(f))
+				}
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package text
+
+// segOp is the kind of a path segment.
+type segOp uint8
+
+const (
+	segMove segOp = iota
+	segLine
+	segQuad
+	segCubic
+)
+
+type point struct{ x, y float32 }
+
+// segment is one element of a glyph outline in font units, y up. A segment
+// uses 1, 2 or 3 of its points depending on the op.
+type segment struct {
+	op segOp
+	p  [3]point
+}
+
+// maxCompositeDepth bounds the nesting of composite glyphs, which would
+// otherwise let a malicious font recurse forever.
+const maxCompositeDepth = 8
+
+// glyfOutline decodes glyph g from the glyf table.
+func (f *Font) glyfOutline(g GlyphID, depth int) ([]segment, error) {
+	if depth > maxCompositeDepth {
+		return nil, ErrInvalidFont
+	}
+	data := f.glyfData(g)
+	if len(data) == 0 {
+		// An empty glyph, such as the space.
+		return nil, nil
+	}
+	if len(data) < 10 {
+		return nil, ErrInvalidFont
+	}
+	contours := int16(u16(data, 0))
+	if contours < 0 {
+		return f.compositeOutline(data, depth)
+	}
+	return simpleOutline(data, int(contours))
+}
+
+// glyfData returns the glyf table bytes of glyph g, located through loca.
+func (f *Font) glyfData(g GlyphID) []byte {
+	glyf, loca := f.tables["glyf"], f.tables["loca"]
+	if int(g) >= f.numGlyphs {
+		return nil
+	}
+	var start, end int
+	if u16(f.tables["head"], 50) == 0 {
+		start, end = 2*int(u16(loca, 2*int(g))), 2*int(u16(loca, 2*int(g)+2))
+	} else {
+		start, end = int(u32(loca, 4*int(g))), int(u32(loca, 4*int(g)+4))
+	}
+	if end <= start {
+		return nil
+	}
+	return slice(glyf, start, end-start)
+}
+
+// Flags of the points of a simple glyph.
+const (
+	flagOnCurve = 0x01
+	flagXShort  = 0x02
+	flagYShort  = 0x04
+	flagRepeat  = 0x08
+	flagXSame   = 0x10
+	flagYSame   = 0x20
+)
+
+func simpleOutline(data []byte, contours int) ([]segment, error) {
+	ends := make([]int, contours)
+	off := 10
+	for i := range ends {
+		ends[i] = int(u16(data, off))
+		off += 2
+	}
+	if contours == 0 {
+		return nil, nil
+	}
+	n := ends[contours-1] + 1
+	off += 2 + int(u16(data, off)) // skip the instructions
+	flags := make([]byte, 0, n)
+	for len(flags) < n {
+		if off >= len(data) {
+			return nil, ErrInvalidFont
+		}
+		fl := data[off]
+		off++
+		flags = append(flags, fl)
+		if fl&flagRepeat != 0 {
+			if off >= len(data) {
+				return nil, ErrInvalidFont
+			}
+			for r := int(data[off]); r > 0 && len(flags) < n; r-- {
+				flags = append(flags, fl)
+			}
+			off++
+		}
+	}
+
+	pts := make([]point, n)
+	var x, y int16
+	for i, fl := range flags {
+		switch {
+		case fl&flagXShort != 0:
+			d := int16(data[min(off, len(data)-1)])
+			off++
+			if fl&flagXSame == 0 {
+				d = -d
+			}
+			x += d
+		case fl&flagXSame == 0:
+			x += int16(u16(data, off))
+			off += 2
+		}
+		pts[i].x = float32(x)
+	}
+	for i, fl := range flags {
+		switch {
+		case fl&flagYShort != 0:
+			d := int16(data[min(off, len(data)-1)])
+			off++
+			if fl&flagYSame == 0 {
+				d = -d
+			}
+			y += d
+		case fl&flagYSame == 0:
+			y += int16(u16(data, off))
+			off += 2
+		}
+		pts[i].y = float32(y)
+	}
+	if off > len(data) {
+		return nil, ErrInvalidFont
+	}
+
+	var segs []segment
+	start := 0
+	for _, end := range ends {
+		if end < start || end >= n {
+			return nil, ErrInvalidFont
+		}
+		segs = appendContour(segs, pts[start:end+1], flags[start:end+1])
+		start = end + 1
+	}
+	return segs, nil
+}
+
+// appendContour converts a closed quadratic contour, in which two off-curve
+// points in a row imply an on-curve point half way between them.
+func appendContour(segs []segment, pts []point, flags []byte) []segment {
+	n := len(pts)
+	if n == 0 {
+		return segs
+	}
+	mid := func(a, b point) point { return point{(a.x + b.x) / 2, (a.y + b.y) / 2} }
+
+	// Start at the first on-curve point, or between the last and the first
+	// point when all of them are off the curve.
+	first, count := -1, n
+	for i := range pts {
+		if flags[i]&flagOnCurve != 0 {
+			first, count = i, n-1
+			break
+		}
+	}
+	var start point
+	if first >= 0 {
+		start = pts[first]
+	} else {
+		start = mid(pts[n-1], pts[0])
+	}
+	segs = append(segs, segment{op: segMove, p: [3]point{start}})
+
+	var ctrl point
+	haveCtrl := false
+	for k := 0; k < count; k++ {
+		i := (first + 1 + k) % n
+		p := pts[i]
+		switch {
+		case flags[i]&flagOnCurve != 0 && haveCtrl:
+			segs = append(segs, segment{op: segQuad, p: [3]point{ctrl, p}})
+			haveCtrl = false
+		case flags[i]&flagOnCurve != 0:
+			segs = append(segs, segment{op: segLine, p: [3]point{p}})
+		default:
+			if haveCtrl {
+				segs = append(segs, segment{op: segQuad, p: [3]point{ctrl, mid(ctrl, p)}})
+			}
+			ctrl, haveCtrl = p, true
+		}
+	}
+	if haveCtrl {
+		return append(segs, segment{op: segQuad, p: [3]point{ctrl, start}})
+	}
+	return append(segs, segment{op: segLine, p: [3]point{start}})
+}
+
+// Flags of the components of a composite glyph.
+const (
+	compArgsAreWords   = 0x0001
+	compArgsAreXY      = 0x0002
+	compHaveScale      = 0x0008
+	compMoreComponents = 0x0020
+	compHaveXYScale    = 0x0040
+	compHave2x2        = 0x0080
+	compScaledOffset   = 0x0800
+	compUnscaledOffset = 0x1000
+)
+
+// compositeOutline assembles a glyph from transformed component glyphs.
+// Components positioned by matching points rather than offsets are placed
+// at the origin.
+func (f *Font) compositeOutline(data []byte, depth int) ([]segment, error) {
+	var segs []segment
+	off := 10
+	for {
+		if off+4 > len(data) {
+			return nil, ErrInvalidFont
+		}
+		flags := u16(data, off)
+		component := GlyphID(u16(data, off+2))
+		off += 4
+		var dx, dy float32
+		if flags&compArgsAreWords != 0 {
+			dx, dy = float32(int16(u16(data, off))), float32(int16(u16(data, off+2)))
+			off += 4
+		} else {
+			dx, dy = float32(int8(data[min(off, len(data)-1)])), float32(int8(data[min(off+1, len(data)-1)]))
+			off += 2
+		}
+		if flags&compArgsAreXY == 0 {
+			dx, dy = 0, 0
+		}
+		a, b, c, d := float32(1), float32(0), float32(0), float32(1)
+		f2dot14 := func(o int) float32 { return float32(int16(u16(data, o))) / 16384 }
+		switch {
+		case flags&compHaveScale != 0:
+			a = f2dot14(off)
+			d = a
+			off += 2
+		case flags&compHaveXYScale != 0:
+			a, d = f2dot14(off), f2dot14(off+2)
+			off += 4
+		case flags&compHave2x2 != 0:
+			a, b, c, d = f2dot14(off), f2dot14(off+2), f2dot14(off+4), f2dot14(off+6)
+			off += 8
+		}
+		if flags&compScaledOffset != 0 && flags&compUnscaledOffset == 0 {
+			dx, dy = a*dx+c*dy, b*dx+d*dy
+		}
+
+		sub, err := f.glyfOutline(component, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sub {
+			for i := range s.p {
+				p := s.p[i]
+				s.p[i] = point{a*p.x + c*p.y + dx, b*p.x + d*p.y + dy}
+			}
+			segs = append(segs, s)
+		}
+		if flags&compMoreComponents == 0 {
+			return segs, nil
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package text
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"prymis/engine/raster"
+)
+
+// outline returns the outline of glyph g in font units.
+func (f *Font) outline(g GlyphID) ([]segment, error) {
+	if f.cff != nil {
+		return f.cffOutline(g)
+	}
+	return f.glyfOutline(g, 0)
+}
+
+// subpixelSteps is the number of horizontal positions within a pixel that
+// glyphs are rendered at, so that kerned and justified text keeps its
+// spacing without rendering every glyph at every position.
+const subpixelSteps = 4
+
+// obliqueSlant is the horizontal shear of synthesized oblique faces, about
+// the 14 degrees of font-style: oblique.
+const obliqueSlant = 0.25
+
+// maxGlyphExtent bounds how far from the pen position, in pixels, any glyph
+// mask reaches, whatever the size.
+const maxGlyphExtent = 2048
+
+// rasterize renders glyph g at size pixels per em with the pen dx pixels
+// right of the origin. The mask's bounds are relative to the pen position
+// on the baseline, y down, and clipped to a few ems around it; it is nil for
+// a glyph without outline.
+func (f *Font) rasterize(g GlyphID, size, dx float32, synth Synthesis) *image.Alpha {
+	segs, err := f.outline(g)
+	if err != nil || len(segs) == 0 {
+		return nil
+	}
+	scale := size / f.unitsPerEm
+	slant := float32(0)
+	if synth&SyntheticOblique != 0 {
+		slant = obliqueSlant
+	}
+	minX, minY := float32(math.Inf(1)), float32(math.Inf(1))
+	maxX, maxY := float32(math.Inf(-1)), float32(math.Inf(-1))
+	for i := range segs {
+		s := &segs[i]
+		for j := range s.p[:pointsOf(s.op)] {
+			p := &s.p[j]
+			p.x, p.y = (p.x+slant*p.y)*scale+dx, -p.y*scale
+			minX, maxX = min(minX, p.x), max(maxX, p.x)
+			minY, maxY = min(minY, p.y), max(maxY, p.y)
+		}
+	}
+	// Outlines of broken fonts can reach far beyond the em box; no real
+	// glyph reaches further than a few ems from the pen.
+	reach := int(min(4*size+4, maxGlyphExtent))
+	b := image.Rect(int(math.Floor(float64(max(minX, -float32(reach))))), int(math.Floor(float64(max(minY, -float32(reach))))),
+		int(math.Ceil(float64(min(maxX, float32(reach))))), int(math.Ceil(float64(min(maxY, float32(reach))))))
+	if b.Empty() {
+		return nil
+	}
+	var path raster.Path
+	for _, s := range segs {
+		switch s.op {
+		case segMove:
+			path.MoveTo(s.p[0].x, s.p[0].y)
+		case segLine:
+			path.LineTo(s.p[0].x, s.p[0].y)
+		case segQuad:
+			path.QuadTo(s.p[0].x, s.p[0].y, s.p[1].x, s.p[1].y)
+		case segCubic:
+			path.CubicTo(s.p[0].x, s.p[0].y, s.p[1].x, s.p[1].y, s.p[2].x, s.p[2].y)
+		}
+	}
+	m := path.Fill(raster.NonZero, b)
+	if m == nil {
+		return nil
+	}
+	if synth&SyntheticBold != 0 {
+		m = embolden(m, size/24)
+	}
+	return m
+}
+
+// embolden thickens the strokes of a glyph mask by smearing it w pixels to
+// the right.
+func embolden(m *image.Alpha, w float32) *image.Alpha {
+	full := int(w)
+	frac := w - float32(full)
+	b := m.Rect
+	b.Max.X += full + 1
+	out := image.NewAlpha(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var a float32
+			for j := 0; j <= full; j++ {
+				a = max(a, float32(m.AlphaAt(x-j, y).A))
+			}
+			a = max(a, frac*float32(m.AlphaAt(x-full-1, y).A))
+			out.Pix[out.PixOffset(x, y)] = uint8(a)
+		}
+	}
+	return out
+}
+
+func pointsOf(op segOp) int {
+	switch op {
+	case segQuad:
+		return 2
+	case segCubic:
+		return 3
+	}
+	return 1
+}
+
+type glyphKey struct {
+	font     *Font
+	id       GlyphID
+	size     float32
+	subpixel uint8
+	synth    Synthesis
+}
+
+// maxCachedGlyphs bounds the glyph cache; when it fills up it starts over.
+const maxCachedGlyphs = 4096
+
+var glyphCache struct {
+	sync.Mutex
+	masks map[glyphKey]*image.Alpha
+}
+
+// GlyphMask returns the anti-aliased coverage of glyph g at size pixels per
+// em, for a pen position whose fractional part is x, with the styles in
+// synth faked. The bounds of the mask are relative to the pen position on
+// the baseline. Masks are cached.
+func (f *Font) GlyphMask(g GlyphID, size, x float32, synth Synthesis) *image.Alpha {
+	frac := x - float32(math.Floor(float64(x)))
+	key := glyphKey{f, g, size, uint8(frac * subpixelSteps), synth}
+	glyphCache.Lock()
+	m, ok := glyphCache.masks[key]
+	glyphCache.Unlock()
+	if ok {
+		return m
+	}
+	m = f.rasterize(g, size, float32(key.subpixel)/subpixelSteps, synth)
+	glyphCache.Lock()
+	if glyphCache.masks == nil || len(glyphCache.masks) >= maxCachedGlyphs {
+		glyphCache.masks = make(map[glyphKey]*image.Alpha)
+	}
+	glyphCache.masks[key] = m
+	glyphCache.Unlock()
+	return m
+}
+
+// Draw paints shaped glyphs in color c with the start of the run at x on the
+// baseline y.
+func Draw(dst draw.Image, x, y float32, glyphs []Glyph, size float32, c color.Color) {
+	src := image.NewUniform(c)
+	baseline := int(math.Round(float64(y)))
+	for _, g := range glyphs {
+		if g.Font == nil {
+			continue
+		}
+		pen := x + g.X
+		m := g.Font.GlyphMask(g.ID, size, pen, g.Synthesis)
+		if m == nil {
+			continue
+		}
+		r := m.Rect.Add(image.Pt(int(math.Floor(float64(pen))), baseline))
+		if rgba, ok := dst.(*image.RGBA); ok {
+			raster.Over(rgba, &image.Alpha{Pix: m.Pix, Stride: m.Stride, Rect: r}, c)
+			continue
+		}
+		draw.DrawMask(dst, r, src, image.Point{}, m, m.Rect.Min, draw.Over)
+	}
+}
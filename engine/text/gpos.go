@@ -0,0 +1,174 @@
+package text
+
+import "sort"
+
+// pairPos is a GPOS pair adjustment subtable (lookup type 2) reduced to the
+// horizontal advance of the first glyph, which is what kerning changes.
+type pairPos struct {
+	data     []byte
+	format   uint16
+	coverage []byte
+	// Offset of XAdvance inside the first value record, or -1, and the size
+	// of both value records.
+	xAdvance   int
+	recordSize int
+	classDef1  []byte
+	classDef2  []byte
+	class2s    int
+}
+
+// parseGPOS collects the pair adjustment lookups of the kern feature.
+func (f *Font) parseGPOS() {
+	gpos := f.tables["GPOS"]
+	if len(gpos) < 10 {
+		return
+	}
+	features := slice(gpos, int(u16(gpos, 6)), len(gpos)-int(u16(gpos, 6)))
+	lookups := slice(gpos, int(u16(gpos, 8)), len(gpos)-int(u16(gpos, 8)))
+	if features == nil || lookups == nil {
+		return
+	}
+	seen := map[uint16]bool{}
+	var indices []uint16
+	for i := 0; i < int(u16(features, 0)); i++ {
+		rec := 2 + 6*i
+		if string(slice(features, rec, 4)) != "kern" {
+			continue
+		}
+		feature := int(u16(features, rec+4))
+		for j := 0; j < int(u16(features, feature+2)); j++ {
+			idx := u16(features, feature+4+2*j)
+			if !seen[idx] {
+				seen[idx] = true
+				indices = append(indices, idx)
+			}
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	for _, idx := range indices {
+		lookup := int(u16(lookups, 2+2*int(idx)))
+		kind := u16(lookups, lookup)
+		var subtables []pairPos
+		for s := 0; s < int(u16(lookups, lookup+4)); s++ {
+			off := lookup + int(u16(lookups, lookup+6+2*s))
+			kind := kind
+			if kind == 9 {
+				// Extension lookup pointing at the real subtable.
+				kind = u16(lookups, off+2)
+				off += int(u32(lookups, off+4))
+			}
+			if kind != 2 || off >= len(lookups) {
+				continue
+			}
+			if p, ok := parsePairPos(lookups[off:]); ok {
+				subtables = append(subtables, p)
+			}
+		}
+		if len(subtables) > 0 {
+			f.gposKern = append(f.gposKern, subtables)
+		}
+	}
+}
+
+func parsePairPos(b []byte) (pairPos, bool) {
+	p := pairPos{data: b, format: u16(b, 0)}
+	cov := int(u16(b, 2))
+	if cov >= len(b) {
+		return p, false
+	}
+	p.coverage = b[cov:]
+	vf1, vf2 := u16(b, 4), u16(b, 6)
+	p.xAdvance = -1
+	if vf1&4 != 0 {
+		p.xAdvance = 2 * popcount(vf1&3)
+	}
+	p.recordSize = 2*popcount(vf1) + 2*popcount(vf2)
+	switch p.format {
+	case 1:
+		return p, true
+	case 2:
+		c1, c2 := int(u16(b, 8)), int(u16(b, 10))
+		if c1 >= len(b) || c2 >= len(b) {
+			return p, false
+		}
+		p.classDef1, p.classDef2 = b[c1:], b[c2:]
+		p.class2s = int(u16(b, 14))
+		return p, true
+	}
+	return p, false
+}
+
+func popcount(v uint16) int {
+	n := 0
+	for ; v != 0; v &= v - 1 {
+		n++
+	}
+	return n
+}
+
+// lookup returns the adjustment for the pair (a, b) and whether the subtable
+// applies to it.
+func (p *pairPos) lookup(a, b GlyphID) (int16, bool) {
+	ci, ok := coverageIndex(p.coverage, a)
+	if !ok {
+		return 0, false
+	}
+	switch p.format {
+	case 1:
+		set := int(u16(p.data, 10+2*ci))
+		count := int(u16(p.data, set))
+		rec := 2 + p.recordSize
+		i := sort.Search(count, func(i int) bool { return GlyphID(u16(p.data, set+2+rec*i)) >= b })
+		if i == count || GlyphID(u16(p.data, set+2+rec*i)) != b {
+			return 0, false
+		}
+		if p.xAdvance < 0 {
+			return 0, true
+		}
+		return int16(u16(p.data, set+2+rec*i+2+p.xAdvance)), true
+	case 2:
+		c1, c2 := classOf(p.classDef1, a), classOf(p.classDef2, b)
+		if p.xAdvance < 0 {
+			return 0, true
+		}
+		off := 16 + (c1*p.class2s+c2)*p.recordSize + p.xAdvance
+		return int16(u16(p.data, off)), true
+	}
+	return 0, false
+}
+
+func coverageIndex(cov []byte, g GlyphID) (int, bool) {
+	switch u16(cov, 0) {
+	case 1:
+		n := int(u16(cov, 2))
+		i := sort.Search(n, func(i int) bool { return GlyphID(u16(cov, 4+2*i)) >= g })
+		if i < n && GlyphID(u16(cov, 4+2*i)) == g {
+			return i, true
+		}
+	case 2:
+		n := int(u16(cov, 2))
+		i := sort.Search(n, func(i int) bool { return GlyphID(u16(cov, 4+6*i+2)) >= g })
+		if i < n && GlyphID(u16(cov, 4+6*i)) <= g {
+			return int(u16(cov, 4+6*i+4)) + int(g-GlyphID(u16(cov, 4+6*i))), true
+		}
+	}
+	return 0, false
+}
+
+func classOf(def []byte, g GlyphID) int {
+	switch u16(def, 0) {
+	case 1:
+		start := GlyphID(u16(def, 2))
+		n := int(u16(def, 4))
+		if g >= start && int(g-start) < n {
+			return int(u16(def, 6+2*int(g-start)))
+		}
+	case 2:
+		n := int(u16(def, 2))
+		i := sort.Search(n, func(i int) bool { return GlyphID(u16(def, 4+6*i+2)) >= g })
+		if i < n && GlyphID(u16(def, 4+6*i)) <= g {
+			return int(u16(def, 4+6*i+4))
+		}
+	}
+	return 0
+}
@@ -0,0 +1,394 @@
+package text
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"unicode/utf16"
+)
+
+var (
+	ErrInvalidFont     = errors.New("text: malformed font data")
+	ErrUnsupportedFont = errors.New("text: unsupported font format")
+)
+
+// GlyphID is a glyph index in a font. Glyph 0 is the .notdef glyph drawn for
+// characters the font does not cover.
+type GlyphID uint16
+
+// Font is a parsed TrueType or OpenType (sfnt) font.
+type Font struct {
+	// data is the file the font was parsed from and index its number in it.
+	data   []byte
+	index  int
+	tables map[string][]byte
+
+	unitsPerEm  float32
+	numGlyphs   int
+	numHMetrics int
+	hmtx        []byte
+
+	ascent, descent, lineGap int16
+	xHeight, capHeight       int16
+	weight                   int
+	stretch                  float32
+	italic                   bool
+	family                   string
+	// names holds the full and PostScript names that local() matches.
+	names []string
+
+	cmap    []byte
+	cmapFmt uint16
+	kern    map[uint32]int16
+	// gposKern holds the subtables of each kern lookup.
+	gposKern [][]pairPos
+
+	// cff is set for fonts with PostScript outlines.
+	cff *cffFont
+}
+
+func u16(b []byte, off int) uint16 {
+	if off < 0 || off+2 > len(b) {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b[off:])
+}
+
+func u32(b []byte, off int) uint32 {
+	if off < 0 || off+4 > len(b) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b[off:])
+}
+
+// slice returns b[off:off+n], or nil when it is out of range.
+func slice(b []byte, off, n int) []byte {
+	if off < 0 || n < 0 || off+n > len(b) {
+		return nil
+	}
+	return b[off : off+n]
+}
+
+// Parse parses an sfnt font. For a font collection the first font is used.
+func Parse(data []byte) (*Font, error) {
+	return ParseIndex(data, 0)
+}
+
+// Data returns the font file f was parsed from and the number of f in it,
+// from which ParseIndex parses f again.
+func (f *Font) Data() (data []byte, index int) {
+	return f.data, f.index
+}
+
+// NumFonts returns the number of fonts in a font collection, or 1 for a
+// single font.
+func NumFonts(data []byte) int {
+	if len(data) >= 12 && string(data[:4]) == "ttcf" {
+		return int(u32(data, 8))
+	}
+	return 1
+}
+
+// ParseIndex parses font number index of a collection, or a single font when
+// index is 0.
+func ParseIndex(data []byte, index int) (*Font, error) {
+	if len(data) < 12 {
+		return nil, ErrInvalidFont
+	}
+	offset := 0
+	if string(data[:4]) == "ttcf" {
+		if index >= int(u32(data, 8)) {
+			return nil, ErrInvalidFont
+		}
+		offset = int(u32(data, 12+4*index))
+	} else if index != 0 {
+		return nil, ErrInvalidFont
+	}
+	switch u32(data, offset) {
+	case 0x00010000, 0x74727565, 0x4f54544f: // 1.0, "true", "OTTO"
+	default:
+		return nil, ErrUnsupportedFont
+	}
+
+	f := &Font{data: data, index: index, tables: make(map[string][]byte), weight: 400, stretch: 100}
+	n := int(u16(data, offset+4))
+	for i := 0; i < n; i++ {
+		rec := slice(data, offset+12+16*i, 16)
+		if rec == nil {
+			return nil, ErrInvalidFont
+		}
+		t := slice(data, int(u32(rec, 8)), int(u32(rec, 12)))
+		if t == nil {
+			return nil, ErrInvalidFont
+		}
+		f.tables[string(rec[:4])] = t
+	}
+	if err := f.parseMetrics(); err != nil {
+		return nil, err
+	}
+	if err := f.parseCmap(); err != nil {
+		return nil, err
+	}
+	if b, ok := f.tables["CFF "]; ok {
+		cff, err := parseCFF(b)
+		if err != nil {
+			return nil, err
+		}
+		f.cff = cff
+	} else if f.tables["glyf"] == nil || f.tables["loca"] == nil {
+		return nil, ErrUnsupportedFont
+	}
+	f.parseName()
+	f.parseKern()
+	f.parseGPOS()
+	return f, nil
+}
+
+func (f *Font) parseMetrics() error {
+	head, hhea, maxp := f.tables["head"], f.tables["hhea"], f.tables["maxp"]
+	f.hmtx = f.tables["hmtx"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 || f.hmtx == nil {
+		return ErrInvalidFont
+	}
+	// The OpenType spec allows 16 to 16384 units per em; outside that the
+	// scale to pixels is meaningless and could blow glyphs up without bound.
+	f.unitsPerEm = float32(u16(head, 18))
+	if f.unitsPerEm < 16 || f.unitsPerEm > 16384 {
+		return ErrInvalidFont
+	}
+	f.numGlyphs = int(u16(maxp, 4))
+	f.numHMetrics = int(u16(hhea, 34))
+	if f.numHMetrics == 0 || 4*f.numHMetrics > len(f.hmtx) {
+		return ErrInvalidFont
+	}
+	f.ascent = int16(u16(hhea, 4))
+	f.descent = int16(u16(hhea, 6))
+	f.lineGap = int16(u16(hhea, 8))
+	if macStyle := u16(head, 44); macStyle&2 != 0 {
+		f.italic = true
+	}
+
+	if os2 := f.tables["OS/2"]; len(os2) >= 78 {
+		f.weight = int(u16(os2, 4))
+		if w := int(u16(os2, 6)); w >= 1 && w <= 9 {
+			f.stretch = widthClasses[w-1]
+		}
+		fsSelection := u16(os2, 62)
+		f.italic = f.italic || fsSelection&1 != 0 || fsSelection&0x200 != 0
+		if fsSelection&0x80 != 0 {
+			// USE_TYPO_METRICS
+			f.ascent = int16(u16(os2, 68))
+			f.descent = int16(u16(os2, 70))
+			f.lineGap = int16(u16(os2, 72))
+		}
+		if u16(os2, 0) >= 2 && len(os2) >= 90 {
+			f.xHeight = int16(u16(os2, 86))
+			f.capHeight = int16(u16(os2, 88))
+		}
+	}
+	if f.xHeight == 0 {
+		f.xHeight = int16(f.unitsPerEm / 2)
+	}
+	if f.capHeight == 0 {
+		f.capHeight = int16(f.unitsPerEm * 0.7)
+	}
+	return nil
+}
+
+// widthClasses are the font-stretch percentages of usWidthClass 1 to 9.
+var widthClasses = [9]float32{50, 62.5, 75, 87.5, 100, 112.5, 125, 150, 200}
+
+// parseCmap picks the best Unicode character map: a full-range format 12
+// table if there is one, otherwise a BMP format 4 table.
+func (f *Font) parseCmap() error {
+	cmap := f.tables["cmap"]
+	if len(cmap) < 4 {
+		return ErrInvalidFont
+	}
+	best := -1
+	for i := 0; i < int(u16(cmap, 2)); i++ {
+		rec := 4 + 8*i
+		platform, encoding := u16(cmap, rec), u16(cmap, rec+2)
+		sub := int(u32(cmap, rec+4))
+		format := u16(cmap, sub)
+		rank := -1
+		switch {
+		case format == 12 && (platform == 0 || platform == 3 && encoding == 10):
+			rank = 2
+		case format == 4 && (platform == 0 || platform == 3 && (encoding == 1 || encoding == 0)):
+			rank = 1
+		}
+		if rank > best && sub < len(cmap) {
+			best = rank
+			f.cmap = cmap[sub:]
+			f.cmapFmt = format
+		}
+	}
+	if best < 0 {
+		return ErrUnsupportedFont
+	}
+	return nil
+}
+
+// GlyphIndex returns the glyph for r, or 0 when the font does not cover it.
+func (f *Font) GlyphIndex(r rune) GlyphID {
+	c := uint32(r)
+	switch f.cmapFmt {
+	case 4:
+		if c > 0xffff {
+			return 0
+		}
+		segCount := int(u16(f.cmap, 6) / 2)
+		ends := 14
+		starts := ends + 2*segCount + 2
+		deltas := starts + 2*segCount
+		ranges := deltas + 2*segCount
+		i := sort.Search(segCount, func(i int) bool { return uint32(u16(f.cmap, ends+2*i)) >= c })
+		if i == segCount || uint32(u16(f.cmap, starts+2*i)) > c {
+			return 0
+		}
+		delta := u16(f.cmap, deltas+2*i)
+		ro := int(u16(f.cmap, ranges+2*i))
+		if ro == 0 {
+			return GlyphID(uint16(c) + delta)
+		}
+		g := u16(f.cmap, ranges+2*i+ro+2*int(c-uint32(u16(f.cmap, starts+2*i))))
+		if g == 0 {
+			return 0
+		}
+		return GlyphID(g + delta)
+	case 12:
+		n := int(u32(f.cmap, 12))
+		i := sort.Search(n, func(i int) bool { return u32(f.cmap, 16+12*i+4) >= c })
+		if i == n {
+			return 0
+		}
+		group := 16 + 12*i
+		start := u32(f.cmap, group)
+		if start > c {
+			return 0
+		}
+		return GlyphID(u32(f.cmap, group+8) + c - start)
+	}
+	return 0
+}
+
+// parseName reads the family name, preferring the typographic family, and
+// the full and PostScript names.
+func (f *Font) parseName() {
+	name := f.tables["name"]
+	if len(name) < 6 {
+		return
+	}
+	count := int(u16(name, 2))
+	strings := int(u16(name, 4))
+	found := map[uint16]string{}
+	for i := 0; i < count; i++ {
+		rec := 6 + 12*i
+		platform, encoding, id := u16(name, rec), u16(name, rec+2), u16(name, rec+6)
+		if id != 1 && id != 4 && id != 6 && id != 16 {
+			continue
+		}
+		raw := slice(name, strings+int(u16(name, rec+10)), int(u16(name, rec+8)))
+		if raw == nil {
+			continue
+		}
+		switch {
+		case platform == 3 && (encoding == 1 || encoding == 10), platform == 0:
+			units := make([]uint16, len(raw)/2)
+			for j := range units {
+				units[j] = u16(raw, 2*j)
+			}
+			found[id] = string(utf16.Decode(units))
+		case platform == 1 && encoding == 0:
+			if _, ok := found[id]; !ok {
+				found[id] = string(raw)
+			}
+		}
+	}
+	f.family = found[1]
+	if typographic := found[16]; typographic != "" {
+		f.family = typographic
+	}
+	for _, id := range []uint16{4, 6} {
+		if found[id] != "" {
+			f.names = append(f.names, found[id])
+		}
+	}
+}
+
+// parseKern reads horizontal format 0 subtables of a version 0 kern table.
+func (f *Font) parseKern() {
+	kern := f.tables["kern"]
+	if len(kern) < 4 || u16(kern, 0) != 0 {
+		return
+	}
+	off := 4
+	for t := 0; t < int(u16(kern, 2)); t++ {
+		length := int(u16(kern, off+2))
+		coverage := u16(kern, off+4)
+		if coverage>>8 == 0 && coverage&1 != 0 && coverage&4 == 0 {
+			if f.kern == nil {
+				f.kern = make(map[uint32]int16)
+			}
+			pairs := int(u16(kern, off+6))
+			for i := 0; i < pairs; i++ {
+				p := off + 14 + 6*i
+				key := u32(kern, p)
+				f.kern[key] += int16(u16(kern, p+4))
+			}
+		}
+		if length == 0 {
+			break
+		}
+		off += length
+	}
+}
+
+// Family returns the font's family name.
+func (f *Font) Family() string { return f.family }
+
+// Weight returns the usWeightClass of the font, 400 for regular.
+func (f *Font) Weight() int { return f.weight }
+
+// Italic reports whether the font is an italic or oblique face.
+func (f *Font) Italic() bool { return f.italic }
+
+// Stretch returns the width of the face as a font-stretch percentage.
+func (f *Font) Stretch() float32 { return f.stretch }
+
+func (f *Font) UnitsPerEm() float32 { return f.unitsPerEm }
+
+func (f *Font) NumGlyphs() int { return f.numGlyphs }
+
+// Advance returns the advance width of g in font units.
+func (f *Font) Advance(g GlyphID) float32 {
+	i := int(g)
+	if i >= f.numHMetrics {
+		i = f.numHMetrics - 1
+	}
+	return float32(u16(f.hmtx, 4*i))
+}
+
+// Kern returns the kerning adjustment between a and b in font units, from
+// the GPOS kern feature or else the legacy kern table.
+func (f *Font) Kern(a, b GlyphID) float32 {
+	if len(f.gposKern) > 0 {
+		// Within a lookup the first subtable that applies wins; the
+		// adjustments of separate lookups add up.
+		var total float32
+		for _, subtables := range f.gposKern {
+			for i := range subtables {
+				if v, ok := subtables[i].lookup(a, b); ok {
+					total += float32(v)
+					break
+				}
+			}
+		}
+		return total
+	}
+	if f.kern != nil {
+		return float32(f.kern[uint32(a)<<16|uint32(b)])
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package text
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+func dejaVuSans(t *testing.T) []byte {
+	t.Helper()
+	data, err := embeddedFS.ReadFile("fonts/DejaVuSans.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// tableOffset returns where the table tag starts in the font file data.
+func tableOffset(t *testing.T, data []byte, tag string) int {
+	t.Helper()
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < n; i++ {
+		rec := data[12+16*i:]
+		if string(rec[:4]) == tag {
+			return int(binary.BigEndian.Uint32(rec[8:]))
+		}
+	}
+	t.Fatalf("no %s table", tag)
+	return 0
+}
+
+func TestParseUnitsPerEm(t *testing.T) {
+	tests := []struct {
+		unitsPerEm uint16
+		ok         bool
+	}{
+		{0, false},
+		{1, false},
+		{15, false},
+		{16, true},
+		{2048, true},
+		{16384, true},
+		{16385, false},
+		{65535, false},
+	}
+	orig := dejaVuSans(t)
+	head := tableOffset(t, orig, "head")
+	for _, tt := range tests {
+		data := append([]byte(nil), orig...)
+		binary.BigEndian.PutUint16(data[head+18:], tt.unitsPerEm)
+		_, err := Parse(data)
+		if (err == nil) != tt.ok {
+			t.Errorf("unitsPerEm %d: err = %v, want ok %v", tt.unitsPerEm, err, tt.ok)
+		}
+	}
+}
+
+// TestGlyphMaskBounded checks that a font whose outlines dwarf its em box
+// cannot make glyph masks grow beyond a few ems.
+func TestGlyphMaskBounded(t *testing.T) {
+	data := dejaVuSans(t)
+	head := tableOffset(t, data, "head")
+	// DejaVu draws at 2048 units per em; claiming 16 scales it up 128 times.
+	binary.BigEndian.PutUint16(data[head+18:], 16)
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []float32{1, 16, 200, 1e6} {
+		m := f.GlyphMask(f.GlyphIndex('W'), size, 0, SyntheticBold|SyntheticOblique)
+		if m == nil {
+			continue
+		}
+		limit := int(min(4*size+4, maxGlyphExtent)) + int(size/24) + 2
+		if b := m.Rect; b.Dx() > 2*limit || b.Dy() > 2*limit {
+			t.Errorf("size %g: mask %v exceeds %d pixels from the pen", size, b, limit)
+		}
+	}
+}
+
+// TestParseMutated parses copies of a font with bytes changed at random and
+// renders glyphs from those that still parse, which must neither panic nor
+// run away.
+func TestParseMutated(t *testing.T) {
+	orig := dejaVuSans(t)
+	rng := rand.New(rand.NewSource(1))
+	iterations := 200
+	if testing.Short() {
+		iterations = 20
+	}
+	for i := 0; i < iterations; i++ {
+		data := append([]byte(nil), orig...)
+		for j := 0; j < 8; j++ {
+			// Most of the structure is in the first tables and the directory.
+			off := rng.Intn(len(data))
+			if j < 4 {
+				off = rng.Intn(min(len(data), 4096))
+			}
+			data[off] = byte(rng.Intn(256))
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("iteration %d: panic %v", i, r)
+				}
+			}()
+			f, err := Parse(data)
+			if err != nil {
+				return
+			}
+			for _, r := range "AWg@é" {
+				f.GlyphMask(f.GlyphIndex(r), 24, 0, 0)
+			}
+		}()
+	}
+}
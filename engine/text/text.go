@@ -0,0 +1,167 @@
+// Package text loads fonts and measures and shapes runs of text with them.
+package text
+
+import "fmt"
+
+// FontSpec describes the font a run of text is set in.
+type FontSpec struct {
+	// Families lists family names in order of preference; the generic
+	// families serif, sans-serif and monospace are always available.
+	Families []string
+	// Size is the font size in pixels per em.
+	Size float32
+	// Weight is the CSS weight from 1 to 1000; 0 means 400.
+	Weight int
+	// Stretch is the font-stretch percentage; 0 means 100.
+	Stretch float32
+	Italic  bool
+
+	LetterSpacing float32
+	WordSpacing   float32
+
+	// Faces are the web fonts of the document the text is in; without
+	// them only installed fonts are used.
+	Faces *FontSet
+}
+
+// Metrics are the vertical metrics of a font at a size, in pixels. Descent
+// is positive below the baseline.
+type Metrics struct {
+	Ascent, Descent, LineGap float32
+	XHeight, CapHeight       float32
+}
+
+func (s FontSpec) weight() int {
+	if s.Weight == 0 {
+		return 400
+	}
+	return s.Weight
+}
+
+func (s FontSpec) stretch() float32 {
+	if s.Stretch == 0 {
+		return 100
+	}
+	return s.Stretch
+}
+
+// key identifies the faces a spec selects, which do not depend on size or
+// spacing.
+func (s FontSpec) key() string {
+	return fmt.Sprintf("%q %d %g %t", s.Families, s.weight(), s.stretch(), s.Italic)
+}
+
+// Glyph is one glyph of shaped text.
+type Glyph struct {
+	Font *Font
+	ID   GlyphID
+	Rune rune
+	// Synthesis lists the styles to fake when drawing the glyph.
+	Synthesis Synthesis
+	// X is the pen position of the glyph relative to the start of the run
+	// and Advance the distance to the next glyph, kerning and spacing
+	// included.
+	X, Advance float32
+}
+
+// MetricsAt returns the metrics of f at size pixels per em.
+func (f *Font) MetricsAt(size float32) Metrics {
+	s := size / f.unitsPerEm
+	return Metrics{
+		Ascent:    float32(f.ascent) * s,
+		Descent:   -float32(f.descent) * s,
+		LineGap:   float32(f.lineGap) * s,
+		XHeight:   float32(f.xHeight) * s,
+		CapHeight: float32(f.capHeight) * s,
+	}
+}
+
+// Select returns the primary font of spec: the face chosen from the first
+// available family in its list.
+func Select(spec FontSpec) *Font {
+	db.Lock()
+	defer db.Unlock()
+	return db.chain(spec)[0].face.Font
+}
+
+// MetricsOf returns the metrics of the primary font of spec.
+func MetricsOf(spec FontSpec) Metrics {
+	return Select(spec).MetricsAt(spec.Size)
+}
+
+// ShapeRunes maps runes to glyphs and positions them with kerning, letter
+// spacing and word spacing. Every character is set in the first face of
+// the family list that has a glyph for it, or else in any installed font
+// that has. Characters that have no visible form, such as line breaks and
+// zero width spaces, get no advance.
+func ShapeRunes(runes []rune, spec FontSpec) []Glyph {
+	db.Lock()
+	chain := db.chain(spec)
+	matches := make([]match, len(runes))
+	for i, r := range runes {
+		if isInvisible(r) {
+			matches[i] = chain[0]
+		} else {
+			matches[i] = db.forRune(chain, r, spec)
+		}
+	}
+	db.Unlock()
+
+	glyphs := make([]Glyph, len(runes))
+	x := float32(0)
+	for i, r := range runes {
+		font := matches[i].face.Font
+		scale := spec.Size / font.unitsPerEm
+		g := Glyph{Font: font, Rune: r, Synthesis: matches[i].synth, X: x}
+		if !isInvisible(r) {
+			g.ID = font.GlyphIndex(r)
+			g.Advance = font.Advance(g.ID)*scale + spec.LetterSpacing
+			if r == ' ' || r == '\u00a0' {
+				g.Advance += spec.WordSpacing
+			}
+		}
+		if i > 0 && glyphs[i-1].Font == font && glyphs[i-1].ID != 0 && g.ID != 0 {
+			k := font.Kern(glyphs[i-1].ID, g.ID) * scale
+			glyphs[i-1].Advance += k
+			x += k
+			g.X = x
+		}
+		x += g.Advance
+		glyphs[i] = g
+	}
+	return glyphs
+}
+
+// Shape is ShapeRunes for a string.
+func Shape(s string, spec FontSpec) []Glyph {
+	return ShapeRunes([]rune(s), spec)
+}
+
+// Advances returns the advance of every rune in runes, with the kerning
+// between two runes counted in the first one.
+func Advances(runes []rune, spec FontSpec) []float32 {
+	glyphs := ShapeRunes(runes, spec)
+	out := make([]float32, len(glyphs))
+	for i, g := range glyphs {
+		out[i] = g.Advance
+	}
+	return out
+}
+
+// Measure returns the advance width of s set in spec.
+func Measure(s string, spec FontSpec) float32 {
+	if s == "" {
+		return 0
+	}
+	glyphs := Shape(s, spec)
+	last := glyphs[len(glyphs)-1]
+	return last.X + last.Advance
+}
+
+func isInvisible(r rune) bool {
+	switch r {
+	case '\n', '\r', '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return true
+	}
+	return false
+}
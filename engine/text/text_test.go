@@ -0,0 +1,109 @@
+package text
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float32) bool { return math.Abs(float64(a-b)) < 0.01 }
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		spec   FontSpec
+		family string
+		weight int
+	}{
+		{FontSpec{}, "DejaVu Sans", 400},
+		{FontSpec{Families: []string{"Nope", "serif"}}, "DejaVu Serif", 400},
+		{FontSpec{Families: []string{"ui-monospace", "serif"}}, "DejaVu Sans Mono", 400},
+		{FontSpec{Families: []string{"monospace"}, Weight: 700}, "DejaVu Sans Mono", 700},
+		{FontSpec{Weight: 500}, "DejaVu Sans", 400},
+		{FontSpec{Weight: 600}, "DejaVu Sans", 700},
+	}
+	for _, tt := range tests {
+		f := Select(tt.spec)
+		if f.Family() != tt.family || f.Weight() != tt.weight {
+			t.Errorf("Select(%+v) = %s %d, want %s %d", tt.spec, f.Family(), f.Weight(), tt.family, tt.weight)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := MetricsOf(FontSpec{Size: 16})
+	// DejaVu Sans: ascent 1901, descent 483, x-height 1024 and cap height
+	// 1433 units of 2048.
+	want := Metrics{Ascent: 14.852, Descent: 3.773, XHeight: 8, CapHeight: 11.195}
+	if !near(m.Ascent, want.Ascent) || !near(m.Descent, want.Descent) || !near(m.XHeight, want.XHeight) || !near(m.CapHeight, want.CapHeight) {
+		t.Errorf("metrics at 16px = %+v, want %+v", m, want)
+	}
+	if m2 := MetricsOf(FontSpec{Size: 32}); !near(m2.Ascent, 2*m.Ascent) || !near(m2.Descent, 2*m.Descent) {
+		t.Errorf("metrics do not scale with size: %+v", m2)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	mono := FontSpec{Families: []string{"monospace"}, Size: 20}
+	// DejaVu Sans Mono advances are 1233 units of 2048.
+	if got, want := Measure("iiii", mono), 4*20*float32(1233)/2048; !near(got, want) {
+		t.Errorf("monospace width %v, want %v", got, want)
+	}
+	if Measure("iiii", mono) != Measure("WWWW", mono) {
+		t.Error("monospace advances differ")
+	}
+	sans := FontSpec{Size: 16}
+	if Measure("", sans) != 0 {
+		t.Error("empty string has a width")
+	}
+	if Measure("iiii", sans) >= Measure("WWWW", sans) {
+		t.Error("proportional advances are equal")
+	}
+	if bold := (FontSpec{Size: 16, Weight: 700}); Measure("Hello", bold) <= Measure("Hello", sans) {
+		t.Error("bold text is not wider")
+	}
+	if got := Measure("Hello", FontSpec{Size: 32}); !near(got, 2*Measure("Hello", sans)) {
+		t.Errorf("width does not scale with size: %v", got)
+	}
+
+	spaced := sans
+	spaced.LetterSpacing, spaced.WordSpacing = 1, 3
+	if got, want := Measure("a b c", spaced), Measure("a b c", sans)+5+2*3; !near(got, want) {
+		t.Errorf("spaced width %v, want %v", got, want)
+	}
+	if got := Advances([]rune("a\nb\u200b"), sans); got[1] != 0 || got[3] != 0 {
+		t.Errorf("invisible characters advance by %v", got)
+	}
+}
+
+func TestKerning(t *testing.T) {
+	sans := FontSpec{Size: 100}
+	apart := Measure("A", sans) + Measure("V", sans)
+	if got := Measure("AV", sans); got >= apart {
+		t.Errorf("AV is %v wide, no narrower than A and V apart (%v)", got, apart)
+	}
+	// The kerning between two runes is counted in the first.
+	glyphs := Shape("AV", sans)
+	if got := glyphs[0].Advance + glyphs[1].Advance; !near(got, Measure("AV", sans)) || glyphs[1].X != glyphs[0].Advance {
+		t.Errorf("glyphs %+v do not add up", glyphs)
+	}
+}
+
+func TestGlyphIndex(t *testing.T) {
+	f := Select(FontSpec{})
+	if f.GlyphIndex('A') == 0 || f.GlyphIndex('é') == 0 {
+		t.Error("DejaVu Sans lacks A or é")
+	}
+	if f.GlyphIndex('\ue000') != 0 {
+		t.Error("private use character has a glyph")
+	}
+	if f.UnitsPerEm() != 2048 || f.NumGlyphs() < 1000 {
+		t.Errorf("units per em %v, %d glyphs", f.UnitsPerEm(), f.NumGlyphs())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("OTTO"), make([]byte, 12), []byte("wOFF0000000000000000")} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("Parse(%q) succeeded", data)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package text
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+)
+
+// ParseFontData parses a font file: an sfnt font or collection, or a WOFF
+// file. WOFF2 needs Brotli, which the standard library lacks, and is
+// reported as unsupported.
+func ParseFontData(data []byte) (*Font, error) {
+	if len(data) >= 4 {
+		switch string(data[:4]) {
+		case "wOFF":
+			sfnt, err := DecodeWOFF(data)
+			if err != nil {
+				return nil, err
+			}
+			return Parse(sfnt)
+		case "wOF2":
+			return nil, ErrUnsupportedFont
+		}
+	}
+	return Parse(data)
+}
+
+// maxFontSize bounds the size of a decoded font. The largest real fonts,
+// for CJK scripts, take a few tens of megabytes.
+const maxFontSize = 64 << 20
+
+// maxDeflateRatio is the most zlib can expand data by.
+const maxDeflateRatio = 1032
+
+// DecodeWOFF unpacks a WOFF 1.0 file into the sfnt font it wraps. Sizes are
+// checked before anything is allocated, so that a small file cannot claim
+// an enormous font.
+func DecodeWOFF(data []byte) ([]byte, error) {
+	if len(data) < 44 || string(data[:4]) != "wOFF" {
+		return nil, ErrInvalidFont
+	}
+	flavor := u32(data, 4)
+	n := int(u16(data, 12))
+	if 44+20*n > len(data) {
+		return nil, ErrInvalidFont
+	}
+
+	type entry struct {
+		tag                  []byte
+		checksum             uint32
+		data                 []byte
+		compLength, origSize int
+	}
+	entries := make([]entry, n)
+	size := 12 + 16*n
+	for i := range entries {
+		rec := data[44+20*i:]
+		off, compLength, origSize := int(u32(rec, 4)), int(u32(rec, 8)), int(u32(rec, 12))
+		e := entry{tag: rec[:4], checksum: u32(rec, 16), compLength: compLength, origSize: origSize}
+		if e.data = slice(data, off, compLength); e.data == nil || compLength > origSize ||
+			origSize > maxFontSize || origSize > compLength*maxDeflateRatio+64 {
+			return nil, ErrInvalidFont
+		}
+		entries[i] = e
+		size += (origSize + 3) &^ 3
+		if size > maxFontSize {
+			return nil, ErrInvalidFont
+		}
+	}
+	if int(u32(data, 16)) != size {
+		// totalSfntSize must match what the tables add up to.
+		return nil, ErrInvalidFont
+	}
+
+	out := make([]byte, 12+16*n, size)
+	binary.BigEndian.PutUint32(out, flavor)
+	binary.BigEndian.PutUint16(out[4:], uint16(n))
+	// searchRange, entrySelector and rangeShift are not used by Parse.
+	for i, e := range entries {
+		table := e.data
+		if e.compLength < e.origSize {
+			r, err := zlib.NewReader(bytes.NewReader(e.data))
+			if err != nil {
+				return nil, ErrInvalidFont
+			}
+			table, err = io.ReadAll(io.LimitReader(r, int64(e.origSize)+1))
+			if err != nil || len(table) != e.origSize {
+				return nil, ErrInvalidFont
+			}
+		}
+		rec := out[12+16*i:]
+		copy(rec, e.tag)
+		binary.BigEndian.PutUint32(rec[4:], e.checksum)
+		binary.BigEndian.PutUint32(rec[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(table)))
+		out = append(out, table...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out, nil
+}
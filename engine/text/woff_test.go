@@ -0,0 +1,153 @@
+package text
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"testing"
+)
+
+type woffTable struct {
+	tag      string
+	checksum uint32
+	data     []byte
+}
+
+// sfntTables returns the tables of an sfnt font in directory order.
+func sfntTables(t *testing.T, data []byte) []woffTable {
+	t.Helper()
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	tables := make([]woffTable, n)
+	for i := range tables {
+		rec := data[12+16*i:]
+		off, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		tables[i] = woffTable{string(rec[:4]), binary.BigEndian.Uint32(rec[4:]), data[off : off+length]}
+	}
+	return tables
+}
+
+// encodeWOFF wraps tables in a WOFF 1.0 file, compressing those that shrink.
+func encodeWOFF(tables []woffTable) []byte {
+	n := len(tables)
+	out := make([]byte, 44+20*n)
+	copy(out, "wOFF")
+	binary.BigEndian.PutUint32(out[4:], 0x00010000)
+	binary.BigEndian.PutUint16(out[12:], uint16(n))
+	total := 12 + 16*n
+	for i, tb := range tables {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		w.Write(tb.data)
+		w.Close()
+		stored := tb.data
+		if z.Len() < len(tb.data) {
+			stored = z.Bytes()
+		}
+		rec := out[44+20*i:]
+		copy(rec, tb.tag)
+		binary.BigEndian.PutUint32(rec[4:], uint32(len(out)))
+		binary.BigEndian.PutUint32(rec[8:], uint32(len(stored)))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(tb.data)))
+		binary.BigEndian.PutUint32(rec[16:], tb.checksum)
+		out = append(out, stored...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+		total += (len(tb.data) + 3) &^ 3
+	}
+	binary.BigEndian.PutUint32(out[8:], uint32(len(out)))
+	binary.BigEndian.PutUint32(out[16:], uint32(total))
+	return out
+}
+
+func TestDecodeWOFF(t *testing.T) {
+	orig := dejaVuSans(t)
+	tables := sfntTables(t, orig)
+	sfnt, err := DecodeWOFF(encodeWOFF(tables))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := sfntTables(t, sfnt)
+	if len(got) != len(tables) {
+		t.Fatalf("decoded %d tables, want %d", len(got), len(tables))
+	}
+	for i := range got {
+		if got[i].tag != tables[i].tag || !bytes.Equal(got[i].data, tables[i].data) {
+			t.Errorf("table %s does not round-trip", tables[i].tag)
+		}
+	}
+	f, err := ParseFontData(encodeWOFF(tables))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Family() != "DejaVu Sans" {
+		t.Errorf("family %q", f.Family())
+	}
+}
+
+func TestDecodeWOFFMalformed(t *testing.T) {
+	valid := encodeWOFF([]woffTable{{tag: "test", data: bytes.Repeat([]byte("abcd"), 64)}})
+	tests := []struct {
+		name  string
+		patch func(b []byte) []byte
+	}{
+		{"truncated header", func(b []byte) []byte { return b[:40] }},
+		{"bad signature", func(b []byte) []byte { copy(b, "wOFX"); return b }},
+		{"too many tables", func(b []byte) []byte { binary.BigEndian.PutUint16(b[12:], 500); return b }},
+		{"table past end", func(b []byte) []byte { binary.BigEndian.PutUint32(b[44+4:], 1<<20); return b }},
+		{"huge original size", func(b []byte) []byte {
+			binary.BigEndian.PutUint32(b[44+12:], 0xffffffff)
+			binary.BigEndian.PutUint32(b[16:], 0xffffffff)
+			return b
+		}},
+		{"original size past ratio", func(b []byte) []byte {
+			binary.BigEndian.PutUint32(b[44+12:], 16<<20)
+			binary.BigEndian.PutUint32(b[16:], 12+16+16<<20)
+			return b
+		}},
+		{"total size mismatch", func(b []byte) []byte {
+			binary.BigEndian.PutUint32(b[16:], binary.BigEndian.Uint32(b[16:])+4)
+			return b
+		}},
+		{"compressed longer than original", func(b []byte) []byte {
+			binary.BigEndian.PutUint32(b[44+12:], binary.BigEndian.Uint32(b[44+8:])-1)
+			return b
+		}},
+	}
+	if _, err := DecodeWOFF(valid); err != nil {
+		t.Fatalf("valid file: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeWOFF(tt.patch(append([]byte(nil), valid...))); err == nil {
+				t.Error("decoded without error")
+			}
+		})
+	}
+}
+
+// TestDecodeWOFFClaimsHugeTables is the 108-byte file that claimed tables of
+// 4 GB each: it must be rejected before anything is allocated for them.
+func TestDecodeWOFFClaimsHugeTables(t *testing.T) {
+	b := make([]byte, 44+20*3+4)
+	copy(b, "wOFF")
+	binary.BigEndian.PutUint16(b[12:], 3)
+	total := 12 + 16*3
+	for i := 0; i < 3; i++ {
+		rec := b[44+20*i:]
+		copy(rec, "big ")
+		binary.BigEndian.PutUint32(rec[4:], uint32(len(b)-4))
+		binary.BigEndian.PutUint32(rec[8:], 4)
+		binary.BigEndian.PutUint32(rec[12:], 0xfffffffc)
+		total += 0xfffffffc
+	}
+	binary.BigEndian.PutUint32(b[16:], uint32(total))
+	allocs := testing.AllocsPerRun(1, func() {
+		if _, err := DecodeWOFF(b); err == nil {
+			t.Error("decoded without error")
+		}
+	})
+	if allocs > 4 {
+		t.Errorf("%v allocations rejecting the file", allocs)
+	}
+}